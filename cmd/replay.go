@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/wozozo/s3pit/pkg/recorder"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Replay recorded S3 traffic against a server",
+	Long: `Replay the requests recorded by "s3pit serve --record-file" against a server
+and compare each response with the recorded one. Status codes, headers and
+bodies are compared; timestamps, request IDs and other values that change
+between runs are ignored. Requests that cannot be sent again, such as ones
+addressing the upload or version IDs of the recording or presigned URLs that
+have expired, are skipped. Exits with an error when any response differs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().String("endpoint", "http://localhost:3333", "Server to replay the requests against")
+	replayCmd.Flags().StringSlice("ignore-header", nil, "Response header to leave out of the comparison (repeatable)")
+	replayCmd.Flags().Bool("fail-fast", false, "Stop at the first response that differs")
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	endpoint, _ := cmd.Flags().GetString("endpoint")
+	ignoreHeaders, _ := cmd.Flags().GetStringSlice("ignore-header")
+	failFast, _ := cmd.Flags().GetBool("fail-fast")
+
+	exchanges, err := recorder.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Redirects are part of the recorded responses, so they are not followed
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Replaying %d request(s) from %s against %s\n", len(exchanges), args[0], endpoint)
+
+	failed, skipped := 0, 0
+	for i := range exchanges {
+		ex := &exchanges[i]
+		label := fmt.Sprintf("#%d %s %s", i+1, ex.Request.Method, ex.Request.URI)
+
+		if reason := recorder.Unreplayable(ex); reason != "" {
+			skipped++
+			fmt.Fprintf(out, "⏭️  %s: skipped, %s\n", label, reason)
+			continue
+		}
+
+		var diffs []string
+		resp, err := recorder.Replay(client, endpoint, ex)
+		if err != nil {
+			diffs = []string{err.Error()}
+		} else {
+			diffs = recorder.Diff(&ex.Response, resp, ignoreHeaders...)
+		}
+
+		if len(diffs) == 0 {
+			fmt.Fprintf(out, "✅ %s\n", label)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "❌ %s\n", label)
+		for _, diff := range diffs {
+			fmt.Fprintf(out, "    %s\n", diff)
+		}
+		if failFast {
+			break
+		}
+	}
+
+	replayed := len(exchanges) - skipped
+	if failed > 0 {
+		return fmt.Errorf("%d of %d response(s) differ from the recording", failed, replayed)
+	}
+	fmt.Fprintf(out, "All %d response(s) match the recording", replayed)
+	if skipped > 0 {
+		fmt.Fprintf(out, ", %d request(s) skipped", skipped)
+	}
+	fmt.Fprintln(out)
+	return nil
+}
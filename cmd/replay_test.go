@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wozozo/s3pit/pkg/recorder"
+)
+
+func TestRunReplay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bucket/gone.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	recording := filepath.Join(t.TempDir(), "session.jsonl")
+	rec, err := recorder.NewRecorder(recording)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	for _, ex := range []recorder.Exchange{
+		{Request: recorder.Request{Method: "GET", URI: "/bucket/file.txt"}, Response: recorder.Response{Status: 200, Body: []byte("content")}},
+		{Request: recorder.Request{Method: "GET", URI: "/bucket/gone.txt"}, Response: recorder.Response{Status: 200}},
+		{Request: recorder.Request{Method: "PUT", URI: "/bucket/large.bin", Truncated: true}, Response: recorder.Response{Status: 200}},
+	} {
+		if err := rec.Record(&ex); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	rec.Close()
+
+	var out bytes.Buffer
+	replayCmd.SetOut(&out)
+	defer replayCmd.SetOut(nil)
+	if err := replayCmd.Flags().Set("endpoint", ts.URL); err != nil {
+		t.Fatalf("Failed to set endpoint: %v", err)
+	}
+	defer replayCmd.Flags().Set("endpoint", "http://localhost:3333")
+
+	err = runReplay(replayCmd, []string{recording})
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 response(s) differ") {
+		t.Errorf("Expected one differing response, got %v", err)
+	}
+	if !strings.Contains(out.String(), "✅ #1 GET /bucket/file.txt") {
+		t.Errorf("Expected the first response to match:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "status: expected 200, got 404") {
+		t.Errorf("Expected the status difference to be reported:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "#3 PUT /bucket/large.bin: skipped, request body was too large to record in full") {
+		t.Errorf("Expected the truncated request to be skipped:\n%s", out.String())
+	}
+}
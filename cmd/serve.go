@@ -50,7 +50,15 @@ func init() {
 	serveCmd.Flags().String("log-level", "info", "Log level: debug|info|warn|error")
 	serveCmd.Flags().String("log-dir", "", "Directory for log files (empty = console only)")
 	serveCmd.Flags().Bool("no-dashboard", false, "Disable web dashboard")
+	serveCmd.Flags().Bool("admin", false, "Enable the unauthenticated /_s3pit/ admin endpoints (lifecycle clock, fault rules)")
+	serveCmd.Flags().Bool("metrics", false, "Serve Prometheus metrics at /_s3pit/metrics, without authentication")
 	serveCmd.Flags().Int64("max-object-size", 5368709120, "Maximum object size in bytes")
+	serveCmd.Flags().Int("multipart-expiry-hours", 0, "Abort incomplete multipart uploads after this many hours (0 = never)")
+	serveCmd.Flags().Bool("permissive-cors", false, "Allow cross-origin requests from any origin instead of evaluating bucket CORS rules")
+	serveCmd.Flags().String("domain", "", "Base domain for virtual-hosted-style requests, e.g. s3pit.localhost (empty = path-style only)")
+	serveCmd.Flags().String("record-file", "", "Record every request and response to this JSON Lines file for s3pit replay")
+	serveCmd.Flags().String("otlp-endpoint", "", "Export trace spans to this OTLP/HTTP collector, e.g. http://localhost:4318")
+	serveCmd.Flags().String("trace-file", "", "Append trace spans to this file as OTLP JSON")
 
 	// Delay configuration flags
 	serveCmd.Flags().Int("read-delay-ms", 0, "Fixed delay for read operations in milliseconds")
@@ -59,6 +67,10 @@ func init() {
 	serveCmd.Flags().Int("write-delay-ms", 0, "Fixed delay for write operations in milliseconds")
 	serveCmd.Flags().Int("write-delay-random-min", 0, "Minimum random delay for write operations in milliseconds")
 	serveCmd.Flags().Int("write-delay-random-max", 0, "Maximum random delay for write operations in milliseconds")
+
+	// Bandwidth configuration flags
+	serveCmd.Flags().Int64("upload-bytes-per-second", 0, "Bandwidth limit for request bodies in bytes per second (0 = unlimited)")
+	serveCmd.Flags().Int64("download-bytes-per-second", 0, "Bandwidth limit for response bodies in bytes per second (0 = unlimited)")
 }
 
 // formatMainConfig formats the main configuration for display
@@ -85,6 +97,11 @@ func formatMainConfig(cfg *config.Config) string {
 	}
 	parts = append(parts, fmt.Sprintf("  %sAuto Create Buckets:%s %s%v%s", ColorBlue, ColorReset, ColorWhite, cfg.AutoCreateBucket, ColorReset))
 	parts = append(parts, fmt.Sprintf("  %sMax Object Size:%s %s%d bytes%s", ColorBlue, ColorReset, ColorWhite, cfg.MaxObjectSize, ColorReset))
+	if cfg.MultipartExpiryHours > 0 {
+		parts = append(parts, fmt.Sprintf("  %sMultipart Expiry:%s %s%d hours%s", ColorBlue, ColorReset, ColorWhite, cfg.MultipartExpiryHours, ColorReset))
+	} else {
+		parts = append(parts, fmt.Sprintf("  %sMultipart Expiry:%s %sNever%s", ColorBlue, ColorReset, ColorDim, ColorReset))
+	}
 	parts = append(parts, "")
 
 	// Authentication
@@ -102,7 +119,36 @@ func formatMainConfig(cfg *config.Config) string {
 		dashboardStatus = "Enabled"
 	}
 	parts = append(parts, fmt.Sprintf("  %sDashboard:%s %s%s%s", ColorBlue, ColorReset, ColorWhite, dashboardStatus, ColorReset))
+	adminStatus := "Disabled"
+	if cfg.EnableAdmin {
+		adminStatus = "Enabled"
+	}
+	parts = append(parts, fmt.Sprintf("  %sAdmin Endpoints:%s %s%s%s", ColorBlue, ColorReset, ColorWhite, adminStatus, ColorReset))
+	metricsStatus := "Disabled"
+	if cfg.EnableMetrics {
+		metricsStatus = "Enabled"
+	}
+	parts = append(parts, fmt.Sprintf("  %sMetrics:%s %s%s%s", ColorBlue, ColorReset, ColorWhite, metricsStatus, ColorReset))
 	parts = append(parts, fmt.Sprintf("  %sRegion:%s %s%s%s", ColorBlue, ColorReset, ColorWhite, cfg.Region, ColorReset))
+	corsMode := "Bucket rules"
+	if cfg.PermissiveCORS {
+		corsMode = "Permissive"
+	}
+	parts = append(parts, fmt.Sprintf("  %sCORS:%s %s%s%s", ColorBlue, ColorReset, ColorWhite, corsMode, ColorReset))
+	if cfg.Domain != "" {
+		parts = append(parts, fmt.Sprintf("  %sVirtual Hosts:%s %s*.%s%s", ColorBlue, ColorReset, ColorWhite, cfg.Domain, ColorReset))
+	} else {
+		parts = append(parts, fmt.Sprintf("  %sVirtual Hosts:%s %sDisabled%s", ColorBlue, ColorReset, ColorDim, ColorReset))
+	}
+	if cfg.RecordFile != "" {
+		parts = append(parts, fmt.Sprintf("  %sRecording:%s %s%s%s", ColorBlue, ColorReset, ColorYellow, cfg.RecordFile, ColorReset))
+	}
+	if cfg.OTLPEndpoint != "" {
+		parts = append(parts, fmt.Sprintf("  %sTracing:%s %s%s%s", ColorBlue, ColorReset, ColorYellow, cfg.OTLPEndpoint, ColorReset))
+	}
+	if cfg.TraceFile != "" {
+		parts = append(parts, fmt.Sprintf("  %sTrace File:%s %s%s%s", ColorBlue, ColorReset, ColorYellow, cfg.TraceFile, ColorReset))
+	}
 	parts = append(parts, "")
 
 	// Logging
@@ -133,6 +179,8 @@ func formatMainConfig(cfg *config.Config) string {
 	} else {
 		parts = append(parts, fmt.Sprintf("  %sWrite Delay:%s %sNone%s", ColorBlue, ColorReset, ColorDim, ColorReset))
 	}
+	parts = append(parts, fmt.Sprintf("  %sUpload Bandwidth:%s %s", ColorBlue, ColorReset, formatBandwidth(cfg.UploadBytesPerSecond)))
+	parts = append(parts, fmt.Sprintf("  %sDownload Bandwidth:%s %s", ColorBlue, ColorReset, formatBandwidth(cfg.DownloadBytesPerSecond)))
 	parts = append(parts, "")
 
 	// Available Options
@@ -141,6 +189,7 @@ func formatMainConfig(cfg *config.Config) string {
 	parts = append(parts, fmt.Sprintf("  %s--read-delay-random-min/max:%s Random delay range for reads", ColorBlue, ColorReset))
 	parts = append(parts, fmt.Sprintf("  %s--write-delay-ms:%s Fixed delay for write operations (ms)", ColorBlue, ColorReset))
 	parts = append(parts, fmt.Sprintf("  %s--write-delay-random-min/max:%s Random delay range for writes", ColorBlue, ColorReset))
+	parts = append(parts, fmt.Sprintf("  %s--upload/download-bytes-per-second:%s Bandwidth limits for bodies", ColorBlue, ColorReset))
 	parts = append(parts, fmt.Sprintf("  %s--port:%s Server port (default: 3333)", ColorBlue, ColorReset))
 	parts = append(parts, fmt.Sprintf("  %s--host:%s Server host (default: 0.0.0.0)", ColorBlue, ColorReset))
 	parts = append(parts, fmt.Sprintf("  %s--global-dir:%s Storage directory", ColorBlue, ColorReset))
@@ -151,6 +200,14 @@ func formatMainConfig(cfg *config.Config) string {
 	return strings.Join(parts, "\n")
 }
 
+// formatBandwidth formats a bandwidth limit for display
+func formatBandwidth(bytesPerSecond int64) string {
+	if bytesPerSecond <= 0 {
+		return fmt.Sprintf("%sUnlimited%s", ColorDim, ColorReset)
+	}
+	return fmt.Sprintf("%s%d B/s%s", ColorYellow, bytesPerSecond, ColorReset)
+}
+
 // formatTenantsConfig formats the tenants configuration for display
 func formatTenantsConfig(config *tenant.Config) string {
 	var parts []string
@@ -162,6 +219,9 @@ func formatTenantsConfig(config *tenant.Config) string {
 	// Global settings
 	parts = append(parts, fmt.Sprintf("%sGlobal Directory:%s %s%s%s", ColorBlue, ColorReset, ColorYellow, config.GlobalDir, ColorReset))
 	parts = append(parts, fmt.Sprintf("%sTotal Tenants:%s %s%d%s", ColorBlue, ColorReset, ColorWhite, len(config.Tenants), ColorReset))
+	if len(config.Faults) > 0 {
+		parts = append(parts, fmt.Sprintf("%sFault Rules:%s %s%d%s", ColorBlue, ColorReset, ColorYellow, len(config.Faults), ColorReset))
+	}
 	parts = append(parts, "")
 
 	// Tenant details
@@ -179,6 +239,15 @@ func formatTenantsConfig(config *tenant.Config) string {
 		if len(tenant.PublicBuckets) > 0 {
 			parts = append(parts, fmt.Sprintf("  %sPublic Buckets:%s %s%s%s", ColorBlue, ColorReset, ColorCyan, strings.Join(tenant.PublicBuckets, ", "), ColorReset))
 		}
+		if tenant.UploadBytesPerSecond > 0 {
+			parts = append(parts, fmt.Sprintf("  %sUpload Bandwidth:%s %s", ColorBlue, ColorReset, formatBandwidth(tenant.UploadBytesPerSecond)))
+		}
+		if tenant.DownloadBytesPerSecond > 0 {
+			parts = append(parts, fmt.Sprintf("  %sDownload Bandwidth:%s %s", ColorBlue, ColorReset, formatBandwidth(tenant.DownloadBytesPerSecond)))
+		}
+		if len(tenant.BucketBandwidth) > 0 {
+			parts = append(parts, fmt.Sprintf("  %sBucket Bandwidth Limits:%s %s%d%s", ColorBlue, ColorReset, ColorYellow, len(tenant.BucketBandwidth), ColorReset))
+		}
 
 		if i < len(config.Tenants)-1 {
 			parts = append(parts, "")
@@ -277,10 +346,42 @@ func runServe(cmd *cobra.Command, args []string) error {
 		serveCfg.EnableDashboard = !noDashboard
 		cmdLineOverrides["no-dashboard"] = true
 	}
+	if admin, _ := cmd.Flags().GetBool("admin"); cmd.Flags().Changed("admin") {
+		serveCfg.EnableAdmin = admin
+		cmdLineOverrides["admin"] = true
+	}
+	if enableMetrics, _ := cmd.Flags().GetBool("metrics"); cmd.Flags().Changed("metrics") {
+		serveCfg.EnableMetrics = enableMetrics
+		cmdLineOverrides["metrics"] = true
+	}
 	if maxObjectSize, _ := cmd.Flags().GetInt64("max-object-size"); cmd.Flags().Changed("max-object-size") {
 		serveCfg.MaxObjectSize = maxObjectSize
 		cmdLineOverrides["max-object-size"] = true
 	}
+	if expiryHours, _ := cmd.Flags().GetInt("multipart-expiry-hours"); cmd.Flags().Changed("multipart-expiry-hours") {
+		serveCfg.MultipartExpiryHours = expiryHours
+		cmdLineOverrides["multipart-expiry-hours"] = true
+	}
+	if permissiveCORS, _ := cmd.Flags().GetBool("permissive-cors"); cmd.Flags().Changed("permissive-cors") {
+		serveCfg.PermissiveCORS = permissiveCORS
+		cmdLineOverrides["permissive-cors"] = true
+	}
+	if domain, _ := cmd.Flags().GetString("domain"); cmd.Flags().Changed("domain") {
+		serveCfg.Domain = domain
+		cmdLineOverrides["domain"] = true
+	}
+	if recordFile, _ := cmd.Flags().GetString("record-file"); cmd.Flags().Changed("record-file") {
+		serveCfg.RecordFile = recordFile
+		cmdLineOverrides["record-file"] = true
+	}
+	if otlpEndpoint, _ := cmd.Flags().GetString("otlp-endpoint"); cmd.Flags().Changed("otlp-endpoint") {
+		serveCfg.OTLPEndpoint = otlpEndpoint
+		cmdLineOverrides["otlp-endpoint"] = true
+	}
+	if traceFile, _ := cmd.Flags().GetString("trace-file"); cmd.Flags().Changed("trace-file") {
+		serveCfg.TraceFile = traceFile
+		cmdLineOverrides["trace-file"] = true
+	}
 
 	// Delay configuration flags
 	if readDelayMs, _ := cmd.Flags().GetInt("read-delay-ms"); cmd.Flags().Changed("read-delay-ms") {
@@ -308,6 +409,16 @@ func runServe(cmd *cobra.Command, args []string) error {
 		cmdLineOverrides["write-delay-random-max"] = true
 	}
 
+	// Bandwidth configuration flags
+	if uploadBps, _ := cmd.Flags().GetInt64("upload-bytes-per-second"); cmd.Flags().Changed("upload-bytes-per-second") {
+		serveCfg.UploadBytesPerSecond = uploadBps
+		cmdLineOverrides["upload-bytes-per-second"] = true
+	}
+	if downloadBps, _ := cmd.Flags().GetInt64("download-bytes-per-second"); cmd.Flags().Changed("download-bytes-per-second") {
+		serveCfg.DownloadBytesPerSecond = downloadBps
+		cmdLineOverrides["download-bytes-per-second"] = true
+	}
+
 	// Initialize config directory and default config.toml if needed
 	if err := setup.InitializeConfigDir(); err != nil {
 		// Log the error but don't fail - it's not critical
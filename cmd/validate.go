@@ -8,6 +8,7 @@ import (
 
 	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
+	"github.com/wozozo/s3pit/pkg/fault"
 	"github.com/wozozo/s3pit/pkg/tenant"
 )
 
@@ -97,6 +98,20 @@ func validateConfigFile(filePath string) error {
 		if tenant.CustomDir != "" && !isValidDirectoryPath(tenant.CustomDir) {
 			return fmt.Errorf("tenant %d: customDir must be an absolute path (starting with /) or home directory path (starting with ~/), got: %s", i, tenant.CustomDir)
 		}
+
+		// Validate bandwidth limits
+		if tenant.UploadBytesPerSecond < 0 || tenant.DownloadBytesPerSecond < 0 {
+			return fmt.Errorf("tenant %d: bandwidth limits must not be negative", i)
+		}
+		for bucket, limits := range tenant.BucketBandwidth {
+			if limits.UploadBytesPerSecond < 0 || limits.DownloadBytesPerSecond < 0 {
+				return fmt.Errorf("tenant %d: bandwidth limits of bucket %s must not be negative", i, bucket)
+			}
+		}
+	}
+
+	if err := fault.ValidateRules(config.Faults); err != nil {
+		return err
 	}
 
 	return nil
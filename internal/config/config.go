@@ -16,6 +16,8 @@ type Config struct {
 	ConfigFile       string
 	InMemory         bool
 	EnableDashboard  bool
+	EnableAdmin      bool // Unauthenticated /_s3pit/ endpoints controlling the clock and fault rules
+	EnableMetrics    bool // Unauthenticated Prometheus endpoint at /_s3pit/metrics
 	AutoCreateBucket bool
 	Region           string
 	LogLevel         string
@@ -26,6 +28,32 @@ type Config struct {
 	MaxLogEntries    int
 	MaxObjectSize    int64
 
+	// Hours after which incomplete multipart uploads are aborted (0 = never)
+	MultipartExpiryHours int
+
+	// Answer every request with wildcard CORS headers instead of evaluating
+	// the bucket CORS configurations
+	PermissiveCORS bool
+
+	// Base domain of virtual-hosted-style requests: with "s3pit.localhost",
+	// Host: my-bucket.s3pit.localhost addresses my-bucket (empty = path-style only)
+	Domain string
+
+	// Bandwidth limits of request (upload) and response (download) bodies in
+	// bytes per second (0 = unlimited); tenants and buckets may override them
+	UploadBytesPerSecond   int64
+	DownloadBytesPerSecond int64
+
+	// JSON Lines file every request and response is recorded to, for replay
+	// with "s3pit replay" (empty = no recording)
+	RecordFile string
+
+	// Trace export: an OTLP/HTTP collector endpoint such as
+	// http://localhost:4318 and a file spans are appended to as OTLP JSON
+	// (both empty = tracing disabled)
+	OTLPEndpoint string
+	TraceFile    string
+
 	// Delay configuration for read operations
 	ReadDelayMs        int // Fixed delay in milliseconds (0 = disabled)
 	ReadDelayRandomMin int // Min delay for random mode (milliseconds)
@@ -63,6 +91,8 @@ func LoadFromEnv() *Config {
 		ConfigFile:       getEnvOrDefault("S3PIT_CONFIG_FILE", defaultConfigFile),
 		InMemory:         getEnvAsBoolOrDefault("S3PIT_IN_MEMORY", false),
 		EnableDashboard:  getEnvAsBoolOrDefault("S3PIT_ENABLE_DASHBOARD", true),
+		EnableAdmin:      getEnvAsBoolOrDefault("S3PIT_ENABLE_ADMIN", false),
+		EnableMetrics:    getEnvAsBoolOrDefault("S3PIT_ENABLE_METRICS", false),
 		AutoCreateBucket: getEnvAsBoolOrDefault("S3PIT_AUTO_CREATE_BUCKET", true),
 		Region:           getEnvOrDefault("S3PIT_REGION", "us-east-1"),
 		LogLevel:         getEnvOrDefault("S3PIT_LOG_LEVEL", "info"),
@@ -73,6 +103,19 @@ func LoadFromEnv() *Config {
 		MaxLogEntries:    getEnvAsIntOrDefault("S3PIT_MAX_LOG_ENTRIES", 10000),
 		MaxObjectSize:    getEnvAsInt64OrDefault("S3PIT_MAX_OBJECT_SIZE", 5*1024*1024*1024), // 5GB default
 
+		MultipartExpiryHours: getEnvAsIntOrDefault("S3PIT_MULTIPART_EXPIRY_HOURS", 0),
+		PermissiveCORS:       getEnvAsBoolOrDefault("S3PIT_PERMISSIVE_CORS", false),
+		Domain:               getEnvOrDefault("S3PIT_DOMAIN", ""),
+
+		// Bandwidth configuration
+		UploadBytesPerSecond:   getEnvAsInt64OrDefault("S3PIT_UPLOAD_BYTES_PER_SECOND", 0),
+		DownloadBytesPerSecond: getEnvAsInt64OrDefault("S3PIT_DOWNLOAD_BYTES_PER_SECOND", 0),
+
+		RecordFile: getEnvOrDefault("S3PIT_RECORD_FILE", ""),
+
+		OTLPEndpoint: getEnvOrDefault("S3PIT_OTLP_ENDPOINT", ""),
+		TraceFile:    getEnvOrDefault("S3PIT_TRACE_FILE", ""),
+
 		// Read delay configuration
 		ReadDelayMs:        getEnvAsIntOrDefault("S3PIT_READ_DELAY_MS", 0),
 		ReadDelayRandomMin: getEnvAsIntOrDefault("S3PIT_READ_DELAY_RANDOM_MIN_MS", 0),
@@ -157,6 +200,14 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log level: %s, must be one of: %s", c.LogLevel, strings.Join(validLogLevels, ", "))
 	}
 
+	if c.MultipartExpiryHours < 0 {
+		return fmt.Errorf("invalid multipart expiry: %d hours, must not be negative", c.MultipartExpiryHours)
+	}
+
+	if c.UploadBytesPerSecond < 0 || c.DownloadBytesPerSecond < 0 {
+		return fmt.Errorf("invalid bandwidth limit: bytes per second must not be negative")
+	}
+
 	// Validate global directory if not in-memory
 	if !c.InMemory {
 		absPath, err := filepath.Abs(c.GlobalDir)
@@ -52,6 +52,8 @@ func TestLoadFromEnv(t *testing.T) {
 				assert.Equal(t, "sigv4", cfg.AuthMode)
 				assert.Equal(t, "info", cfg.LogLevel)
 				assert.True(t, cfg.EnableDashboard)
+				assert.False(t, cfg.EnableAdmin)
+				assert.False(t, cfg.EnableMetrics)
 				assert.True(t, cfg.AutoCreateBucket)
 			},
 		},
@@ -64,6 +66,8 @@ func TestLoadFromEnv(t *testing.T) {
 				"S3PIT_AUTH_MODE":          "sigv4",
 				"S3PIT_LOG_LEVEL":          "debug",
 				"S3PIT_ENABLE_DASHBOARD":   "false",
+				"S3PIT_ENABLE_ADMIN":       "true",
+				"S3PIT_ENABLE_METRICS":     "true",
 				"S3PIT_AUTO_CREATE_BUCKET": "false",
 			},
 			checkFn: func(t *testing.T, cfg *Config) {
@@ -73,6 +77,8 @@ func TestLoadFromEnv(t *testing.T) {
 				assert.Equal(t, "sigv4", cfg.AuthMode)
 				assert.Equal(t, "debug", cfg.LogLevel)
 				assert.False(t, cfg.EnableDashboard)
+				assert.True(t, cfg.EnableAdmin)
+				assert.True(t, cfg.EnableMetrics)
 				assert.False(t, cfg.AutoCreateBucket)
 			},
 		},
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// xsiNamespace is the namespace of the xsi:type attribute of a Grantee
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// grantHeaders are the x-amz-grant-* request headers and the permission
+// they grant
+var grantHeaders = []struct {
+	header     string
+	permission string
+}{
+	{"x-amz-grant-full-control", storage.PermissionFullControl},
+	{"x-amz-grant-read", storage.PermissionRead},
+	{"x-amz-grant-read-acp", storage.PermissionReadACP},
+	{"x-amz-grant-write", storage.PermissionWrite},
+	{"x-amz-grant-write-acp", storage.PermissionWriteACP},
+}
+
+// AccessControlPolicy is the request and response body of the ACL operations
+type AccessControlPolicy struct {
+	XMLName           xml.Name `xml:"AccessControlPolicy"`
+	Xmlns             string   `xml:"xmlns,attr,omitempty"`
+	Owner             Owner    `xml:"Owner"`
+	AccessControlList struct {
+		Grants []ACLGrant `xml:"Grant"`
+	} `xml:"AccessControlList"`
+}
+
+type ACLGrant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
+}
+
+// Grantee is written with the xsi:type attribute and read back with either
+// the namespaced attribute or, failing that, the identifier that is set
+type Grantee struct {
+	XmlnsXsi     string `xml:"xmlns:xsi,attr,omitempty"`
+	XsiType      string `xml:"xsi:type,attr,omitempty"`
+	Type         string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr,omitempty"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+}
+
+// newAccessControlPolicy builds the response for an ACL. An empty ACL is
+// reported as the default private ACL.
+func newAccessControlPolicy(acl []storage.Grant) AccessControlPolicy {
+	if len(acl) == 0 {
+		acl, _ = storage.CannedACL("private", defaultOwner.ID)
+	}
+
+	policy := AccessControlPolicy{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Owner: defaultOwner,
+	}
+	for _, grant := range acl {
+		grantee := Grantee{XmlnsXsi: xsiNamespace, XsiType: grant.GranteeType}
+		switch grant.GranteeType {
+		case storage.GranteeGroup:
+			grantee.URI = grant.Grantee
+		case storage.GranteeEmail:
+			grantee.EmailAddress = grant.Grantee
+		default:
+			grantee.ID = grant.Grantee
+			if grant.Grantee == defaultOwner.ID {
+				grantee.DisplayName = defaultOwner.DisplayName
+			}
+		}
+		policy.AccessControlList.Grants = append(policy.AccessControlList.Grants, ACLGrant{
+			Grantee:    grantee,
+			Permission: grant.Permission,
+		})
+	}
+	return policy
+}
+
+// validateGrant checks a grant read from a request. The returned error is
+// nil when the grant is valid.
+func validateGrant(grant storage.Grant) *S3Error {
+	switch grant.Permission {
+	case storage.PermissionRead, storage.PermissionWrite, storage.PermissionReadACP,
+		storage.PermissionWriteACP, storage.PermissionFullControl:
+	default:
+		return &S3Error{
+			Code:    ErrMalformedACLError,
+			Message: "The XML you provided was not well-formed or did not validate against our published schema",
+		}
+	}
+
+	switch grant.GranteeType {
+	case storage.GranteeGroup:
+		switch grant.Grantee {
+		case storage.AllUsersGroup, storage.AuthenticatedUsersGroup, storage.LogDeliveryGroup:
+			return nil
+		}
+		return &S3Error{Code: ErrInvalidArgument, Message: "Invalid group uri"}
+	case storage.GranteeCanonicalUser, storage.GranteeEmail:
+		if grant.Grantee == "" {
+			return &S3Error{Code: ErrInvalidArgument, Message: "Invalid id"}
+		}
+		return nil
+	}
+	return &S3Error{Code: ErrInvalidArgument, Message: "Invalid grantee type: " + grant.GranteeType}
+}
+
+// hasACLHeaders reports whether a request sets a canned ACL or header grants
+func hasACLHeaders(c *gin.Context) bool {
+	if c.GetHeader("x-amz-acl") != "" {
+		return true
+	}
+	for _, grantHeader := range grantHeaders {
+		if c.GetHeader(grantHeader.header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// aclFromHeaders reads the ACL a write request sets through x-amz-acl or the
+// x-amz-grant-* headers. The ACL is nil when the request sets none. It returns
+// false when an error response has already been written.
+func (h *Handler) aclFromHeaders(c *gin.Context) ([]storage.Grant, bool) {
+	var acl []storage.Grant
+	for _, grantHeader := range grantHeaders {
+		value := c.GetHeader(grantHeader.header)
+		if value == "" {
+			continue
+		}
+		for _, grantee := range strings.Split(value, ",") {
+			kind, id, _ := strings.Cut(strings.TrimSpace(grantee), "=")
+			grant := storage.Grant{
+				Grantee:    strings.Trim(strings.TrimSpace(id), `"`),
+				Permission: grantHeader.permission,
+			}
+			switch strings.ToLower(strings.TrimSpace(kind)) {
+			case "id":
+				grant.GranteeType = storage.GranteeCanonicalUser
+			case "uri":
+				grant.GranteeType = storage.GranteeGroup
+			case "emailaddress":
+				grant.GranteeType = storage.GranteeEmail
+			}
+			if s3Err := validateGrant(grant); s3Err != nil {
+				h.sendS3Error(c, *s3Err)
+				return nil, false
+			}
+			acl = append(acl, grant)
+		}
+	}
+
+	canned := c.GetHeader("x-amz-acl")
+	if canned == "" {
+		return acl, true
+	}
+	if acl != nil {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrInvalidRequest,
+			Message: "Specifying both Canned ACLs and Header Grants is not allowed",
+		})
+		return nil, false
+	}
+
+	acl, ok := storage.CannedACL(canned, defaultOwner.ID)
+	if !ok {
+		h.sendS3Error(c, S3Error{Code: ErrInvalidArgument, Message: "Invalid canned ACL: " + canned})
+		return nil, false
+	}
+	return acl, true
+}
+
+// aclFromRequest reads the ACL of a PutBucketAcl or PutObjectAcl request,
+// set either through headers or an AccessControlPolicy body. It returns false
+// when an error response has already been written.
+func (h *Handler) aclFromRequest(c *gin.Context) ([]storage.Grant, bool) {
+	if hasACLHeaders(c) {
+		return h.aclFromHeaders(c)
+	}
+
+	var policy AccessControlPolicy
+	if err := c.ShouldBindXML(&policy); err != nil {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrMalformedACLError,
+			Message: "The XML you provided was not well-formed or did not validate against our published schema",
+		})
+		return nil, false
+	}
+
+	acl := []storage.Grant{}
+	for _, item := range policy.AccessControlList.Grants {
+		grant := storage.Grant{GranteeType: item.Grantee.Type, Permission: item.Permission}
+		switch {
+		case item.Grantee.URI != "":
+			grant.Grantee = item.Grantee.URI
+			if grant.GranteeType == "" {
+				grant.GranteeType = storage.GranteeGroup
+			}
+		case item.Grantee.EmailAddress != "":
+			grant.Grantee = item.Grantee.EmailAddress
+			if grant.GranteeType == "" {
+				grant.GranteeType = storage.GranteeEmail
+			}
+		default:
+			grant.Grantee = item.Grantee.ID
+			if grant.GranteeType == "" {
+				grant.GranteeType = storage.GranteeCanonicalUser
+			}
+		}
+		if s3Err := validateGrant(grant); s3Err != nil {
+			h.sendS3Error(c, *s3Err)
+			return nil, false
+		}
+		acl = append(acl, grant)
+	}
+	return acl, true
+}
+
+func (h *Handler) GetObjectAcl(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	versionId := c.Query("versionId")
+
+	meta, err := h.getStorage(c).GetObjectVersionMetadata(bucket, key, versionId)
+	if err != nil {
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	if meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, newAccessControlPolicy(meta.ACL))
+}
+
+func (h *Handler) PutObjectAcl(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	versionId := c.Query("versionId")
+
+	acl, ok := h.aclFromRequest(c)
+	if !ok {
+		return
+	}
+
+	meta, err := h.getStorage(c).GetObjectVersionMetadata(bucket, key, versionId)
+	if err != nil {
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	if err := h.getStorage(c).PutObjectACL(bucket, key, meta.VersionId, acl); err != nil {
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	if meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetBucketAcl(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	acl, err := h.getStorage(c).GetBucketACL(bucket)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, newAccessControlPolicy(acl))
+}
+
+func (h *Handler) PutBucketAcl(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	acl, ok := h.aclFromRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.getStorage(c).PutBucketACL(bucket, acl); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
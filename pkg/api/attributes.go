@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// Attributes that can be requested with x-amz-object-attributes
+const (
+	attributeETag         = "ETag"
+	attributeChecksum     = "Checksum"
+	attributeObjectParts  = "ObjectParts"
+	attributeStorageClass = "StorageClass"
+	attributeObjectSize   = "ObjectSize"
+)
+
+type GetObjectAttributesResponse struct {
+	XMLName      xml.Name         `xml:"GetObjectAttributesResponse"`
+	Xmlns        string           `xml:"xmlns,attr"`
+	ETag         string           `xml:"ETag,omitempty"`
+	Checksum     *ChecksumInfo    `xml:"Checksum,omitempty"`
+	ObjectParts  *ObjectPartsInfo `xml:"ObjectParts,omitempty"`
+	StorageClass string           `xml:"StorageClass,omitempty"`
+	ObjectSize   *int64           `xml:"ObjectSize,omitempty"`
+}
+
+type ChecksumInfo struct {
+	Checksums
+	ChecksumType string `xml:"ChecksumType,omitempty"`
+}
+
+type ObjectPartsInfo struct {
+	TotalPartsCount      int              `xml:"TotalPartsCount"`
+	PartNumberMarker     int              `xml:"PartNumberMarker"`
+	NextPartNumberMarker int              `xml:"NextPartNumberMarker"`
+	MaxParts             int              `xml:"MaxParts"`
+	IsTruncated          bool             `xml:"IsTruncated"`
+	Parts                []ObjectPartInfo `xml:"Part"`
+}
+
+type ObjectPartInfo struct {
+	PartNumber int   `xml:"PartNumber"`
+	Size       int64 `xml:"Size"`
+	Checksums
+}
+
+func (h *Handler) GetObjectAttributes(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	versionId := c.Query("versionId")
+
+	requested := make(map[string]bool)
+	for _, header := range c.Request.Header.Values("x-amz-object-attributes") {
+		for _, attribute := range strings.Split(header, ",") {
+			requested[strings.TrimSpace(attribute)] = true
+		}
+	}
+	delete(requested, "")
+	if len(requested) == 0 {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrInvalidArgument,
+			Message: "The x-amz-object-attributes header specifying the attributes to be retrieved is either missing or empty",
+		})
+		return
+	}
+	for attribute := range requested {
+		switch attribute {
+		case attributeETag, attributeChecksum, attributeObjectParts, attributeStorageClass, attributeObjectSize:
+		default:
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidArgument,
+				Message: "Invalid attribute name specified: " + attribute,
+			})
+			return
+		}
+	}
+
+	maxParts := 1000
+	if mp := c.GetHeader("x-amz-max-parts"); mp != "" {
+		parsed, err := strconv.Atoi(mp)
+		if err != nil || parsed < 0 {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidArgument,
+				Message: "Argument max-parts must be an integer between 0 and 1000",
+			})
+			return
+		}
+		if parsed < maxParts {
+			maxParts = parsed
+		}
+	}
+	partNumberMarker := 0
+	if marker := c.GetHeader("x-amz-part-number-marker"); marker != "" {
+		parsed, err := strconv.Atoi(marker)
+		if err != nil || parsed < 0 {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidArgument,
+				Message: "Argument part-number-marker must be a non-negative integer",
+			})
+			return
+		}
+		partNumberMarker = parsed
+	}
+
+	meta, err := h.getStorage(c).GetObjectVersionMetadata(bucket, key, versionId)
+	if err != nil {
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	response := GetObjectAttributesResponse{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+	}
+	if requested[attributeETag] {
+		response.ETag = storage.StripETagQuotes(meta.ETag)
+	}
+	if requested[attributeChecksum] && meta.Checksum != "" {
+		response.Checksum = &ChecksumInfo{
+			Checksums:    newChecksums(meta.ChecksumAlgorithm, meta.Checksum),
+			ChecksumType: meta.ChecksumType,
+		}
+	}
+	if requested[attributeObjectParts] && len(meta.Parts) > 0 {
+		response.ObjectParts = objectPartsPage(meta, partNumberMarker, maxParts)
+	}
+	if requested[attributeStorageClass] {
+		response.StorageClass = "STANDARD"
+	}
+	if requested[attributeObjectSize] {
+		size := meta.Size
+		response.ObjectSize = &size
+	}
+
+	if meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+	c.Header("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, response)
+}
+
+// objectPartsPage returns the parts of a multipart object after
+// partNumberMarker, at most maxParts of them
+func objectPartsPage(meta *storage.ObjectMetadata, partNumberMarker, maxParts int) *ObjectPartsInfo {
+	info := &ObjectPartsInfo{
+		TotalPartsCount:  len(meta.Parts),
+		PartNumberMarker: partNumberMarker,
+		MaxParts:         maxParts,
+	}
+
+	for _, part := range meta.Parts {
+		if part.PartNumber <= partNumberMarker {
+			continue
+		}
+		if len(info.Parts) == maxParts {
+			info.IsTruncated = true
+			break
+		}
+		info.Parts = append(info.Parts, ObjectPartInfo{
+			PartNumber: part.PartNumber,
+			Size:       part.Size,
+			Checksums:  newChecksums(meta.ChecksumAlgorithm, part.Checksum),
+		})
+		info.NextPartNumberMarker = part.PartNumber
+	}
+
+	return info
+}
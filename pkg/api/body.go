@@ -0,0 +1,91 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/auth"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// objectBody wraps the request body of PutObject and UploadPart so that the
+// configured maximum object size and the Content-MD5 and x-amz-content-sha256
+// digests and the additional x-amz-checksum-* checksum are enforced while the
+// body is streamed to storage. aws-chunked bodies are decoded, verifying each
+// chunk signature. It returns the body and its decoded size, or false when an
+// error response has already been written.
+func (h *Handler) objectBody(c *gin.Context) (*storage.VerifyingReader, int64, bool) {
+	var reader io.Reader = c.Request.Body
+	var chunked *auth.ChunkedReader
+	size := c.Request.ContentLength
+
+	payloadHash := c.GetHeader("x-amz-content-sha256")
+	if auth.IsStreamingPayload(payloadHash) {
+		decodedLength, err := strconv.ParseInt(c.GetHeader("x-amz-decoded-content-length"), 10, 64)
+		if err != nil || decodedLength < 0 {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrMissingContentLength,
+				Message: "You must provide the x-amz-decoded-content-length header for aws-chunked uploads",
+			})
+			return nil, 0, false
+		}
+		chunked = auth.NewChunkedReader(reader, payloadHash, decodedLength, auth.ChunkSigningContextFromRequest(c.Request))
+		reader = chunked
+		size = decodedLength
+	}
+
+	maxSize := h.config.MaxObjectSize
+	if maxSize > 0 && size > maxSize {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrEntityTooLarge,
+			Message: "Your proposed upload exceeds the maximum allowed object size",
+		})
+		return nil, 0, false
+	}
+
+	body := storage.NewVerifyingReader(reader, maxSize)
+
+	if contentMD5 := c.GetHeader("Content-MD5"); contentMD5 != "" {
+		sum, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(sum) != md5.Size {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidDigest,
+				Message: "The Content-MD5 you specified was invalid",
+			})
+			return nil, 0, false
+		}
+		body.ExpectMD5(sum)
+	}
+
+	// Only a literal payload hash can be verified. UNSIGNED-PAYLOAD and the
+	// STREAMING-* signing modes carry no digest of the whole body.
+	if len(payloadHash) == 64 {
+		if sum, err := hex.DecodeString(payloadHash); err == nil {
+			body.ExpectSHA256(sum)
+		}
+	}
+
+	if !h.expectChecksum(c, body, chunked) {
+		return nil, 0, false
+	}
+
+	return body, size, true
+}
+
+// contentEncoding returns the Content-Encoding to store with an object. The
+// aws-chunked transfer encoding describes the request body only and is dropped.
+func contentEncoding(header string) string {
+	var encodings []string
+	for _, encoding := range strings.Split(header, ",") {
+		encoding = strings.TrimSpace(encoding)
+		if encoding != "" && encoding != "aws-chunked" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return strings.Join(encodings, ",")
+}
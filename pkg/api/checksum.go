@@ -0,0 +1,164 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/auth"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// checksumHeaderPrefix starts the x-amz-checksum-<algorithm> headers
+const checksumHeaderPrefix = "x-amz-checksum-"
+
+// Checksums is the set of checksum elements shared by the multipart and
+// object attribute responses. At most one checksum is set.
+type Checksums struct {
+	ChecksumCRC32     string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C    string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumCRC64NVME string `xml:"ChecksumCRC64NVME,omitempty"`
+	ChecksumSHA1      string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256    string `xml:"ChecksumSHA256,omitempty"`
+}
+
+// newChecksums returns the checksum elements for a checksum value
+func newChecksums(algorithm, value string) Checksums {
+	var checksums Checksums
+	switch algorithm {
+	case storage.ChecksumCRC32:
+		checksums.ChecksumCRC32 = value
+	case storage.ChecksumCRC32C:
+		checksums.ChecksumCRC32C = value
+	case storage.ChecksumCRC64NVME:
+		checksums.ChecksumCRC64NVME = value
+	case storage.ChecksumSHA1:
+		checksums.ChecksumSHA1 = value
+	case storage.ChecksumSHA256:
+		checksums.ChecksumSHA256 = value
+	}
+	return checksums
+}
+
+// checksumAlgorithm normalises an algorithm name from a request header. It
+// returns false for unsupported algorithms.
+func checksumAlgorithm(name string) (string, bool) {
+	algorithm := strings.ToUpper(strings.TrimSpace(name))
+	return algorithm, storage.NewChecksumHash(algorithm) != nil
+}
+
+// setChecksumHeaders reports an additional checksum in the
+// x-amz-checksum-<algorithm> and x-amz-checksum-type response headers
+func setChecksumHeaders(c *gin.Context, algorithm, value, checksumType string) {
+	if algorithm == "" || value == "" {
+		return
+	}
+	c.Header(checksumHeaderPrefix+strings.ToLower(algorithm), value)
+	if checksumType != "" {
+		c.Header("x-amz-checksum-type", checksumType)
+	}
+}
+
+// expectChecksum configures the additional checksum of a PutObject or
+// UploadPart body. The expected value comes from an x-amz-checksum-* header
+// or, for aws-chunked bodies, from the trailer named by x-amz-trailer. With
+// only x-amz-sdk-checksum-algorithm the checksum is computed but not checked.
+// It returns false when an error response has already been written.
+func (h *Handler) expectChecksum(c *gin.Context, body *storage.VerifyingReader, chunked *auth.ChunkedReader) bool {
+	var algorithm, value string
+	for _, candidate := range storage.ChecksumAlgorithms {
+		headerValue := c.GetHeader(checksumHeaderPrefix + strings.ToLower(candidate))
+		if headerValue == "" {
+			continue
+		}
+		if algorithm != "" {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidRequest,
+				Message: "Expecting a single x-amz-checksum- header. Multiple checksum Types are not allowed.",
+			})
+			return false
+		}
+		algorithm, value = candidate, headerValue
+	}
+	if algorithm != "" {
+		body.ExpectChecksum(algorithm, func() string { return value })
+		return true
+	}
+
+	if trailer := strings.ToLower(strings.TrimSpace(c.GetHeader("x-amz-trailer"))); trailer != "" && chunked != nil {
+		algorithm, ok := checksumAlgorithm(strings.TrimPrefix(trailer, checksumHeaderPrefix))
+		if !strings.HasPrefix(trailer, checksumHeaderPrefix) || !ok {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidRequest,
+				Message: "The value specified in the x-amz-trailer header is not supported",
+			})
+			return false
+		}
+		body.ExpectChecksum(algorithm, func() string { return chunked.Trailers().Get(trailer) })
+		return true
+	}
+
+	if sdkAlgorithm := c.GetHeader("x-amz-sdk-checksum-algorithm"); sdkAlgorithm != "" {
+		algorithm, ok := checksumAlgorithm(sdkAlgorithm)
+		if !ok {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidRequest,
+				Message: "Value for x-amz-sdk-checksum-algorithm header is invalid.",
+			})
+			return false
+		}
+		body.ExpectChecksum(algorithm, nil)
+	}
+
+	return true
+}
+
+// multipartChecksumFromRequest reads the x-amz-checksum-algorithm and
+// x-amz-checksum-type headers of CreateMultipartUpload into the upload's
+// metadata. CRC64NVME only supports full object checksums and the SHA
+// algorithms only composite ones. It returns false when an error response has
+// already been written.
+func (h *Handler) multipartChecksumFromRequest(c *gin.Context, metadata *storage.ObjectMetadata) bool {
+	algorithmHeader := c.GetHeader("x-amz-checksum-algorithm")
+	checksumType := strings.ToUpper(c.GetHeader("x-amz-checksum-type"))
+
+	if algorithmHeader == "" {
+		if checksumType != "" {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidRequest,
+				Message: "The x-amz-checksum-type header can only be used with the x-amz-checksum-algorithm header.",
+			})
+			return false
+		}
+		return true
+	}
+
+	algorithm, ok := checksumAlgorithm(algorithmHeader)
+	if !ok {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrInvalidRequest,
+			Message: "Checksum algorithm provided is unsupported. Please try again with any of the valid types: [CRC32, CRC32C, CRC64NVME, SHA1, SHA256]",
+		})
+		return false
+	}
+
+	if checksumType == "" {
+		checksumType = storage.ChecksumTypeComposite
+		if algorithm == storage.ChecksumCRC64NVME {
+			checksumType = storage.ChecksumTypeFullObject
+		}
+	}
+
+	valid := (checksumType == storage.ChecksumTypeFullObject && storage.SupportsFullObjectChecksum(algorithm)) ||
+		(checksumType == storage.ChecksumTypeComposite && algorithm != storage.ChecksumCRC64NVME)
+	if !valid {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrInvalidRequest,
+			Message: "The " + algorithm + " checksum algorithm does not support the " + checksumType + " checksum type.",
+		})
+		return false
+	}
+
+	metadata.ChecksumAlgorithm = algorithm
+	metadata.ChecksumType = checksumType
+	return true
+}
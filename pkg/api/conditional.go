@@ -0,0 +1,200 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// preconditions holds the conditional request headers for a single request.
+// The same evaluation is used for GET/HEAD (If-*) and for the copy source of
+// CopyObject (x-amz-copy-source-if-*).
+type preconditions struct {
+	ifMatch           string
+	ifNoneMatch       string
+	ifModifiedSince   string
+	ifUnmodifiedSince string
+}
+
+// requestPreconditions reads the standard If-* headers
+func requestPreconditions(c *gin.Context) preconditions {
+	return preconditions{
+		ifMatch:           c.GetHeader("If-Match"),
+		ifNoneMatch:       c.GetHeader("If-None-Match"),
+		ifModifiedSince:   c.GetHeader("If-Modified-Since"),
+		ifUnmodifiedSince: c.GetHeader("If-Unmodified-Since"),
+	}
+}
+
+// copySourcePreconditions reads the x-amz-copy-source-if-* headers
+func copySourcePreconditions(c *gin.Context) preconditions {
+	return preconditions{
+		ifMatch:           c.GetHeader("x-amz-copy-source-if-match"),
+		ifNoneMatch:       c.GetHeader("x-amz-copy-source-if-none-match"),
+		ifModifiedSince:   c.GetHeader("x-amz-copy-source-if-modified-since"),
+		ifUnmodifiedSince: c.GetHeader("x-amz-copy-source-if-unmodified-since"),
+	}
+}
+
+// evaluate checks the preconditions against the object metadata and returns
+// http.StatusOK when the request may proceed, http.StatusPreconditionFailed or
+// http.StatusNotModified otherwise.
+//
+// If-Match takes precedence over If-Unmodified-Since and If-None-Match takes
+// precedence over If-Modified-Since, as described in RFC 7232.
+func (p preconditions) evaluate(meta *storage.ObjectMetadata) int {
+	lastModified := meta.LastModified.Truncate(time.Second)
+
+	if p.ifMatch != "" {
+		if !etagListMatches(p.ifMatch, meta.ETag) {
+			return http.StatusPreconditionFailed
+		}
+	} else if p.ifUnmodifiedSince != "" {
+		if t, err := http.ParseTime(p.ifUnmodifiedSince); err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if p.ifNoneMatch != "" {
+		if etagListMatches(p.ifNoneMatch, meta.ETag) {
+			return http.StatusNotModified
+		}
+	} else if p.ifModifiedSince != "" {
+		if t, err := http.ParseTime(p.ifModifiedSince); err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+
+	return http.StatusOK
+}
+
+// etagListMatches reports whether a comma separated If-Match/If-None-Match
+// header value matches the ETag. "*" matches any existing object.
+func etagListMatches(header, etag string) bool {
+	etag = storage.StripETagQuotes(etag)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if storage.StripETagQuotes(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReadPreconditions evaluates If-* headers for GET and HEAD. It returns
+// false when a 304 or 412 response has already been written.
+func (h *Handler) checkReadPreconditions(c *gin.Context, meta *storage.ObjectMetadata) bool {
+	switch requestPreconditions(c).evaluate(meta) {
+	case http.StatusNotModified:
+		c.Header("ETag", meta.ETag)
+		c.Header("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+		c.Status(http.StatusNotModified)
+		return false
+	case http.StatusPreconditionFailed:
+		h.sendPreconditionFailed(c)
+		return false
+	}
+	return true
+}
+
+// checkWritePreconditions evaluates If-Match and If-None-Match for writes
+// (PutObject, CompleteMultipartUpload). Only "*" is accepted for If-None-Match,
+// which makes the write fail when the key already exists. It returns false
+// when an error response has already been written.
+func (h *Handler) checkWritePreconditions(c *gin.Context, bucket, key string) bool {
+	ifMatch := c.GetHeader("If-Match")
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		return true
+	}
+
+	meta, err := h.getStorage(c).GetObjectMetadata(bucket, key)
+	if err != nil && err != storage.ErrObjectNotFound {
+		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	exists := err == nil
+
+	if ifNoneMatch != "" {
+		if strings.TrimSpace(ifNoneMatch) != "*" {
+			h.sendError(c, "NotImplemented", "If-None-Match only supports the * wildcard on writes", http.StatusNotImplemented)
+			return false
+		}
+		if exists {
+			h.sendPreconditionFailed(c)
+			return false
+		}
+	}
+
+	if ifMatch != "" {
+		if !exists {
+			h.sendError(c, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+			return false
+		}
+		if !etagListMatches(ifMatch, meta.ETag) {
+			h.sendPreconditionFailed(c)
+			return false
+		}
+	}
+
+	return true
+}
+
+// sendPreconditionFailed writes a PreconditionFailed error response
+func (h *Handler) sendPreconditionFailed(c *gin.Context) {
+	h.sendS3Error(c, S3Error{
+		Code:    ErrPreconditionFailed,
+		Message: "At least one of the pre-conditions you specified did not hold",
+	})
+}
+
+// keyLocks serializes conditional writes to the same object so that the
+// existence/ETag check and the write happen atomically with respect to
+// other conditional writers. Locks are reference counted and dropped once no
+// writer holds or waits for them.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock // tenant, bucket and key -> lock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock acquires the lock for a tenant's bucket/key and returns its unlock
+// function
+func (k *keyLocks) lock(tenant, bucket, key string) func() {
+	name := tenant + "\x00" + bucket + "/" + key
+
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	l, ok := k.locks[name]
+	if !ok {
+		l = &keyLock{}
+		k.locks[name] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, name)
+		}
+		k.mu.Unlock()
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// maxCORSRules is the largest number of rules a CORS configuration may have
+const maxCORSRules = 100
+
+// corsMethods are the methods a CORS rule may allow
+var corsMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPut:    true,
+	http.MethodPost:   true,
+	http.MethodDelete: true,
+	http.MethodHead:   true,
+}
+
+// CORSConfiguration is the request and response body of the bucket CORS
+// operations
+type CORSConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration"`
+	Xmlns   string     `xml:"xmlns,attr,omitempty"`
+	Rules   []CORSRule `xml:"CORSRule"`
+}
+
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedHeaders []string `xml:"AllowedHeader"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	ExposeHeaders  []string `xml:"ExposeHeader"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// validateCORSRules checks a CORS configuration the way S3 does and converts
+// it to storage rules. The returned error is nil when the rules are valid.
+func validateCORSRules(rules []CORSRule) ([]storage.CORSRule, *S3Error) {
+	if len(rules) == 0 || len(rules) > maxCORSRules {
+		return nil, &S3Error{Code: ErrMalformedXML, Message: "The XML you provided was not well-formed or did not validate against our published schema"}
+	}
+
+	converted := make([]storage.CORSRule, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return nil, &S3Error{Code: ErrMalformedXML, Message: "The XML you provided was not well-formed or did not validate against our published schema"}
+		}
+		for _, method := range rule.AllowedMethods {
+			if !corsMethods[method] {
+				return nil, &S3Error{
+					Code:    ErrInvalidRequest,
+					Message: "Found unsupported HTTP method in CORS config. Unsupported method is " + method,
+				}
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return nil, &S3Error{
+					Code:    ErrInvalidRequest,
+					Message: fmt.Sprintf("AllowedOrigin \"%s\" can not have more than one wildcard.", origin),
+				}
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return nil, &S3Error{
+					Code:    ErrInvalidRequest,
+					Message: fmt.Sprintf("AllowedHeader \"%s\" can not have more than one wildcard.", header),
+				}
+			}
+		}
+
+		converted = append(converted, storage.CORSRule{
+			ID:             rule.ID,
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposeHeaders,
+			MaxAgeSeconds:  rule.MaxAgeSeconds,
+		})
+	}
+
+	return converted, nil
+}
+
+func (h *Handler) PutBucketCors(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	var config CORSConfiguration
+	if err := c.ShouldBindXML(&config); err != nil {
+		h.sendError(c, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
+		return
+	}
+
+	rules, s3Err := validateCORSRules(config.Rules)
+	if s3Err != nil {
+		h.sendS3Error(c, *s3Err)
+		return
+	}
+
+	if err := h.getStorage(c).PutBucketCORS(bucket, rules); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetBucketCors(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	rules, err := h.getStorage(c).GetBucketCORS(bucket)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+	if len(rules) == 0 {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrNoSuchCORSConfiguration,
+			Message: "The CORS configuration does not exist",
+		})
+		return
+	}
+
+	config := CORSConfiguration{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	for _, rule := range rules {
+		config.Rules = append(config.Rules, CORSRule{
+			ID:             rule.ID,
+			AllowedHeaders: rule.AllowedHeaders,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedOrigins: rule.AllowedOrigins,
+			ExposeHeaders:  rule.ExposeHeaders,
+			MaxAgeSeconds:  rule.MaxAgeSeconds,
+		})
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, config)
+}
+
+func (h *Handler) DeleteBucketCors(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	if err := h.getStorage(c).PutBucketCORS(bucket, nil); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
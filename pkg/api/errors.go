@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	storageerrors "github.com/wozozo/s3pit/pkg/errors"
 )
 
 // S3ErrorCode represents standard S3 error codes
@@ -16,9 +17,13 @@ const (
 	// Common errors
 	ErrAccessDenied                  S3ErrorCode = "AccessDenied"
 	ErrBadDigest                     S3ErrorCode = "BadDigest"
+	ErrBadRequest                    S3ErrorCode = "BadRequest"
 	ErrBucketAlreadyExists           S3ErrorCode = "BucketAlreadyExists"
 	ErrBucketAlreadyOwnedByYou       S3ErrorCode = "BucketAlreadyOwnedByYou"
 	ErrBucketNotEmpty                S3ErrorCode = "BucketNotEmpty"
+	ErrEntityTooLarge                S3ErrorCode = "EntityTooLarge"
+	ErrEntityTooSmall                S3ErrorCode = "EntityTooSmall"
+	ErrIllegalVersioningConfig       S3ErrorCode = "IllegalVersioningConfigurationException"
 	ErrIncompleteBody                S3ErrorCode = "IncompleteBody"
 	ErrInternalError                 S3ErrorCode = "InternalError"
 	ErrInvalidAccessKeyId            S3ErrorCode = "InvalidAccessKeyId"
@@ -29,10 +34,15 @@ const (
 	ErrInvalidPart                   S3ErrorCode = "InvalidPart"
 	ErrInvalidPartNumber             S3ErrorCode = "InvalidPartNumber"
 	ErrInvalidPartOrder              S3ErrorCode = "InvalidPartOrder"
+	ErrInvalidRange                  S3ErrorCode = "InvalidRange"
 	ErrInvalidRequest                S3ErrorCode = "InvalidRequest"
 	ErrInvalidStorageClass           S3ErrorCode = "InvalidStorageClass"
+	ErrInvalidTag                    S3ErrorCode = "InvalidTag"
 	ErrInvalidTargetBucketForLogging S3ErrorCode = "InvalidTargetBucketForLogging"
+	ErrMalformedACLError             S3ErrorCode = "MalformedACLError"
+	ErrMalformedPolicy               S3ErrorCode = "MalformedPolicy"
 	ErrMalformedXML                  S3ErrorCode = "MalformedXML"
+	ErrMetadataTooLarge              S3ErrorCode = "MetadataTooLarge"
 	ErrMethodNotAllowed              S3ErrorCode = "MethodNotAllowed"
 	ErrMissingContentLength          S3ErrorCode = "MissingContentLength"
 	ErrMissingSecurityHeader         S3ErrorCode = "MissingSecurityHeader"
@@ -40,12 +50,16 @@ const (
 	ErrNoSuchBucketPolicy            S3ErrorCode = "NoSuchBucketPolicy"
 	ErrNoSuchCORSConfiguration       S3ErrorCode = "NoSuchCORSConfiguration"
 	ErrNoSuchKey                     S3ErrorCode = "NoSuchKey"
+	ErrNoSuchLifecycleConfiguration  S3ErrorCode = "NoSuchLifecycleConfiguration"
+	ErrNoSuchTagSet                  S3ErrorCode = "NoSuchTagSet"
 	ErrNoSuchUpload                  S3ErrorCode = "NoSuchUpload"
+	ErrNoSuchVersion                 S3ErrorCode = "NoSuchVersion"
 	ErrNotImplemented                S3ErrorCode = "NotImplemented"
 	ErrPreconditionFailed            S3ErrorCode = "PreconditionFailed"
 	ErrRequestTimeout                S3ErrorCode = "RequestTimeout"
 	ErrSignatureDoesNotMatch         S3ErrorCode = "SignatureDoesNotMatch"
 	ErrTooManyBuckets                S3ErrorCode = "TooManyBuckets"
+	ErrXAmzContentSHA256Mismatch     S3ErrorCode = "XAmzContentSHA256Mismatch"
 )
 
 // S3Error represents a complete S3 error with all necessary details
@@ -76,9 +90,13 @@ type S3ErrorResponse struct {
 var errorCodeToHTTPStatus = map[S3ErrorCode]int{
 	ErrAccessDenied:                  http.StatusForbidden,
 	ErrBadDigest:                     http.StatusBadRequest,
+	ErrBadRequest:                    http.StatusBadRequest,
 	ErrBucketAlreadyExists:           http.StatusConflict,
 	ErrBucketAlreadyOwnedByYou:       http.StatusConflict,
 	ErrBucketNotEmpty:                http.StatusConflict,
+	ErrEntityTooLarge:                http.StatusBadRequest,
+	ErrEntityTooSmall:                http.StatusBadRequest,
+	ErrIllegalVersioningConfig:       http.StatusBadRequest,
 	ErrIncompleteBody:                http.StatusBadRequest,
 	ErrInternalError:                 http.StatusInternalServerError,
 	ErrInvalidAccessKeyId:            http.StatusForbidden,
@@ -89,10 +107,15 @@ var errorCodeToHTTPStatus = map[S3ErrorCode]int{
 	ErrInvalidPart:                   http.StatusBadRequest,
 	ErrInvalidPartNumber:             http.StatusBadRequest,
 	ErrInvalidPartOrder:              http.StatusBadRequest,
+	ErrInvalidRange:                  http.StatusRequestedRangeNotSatisfiable,
 	ErrInvalidRequest:                http.StatusBadRequest,
 	ErrInvalidStorageClass:           http.StatusBadRequest,
+	ErrInvalidTag:                    http.StatusBadRequest,
 	ErrInvalidTargetBucketForLogging: http.StatusBadRequest,
+	ErrMalformedACLError:             http.StatusBadRequest,
+	ErrMalformedPolicy:               http.StatusBadRequest,
 	ErrMalformedXML:                  http.StatusBadRequest,
+	ErrMetadataTooLarge:              http.StatusBadRequest,
 	ErrMethodNotAllowed:              http.StatusMethodNotAllowed,
 	ErrMissingContentLength:          http.StatusBadRequest,
 	ErrMissingSecurityHeader:         http.StatusBadRequest,
@@ -100,12 +123,16 @@ var errorCodeToHTTPStatus = map[S3ErrorCode]int{
 	ErrNoSuchBucketPolicy:            http.StatusNotFound,
 	ErrNoSuchCORSConfiguration:       http.StatusNotFound,
 	ErrNoSuchKey:                     http.StatusNotFound,
+	ErrNoSuchLifecycleConfiguration:  http.StatusNotFound,
+	ErrNoSuchTagSet:                  http.StatusNotFound,
 	ErrNoSuchUpload:                  http.StatusNotFound,
+	ErrNoSuchVersion:                 http.StatusNotFound,
 	ErrNotImplemented:                http.StatusNotImplemented,
 	ErrPreconditionFailed:            http.StatusPreconditionFailed,
 	ErrRequestTimeout:                http.StatusRequestTimeout,
 	ErrSignatureDoesNotMatch:         http.StatusForbidden,
 	ErrTooManyBuckets:                http.StatusBadRequest,
+	ErrXAmzContentSHA256Mismatch:     http.StatusBadRequest,
 }
 
 // sendS3Error sends a properly formatted S3 error response
@@ -145,3 +172,12 @@ func (h *Handler) sendError(c *gin.Context, code string, message string, statusC
 		StatusCode: statusCode,
 	})
 }
+
+// sendStorageError sends the S3 error matching a storage error
+func (h *Handler) sendStorageError(c *gin.Context, err error) {
+	code, message := storageerrors.MapStorageErrorToS3(err)
+	h.sendS3Error(c, S3Error{
+		Code:    S3ErrorCode(code),
+		Message: message,
+	})
+}
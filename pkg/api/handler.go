@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,7 @@ type Handler struct {
 	auth          auth.Handler
 	tenantManager *tenant.Manager
 	config        *config.Config
+	writeLocks    keyLocks
 }
 
 func NewHandler(storage storage.Storage, auth auth.Handler, tenantManager *tenant.Manager, config *config.Config) *Handler {
@@ -33,9 +35,15 @@ func NewHandler(storage storage.Storage, auth auth.Handler, tenantManager *tenan
 	}
 }
 
-// getStorage returns the appropriate storage for the current request
-// If using TenantAwareStorage, it returns the tenant-specific storage instance
+// getStorage returns the appropriate storage for the current request,
+// recording a span per storage call when the request is traced
 func (h *Handler) getStorage(c *gin.Context) storage.Storage {
+	return storage.WithTracing(c.Request.Context(), h.selectStorage(c))
+}
+
+// selectStorage returns the storage holding the buckets of the request
+// If using TenantAwareStorage, it returns the tenant-specific storage instance
+func (h *Handler) selectStorage(c *gin.Context) storage.Storage {
 	// If using tenant-aware storage, get the tenant-specific storage
 	if tenantStorage, ok := h.storage.(*storage.TenantAwareStorage); ok {
 		// Get access key from context (set by auth middleware)
@@ -65,13 +73,18 @@ func (h *Handler) getStorage(c *gin.Context) storage.Storage {
 	return h.storage
 }
 
+type Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+// defaultOwner is reported as the owner of every bucket and object
+var defaultOwner = Owner{ID: "s3pit", DisplayName: "S3pit User"}
+
 type ListBucketsResponse struct {
 	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
 	Xmlns   string   `xml:"xmlns,attr"`
-	Owner   struct {
-		ID          string `xml:"ID"`
-		DisplayName string `xml:"DisplayName"`
-	} `xml:"Owner"`
+	Owner   Owner    `xml:"Owner"`
 	Buckets struct {
 		Bucket []BucketInfo `xml:"Bucket"`
 	} `xml:"Buckets"`
@@ -82,6 +95,21 @@ type BucketInfo struct {
 	CreationDate time.Time `xml:"CreationDate"`
 }
 
+type ListObjectsV1Response struct {
+	XMLName        xml.Name       `xml:"ListBucketResult"`
+	Xmlns          string         `xml:"xmlns,attr"`
+	Name           string         `xml:"Name"`
+	Prefix         string         `xml:"Prefix"`
+	Marker         string         `xml:"Marker"`
+	NextMarker     string         `xml:"NextMarker,omitempty"`
+	Delimiter      string         `xml:"Delimiter,omitempty"`
+	MaxKeys        int            `xml:"MaxKeys"`
+	EncodingType   string         `xml:"EncodingType,omitempty"`
+	IsTruncated    bool           `xml:"IsTruncated"`
+	Contents       []ObjectInfo   `xml:"Contents"`
+	CommonPrefixes []CommonPrefix `xml:"CommonPrefixes,omitempty"`
+}
+
 type ListObjectsV2Response struct {
 	XMLName               xml.Name       `xml:"ListBucketResult"`
 	Xmlns                 string         `xml:"xmlns,attr"`
@@ -89,10 +117,12 @@ type ListObjectsV2Response struct {
 	Prefix                string         `xml:"Prefix,omitempty"`
 	Delimiter             string         `xml:"Delimiter,omitempty"`
 	MaxKeys               int            `xml:"MaxKeys"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
 	KeyCount              int            `xml:"KeyCount"`
 	IsTruncated           bool           `xml:"IsTruncated"`
 	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
 	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
 	Contents              []ObjectInfo   `xml:"Contents"`
 	CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes,omitempty"`
 }
@@ -102,6 +132,7 @@ type ObjectInfo struct {
 	LastModified time.Time `xml:"LastModified"`
 	ETag         string    `xml:"ETag"`
 	Size         int64     `xml:"Size"`
+	Owner        *Owner    `xml:"Owner,omitempty"`
 	StorageClass string    `xml:"StorageClass"`
 }
 
@@ -116,7 +147,8 @@ type DeleteRequest struct {
 }
 
 type DeleteObject struct {
-	Key string `xml:"Key"`
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
 }
 
 type DeleteResponse struct {
@@ -126,7 +158,10 @@ type DeleteResponse struct {
 }
 
 type DeletedObject struct {
-	Key string `xml:"Key"`
+	Key                   string `xml:"Key"`
+	VersionId             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionId string `xml:"DeleteMarkerVersionId,omitempty"`
 }
 
 type DeleteError struct {
@@ -145,8 +180,7 @@ func (h *Handler) ListBuckets(c *gin.Context) {
 	response := ListBucketsResponse{
 		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
 	}
-	response.Owner.ID = "s3pit"
-	response.Owner.DisplayName = "S3pit User"
+	response.Owner = defaultOwner
 
 	for _, bucket := range buckets {
 		response.Buckets.Bucket = append(response.Buckets.Bucket, BucketInfo{
@@ -179,6 +213,11 @@ func (h *Handler) HeadBucket(c *gin.Context) {
 func (h *Handler) CreateBucket(c *gin.Context) {
 	bucket := c.Param("bucket")
 
+	acl, ok := h.aclFromHeaders(c)
+	if !ok {
+		return
+	}
+
 	created, err := h.getStorage(c).CreateBucket(bucket)
 	if err != nil {
 		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
@@ -189,6 +228,13 @@ func (h *Handler) CreateBucket(c *gin.Context) {
 		c.Header("x-s3pit-bucket-created", "true")
 	}
 
+	if acl != nil {
+		if err := h.getStorage(c).PutBucketACL(bucket, acl); err != nil {
+			h.sendStorageError(c, err)
+			return
+		}
+	}
+
 	c.Status(http.StatusOK)
 }
 
@@ -212,64 +258,164 @@ func (h *Handler) DeleteBucket(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func (h *Handler) ListObjectsV2(c *gin.Context) {
-	bucket := c.Param("bucket")
+// ListObjects serves GET on a bucket, dispatching on list-type like S3:
+// list-type=2 selects ListObjectsV2, anything else the legacy V1 listing
+func (h *Handler) ListObjects(c *gin.Context) {
+	if c.Query("list-type") == "2" {
+		h.ListObjectsV2(c)
+		return
+	}
+	h.ListObjectsV1(c)
+}
+
+// listParams holds the query parameters shared by both listing versions
+type listParams struct {
+	prefix       string
+	delimiter    string
+	maxKeys      int
+	encodingType string
+}
 
+// parseListParams validates the bucket and reads the common listing
+// parameters. It returns false when an error response has already been written.
+func (h *Handler) parseListParams(c *gin.Context, bucket string) (listParams, bool) {
 	exists, err := h.getStorage(c).BucketExists(bucket)
 	if err != nil {
 		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
-		return
+		return listParams{}, false
 	}
 
 	if !exists {
 		h.sendError(c, "NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound)
-		return
+		return listParams{}, false
 	}
 
-	prefix := c.Query("prefix")
-	delimiter := c.Query("delimiter")
-	maxKeys := 1000
+	params := listParams{
+		prefix:       c.Query("prefix"),
+		delimiter:    c.Query("delimiter"),
+		maxKeys:      1000,
+		encodingType: c.Query("encoding-type"),
+	}
 	if mk := c.Query("max-keys"); mk != "" {
 		if parsed, err := strconv.Atoi(mk); err == nil && parsed > 0 {
-			maxKeys = parsed
+			params.maxKeys = parsed
 		}
 	}
-	continuationToken := c.Query("continuation-token")
 
-	objects, commonPrefixes, nextToken, err := h.getStorage(c).ListObjects(bucket, prefix, delimiter, maxKeys, continuationToken)
-	if err != nil {
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
-		return
+	if params.encodingType != "" && params.encodingType != "url" {
+		h.sendError(c, "InvalidArgument", "Invalid Encoding Method specified in Request", http.StatusBadRequest)
+		return listParams{}, false
 	}
 
-	response := ListObjectsV2Response{
-		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
-		Name:                  bucket,
-		Prefix:                prefix,
-		Delimiter:             delimiter,
-		MaxKeys:               maxKeys,
-		KeyCount:              len(objects),
-		IsTruncated:           nextToken != "",
-		ContinuationToken:     continuationToken,
-		NextContinuationToken: nextToken,
+	return params, true
+}
+
+// encode applies the requested encoding-type to a key or prefix in a listing
+func (p listParams) encode(value string) string {
+	if p.encodingType != "url" {
+		return value
 	}
+	// S3 leaves the path separator readable in URL-encoded listings
+	return strings.ReplaceAll(url.QueryEscape(value), "%2F", "/")
+}
 
+// listContents converts storage listing results to response entries
+func (p listParams) listContents(objects []storage.ObjectInfo, commonPrefixes []string, withOwner bool) ([]ObjectInfo, []CommonPrefix) {
+	var contents []ObjectInfo
 	for _, obj := range objects {
-		response.Contents = append(response.Contents, ObjectInfo{
-			Key:          obj.Key,
+		info := ObjectInfo{
+			Key:          p.encode(obj.Key),
 			LastModified: obj.LastModified,
 			ETag:         obj.ETag,
 			Size:         obj.Size,
 			StorageClass: "STANDARD",
-		})
+		}
+		if withOwner {
+			owner := defaultOwner
+			info.Owner = &owner
+		}
+		contents = append(contents, info)
 	}
 
+	var prefixes []CommonPrefix
 	for _, prefix := range commonPrefixes {
-		response.CommonPrefixes = append(response.CommonPrefixes, CommonPrefix{
-			Prefix: prefix,
+		prefixes = append(prefixes, CommonPrefix{
+			Prefix: p.encode(prefix),
 		})
 	}
 
+	return contents, prefixes
+}
+
+func (h *Handler) ListObjectsV1(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	params, ok := h.parseListParams(c, bucket)
+	if !ok {
+		return
+	}
+	marker := c.Query("marker")
+
+	objects, commonPrefixes, nextMarker, err := h.getStorage(c).ListObjects(bucket, params.prefix, params.delimiter, params.maxKeys, marker)
+	if err != nil {
+		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := ListObjectsV1Response{
+		Xmlns:        "http://s3.amazonaws.com/doc/2006-03-01/",
+		Name:         bucket,
+		Prefix:       params.encode(params.prefix),
+		Marker:       params.encode(marker),
+		NextMarker:   params.encode(nextMarker),
+		Delimiter:    params.encode(params.delimiter),
+		MaxKeys:      params.maxKeys,
+		EncodingType: params.encodingType,
+		IsTruncated:  nextMarker != "",
+	}
+	response.Contents, response.CommonPrefixes = params.listContents(objects, commonPrefixes, true)
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, response)
+}
+
+func (h *Handler) ListObjectsV2(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	params, ok := h.parseListParams(c, bucket)
+	if !ok {
+		return
+	}
+	continuationToken := c.Query("continuation-token")
+	startAfter := c.Query("start-after")
+
+	// The continuation token takes precedence over start-after
+	after := continuationToken
+	if after == "" {
+		after = startAfter
+	}
+
+	objects, commonPrefixes, nextToken, err := h.getStorage(c).ListObjects(bucket, params.prefix, params.delimiter, params.maxKeys, after)
+	if err != nil {
+		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := ListObjectsV2Response{
+		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		Name:                  bucket,
+		Prefix:                params.encode(params.prefix),
+		Delimiter:             params.encode(params.delimiter),
+		MaxKeys:               params.maxKeys,
+		EncodingType:          params.encodingType,
+		KeyCount:              len(objects) + len(commonPrefixes),
+		IsTruncated:           nextToken != "",
+		ContinuationToken:     continuationToken,
+		NextContinuationToken: nextToken,
+		StartAfter:            params.encode(startAfter),
+	}
+	response.Contents, response.CommonPrefixes = params.listContents(objects, commonPrefixes, c.Query("fetch-owner") == "true")
+
 	c.Header("Content-Type", "application/xml")
 	c.XML(http.StatusOK, response)
 }
@@ -277,48 +423,96 @@ func (h *Handler) ListObjectsV2(c *gin.Context) {
 func (h *Handler) HeadObject(c *gin.Context) {
 	bucket := c.Param("bucket")
 	key := strings.TrimPrefix(c.Param("key"), "/")
+	versionId := c.Query("versionId")
 
-	meta, err := h.getStorage(c).GetObjectMetadata(bucket, key)
+	meta, err := h.getStorage(c).GetObjectVersionMetadata(bucket, key, versionId)
 	if err != nil {
-		if err == storage.ErrObjectNotFound {
-			h.sendError(c, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
-			return
-		}
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	if !h.checkReadPreconditions(c, meta) {
+		return
+	}
+
+	setObjectHeaders(c, meta)
+
+	byteRange, ok := h.resolveRange(c, meta)
+	if !ok {
+		return
+	}
+	if byteRange != nil {
+		c.Header("Content-Range", byteRange.contentRange(meta.Size))
+		c.Header("Content-Length", fmt.Sprintf("%d", byteRange.length))
+		c.Status(http.StatusPartialContent)
 		return
 	}
 
-	c.Header("Content-Type", meta.ContentType)
 	c.Header("Content-Length", fmt.Sprintf("%d", meta.Size))
-	c.Header("ETag", meta.ETag)
-	c.Header("Last-Modified", meta.LastModified.Format(http.TimeFormat))
 	c.Status(http.StatusOK)
 }
 
 func (h *Handler) GetObject(c *gin.Context) {
 	bucket := c.Param("bucket")
 	key := strings.TrimPrefix(c.Param("key"), "/")
+	versionId := c.Query("versionId")
 
-	reader, meta, err := h.getStorage(c).GetObject(bucket, key)
+	reader, meta, err := h.getStorage(c).GetObjectVersion(bucket, key, versionId)
 	if err != nil {
-		if err == storage.ErrObjectNotFound {
-			h.sendError(c, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+	defer reader.Close()
+
+	if !h.checkReadPreconditions(c, meta) {
+		return
+	}
+
+	setObjectHeaders(c, meta)
+
+	byteRange, ok := h.resolveRange(c, meta)
+	if !ok {
+		return
+	}
+	if byteRange != nil {
+		if _, err := reader.Seek(byteRange.start, io.SeekStart); err != nil {
+			h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
 			return
 		}
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		c.Header("Content-Range", byteRange.contentRange(meta.Size))
+		c.Header("Content-Length", fmt.Sprintf("%d", byteRange.length))
+		c.Status(http.StatusPartialContent)
+		_, _ = io.CopyN(c.Writer, reader, byteRange.length)
 		return
 	}
-	defer reader.Close()
 
-	c.Header("Content-Type", meta.ContentType)
 	c.Header("Content-Length", fmt.Sprintf("%d", meta.Size))
-	c.Header("ETag", meta.ETag)
-	c.Header("Last-Modified", meta.LastModified.Format(http.TimeFormat))
-
 	c.Status(http.StatusOK)
 	_, _ = io.Copy(c.Writer, reader)
 }
 
+// resolveRange evaluates the Range and If-Range headers against the object.
+// It returns a nil range when the whole object should be served, and false
+// when an InvalidRange error has already been written to the response.
+func (h *Handler) resolveRange(c *gin.Context, meta *storage.ObjectMetadata) (*byteRange, bool) {
+	rangeHeader := c.GetHeader("Range")
+	if rangeHeader == "" || !ifRangeMatches(c.GetHeader("If-Range"), meta) {
+		return nil, true
+	}
+
+	byteRange, err := parseRange(rangeHeader, meta.Size)
+	if err != nil {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", meta.Size))
+		h.sendS3Error(c, S3Error{
+			Code:    ErrInvalidRange,
+			Message: "The requested range is not satisfiable",
+		})
+		return nil, false
+	}
+
+	return byteRange, true
+}
+
 func (h *Handler) PutObject(c *gin.Context) {
 	bucket := c.Param("bucket")
 	key := strings.TrimPrefix(c.Param("key"), "/")
@@ -351,17 +545,33 @@ func (h *Handler) PutObject(c *gin.Context) {
 		}
 	}
 
-	contentType := c.GetHeader("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream"
+	if c.GetHeader("If-Match") != "" || c.GetHeader("If-None-Match") != "" {
+		unlock := h.writeLocks.lock(c.GetString("accessKey"), bucket, key)
+		defer unlock()
+		if !h.checkWritePreconditions(c, bucket, key) {
+			return
+		}
 	}
 
-	etag, err := h.getStorage(c).PutObject(bucket, key, c.Request.Body, c.Request.ContentLength, contentType)
+	metadata, ok := h.objectMetadataFromRequest(c)
+	if !ok {
+		return
+	}
+
+	body, size, ok := h.objectBody(c)
+	if !ok {
+		return
+	}
+
+	etag, err := h.getStorage(c).PutObjectWithMetadata(bucket, key, body, size, metadata)
 	if err != nil {
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		h.sendStorageError(c, err)
 		return
 	}
 
+	h.setVersionIdHeader(c, bucket, key)
+	algorithm, checksum := body.Checksum()
+	setChecksumHeaders(c, algorithm, checksum, storage.ChecksumTypeFullObject)
 	c.Header("ETag", etag)
 	c.Status(http.StatusOK)
 }
@@ -370,16 +580,18 @@ func (h *Handler) DeleteObject(c *gin.Context) {
 	bucket := c.Param("bucket")
 	key := strings.TrimPrefix(c.Param("key"), "/")
 
-	err := h.getStorage(c).DeleteObject(bucket, key)
+	versionId, deleteMarker, err := h.deleteObjectVersion(c, bucket, key, c.Query("versionId"))
 	if err != nil {
-		if err == storage.ErrObjectNotFound {
-			c.Status(http.StatusNoContent)
-			return
-		}
 		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if versionId != "" {
+		c.Header("x-amz-version-id", versionId)
+	}
+	if deleteMarker {
+		c.Header("x-amz-delete-marker", "true")
+	}
 	c.Status(http.StatusNoContent)
 }
 
@@ -395,18 +607,24 @@ func (h *Handler) DeleteObjects(c *gin.Context) {
 	response := DeleteResponse{}
 
 	for _, obj := range req.Objects {
-		err := h.getStorage(c).DeleteObject(bucket, obj.Key)
+		versionId, deleteMarker, err := h.deleteObjectVersion(c, bucket, obj.Key, obj.VersionId)
 		if err != nil {
-			if err != storage.ErrObjectNotFound {
-				response.Error = append(response.Error, DeleteError{
-					Key:     obj.Key,
-					Code:    "InternalError",
-					Message: err.Error(),
-				})
-			}
-		} else if !req.Quiet {
-			response.Deleted = append(response.Deleted, DeletedObject(obj))
+			response.Error = append(response.Error, DeleteError{
+				Key:     obj.Key,
+				Code:    "InternalError",
+				Message: err.Error(),
+			})
+			continue
 		}
+		if req.Quiet {
+			continue
+		}
+
+		deleted := DeletedObject{Key: obj.Key, VersionId: obj.VersionId, DeleteMarker: deleteMarker}
+		if deleteMarker {
+			deleted.DeleteMarkerVersionId = versionId
+		}
+		response.Deleted = append(response.Deleted, deleted)
 	}
 
 	c.Header("Content-Type", "application/xml")
@@ -440,32 +658,41 @@ func (h *Handler) CopyObject(c *gin.Context) {
 	destBucket := c.Param("bucket")
 	destKey := strings.TrimPrefix(c.Param("key"), "/")
 
-	copySource := c.GetHeader("x-amz-copy-source")
-	if copySource == "" {
-		h.sendError(c, "InvalidRequest", "x-amz-copy-source header is required", http.StatusBadRequest)
+	sourceBucket, sourceKey, sourceVersionId, ok := h.parseCopySource(c)
+	if !ok {
 		return
 	}
 
-	// Parse source bucket and key from x-amz-copy-source
-	// Format: /bucket/key or bucket/key
-	copySource = strings.TrimPrefix(copySource, "/")
-	parts := strings.SplitN(copySource, "/", 2)
-	if len(parts) != 2 {
-		h.sendError(c, "InvalidRequest", "Invalid x-amz-copy-source format", http.StatusBadRequest)
+	directive := strings.ToUpper(c.GetHeader("x-amz-metadata-directive"))
+	if directive == "" {
+		directive = "COPY"
+	}
+	if directive != "COPY" && directive != "REPLACE" {
+		h.sendError(c, "InvalidArgument", "Unknown metadata directive", http.StatusBadRequest)
+		return
+	}
+	taggingDirective := strings.ToUpper(c.GetHeader("x-amz-tagging-directive"))
+	if taggingDirective == "" {
+		taggingDirective = "COPY"
+	}
+	if taggingDirective != "COPY" && taggingDirective != "REPLACE" {
+		h.sendError(c, "InvalidArgument", "Unknown tagging directive", http.StatusBadRequest)
+		return
+	}
+	if sourceBucket == destBucket && sourceKey == destKey && sourceVersionId == "" && directive != "REPLACE" {
+		h.sendError(c, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.", http.StatusBadRequest)
 		return
 	}
-
-	sourceBucket := parts[0]
-	sourceKey := parts[1]
 
 	// Check if source object exists
-	sourceMeta, err := h.getStorage(c).GetObjectMetadata(sourceBucket, sourceKey)
+	sourceMeta, err := h.getStorage(c).GetObjectVersionMetadata(sourceBucket, sourceKey, sourceVersionId)
 	if err != nil {
-		if err == storage.ErrObjectNotFound {
-			h.sendError(c, "NoSuchKey", "The specified source key does not exist", http.StatusNotFound)
-			return
-		}
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		h.sendCopySourceError(c, sourceVersionId, err)
+		return
+	}
+
+	if copySourcePreconditions(c).evaluate(sourceMeta) != http.StatusOK {
+		h.sendPreconditionFailed(c)
 		return
 	}
 
@@ -491,21 +718,39 @@ func (h *Handler) CopyObject(c *gin.Context) {
 		}
 	}
 
-	// Get source object data
-	reader, _, err := h.getStorage(c).GetObject(sourceBucket, sourceKey)
-	if err != nil {
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
-		return
+	// Keep the source metadata and tags unless the request replaces them.
+	// The ACL is never copied and comes from the request headers.
+	var destMeta *storage.ObjectMetadata
+	if directive == "REPLACE" || taggingDirective == "REPLACE" || hasACLHeaders(c) {
+		requestMeta, ok := h.objectMetadataFromRequest(c)
+		if !ok {
+			return
+		}
+		destMeta = sourceMeta
+		if directive == "REPLACE" {
+			destMeta = requestMeta
+		}
+		destMeta.Tags = sourceMeta.Tags
+		if taggingDirective == "REPLACE" {
+			destMeta.Tags = requestMeta.Tags
+		}
+		destMeta.ACL = requestMeta.ACL
 	}
-	defer reader.Close()
 
-	// Put to destination
-	etag, err := h.getStorage(c).PutObject(destBucket, destKey, reader, sourceMeta.Size, sourceMeta.ContentType)
+	// Copy within the backend so the data never passes through the handler
+	meta, err := h.getStorage(c).CopyObjectVersion(sourceBucket, sourceKey, sourceMeta.VersionId, destBucket, destKey, destMeta)
 	if err != nil {
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		h.sendCopySourceError(c, sourceVersionId, err)
 		return
 	}
 
+	if sourceMeta.VersionId != "" {
+		c.Header("x-amz-copy-source-version-id", sourceMeta.VersionId)
+	}
+	if meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+
 	// Return CopyObjectResult XML
 	type CopyObjectResult struct {
 		XMLName      xml.Name  `xml:"CopyObjectResult"`
@@ -515,11 +760,66 @@ func (h *Handler) CopyObject(c *gin.Context) {
 
 	c.Header("Content-Type", "application/xml")
 	c.XML(http.StatusOK, CopyObjectResult{
-		LastModified: time.Now(),
-		ETag:         etag,
+		LastModified: meta.LastModified,
+		ETag:         meta.ETag,
 	})
 }
 
+// parseCopySource reads the source bucket, key and version from the
+// x-amz-copy-source header. It returns false when an error has already been
+// written to the response.
+func (h *Handler) parseCopySource(c *gin.Context) (string, string, string, bool) {
+	bucket, key, versionId, err := ParseCopySource(c.GetHeader("x-amz-copy-source"))
+	if err != nil {
+		h.sendS3Error(c, *err)
+		return "", "", "", false
+	}
+	return bucket, key, versionId, true
+}
+
+// ParseCopySource splits an x-amz-copy-source header into the source
+// bucket, key and version. The auth middleware uses it as well, so the
+// source a copy is authorized for is the source the copy reads.
+func ParseCopySource(copySource string) (string, string, string, *S3Error) {
+	if copySource == "" {
+		return "", "", "", &S3Error{Code: ErrInvalidRequest, Message: "x-amz-copy-source header is required"}
+	}
+
+	// Format: /bucket/key or bucket/key, URL-encoded and optionally followed
+	// by ?versionId=
+	var versionId string
+	if source, query, found := strings.Cut(copySource, "?"); found {
+		copySource = source
+		if values, err := url.ParseQuery(query); err == nil {
+			versionId = values.Get("versionId")
+		}
+	}
+	copySource, err := url.PathUnescape(strings.TrimPrefix(copySource, "/"))
+	if err != nil {
+		return "", "", "", &S3Error{Code: ErrInvalidArgument, Message: "Invalid copy source encoding"}
+	}
+	parts := strings.SplitN(copySource, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", &S3Error{Code: ErrInvalidRequest, Message: "Invalid x-amz-copy-source format"}
+	}
+
+	return parts[0], parts[1], versionId, nil
+}
+
+// sendCopySourceError reports a failed lookup of the copy source object
+func (h *Handler) sendCopySourceError(c *gin.Context, versionId string, err error) {
+	switch {
+	case err == storage.ErrDeleteMarker && versionId != "":
+		h.sendError(c, "InvalidRequest", "The source of a copy request may not specifically refer to a delete marker by version id.", http.StatusBadRequest)
+	case err == storage.ErrObjectNotFound || err == storage.ErrDeleteMarker:
+		h.sendError(c, "NoSuchKey", "The specified source key does not exist", http.StatusNotFound)
+	case err == storage.ErrVersionNotFound:
+		h.sendS3Error(c, S3Error{Code: ErrNoSuchVersion, Message: "The specified version does not exist"})
+	default:
+		h.sendStorageError(c, err)
+	}
+}
+
 func (h *Handler) InitiateMultipartUpload(c *gin.Context) {
 	bucket := c.Param("bucket")
 	key := strings.TrimPrefix(c.Param("key"), "/")
@@ -546,8 +846,16 @@ func (h *Handler) InitiateMultipartUpload(c *gin.Context) {
 		}
 	}
 
+	metadata, ok := h.objectMetadataFromRequest(c)
+	if !ok {
+		return
+	}
+	if !h.multipartChecksumFromRequest(c, metadata) {
+		return
+	}
+
 	// Initialize multipart upload in storage
-	uploadId, err := h.getStorage(c).InitiateMultipartUpload(bucket, key)
+	uploadId, err := h.getStorage(c).InitiateMultipartUploadWithMetadata(bucket, key, metadata)
 	if err != nil {
 		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
 		return
@@ -561,6 +869,11 @@ func (h *Handler) InitiateMultipartUpload(c *gin.Context) {
 		UploadId string   `xml:"UploadId"`
 	}
 
+	if metadata.ChecksumAlgorithm != "" {
+		c.Header("x-amz-checksum-algorithm", metadata.ChecksumAlgorithm)
+		c.Header("x-amz-checksum-type", metadata.ChecksumType)
+	}
+
 	c.Header("Content-Type", "application/xml")
 	c.XML(http.StatusOK, InitiateMultipartUploadResult{
 		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
@@ -593,12 +906,19 @@ func (h *Handler) UploadPart(c *gin.Context) {
 		return
 	}
 
-	etag, err := h.getStorage(c).UploadPart(bucket, key, uploadId, partNumber, c.Request.Body, contentLength)
+	body, size, ok := h.objectBody(c)
+	if !ok {
+		return
+	}
+
+	etag, err := h.getStorage(c).UploadPart(bucket, key, uploadId, partNumber, body, size)
 	if err != nil {
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		h.sendStorageError(c, err)
 		return
 	}
 
+	algorithm, checksum := body.Checksum()
+	setChecksumHeaders(c, algorithm, checksum, "")
 	c.Header("ETag", etag)
 	c.Status(http.StatusOK)
 }
@@ -624,7 +944,7 @@ func (h *Handler) CompleteMultipartUpload(c *gin.Context) {
 	}
 
 	var req CompleteMultipartUploadRequest
-	if err := c.ShouldBindXML(&req); err != nil {
+	if err := c.ShouldBindXML(&req); err != nil || len(req.Parts) == 0 {
 		h.sendError(c, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
 		return
 	}
@@ -638,12 +958,22 @@ func (h *Handler) CompleteMultipartUpload(c *gin.Context) {
 		})
 	}
 
+	if c.GetHeader("If-Match") != "" || c.GetHeader("If-None-Match") != "" {
+		unlock := h.writeLocks.lock(c.GetString("accessKey"), bucket, key)
+		defer unlock()
+		if !h.checkWritePreconditions(c, bucket, key) {
+			return
+		}
+	}
+
 	etag, err := h.getStorage(c).CompleteMultipartUpload(bucket, key, uploadId, parts)
 	if err != nil {
-		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		h.sendStorageError(c, err)
 		return
 	}
 
+	h.setVersionIdHeader(c, bucket, key)
+
 	type CompleteMultipartUploadResult struct {
 		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
 		Xmlns    string   `xml:"xmlns,attr"`
@@ -651,16 +981,26 @@ func (h *Handler) CompleteMultipartUpload(c *gin.Context) {
 		Bucket   string   `xml:"Bucket"`
 		Key      string   `xml:"Key"`
 		ETag     string   `xml:"ETag"`
+		Checksums
+		ChecksumType string `xml:"ChecksumType,omitempty"`
 	}
 
-	c.Header("Content-Type", "application/xml")
-	c.XML(http.StatusOK, CompleteMultipartUploadResult{
+	result := CompleteMultipartUploadResult{
 		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
 		Location: fmt.Sprintf("http://%s/%s/%s", c.Request.Host, bucket, key),
 		Bucket:   bucket,
 		Key:      key,
 		ETag:     etag,
-	})
+	}
+	if meta, err := h.getStorage(c).GetObjectMetadata(bucket, key); err == nil {
+		result.Checksums = newChecksums(meta.ChecksumAlgorithm, meta.Checksum)
+		if meta.Checksum != "" {
+			result.ChecksumType = meta.ChecksumType
+		}
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, result)
 }
 
 func (h *Handler) AbortMultipartUpload(c *gin.Context) {
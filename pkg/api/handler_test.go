@@ -2,12 +2,19 @@ package api
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wozozo/s3pit/pkg/auth"
@@ -36,22 +43,102 @@ func setupTestHandler(t *testing.T) (*Handler, *gin.Engine) {
 	// Setup routes manually for testing
 	router.GET("/", handler.ListBuckets)
 	router.HEAD("/:bucket", handler.HeadBucket)
-	router.PUT("/:bucket", handler.CreateBucket)
-	router.DELETE("/:bucket", handler.DeleteBucket)
-	router.GET("/:bucket", handler.ListObjectsV2)
+	router.PUT("/:bucket", func(c *gin.Context) {
+		if _, exists := c.GetQuery("versioning"); exists {
+			handler.PutBucketVersioning(c)
+		} else if _, exists := c.GetQuery("tagging"); exists {
+			handler.PutBucketTagging(c)
+		} else if _, exists := c.GetQuery("acl"); exists {
+			handler.PutBucketAcl(c)
+		} else if _, exists := c.GetQuery("cors"); exists {
+			handler.PutBucketCors(c)
+		} else if _, exists := c.GetQuery("lifecycle"); exists {
+			handler.PutBucketLifecycleConfiguration(c)
+		} else if _, exists := c.GetQuery("policy"); exists {
+			handler.PutBucketPolicy(c)
+		} else {
+			handler.CreateBucket(c)
+		}
+	})
+	router.DELETE("/:bucket", func(c *gin.Context) {
+		if _, exists := c.GetQuery("tagging"); exists {
+			handler.DeleteBucketTagging(c)
+		} else if _, exists := c.GetQuery("cors"); exists {
+			handler.DeleteBucketCors(c)
+		} else if _, exists := c.GetQuery("lifecycle"); exists {
+			handler.DeleteBucketLifecycle(c)
+		} else if _, exists := c.GetQuery("policy"); exists {
+			handler.DeleteBucketPolicy(c)
+		} else {
+			handler.DeleteBucket(c)
+		}
+	})
+	router.GET("/:bucket", func(c *gin.Context) {
+		if _, exists := c.GetQuery("versioning"); exists {
+			handler.GetBucketVersioning(c)
+		} else if _, exists := c.GetQuery("tagging"); exists {
+			handler.GetBucketTagging(c)
+		} else if _, exists := c.GetQuery("acl"); exists {
+			handler.GetBucketAcl(c)
+		} else if _, exists := c.GetQuery("cors"); exists {
+			handler.GetBucketCors(c)
+		} else if _, exists := c.GetQuery("lifecycle"); exists {
+			handler.GetBucketLifecycleConfiguration(c)
+		} else if _, exists := c.GetQuery("policy"); exists {
+			handler.GetBucketPolicy(c)
+		} else if _, exists := c.GetQuery("policyStatus"); exists {
+			handler.GetBucketPolicyStatus(c)
+		} else if _, exists := c.GetQuery("versions"); exists {
+			handler.ListObjectVersions(c)
+		} else if _, exists := c.GetQuery("uploads"); exists {
+			handler.ListMultipartUploads(c)
+		} else {
+			handler.ListObjects(c)
+		}
+	})
 
 	router.PUT("/:bucket/*key", func(c *gin.Context) {
-		if c.GetHeader("x-amz-copy-source") != "" {
-			handler.CopyObject(c)
-		} else if c.Query("uploadId") != "" && c.Query("partNumber") != "" {
+		if _, exists := c.GetQuery("tagging"); exists {
+			handler.PutObjectTagging(c)
+			return
+		}
+		if _, exists := c.GetQuery("acl"); exists {
+			handler.PutObjectAcl(c)
+			return
+		}
+		isPart := c.Query("uploadId") != "" && c.Query("partNumber") != ""
+		isCopy := c.GetHeader("x-amz-copy-source") != ""
+		if isPart && isCopy {
+			handler.UploadPartCopy(c)
+		} else if isPart {
 			handler.UploadPart(c)
+		} else if isCopy {
+			handler.CopyObject(c)
 		} else {
 			handler.PutObject(c)
 		}
 	})
-	router.GET("/:bucket/*key", handler.GetObject)
+	router.GET("/:bucket/*key", func(c *gin.Context) {
+		if _, exists := c.GetQuery("attributes"); exists {
+			handler.GetObjectAttributes(c)
+		} else if _, exists := c.GetQuery("tagging"); exists {
+			handler.GetObjectTagging(c)
+		} else if _, exists := c.GetQuery("acl"); exists {
+			handler.GetObjectAcl(c)
+		} else if c.Query("uploadId") != "" {
+			handler.ListParts(c)
+		} else {
+			handler.GetObject(c)
+		}
+	})
 	router.HEAD("/:bucket/*key", handler.HeadObject)
-	router.DELETE("/:bucket/*key", handler.DeleteObject)
+	router.DELETE("/:bucket/*key", func(c *gin.Context) {
+		if _, exists := c.GetQuery("tagging"); exists {
+			handler.DeleteObjectTagging(c)
+		} else {
+			handler.DeleteObject(c)
+		}
+	})
 	router.POST("/:bucket/*key", func(c *gin.Context) {
 		if _, exists := c.GetQuery("uploads"); exists {
 			handler.InitiateMultipartUpload(c)
@@ -301,6 +388,108 @@ func TestListObjectsV2(t *testing.T) {
 	})
 }
 
+func TestListObjectsV1(t *testing.T) {
+	handler, router := setupTestHandler(t)
+
+	bucket := "v1-bucket"
+	_, _ = handler.storage.CreateBucket(bucket)
+	for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+		_, _ = handler.storage.PutObject(bucket, key, bytes.NewReader([]byte("test")), 4, "text/plain")
+	}
+
+	req := httptest.NewRequest("GET", "/"+bucket+"?marker=a.txt&max-keys=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var result ListObjectsV1Response
+	if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse XML response: %v", err)
+	}
+
+	if result.Marker != "a.txt" || result.NextMarker != "b.txt" || !result.IsTruncated {
+		t.Errorf("Unexpected V1 markers: marker=%q next=%q truncated=%v", result.Marker, result.NextMarker, result.IsTruncated)
+	}
+	if len(result.Contents) != 1 || result.Contents[0].Key != "b.txt" {
+		t.Fatalf("Expected b.txt after marker, got %+v", result.Contents)
+	}
+	if result.Contents[0].Owner == nil {
+		t.Error("Expected V1 listing to include the owner")
+	}
+	if strings.Contains(w.Body.String(), "KeyCount") {
+		t.Error("V1 listing should not include KeyCount")
+	}
+}
+
+func TestListObjectsV2Options(t *testing.T) {
+	handler, router := setupTestHandler(t)
+
+	bucket := "v2-options"
+	_, _ = handler.storage.CreateBucket(bucket)
+	for _, key := range []string{"a.txt", "b.txt", "dir/with space.txt"} {
+		_, _ = handler.storage.PutObject(bucket, key, bytes.NewReader([]byte("test")), 4, "text/plain")
+	}
+
+	list := func(query string) ListObjectsV2Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/"+bucket+"?list-type=2&"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var result ListObjectsV2Response
+		if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to parse XML response: %v", err)
+		}
+		return result
+	}
+
+	t.Run("StartAfter", func(t *testing.T) {
+		result := list("start-after=a.txt")
+		if result.StartAfter != "a.txt" || len(result.Contents) != 2 || result.Contents[0].Key != "b.txt" {
+			t.Errorf("Unexpected start-after result: %+v", result)
+		}
+	})
+
+	t.Run("FetchOwner", func(t *testing.T) {
+		if result := list(""); result.Contents[0].Owner != nil {
+			t.Error("Owner should only be returned with fetch-owner=true")
+		}
+		if result := list("fetch-owner=true"); result.Contents[0].Owner == nil {
+			t.Error("Expected owner with fetch-owner=true")
+		}
+	})
+
+	t.Run("EncodingTypeURL", func(t *testing.T) {
+		result := list("prefix=dir/&encoding-type=url")
+		if result.EncodingType != "url" || len(result.Contents) != 1 {
+			t.Fatalf("Unexpected result: %+v", result)
+		}
+		if result.Contents[0].Key != "dir/with+space.txt" {
+			t.Errorf("Expected URL-encoded key, got %q", result.Contents[0].Key)
+		}
+	})
+
+	t.Run("CommonPrefixesCountTowardMaxKeys", func(t *testing.T) {
+		result := list("delimiter=/&max-keys=2")
+		if result.KeyCount != 2 || !result.IsTruncated {
+			t.Errorf("Expected a truncated page of 2 entries, got %+v", result)
+		}
+		if len(result.Contents) != 2 || len(result.CommonPrefixes) != 0 {
+			t.Errorf("Expected a.txt and b.txt on the first page, got %+v", result)
+		}
+
+		next := list("delimiter=/&max-keys=2&continuation-token=" + result.NextContinuationToken)
+		if next.IsTruncated || len(next.CommonPrefixes) != 1 || next.CommonPrefixes[0].Prefix != "dir/" {
+			t.Errorf("Expected dir/ on the last page, got %+v", next)
+		}
+	})
+}
+
 func TestCopyObject(t *testing.T) {
 	handler, router := setupTestHandler(t)
 
@@ -337,6 +526,49 @@ func TestCopyObject(t *testing.T) {
 	if !bytes.Equal(w.Body.Bytes(), content) {
 		t.Errorf("Copied content mismatch. Got %s, want %s", w.Body.Bytes(), content)
 	}
+
+	t.Run("EncodedSource", func(t *testing.T) {
+		_, _ = handler.storage.PutObjectWithMetadata(srcBucket, "dir/a file+1.txt", bytes.NewReader(content), int64(len(content)), &storage.ObjectMetadata{
+			ContentType: "text/plain",
+			Metadata:    map[string]string{"owner": "me"},
+		})
+
+		req := httptest.NewRequest("PUT", "/"+dstBucket+"/encoded.txt", nil)
+		req.Header.Set("x-amz-copy-source", srcBucket+"/dir/a%20file%2B1.txt")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var result struct {
+			LastModified time.Time `xml:"LastModified"`
+			ETag         string    `xml:"ETag"`
+		}
+		if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to parse CopyObjectResult: %v", err)
+		}
+		meta, err := handler.storage.GetObjectMetadata(dstBucket, "encoded.txt")
+		if err != nil {
+			t.Fatalf("Failed to get copied object: %v", err)
+		}
+		if !result.LastModified.Equal(meta.LastModified) || result.ETag != meta.ETag {
+			t.Errorf("Expected result %s %s, got %s %s", meta.LastModified, meta.ETag, result.LastModified, result.ETag)
+		}
+		if meta.ContentType != "text/plain" || meta.Metadata["owner"] != "me" {
+			t.Errorf("Expected source metadata to be copied, got %+v", meta)
+		}
+	})
+
+	t.Run("InvalidEncoding", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+dstBucket+"/bad.txt", nil)
+		req.Header.Set("x-amz-copy-source", srcBucket+"/bad%zz.txt")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "InvalidArgument") {
+			t.Errorf("Expected InvalidArgument, got %d: %s", w.Code, w.Body.String())
+		}
+	})
 }
 
 func TestMultipartUpload(t *testing.T) {
@@ -380,8 +612,8 @@ func TestMultipartUpload(t *testing.T) {
 			number  int
 			content string
 		}{
-			{1, "Part 1 "},
-			{2, "Part 2 "},
+			{1, strings.Repeat("1", storage.MinPartSize)},
+			{2, strings.Repeat("2", storage.MinPartSize)},
 			{3, "Part 3"},
 		}
 
@@ -434,6 +666,9 @@ func TestMultipartUpload(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 		}
+		if !strings.Contains(w.Body.String(), "-3&#34;</ETag>") {
+			t.Errorf("Expected a multipart ETag, got %s", w.Body.String())
+		}
 
 		// Verify the assembled object
 		req = httptest.NewRequest("GET", "/"+bucket+"/"+key, nil)
@@ -444,9 +679,9 @@ func TestMultipartUpload(t *testing.T) {
 			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 		}
 
-		expected := "Part 1 Part 2 Part 3"
+		expected := strings.Repeat("1", storage.MinPartSize) + strings.Repeat("2", storage.MinPartSize) + "Part 3"
 		if w.Body.String() != expected {
-			t.Errorf("Multipart content mismatch. Got %s, want %s", w.Body.String(), expected)
+			t.Errorf("Multipart content mismatch. Got %d bytes, want %d", w.Body.Len(), len(expected))
 		}
 	})
 }
@@ -586,3 +821,1642 @@ func TestImplicitBucketCreation(t *testing.T) {
 		}
 	})
 }
+
+func TestGetObjectRange(t *testing.T) {
+	handler, router := setupTestHandler(t)
+
+	bucket := "range-bucket"
+	key := "range.txt"
+	content := []byte("0123456789")
+
+	_, _ = handler.storage.CreateBucket(bucket)
+	etag, _ := handler.storage.PutObject(bucket, key, bytes.NewReader(content), int64(len(content)), "text/plain")
+	meta, _ := handler.storage.GetObjectMetadata(bucket, key)
+	lastModified := meta.LastModified.UTC().Format(http.TimeFormat)
+	later := meta.LastModified.Add(time.Hour).UTC().Format(http.TimeFormat)
+	earlier := meta.LastModified.Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name         string
+		rangeHeader  string
+		ifRange      string
+		wantStatus   int
+		wantBody     string
+		wantRange    string
+		wantRangeSet bool
+	}{
+		{"SingleRange", "bytes=2-5", "", http.StatusPartialContent, "2345", "bytes 2-5/10", true},
+		{"OpenEndedRange", "bytes=7-", "", http.StatusPartialContent, "789", "bytes 7-9/10", true},
+		{"SuffixRange", "bytes=-3", "", http.StatusPartialContent, "789", "bytes 7-9/10", true},
+		{"EndBeyondSize", "bytes=8-100", "", http.StatusPartialContent, "89", "bytes 8-9/10", true},
+		{"Unsatisfiable", "bytes=10-20", "", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10", true},
+		{"MultipleRangesIgnored", "bytes=0-1,3-4", "", http.StatusOK, "0123456789", "", false},
+		{"IfRangeMatchingETag", "bytes=0-0", etag, http.StatusPartialContent, "0", "bytes 0-0/10", true},
+		{"IfRangeStaleETag", "bytes=0-0", "\"stale\"", http.StatusOK, "0123456789", "", false},
+		{"IfRangeMatchingDate", "bytes=0-0", lastModified, http.StatusPartialContent, "0", "bytes 0-0/10", true},
+		{"IfRangeLaterDate", "bytes=0-0", later, http.StatusOK, "0123456789", "", false},
+		{"IfRangeEarlierDate", "bytes=0-0", earlier, http.StatusOK, "0123456789", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/"+bucket+"/"+key, nil)
+			req.Header.Set("Range", tt.rangeHeader)
+			if tt.ifRange != "" {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if w.Header().Get("Accept-Ranges") != "bytes" && w.Code != http.StatusRequestedRangeNotSatisfiable {
+				t.Error("Expected Accept-Ranges: bytes")
+			}
+			if got := w.Header().Get("Content-Range"); tt.wantRangeSet && got != tt.wantRange {
+				t.Errorf("Expected Content-Range %q, got %q", tt.wantRange, got)
+			}
+			if tt.wantStatus == http.StatusRequestedRangeNotSatisfiable {
+				if !strings.Contains(w.Body.String(), "InvalidRange") {
+					t.Errorf("Expected InvalidRange error, got %s", w.Body.String())
+				}
+				return
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestKeyLocks(t *testing.T) {
+	var locks keyLocks
+
+	unlock := locks.lock("tenant-a", "bucket", "key")
+
+	// Other tenants writing the same bucket and key are not blocked
+	done := make(chan struct{})
+	go func() {
+		locks.lock("tenant-b", "bucket", "key")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the lock of another tenant to be independent")
+	}
+
+	// The same tenant waits for the lock
+	acquired := make(chan func())
+	go func() { acquired <- locks.lock("tenant-a", "bucket", "key") }()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second writer to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	(<-acquired)()
+
+	// Released locks are dropped
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected no locks to be kept, got %d", len(locks.locks))
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	handler, router := setupTestHandler(t)
+
+	bucket := "conditional-bucket"
+	key := "object.txt"
+	content := []byte("conditional")
+
+	_, _ = handler.storage.CreateBucket(bucket)
+	etag, _ := handler.storage.PutObject(bucket, key, bytes.NewReader(content), int64(len(content)), "text/plain")
+	meta, _ := handler.storage.GetObjectMetadata(bucket, key)
+	past := meta.LastModified.Add(-time.Hour).Format(http.TimeFormat)
+	future := meta.LastModified.Add(time.Hour).Format(http.TimeFormat)
+
+	readTests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"IfMatchSuccess", "GET", map[string]string{"If-Match": etag}, http.StatusOK},
+		{"IfMatchFailure", "GET", map[string]string{"If-Match": "\"other\""}, http.StatusPreconditionFailed},
+		{"IfMatchWildcard", "HEAD", map[string]string{"If-Match": "*"}, http.StatusOK},
+		{"IfNoneMatchHit", "GET", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"IfNoneMatchMiss", "GET", map[string]string{"If-None-Match": "\"other\""}, http.StatusOK},
+		{"IfModifiedSinceNotModified", "GET", map[string]string{"If-Modified-Since": future}, http.StatusNotModified},
+		{"IfModifiedSinceModified", "HEAD", map[string]string{"If-Modified-Since": past}, http.StatusOK},
+		{"IfUnmodifiedSinceFailure", "GET", map[string]string{"If-Unmodified-Since": past}, http.StatusPreconditionFailed},
+		{"IfMatchOverridesIfUnmodifiedSince", "GET", map[string]string{"If-Match": etag, "If-Unmodified-Since": past}, http.StatusOK},
+	}
+
+	for _, tt := range readTests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/"+bucket+"/"+key, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.method == "GET" && w.Code == http.StatusPreconditionFailed && !strings.Contains(w.Body.String(), "PreconditionFailed") {
+				t.Errorf("Expected PreconditionFailed error, got %s", w.Body.String())
+			}
+		})
+	}
+
+	t.Run("PutIfNoneMatchExisting", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/"+key, bytes.NewReader([]byte("overwrite")))
+		req.Header.Set("If-None-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+		}
+	})
+
+	t.Run("PutIfNoneMatchNew", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/new.txt", bytes.NewReader([]byte("new")))
+		req.Header.Set("If-None-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("PutIfMatchStale", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/"+key, bytes.NewReader([]byte("overwrite")))
+		req.Header.Set("If-Match", "\"stale\"")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+		}
+	})
+
+	t.Run("CopySourceIfMatchFailure", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/copy.txt", nil)
+		req.Header.Set("x-amz-copy-source", "/"+bucket+"/"+key)
+		req.Header.Set("x-amz-copy-source-if-match", "\"other\"")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+		}
+	})
+
+	t.Run("CopySourceIfNoneMatchSuccess", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/copy.txt", nil)
+		req.Header.Set("x-amz-copy-source", "/"+bucket+"/"+key)
+		req.Header.Set("x-amz-copy-source-if-none-match", "\"other\"")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+}
+
+func TestObjectUserMetadata(t *testing.T) {
+	handler, router := setupTestHandler(t)
+
+	bucket := "metadata-bucket"
+	key := "object.txt"
+	_, _ = handler.storage.CreateBucket(bucket)
+
+	req := httptest.NewRequest("PUT", "/"+bucket+"/"+key, bytes.NewReader([]byte("metadata")))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Content-Language", "en")
+	req.Header.Set("x-amz-meta-Project", "s3pit")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	t.Run("HeadReturnsMetadata", func(t *testing.T) {
+		req := httptest.NewRequest("HEAD", "/"+bucket+"/"+key, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("x-amz-meta-project"); got != "s3pit" {
+			t.Errorf("Expected x-amz-meta-project s3pit, got %q", got)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("Expected Cache-Control no-cache, got %q", got)
+		}
+		if got := w.Header().Get("Content-Language"); got != "en" {
+			t.Errorf("Expected Content-Language en, got %q", got)
+		}
+	})
+
+	t.Run("CopyKeepsMetadata", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/copy.txt", nil)
+		req.Header.Set("x-amz-copy-source", "/"+bucket+"/"+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		meta, _ := handler.storage.GetObjectMetadata(bucket, "copy.txt")
+		if meta.Metadata["project"] != "s3pit" || meta.ContentType != "text/plain" {
+			t.Errorf("Expected source metadata to be copied, got %+v", meta)
+		}
+	})
+
+	t.Run("CopyReplacesMetadata", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/replaced.txt", nil)
+		req.Header.Set("x-amz-copy-source", "/"+bucket+"/"+key)
+		req.Header.Set("x-amz-metadata-directive", "REPLACE")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-amz-meta-stage", "replaced")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		meta, _ := handler.storage.GetObjectMetadata(bucket, "replaced.txt")
+		if meta.Metadata["stage"] != "replaced" || meta.Metadata["project"] != "" {
+			t.Errorf("Expected metadata to be replaced, got %v", meta.Metadata)
+		}
+		if meta.ContentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %s", meta.ContentType)
+		}
+	})
+
+	t.Run("CopyToSelfRequiresReplace", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/"+bucket+"/"+key, nil)
+		req.Header.Set("x-amz-copy-source", "/"+bucket+"/"+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestObjectVersioning(t *testing.T) {
+	_, router := setupTestHandler(t)
+
+	do := func(method, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	do("PUT", "/versioned", "", nil)
+
+	w := do("PUT", "/versioned?versioning", `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PutBucketVersioning: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/versioned?versioning", "", nil)
+	var config VersioningConfiguration
+	if err := xml.Unmarshal(w.Body.Bytes(), &config); err != nil || config.Status != "Enabled" {
+		t.Fatalf("GetBucketVersioning: expected Enabled, got %q (%v)", config.Status, err)
+	}
+
+	w = do("PUT", "/versioned/doc.txt", "first", nil)
+	firstVersion := w.Header().Get("x-amz-version-id")
+	w = do("PUT", "/versioned/doc.txt", "second", nil)
+	secondVersion := w.Header().Get("x-amz-version-id")
+	if firstVersion == "" || secondVersion == "" || firstVersion == secondVersion {
+		t.Fatalf("Expected distinct version IDs, got %q and %q", firstVersion, secondVersion)
+	}
+
+	t.Run("GetSpecificVersion", func(t *testing.T) {
+		w := do("GET", "/versioned/doc.txt?versionId="+firstVersion, "", nil)
+		if w.Code != http.StatusOK || w.Body.String() != "first" {
+			t.Errorf("Expected first version, got %d %q", w.Code, w.Body.String())
+		}
+		if w.Header().Get("x-amz-version-id") != firstVersion {
+			t.Errorf("Expected x-amz-version-id %q, got %q", firstVersion, w.Header().Get("x-amz-version-id"))
+		}
+
+		w = do("HEAD", "/versioned/doc.txt?versionId=missing", "", nil)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for unknown version, got %d", w.Code)
+		}
+	})
+
+	t.Run("CopyFromVersion", func(t *testing.T) {
+		w := do("PUT", "/versioned/restored.txt", "", map[string]string{
+			"x-amz-copy-source": "/versioned/doc.txt?versionId=" + firstVersion,
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if w.Header().Get("x-amz-copy-source-version-id") != firstVersion {
+			t.Errorf("Expected copy source version %q, got %q", firstVersion, w.Header().Get("x-amz-copy-source-version-id"))
+		}
+
+		w = do("GET", "/versioned/restored.txt", "", nil)
+		if w.Body.String() != "first" {
+			t.Errorf("Expected copied content %q, got %q", "first", w.Body.String())
+		}
+	})
+
+	t.Run("DeleteMarker", func(t *testing.T) {
+		w := do("DELETE", "/versioned/doc.txt", "", nil)
+		if w.Code != http.StatusNoContent || w.Header().Get("x-amz-delete-marker") != "true" {
+			t.Fatalf("Expected a delete marker, got %d %v", w.Code, w.Header())
+		}
+		markerVersion := w.Header().Get("x-amz-version-id")
+
+		w = do("GET", "/versioned/doc.txt", "", nil)
+		if w.Code != http.StatusNotFound || w.Header().Get("x-amz-delete-marker") != "true" {
+			t.Errorf("Expected 404 with x-amz-delete-marker, got %d %v", w.Code, w.Header())
+		}
+
+		w = do("GET", "/versioned/doc.txt?versionId="+markerVersion, "", nil)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405 for a delete marker version, got %d", w.Code)
+		}
+
+		w = do("GET", "/versioned?versions&prefix=doc", "", nil)
+		var list ListVersionsResponse
+		if err := xml.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("Failed to parse ListVersionsResult: %v", err)
+		}
+		if len(list.Versions) != 2 || len(list.DeleteMarkers) != 1 || !list.DeleteMarkers[0].IsLatest {
+			t.Errorf("Expected 2 versions and a latest delete marker, got %+v", list)
+		}
+
+		// Deleting the marker brings the object back
+		w = do("DELETE", "/versioned/doc.txt?versionId="+markerVersion, "", nil)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", w.Code)
+		}
+		w = do("GET", "/versioned/doc.txt", "", nil)
+		if w.Body.String() != "second" {
+			t.Errorf("Expected second version to be restored, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("ListVersionsPagination", func(t *testing.T) {
+		w := do("GET", "/versioned?versions&prefix=doc&max-keys=1", "", nil)
+		var page ListVersionsResponse
+		_ = xml.Unmarshal(w.Body.Bytes(), &page)
+		if !page.IsTruncated || len(page.Versions) != 1 || page.NextVersionIdMarker != secondVersion {
+			t.Fatalf("Unexpected first page: %+v", page)
+		}
+
+		w = do("GET", "/versioned?versions&prefix=doc&max-keys=1&key-marker="+page.NextKeyMarker+"&version-id-marker="+page.NextVersionIdMarker, "", nil)
+		page = ListVersionsResponse{}
+		_ = xml.Unmarshal(w.Body.Bytes(), &page)
+		if page.IsTruncated || len(page.Versions) != 1 || page.Versions[0].VersionId != firstVersion {
+			t.Errorf("Unexpected second page: %+v", page)
+		}
+	})
+}
+
+func TestObjectBodyVerification(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	handler.config.MaxObjectSize = 16
+	_, _ = handler.storage.CreateBucket("verify")
+
+	content := "verified content"
+	md5Sum := md5.Sum([]byte(content))
+	sha256Sum := sha256.Sum256([]byte(content))
+
+	put := func(key, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/verify/"+key, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		headers  map[string]string
+		wantCode int
+		wantErr  string
+	}{
+		{"ValidDigests", content, map[string]string{
+			"Content-MD5":          base64.StdEncoding.EncodeToString(md5Sum[:]),
+			"x-amz-content-sha256": hex.EncodeToString(sha256Sum[:]),
+		}, http.StatusOK, ""},
+		{"UnsignedPayload", content, map[string]string{"x-amz-content-sha256": "UNSIGNED-PAYLOAD"}, http.StatusOK, ""},
+		{"BadDigest", "tampered content", map[string]string{
+			"Content-MD5": base64.StdEncoding.EncodeToString(md5Sum[:]),
+		}, http.StatusBadRequest, "BadDigest"},
+		{"InvalidDigest", content, map[string]string{"Content-MD5": "not-base64"}, http.StatusBadRequest, "InvalidDigest"},
+		{"SHA256Mismatch", "tampered content", map[string]string{
+			"x-amz-content-sha256": hex.EncodeToString(sha256Sum[:]),
+		}, http.StatusBadRequest, "XAmzContentSHA256Mismatch"},
+		{"EntityTooLarge", content + "!", nil, http.StatusBadRequest, "EntityTooLarge"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := put(tt.name, tt.body, tt.headers)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantErr == "" {
+				return
+			}
+			if !strings.Contains(w.Body.String(), "<Code>"+tt.wantErr+"</Code>") {
+				t.Errorf("Expected %s error, got %s", tt.wantErr, w.Body.String())
+			}
+			// A rejected body must not be stored
+			if _, err := handler.storage.GetObjectMetadata("verify", tt.name); err != storage.ErrObjectNotFound {
+				t.Errorf("Expected rejected object not to be stored, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAwsChunkedUpload(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("chunked")
+
+	body := "5\r\nhello\r\n7\r\n chunks\r\n0\r\nx-amz-checksum-crc32:tX2eMA==\r\n\r\n"
+
+	req := httptest.NewRequest("PUT", "/chunked/object.txt", strings.NewReader(body))
+	req.Header.Set("Content-Encoding", "aws-chunked")
+	req.Header.Set("x-amz-content-sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	req.Header.Set("x-amz-decoded-content-length", "12")
+	req.Header.Set("x-amz-trailer", "x-amz-checksum-crc32")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	reader, meta, err := handler.storage.GetObject("chunked", "object.txt")
+	if err != nil {
+		t.Fatalf("Failed to get object: %v", err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	if string(data) != "hello chunks" {
+		t.Errorf("Expected decoded content, got %q", data)
+	}
+	if meta.ContentEncoding != "" {
+		t.Errorf("Expected aws-chunked not to be stored as Content-Encoding, got %q", meta.ContentEncoding)
+	}
+
+	// A body shorter than x-amz-decoded-content-length is rejected
+	req = httptest.NewRequest("PUT", "/chunked/short.txt", strings.NewReader(body))
+	req.Header.Set("x-amz-content-sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	req.Header.Set("x-amz-decoded-content-length", "20")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "IncompleteBody") {
+		t.Errorf("Expected IncompleteBody, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The decoded length is required
+	req = httptest.NewRequest("PUT", "/chunked/missing.txt", strings.NewReader(body))
+	req.Header.Set("x-amz-content-sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MissingContentLength") {
+		t.Errorf("Expected MissingContentLength, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFlexibleChecksums(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("checksums")
+
+	crc32Of := func(data string) string {
+		h := storage.NewChecksumHash(storage.ChecksumCRC32)
+		h.Write([]byte(data))
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	content := "checksummed content"
+
+	t.Run("PutObjectEchoesChecksum", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/checksums/object.txt", strings.NewReader(content))
+		req.Header.Set("x-amz-checksum-crc32", crc32Of(content))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("x-amz-checksum-crc32"); got != crc32Of(content) {
+			t.Errorf("Expected echoed checksum %s, got %s", crc32Of(content), got)
+		}
+	})
+
+	t.Run("ChecksumModeEnabled", func(t *testing.T) {
+		req := httptest.NewRequest("HEAD", "/checksums/object.txt", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if got := w.Header().Get("x-amz-checksum-crc32"); got != "" {
+			t.Errorf("Expected no checksum without x-amz-checksum-mode, got %s", got)
+		}
+
+		req = httptest.NewRequest("GET", "/checksums/object.txt", nil)
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if got := w.Header().Get("x-amz-checksum-crc32"); got != crc32Of(content) {
+			t.Errorf("Expected checksum %s, got %s", crc32Of(content), got)
+		}
+		if got := w.Header().Get("x-amz-checksum-type"); got != "FULL_OBJECT" {
+			t.Errorf("Expected FULL_OBJECT checksum type, got %s", got)
+		}
+	})
+
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/checksums/bad.txt", strings.NewReader("tampered"))
+		req.Header.Set("x-amz-checksum-crc32", crc32Of(content))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<Code>BadDigest</Code>") {
+			t.Errorf("Expected BadDigest, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("TrailingChecksum", func(t *testing.T) {
+		body := fmt.Sprintf("%x\r\n%s\r\n0\r\nx-amz-checksum-crc32:%s\r\n\r\n", len(content), content, crc32Of("other"))
+		req := httptest.NewRequest("PUT", "/checksums/trailer.txt", strings.NewReader(body))
+		req.Header.Set("x-amz-content-sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+		req.Header.Set("x-amz-decoded-content-length", fmt.Sprintf("%d", len(content)))
+		req.Header.Set("x-amz-trailer", "x-amz-checksum-crc32")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<Code>BadDigest</Code>") {
+			t.Errorf("Expected BadDigest for a mismatched trailer, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("CompositeMultipartChecksum", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/checksums/multipart.txt?uploads", nil)
+		req.Header.Set("x-amz-checksum-algorithm", "CRC32")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if got := w.Header().Get("x-amz-checksum-type"); got != "COMPOSITE" {
+			t.Fatalf("Expected COMPOSITE checksum type, got %q: %s", got, w.Body.String())
+		}
+
+		var initResult struct {
+			UploadId string `xml:"UploadId"`
+		}
+		_ = xml.Unmarshal(w.Body.Bytes(), &initResult)
+
+		var partChecksums []byte
+		var completeParts string
+		for i, part := range []string{strings.Repeat("first part ", storage.MinPartSize/10), "second part"} {
+			req = httptest.NewRequest("PUT", fmt.Sprintf("/checksums/multipart.txt?uploadId=%s&partNumber=%d", initResult.UploadId, i+1), strings.NewReader(part))
+			req.Header.Set("x-amz-checksum-crc32", crc32Of(part))
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("UploadPart failed: %d %s", w.Code, w.Body.String())
+			}
+			raw, _ := base64.StdEncoding.DecodeString(crc32Of(part))
+			partChecksums = append(partChecksums, raw...)
+			completeParts += fmt.Sprintf("<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", i+1, w.Header().Get("ETag"))
+		}
+
+		complete := "<CompleteMultipartUpload>" + completeParts + "</CompleteMultipartUpload>"
+		req = httptest.NewRequest("POST", "/checksums/multipart.txt?uploadId="+initResult.UploadId, strings.NewReader(complete))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		want := crc32Of(string(partChecksums)) + "-2"
+		if !strings.Contains(w.Body.String(), "<ChecksumCRC32>"+want+"</ChecksumCRC32>") ||
+			!strings.Contains(w.Body.String(), "<ChecksumType>COMPOSITE</ChecksumType>") {
+			t.Errorf("Expected composite checksum %s, got %s", want, w.Body.String())
+		}
+	})
+
+	t.Run("UnsupportedAlgorithm", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/checksums/invalid.txt?uploads", nil)
+		req.Header.Set("x-amz-checksum-algorithm", "SHA1")
+		req.Header.Set("x-amz-checksum-type", "FULL_OBJECT")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "InvalidRequest") {
+			t.Errorf("Expected InvalidRequest, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestGetObjectAttributes(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("attributes")
+
+	uploadId, _ := handler.storage.InitiateMultipartUploadWithMetadata("attributes", "multipart.bin", &storage.ObjectMetadata{
+		ChecksumAlgorithm: storage.ChecksumSHA256,
+		ChecksumType:      storage.ChecksumTypeComposite,
+	})
+	first := strings.Repeat("1", storage.MinPartSize)
+	etag1, _ := handler.storage.UploadPart("attributes", "multipart.bin", uploadId, 1, strings.NewReader(first), int64(len(first)))
+	etag2, _ := handler.storage.UploadPart("attributes", "multipart.bin", uploadId, 2, strings.NewReader("second"), 6)
+	etag, err := handler.storage.CompleteMultipartUpload("attributes", "multipart.bin", uploadId,
+		[]storage.CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}})
+	if err != nil {
+		t.Fatalf("Failed to complete upload: %v", err)
+	}
+
+	getAttributes := func(key string, headers map[string]string) (*httptest.ResponseRecorder, GetObjectAttributesResponse) {
+		req := httptest.NewRequest("GET", "/attributes/"+key+"?attributes", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response GetObjectAttributesResponse
+		_ = xml.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("AllAttributes", func(t *testing.T) {
+		w, response := getAttributes("multipart.bin", map[string]string{
+			"x-amz-object-attributes": "ETag,Checksum,ObjectParts,StorageClass,ObjectSize",
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if response.ETag != storage.StripETagQuotes(etag) {
+			t.Errorf("Expected ETag %s, got %s", etag, response.ETag)
+		}
+		if response.ObjectSize == nil || *response.ObjectSize != storage.MinPartSize+6 {
+			t.Errorf("Expected object size %d, got %v", storage.MinPartSize+6, response.ObjectSize)
+		}
+		if response.StorageClass != "STANDARD" {
+			t.Errorf("Expected STANDARD storage class, got %s", response.StorageClass)
+		}
+		if response.Checksum == nil || !strings.HasSuffix(response.Checksum.ChecksumSHA256, "-2") ||
+			response.Checksum.ChecksumType != "COMPOSITE" {
+			t.Errorf("Expected composite SHA256 checksum, got %+v", response.Checksum)
+		}
+		parts := response.ObjectParts
+		if parts == nil || parts.TotalPartsCount != 2 || len(parts.Parts) != 2 {
+			t.Fatalf("Expected two parts, got %+v", parts)
+		}
+		if parts.Parts[1].PartNumber != 2 || parts.Parts[1].Size != 6 || parts.Parts[1].ChecksumSHA256 == "" {
+			t.Errorf("Unexpected second part %+v", parts.Parts[1])
+		}
+	})
+
+	t.Run("PartPagination", func(t *testing.T) {
+		_, response := getAttributes("multipart.bin", map[string]string{
+			"x-amz-object-attributes": "ObjectParts",
+			"x-amz-max-parts":         "1",
+		})
+		parts := response.ObjectParts
+		if parts == nil || len(parts.Parts) != 1 || !parts.IsTruncated || parts.NextPartNumberMarker != 1 {
+			t.Fatalf("Expected a truncated first page, got %+v", parts)
+		}
+		if response.ETag != "" || response.ObjectSize != nil {
+			t.Errorf("Expected only the requested attributes, got %+v", response)
+		}
+
+		_, response = getAttributes("multipart.bin", map[string]string{
+			"x-amz-object-attributes":  "ObjectParts",
+			"x-amz-part-number-marker": "1",
+		})
+		parts = response.ObjectParts
+		if parts == nil || len(parts.Parts) != 1 || parts.Parts[0].PartNumber != 2 || parts.IsTruncated {
+			t.Errorf("Expected the second part only, got %+v", parts)
+		}
+	})
+
+	t.Run("MissingAttributesHeader", func(t *testing.T) {
+		w, _ := getAttributes("multipart.bin", nil)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "InvalidArgument") {
+			t.Errorf("Expected InvalidArgument, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("NoSuchKey", func(t *testing.T) {
+		w, _ := getAttributes("missing.bin", map[string]string{"x-amz-object-attributes": "ETag"})
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestListMultipartUploads(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("uploads")
+
+	var bUploads []string
+	for _, key := range []string{"c", "a/1", "b", "a/2", "b"} {
+		uploadId, err := handler.storage.InitiateMultipartUpload("uploads", key)
+		if err != nil {
+			t.Fatalf("Failed to initiate upload: %v", err)
+		}
+		if key == "b" {
+			bUploads = append(bUploads, uploadId)
+		}
+	}
+
+	listUploads := func(query string) ListMultipartUploadsResponse {
+		req := httptest.NewRequest("GET", "/uploads?uploads"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var response ListMultipartUploadsResponse
+		if err := xml.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response
+	}
+	keysOf := func(response ListMultipartUploadsResponse) []string {
+		var keys []string
+		for _, upload := range response.Uploads {
+			keys = append(keys, upload.Key)
+		}
+		for _, prefix := range response.CommonPrefixes {
+			keys = append(keys, prefix.Prefix)
+		}
+		return keys
+	}
+
+	t.Run("AllUploads", func(t *testing.T) {
+		response := listUploads("")
+		if got := strings.Join(keysOf(response), ","); got != "a/1,a/2,b,b,c" {
+			t.Errorf("Expected uploads ordered by key, got %s", got)
+		}
+		if response.Uploads[2].UploadId != bUploads[0] || response.Uploads[3].UploadId != bUploads[1] {
+			t.Errorf("Expected uploads of one key ordered by initiation")
+		}
+		if response.IsTruncated {
+			t.Errorf("Expected a complete listing")
+		}
+	})
+
+	t.Run("PrefixAndDelimiter", func(t *testing.T) {
+		if got := strings.Join(keysOf(listUploads("&delimiter=/")), ","); got != "b,b,c,a/" {
+			t.Errorf("Expected a/ to be grouped, got %s", got)
+		}
+		if got := strings.Join(keysOf(listUploads("&prefix=a/")), ","); got != "a/1,a/2" {
+			t.Errorf("Expected only a/ uploads, got %s", got)
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		response := listUploads("&max-uploads=3")
+		if !response.IsTruncated || response.NextKeyMarker != "b" || response.NextUploadIdMarker != bUploads[0] {
+			t.Fatalf("Expected truncation after the first b upload, got %+v", response)
+		}
+
+		response = listUploads("&key-marker=b&upload-id-marker=" + url.QueryEscape(bUploads[0]))
+		if got := strings.Join(keysOf(response), ","); got != "b,c" || response.Uploads[0].UploadId != bUploads[1] {
+			t.Errorf("Expected the second b upload and c, got %s", got)
+		}
+
+		response = listUploads("&key-marker=b")
+		if got := strings.Join(keysOf(response), ","); got != "c" {
+			t.Errorf("Expected uploads after b, got %s", got)
+		}
+	})
+
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/missing?uploads", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestListParts(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("parts")
+
+	uploadId, _ := handler.storage.InitiateMultipartUploadWithMetadata("parts", "object.bin", &storage.ObjectMetadata{
+		ChecksumAlgorithm: storage.ChecksumCRC32,
+		ChecksumType:      storage.ChecksumTypeComposite,
+	})
+	for _, partNumber := range []int{3, 1, 2} {
+		data := strings.Repeat("x", partNumber)
+		if _, err := handler.storage.UploadPart("parts", "object.bin", uploadId, partNumber, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Failed to upload part: %v", err)
+		}
+	}
+
+	listParts := func(query string) (*httptest.ResponseRecorder, ListPartsResponse) {
+		req := httptest.NewRequest("GET", "/parts/object.bin?uploadId="+url.QueryEscape(uploadId)+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response ListPartsResponse
+		_ = xml.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("AllParts", func(t *testing.T) {
+		w, response := listParts("")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if len(response.Parts) != 3 || response.IsTruncated {
+			t.Fatalf("Expected three parts, got %+v", response.Parts)
+		}
+		for i, part := range response.Parts {
+			if part.PartNumber != i+1 || part.Size != int64(i+1) || part.ETag == "" || part.ChecksumCRC32 == "" {
+				t.Errorf("Unexpected part %+v", part)
+			}
+		}
+		if response.ChecksumAlgorithm != storage.ChecksumCRC32 || response.ChecksumType != storage.ChecksumTypeComposite {
+			t.Errorf("Expected the upload's checksum settings, got %s %s", response.ChecksumAlgorithm, response.ChecksumType)
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		_, response := listParts("&max-parts=2")
+		if len(response.Parts) != 2 || !response.IsTruncated || response.NextPartNumberMarker != 2 {
+			t.Fatalf("Expected a truncated page of two parts, got %+v", response)
+		}
+		_, response = listParts("&part-number-marker=2")
+		if len(response.Parts) != 1 || response.Parts[0].PartNumber != 3 || response.IsTruncated {
+			t.Errorf("Expected the last part only, got %+v", response.Parts)
+		}
+	})
+
+	t.Run("NoSuchUpload", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/parts/object.bin?uploadId=missing", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchUpload") {
+			t.Errorf("Expected NoSuchUpload, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestCompleteMultipartUploadValidation(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("complete")
+
+	// newUpload uploads a full-size first part and a small second part
+	newUpload := func(key string) (string, []string) {
+		uploadId, _ := handler.storage.InitiateMultipartUpload("complete", key)
+		var etags []string
+		for i, size := range []int{storage.MinPartSize, 10} {
+			data := strings.Repeat("x", size)
+			etag, err := handler.storage.UploadPart("complete", key, uploadId, i+1, strings.NewReader(data), int64(size))
+			if err != nil {
+				t.Fatalf("Failed to upload part: %v", err)
+			}
+			etags = append(etags, etag)
+		}
+		return uploadId, etags
+	}
+	complete := func(key, uploadId string, parts ...string) *httptest.ResponseRecorder {
+		body := "<CompleteMultipartUpload>"
+		for i := 0; i+1 < len(parts); i += 2 {
+			body += "<Part><PartNumber>" + parts[i] + "</PartNumber><ETag>" + parts[i+1] + "</ETag></Part>"
+		}
+		body += "</CompleteMultipartUpload>"
+		req := httptest.NewRequest("POST", "/complete/"+key+"?uploadId="+url.QueryEscape(uploadId), strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	expectError := func(t *testing.T, w *httptest.ResponseRecorder, code string) {
+		t.Helper()
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<Code>"+code+"</Code>") {
+			t.Errorf("Expected %s, got %d: %s", code, w.Code, w.Body.String())
+		}
+	}
+
+	t.Run("MultipartETag", func(t *testing.T) {
+		uploadId, etags := newUpload("ok.bin")
+		w := complete("ok.bin", uploadId, "1", storage.StripETagQuotes(etags[0]), "2", etags[1])
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		digests := md5.New()
+		for _, etag := range etags {
+			digest, _ := hex.DecodeString(storage.StripETagQuotes(etag))
+			digests.Write(digest)
+		}
+		want := fmt.Sprintf("\"%s-2\"", hex.EncodeToString(digests.Sum(nil)))
+		meta, _ := handler.storage.GetObjectMetadata("complete", "ok.bin")
+		if meta.ETag != want {
+			t.Errorf("Expected ETag %s, got %s", want, meta.ETag)
+		}
+	})
+
+	t.Run("WrongETag", func(t *testing.T) {
+		uploadId, etags := newUpload("wrong-etag.bin")
+		expectError(t, complete("wrong-etag.bin", uploadId, "1", etags[1], "2", etags[1]), "InvalidPart")
+	})
+
+	t.Run("MissingPart", func(t *testing.T) {
+		uploadId, etags := newUpload("missing.bin")
+		expectError(t, complete("missing.bin", uploadId, "1", etags[0], "3", etags[1]), "InvalidPart")
+	})
+
+	t.Run("PartOrder", func(t *testing.T) {
+		uploadId, etags := newUpload("order.bin")
+		expectError(t, complete("order.bin", uploadId, "2", etags[1], "1", etags[0]), "InvalidPartOrder")
+		expectError(t, complete("order.bin", uploadId, "1", etags[0], "1", etags[0]), "InvalidPartOrder")
+	})
+
+	t.Run("PartTooSmall", func(t *testing.T) {
+		uploadId, etags := newUpload("small.bin")
+		etag3, _ := handler.storage.UploadPart("complete", "small.bin", uploadId, 3, strings.NewReader("end"), 3)
+		expectError(t, complete("small.bin", uploadId, "1", etags[0], "2", etags[1], "3", etag3), "EntityTooSmall")
+
+		// The upload is still usable after a rejected completion
+		if w := complete("small.bin", uploadId, "1", etags[0], "3", etag3); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("NoParts", func(t *testing.T) {
+		uploadId, _ := newUpload("empty.bin")
+		expectError(t, complete("empty.bin", uploadId), "MalformedXML")
+	})
+}
+
+func TestUploadPartCopy(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("copy-parts")
+
+	source := strings.Repeat("0123456789", storage.MinPartSize/10+1)
+	_, _ = handler.storage.PutObject("copy-parts", "source.bin", strings.NewReader(source), int64(len(source)), "application/octet-stream")
+	_, _ = handler.storage.PutObject("copy-parts", "tail.txt", strings.NewReader("tail"), 4, "text/plain")
+	_, _ = handler.storage.PutObject("copy-parts", "dest.bin", strings.NewReader("original"), 8, "text/plain")
+
+	uploadId, _ := handler.storage.InitiateMultipartUpload("copy-parts", "dest.bin")
+	copyPart := func(partNumber, copySource, copyRange string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/copy-parts/dest.bin?partNumber="+partNumber+"&uploadId="+url.QueryEscape(uploadId), nil)
+		req.Header.Set("x-amz-copy-source", copySource)
+		if copyRange != "" {
+			req.Header.Set("x-amz-copy-source-range", copyRange)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var etags []string
+	for i, w := range []*httptest.ResponseRecorder{
+		copyPart("1", "/copy-parts/source.bin", fmt.Sprintf("bytes=0-%d", storage.MinPartSize-1)),
+		copyPart("2", "/copy-parts/tail.txt", ""),
+	} {
+		if w.Code != http.StatusOK {
+			t.Fatalf("UploadPartCopy %d failed: %d %s", i+1, w.Code, w.Body.String())
+		}
+		var result CopyPartResult
+		if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil || result.ETag == "" {
+			t.Fatalf("Invalid CopyPartResult: %v %s", err, w.Body.String())
+		}
+		etags = append(etags, result.ETag)
+	}
+
+	// Copying parts must not touch the destination object
+	reader, _, err := handler.storage.GetObject("copy-parts", "dest.bin")
+	if err != nil {
+		t.Fatalf("Failed to get destination: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "original" {
+		t.Errorf("Expected destination to be unchanged, got %d bytes", len(data))
+	}
+
+	parts, _ := handler.storage.ListParts("copy-parts", "dest.bin", uploadId)
+	if len(parts) != 2 || parts[0].Size != storage.MinPartSize || parts[1].Size != 4 {
+		t.Fatalf("Unexpected parts: %+v", parts)
+	}
+
+	body := "<CompleteMultipartUpload>" +
+		"<Part><PartNumber>1</PartNumber><ETag>" + etags[0] + "</ETag></Part>" +
+		"<Part><PartNumber>2</PartNumber><ETag>" + etags[1] + "</ETag></Part>" +
+		"</CompleteMultipartUpload>"
+	req := httptest.NewRequest("POST", "/copy-parts/dest.bin?uploadId="+url.QueryEscape(uploadId), strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("CompleteMultipartUpload failed: %d %s", w.Code, w.Body.String())
+	}
+
+	reader, _, err = handler.storage.GetObject("copy-parts", "dest.bin")
+	if err != nil {
+		t.Fatalf("Failed to get completed object: %v", err)
+	}
+	data, _ = io.ReadAll(reader)
+	reader.Close()
+	if string(data) != source[:storage.MinPartSize]+"tail" {
+		t.Errorf("Completed object has unexpected content (%d bytes)", len(data))
+	}
+
+	t.Run("InvalidRange", func(t *testing.T) {
+		uploadId, _ = handler.storage.InitiateMultipartUpload("copy-parts", "dest.bin")
+		for _, copyRange := range []string{"bytes=0-4", "bytes=2-", "bytes=-2", "bytes=3-1", "0-1"} {
+			w := copyPart("1", "/copy-parts/tail.txt", copyRange)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "InvalidArgument") {
+				t.Errorf("Range %q: expected InvalidArgument, got %d: %s", copyRange, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("MissingSource", func(t *testing.T) {
+		w := copyPart("1", "/copy-parts/missing.bin", "")
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchKey") {
+			t.Errorf("Expected NoSuchKey, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("NoSuchUpload", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/copy-parts/dest.bin?partNumber=1&uploadId=missing", nil)
+		req.Header.Set("x-amz-copy-source", "/copy-parts/tail.txt")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchUpload") {
+			t.Errorf("Expected NoSuchUpload, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("UploadOfAnotherKey", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/copy-parts/other.bin?partNumber=1&uploadId="+url.QueryEscape(uploadId), nil)
+		req.Header.Set("x-amz-copy-source", "/copy-parts/tail.txt")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchUpload") {
+			t.Errorf("Expected NoSuchUpload, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestObjectTagging(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("tags")
+
+	do := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	tagging := func(tags ...string) string {
+		body := "<Tagging><TagSet>"
+		for i := 0; i+1 < len(tags); i += 2 {
+			body += "<Tag><Key>" + tags[i] + "</Key><Value>" + tags[i+1] + "</Value></Tag>"
+		}
+		return body + "</TagSet></Tagging>"
+	}
+	getTags := func(t *testing.T, target string) map[string]string {
+		t.Helper()
+		w := do("GET", target, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GetObjectTagging failed: %d %s", w.Code, w.Body.String())
+		}
+		var result Tagging
+		if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to parse Tagging: %v", err)
+		}
+		tags := make(map[string]string)
+		for _, tag := range result.TagSet.Tags {
+			tags[tag.Key] = tag.Value
+		}
+		return tags
+	}
+
+	t.Run("HeaderOnPut", func(t *testing.T) {
+		w := do("PUT", "/tags/object.txt", "data", map[string]string{"x-amz-tagging": "project=s3pit&team=a%20b"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("PutObject failed: %d %s", w.Code, w.Body.String())
+		}
+		tags := getTags(t, "/tags/object.txt?tagging")
+		if len(tags) != 2 || tags["project"] != "s3pit" || tags["team"] != "a b" {
+			t.Errorf("Unexpected tags: %v", tags)
+		}
+
+		w = do("GET", "/tags/object.txt", "", nil)
+		if w.Header().Get("x-amz-tagging-count") != "2" {
+			t.Errorf("Expected x-amz-tagging-count 2, got %q", w.Header().Get("x-amz-tagging-count"))
+		}
+	})
+
+	t.Run("PutAndDelete", func(t *testing.T) {
+		w := do("PUT", "/tags/object.txt?tagging", tagging("env", "dev"), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("PutObjectTagging failed: %d %s", w.Code, w.Body.String())
+		}
+		if tags := getTags(t, "/tags/object.txt?tagging"); len(tags) != 1 || tags["env"] != "dev" {
+			t.Errorf("Expected the tag set to be replaced, got %v", tags)
+		}
+
+		w = do("DELETE", "/tags/object.txt?tagging", "", nil)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("DeleteObjectTagging failed: %d %s", w.Code, w.Body.String())
+		}
+		if tags := getTags(t, "/tags/object.txt?tagging"); len(tags) != 0 {
+			t.Errorf("Expected no tags, got %v", tags)
+		}
+		if _, err := handler.storage.GetObjectMetadata("tags", "object.txt"); err != nil {
+			t.Errorf("Expected the object to survive DeleteObjectTagging: %v", err)
+		}
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		var tooMany []string
+		for i := 0; i <= maxObjectTags; i++ {
+			tooMany = append(tooMany, fmt.Sprintf("k%d", i), "v")
+		}
+		cases := map[string]struct {
+			body string
+			code string
+		}{
+			"TooManyTags":   {tagging(tooMany...), "BadRequest"},
+			"LongKey":       {tagging(strings.Repeat("k", maxTagKeyLength+1), "v"), "InvalidTag"},
+			"LongValue":     {tagging("k", strings.Repeat("v", maxTagValueLength+1)), "InvalidTag"},
+			"DuplicateKeys": {tagging("k", "1", "k", "2"), "InvalidTag"},
+			"ReservedKey":   {tagging("aws:owner", "me"), "InvalidTag"},
+			"MalformedXML":  {"<Tagging>", "MalformedXML"},
+		}
+		for name, tc := range cases {
+			w := do("PUT", "/tags/object.txt?tagging", tc.body, nil)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "<Code>"+tc.code+"</Code>") {
+				t.Errorf("%s: expected %s, got %d: %s", name, tc.code, w.Code, w.Body.String())
+			}
+		}
+
+		// Keys and values at the limit are accepted, counted in characters
+		w := do("PUT", "/tags/object.txt?tagging", tagging(strings.Repeat("é", maxTagKeyLength), strings.Repeat("é", maxTagValueLength)), nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected tags at the limit to be accepted, got %d: %s", w.Code, w.Body.String())
+		}
+
+		w = do("PUT", "/tags/other.txt", "data", map[string]string{"x-amz-tagging": "a=1&a=2"})
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "InvalidTag") {
+			t.Errorf("Expected duplicate header tags to be rejected, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("CopyDirective", func(t *testing.T) {
+		_ = do("PUT", "/tags/source.txt", "data", map[string]string{"x-amz-tagging": "origin=source"})
+
+		w := do("PUT", "/tags/copied.txt", "", map[string]string{"x-amz-copy-source": "/tags/source.txt"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("CopyObject failed: %d %s", w.Code, w.Body.String())
+		}
+		if tags := getTags(t, "/tags/copied.txt?tagging"); tags["origin"] != "source" {
+			t.Errorf("Expected tags to be copied, got %v", tags)
+		}
+
+		w = do("PUT", "/tags/replaced.txt", "", map[string]string{
+			"x-amz-copy-source":       "/tags/source.txt",
+			"x-amz-tagging-directive": "REPLACE",
+			"x-amz-tagging":           "origin=copy",
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("CopyObject failed: %d %s", w.Code, w.Body.String())
+		}
+		if tags := getTags(t, "/tags/replaced.txt?tagging"); len(tags) != 1 || tags["origin"] != "copy" {
+			t.Errorf("Expected tags to be replaced, got %v", tags)
+		}
+	})
+
+	t.Run("NoSuchKey", func(t *testing.T) {
+		w := do("GET", "/tags/missing.txt?tagging", "", nil)
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchKey") {
+			t.Errorf("Expected NoSuchKey, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestBucketTagging(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("tagged")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tagged?tagging", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchTagSet") {
+		t.Fatalf("Expected NoSuchTagSet, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body := "<Tagging><TagSet><Tag><Key>cost-center</Key><Value>42</Value></Tag></TagSet></Tagging>"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/tagged?tagging", strings.NewReader(body)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PutBucketTagging failed: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tagged?tagging", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<Key>cost-center</Key><Value>42</Value>") {
+		t.Errorf("Unexpected GetBucketTagging response: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/tagged?tagging", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DeleteBucketTagging failed: %d %s", w.Code, w.Body.String())
+	}
+	if exists, _ := handler.storage.BucketExists("tagged"); !exists {
+		t.Errorf("Expected the bucket to survive DeleteBucketTagging")
+	}
+	if tags, _ := handler.storage.GetBucketTagging("tagged"); len(tags) != 0 {
+		t.Errorf("Expected no bucket tags, got %v", tags)
+	}
+}
+
+func TestBucketPolicy(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("policy-bucket")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("GET", "/policy-bucket?policy", "")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchBucketPolicy") {
+		t.Fatalf("Expected NoSuchBucketPolicy, got %d: %s", w.Code, w.Body.String())
+	}
+
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::policy-bucket/*"}]}`
+	w = do("PUT", "/policy-bucket?policy", policy)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PutBucketPolicy failed: %d %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/policy-bucket?policy", "")
+	if w.Code != http.StatusOK || w.Body.String() != policy {
+		t.Errorf("Unexpected GetBucketPolicy response: %d %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/policy-bucket?policyStatus", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<IsPublic>true</IsPublic>") {
+		t.Errorf("Unexpected GetBucketPolicyStatus response: %d %s", w.Code, w.Body.String())
+	}
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, body := range []string{
+			"not json",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::other-bucket/*"}]}`,
+		} {
+			w := do("PUT", "/policy-bucket?policy", body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "MalformedPolicy") {
+				t.Errorf("Expected MalformedPolicy for %s, got %d: %s", body, w.Code, w.Body.String())
+			}
+		}
+		if w := do("GET", "/policy-bucket?policy", ""); w.Body.String() != policy {
+			t.Errorf("A rejected policy must not replace the stored one, got %s", w.Body.String())
+		}
+	})
+
+	w = do("DELETE", "/policy-bucket?policy", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DeleteBucketPolicy failed: %d %s", w.Code, w.Body.String())
+	}
+	if exists, _ := handler.storage.BucketExists("policy-bucket"); !exists {
+		t.Errorf("Expected the bucket to survive DeleteBucketPolicy")
+	}
+	if w := do("GET", "/policy-bucket?policyStatus", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected NoSuchBucketPolicy after delete, got %d", w.Code)
+	}
+
+	missing := strings.ReplaceAll(policy, "policy-bucket", "missing-bucket")
+	if w := do("PUT", "/missing-bucket?policy", missing); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchBucket") {
+		t.Errorf("Expected NoSuchBucket, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestObjectAcl(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("acl-bucket")
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	getACL := func(t *testing.T, path string) AccessControlPolicy {
+		t.Helper()
+		w := do("GET", path, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GetAcl failed: %d %s", w.Code, w.Body.String())
+		}
+		var policy AccessControlPolicy
+		if err := xml.Unmarshal(w.Body.Bytes(), &policy); err != nil {
+			t.Fatalf("Failed to parse ACL: %v", err)
+		}
+		return policy
+	}
+
+	t.Run("DefaultPrivate", func(t *testing.T) {
+		do("PUT", "/acl-bucket/private.txt", "data", nil)
+		policy := getACL(t, "/acl-bucket/private.txt?acl")
+		grants := policy.AccessControlList.Grants
+		if len(grants) != 1 || grants[0].Grantee.ID != defaultOwner.ID || grants[0].Permission != "FULL_CONTROL" {
+			t.Errorf("Expected the owner to have full control, got %+v", grants)
+		}
+		if policy.Owner.ID != defaultOwner.ID {
+			t.Errorf("Unexpected owner %+v", policy.Owner)
+		}
+	})
+
+	t.Run("CannedOnPut", func(t *testing.T) {
+		w := do("PUT", "/acl-bucket/public.txt", "data", map[string]string{"x-amz-acl": "public-read"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("PutObject failed: %d %s", w.Code, w.Body.String())
+		}
+		w = do("GET", "/acl-bucket/public.txt?acl", "", nil)
+		body := w.Body.String()
+		if !strings.Contains(body, `xsi:type="Group"`) || !strings.Contains(body, "<URI>http://acs.amazonaws.com/groups/global/AllUsers</URI>") {
+			t.Errorf("Expected an AllUsers READ grant, got %s", body)
+		}
+	})
+
+	t.Run("GrantHeaders", func(t *testing.T) {
+		w := do("PUT", "/acl-bucket/private.txt?acl", "", map[string]string{
+			"x-amz-grant-read":         `uri="http://acs.amazonaws.com/groups/global/AuthenticatedUsers"`,
+			"x-amz-grant-full-control": `id="s3pit", id="partner"`,
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("PutObjectAcl failed: %d %s", w.Code, w.Body.String())
+		}
+		grants := getACL(t, "/acl-bucket/private.txt?acl").AccessControlList.Grants
+		if len(grants) != 3 || grants[1].Grantee.ID != "partner" || grants[2].Grantee.URI != storage.AuthenticatedUsersGroup {
+			t.Errorf("Unexpected grants %+v", grants)
+		}
+	})
+
+	t.Run("Body", func(t *testing.T) {
+		body := `<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+			<Owner><ID>s3pit</ID></Owner>
+			<AccessControlList>
+				<Grant>
+					<Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee>
+					<Permission>READ</Permission>
+				</Grant>
+			</AccessControlList>
+		</AccessControlPolicy>`
+		w := do("PUT", "/acl-bucket/private.txt?acl", body, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("PutObjectAcl failed: %d %s", w.Code, w.Body.String())
+		}
+		meta, _ := handler.storage.GetObjectMetadata("acl-bucket", "private.txt")
+		if len(meta.ACL) != 1 || !storage.GrantsAllUsers(meta.ACL, storage.PermissionRead) {
+			t.Errorf("Expected the ACL of the body, got %+v", meta.ACL)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			body    string
+			headers map[string]string
+			code    string
+		}{
+			{"UnknownCanned", "", map[string]string{"x-amz-acl": "everyone"}, "InvalidArgument"},
+			{"CannedAndGrants", "", map[string]string{"x-amz-acl": "private", "x-amz-grant-read": `id="s3pit"`}, "InvalidRequest"},
+			{"UnknownGroup", "", map[string]string{"x-amz-grant-read": `uri="http://example.com/group"`}, "InvalidArgument"},
+			{"MalformedBody", "<AccessControlPolicy>", nil, "MalformedACLError"},
+			{"BadPermission", `<AccessControlPolicy><AccessControlList><Grant><Grantee><ID>s3pit</ID></Grantee><Permission>OWN</Permission></Grant></AccessControlList></AccessControlPolicy>`, nil, "MalformedACLError"},
+		}
+		for _, tt := range tests {
+			w := do("PUT", "/acl-bucket/public.txt?acl", tt.body, tt.headers)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.code) {
+				t.Errorf("%s: expected %s, got %d %s", tt.name, tt.code, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("NotCopied", func(t *testing.T) {
+		w := do("PUT", "/acl-bucket/copy.txt", "", map[string]string{"x-amz-copy-source": "/acl-bucket/public.txt"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("CopyObject failed: %d %s", w.Code, w.Body.String())
+		}
+		if meta, _ := handler.storage.GetObjectMetadata("acl-bucket", "copy.txt"); len(meta.ACL) != 0 {
+			t.Errorf("Expected the copy to be private, got %+v", meta.ACL)
+		}
+
+		w = do("PUT", "/acl-bucket/copy.txt", "", map[string]string{"x-amz-copy-source": "/acl-bucket/private.txt", "x-amz-acl": "public-read"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("CopyObject failed: %d %s", w.Code, w.Body.String())
+		}
+		if meta, _ := handler.storage.GetObjectMetadata("acl-bucket", "copy.txt"); !storage.GrantsAllUsers(meta.ACL, storage.PermissionRead) {
+			t.Errorf("Expected the copy to take the request ACL, got %+v", meta.ACL)
+		}
+	})
+
+	if w := do("GET", "/acl-bucket/missing.txt?acl", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected NoSuchKey, got %d", w.Code)
+	}
+}
+
+func TestBucketAcl(t *testing.T) {
+	handler, router := setupTestHandler(t)
+
+	req := httptest.NewRequest("PUT", "/acl-bucket", nil)
+	req.Header.Set("x-amz-acl", "public-read")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("CreateBucket failed: %d %s", w.Code, w.Body.String())
+	}
+	if acl, _ := handler.storage.GetBucketACL("acl-bucket"); !storage.GrantsAllUsers(acl, storage.PermissionRead) {
+		t.Errorf("Expected CreateBucket to apply the canned ACL, got %+v", acl)
+	}
+
+	req = httptest.NewRequest("PUT", "/acl-bucket?acl", nil)
+	req.Header.Set("x-amz-acl", "private")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PutBucketAcl failed: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/acl-bucket?acl", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "AllUsers") || !strings.Contains(w.Body.String(), "<Permission>FULL_CONTROL</Permission>") {
+		t.Errorf("Unexpected GetBucketAcl response: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/missing-bucket?acl", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected NoSuchBucket, got %d", w.Code)
+	}
+}
+
+func TestBucketCors(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("cors-bucket")
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("GET", "/cors-bucket?cors", ""); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchCORSConfiguration") {
+		t.Errorf("Expected NoSuchCORSConfiguration, got %d %s", w.Code, w.Body.String())
+	}
+
+	config := `<CORSConfiguration>
+		<CORSRule>
+			<ID>uploads</ID>
+			<AllowedOrigin>https://*.example.com</AllowedOrigin>
+			<AllowedMethod>PUT</AllowedMethod>
+			<AllowedMethod>POST</AllowedMethod>
+			<AllowedHeader>*</AllowedHeader>
+			<ExposeHeader>ETag</ExposeHeader>
+			<MaxAgeSeconds>3000</MaxAgeSeconds>
+		</CORSRule>
+	</CORSConfiguration>`
+	if w := do("PUT", "/cors-bucket?cors", config); w.Code != http.StatusOK {
+		t.Fatalf("PutBucketCors failed: %d %s", w.Code, w.Body.String())
+	}
+
+	w := do("GET", "/cors-bucket?cors", "")
+	for _, want := range []string{"<ID>uploads</ID>", "<AllowedOrigin>https://*.example.com</AllowedOrigin>", "<AllowedMethod>POST</AllowedMethod>", "<ExposeHeader>ETag</ExposeHeader>", "<MaxAgeSeconds>3000</MaxAgeSeconds>"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Expected GetBucketCors to contain %s, got %s", want, w.Body.String())
+		}
+	}
+
+	invalid := map[string]string{
+		"unsupported method": `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>`,
+		"two wildcards":      `<CORSConfiguration><CORSRule><AllowedOrigin>https://*.*.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`,
+		"missing origin":     `<CORSConfiguration><CORSRule><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`,
+		"no rules":           `<CORSConfiguration></CORSConfiguration>`,
+	}
+	for name, body := range invalid {
+		if w := do("PUT", "/cors-bucket?cors", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	if w := do("DELETE", "/cors-bucket?cors", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DeleteBucketCors failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/cors-bucket?cors", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the CORS configuration to be removed, got %d", w.Code)
+	}
+	if w := do("PUT", "/missing-bucket?cors", config); w.Code != http.StatusNotFound {
+		t.Errorf("Expected NoSuchBucket, got %d", w.Code)
+	}
+}
+
+func TestBucketLifecycle(t *testing.T) {
+	handler, router := setupTestHandler(t)
+	_, _ = handler.storage.CreateBucket("lifecycle-bucket")
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("GET", "/lifecycle-bucket?lifecycle", ""); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchLifecycleConfiguration") {
+		t.Errorf("Expected NoSuchLifecycleConfiguration, got %d %s", w.Code, w.Body.String())
+	}
+
+	config := `<LifecycleConfiguration>
+		<Rule>
+			<ID>tmp</ID>
+			<Filter><Prefix>tmp/</Prefix></Filter>
+			<Status>Enabled</Status>
+			<Expiration><Days>1</Days></Expiration>
+		</Rule>
+		<Rule>
+			<ID>uploads</ID>
+			<Filter></Filter>
+			<Status>Enabled</Status>
+			<AbortIncompleteMultipartUpload><DaysAfterInitiation>7</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+		</Rule>
+		<Rule>
+			<ID>large-scratch</ID>
+			<Filter>
+				<And>
+					<Prefix>scratch/</Prefix>
+					<Tag><Key>class</Key><Value>scratch</Value></Tag>
+					<ObjectSizeGreaterThan>1024</ObjectSizeGreaterThan>
+				</And>
+			</Filter>
+			<Status>Disabled</Status>
+			<NoncurrentVersionExpiration><NoncurrentDays>30</NoncurrentDays><NewerNoncurrentVersions>2</NewerNoncurrentVersions></NoncurrentVersionExpiration>
+		</Rule>
+	</LifecycleConfiguration>`
+	if w := do("PUT", "/lifecycle-bucket?lifecycle", config); w.Code != http.StatusOK {
+		t.Fatalf("PutBucketLifecycleConfiguration failed: %d %s", w.Code, w.Body.String())
+	}
+
+	w := do("GET", "/lifecycle-bucket?lifecycle", "")
+	for _, want := range []string{
+		"<ID>tmp</ID>", "<Prefix>tmp/</Prefix>", "<Days>1</Days>",
+		"<DaysAfterInitiation>7</DaysAfterInitiation>",
+		"<Status>Disabled</Status>", "<And><Prefix>scratch/</Prefix><Tag><Key>class</Key><Value>scratch</Value></Tag><ObjectSizeGreaterThan>1024</ObjectSizeGreaterThan></And>",
+		"<NoncurrentDays>30</NoncurrentDays>", "<NewerNoncurrentVersions>2</NewerNoncurrentVersions>",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Expected GetBucketLifecycleConfiguration to contain %s, got %s", want, w.Body.String())
+		}
+	}
+
+	invalid := map[string]string{
+		"no action":              `<LifecycleConfiguration><Rule><Filter></Filter><Status>Enabled</Status></Rule></LifecycleConfiguration>`,
+		"bad status":             `<LifecycleConfiguration><Rule><Filter></Filter><Status>On</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		"zero days":              `<LifecycleConfiguration><Rule><Filter></Filter><Status>Enabled</Status><Expiration><Days>0</Days></Expiration></Rule></LifecycleConfiguration>`,
+		"date not midnight":      `<LifecycleConfiguration><Rule><Filter></Filter><Status>Enabled</Status><Expiration><Date>2030-01-01T12:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+		"days and date":          `<LifecycleConfiguration><Rule><Filter></Filter><Status>Enabled</Status><Expiration><Days>1</Days><Date>2030-01-01T00:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+		"duplicate ids":          `<LifecycleConfiguration><Rule><ID>a</ID><Filter></Filter><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule><Rule><ID>a</ID><Filter></Filter><Status>Enabled</Status><Expiration><Days>2</Days></Expiration></Rule></LifecycleConfiguration>`,
+		"conditions without And": `<LifecycleConfiguration><Rule><Filter><Prefix>a/</Prefix><ObjectSizeLessThan>10</ObjectSizeLessThan></Filter><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		"abort with tags":        `<LifecycleConfiguration><Rule><Filter><Tag><Key>a</Key><Value>b</Value></Tag></Filter><Status>Enabled</Status><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule></LifecycleConfiguration>`,
+		"no rules":               `<LifecycleConfiguration></LifecycleConfiguration>`,
+	}
+	for name, body := range invalid {
+		if w := do("PUT", "/lifecycle-bucket?lifecycle", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	if w := do("DELETE", "/lifecycle-bucket?lifecycle", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DeleteBucketLifecycle failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/lifecycle-bucket?lifecycle", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the lifecycle configuration to be removed, got %d", w.Code)
+	}
+	if w := do("PUT", "/missing-bucket?lifecycle", config); w.Code != http.StatusNotFound {
+		t.Errorf("Expected NoSuchBucket, got %d", w.Code)
+	}
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// maxLifecycleRules is the largest number of rules a lifecycle configuration
+// may have
+const maxLifecycleRules = 1000
+
+// LifecycleConfiguration is the request and response body of the bucket
+// lifecycle operations
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+type LifecycleRule struct {
+	ID                             string                          `xml:"ID,omitempty"`
+	Filter                         *LifecycleFilter                `xml:"Filter"`
+	Prefix                         *string                         `xml:"Prefix"` // Deprecated form of Filter
+	Status                         string                          `xml:"Status"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload"`
+}
+
+// LifecycleFilter holds a single condition, or several combined with And
+type LifecycleFilter struct {
+	Prefix                string              `xml:"Prefix,omitempty"`
+	Tag                   *Tag                `xml:"Tag"`
+	ObjectSizeGreaterThan int64               `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    int64               `xml:"ObjectSizeLessThan,omitempty"`
+	And                   *LifecycleFilterAnd `xml:"And"`
+}
+
+type LifecycleFilterAnd struct {
+	Prefix                string `xml:"Prefix,omitempty"`
+	Tags                  []Tag  `xml:"Tag"`
+	ObjectSizeGreaterThan int64  `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    int64  `xml:"ObjectSizeLessThan,omitempty"`
+}
+
+type LifecycleExpiration struct {
+	Days                      *int   `xml:"Days"`
+	Date                      string `xml:"Date,omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:"ExpiredObjectDeleteMarker,omitempty"`
+}
+
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays          *int `xml:"NoncurrentDays"`
+	NewerNoncurrentVersions int  `xml:"NewerNoncurrentVersions,omitempty"`
+}
+
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation *int `xml:"DaysAfterInitiation"`
+}
+
+// validateLifecycleRules checks a lifecycle configuration the way S3 does and
+// converts it to storage rules. The returned error is nil when the rules are
+// valid.
+func validateLifecycleRules(rules []LifecycleRule) ([]storage.LifecycleRule, *S3Error) {
+	malformed := &S3Error{Code: ErrMalformedXML, Message: "The XML you provided was not well-formed or did not validate against our published schema"}
+	if len(rules) == 0 || len(rules) > maxLifecycleRules {
+		return nil, malformed
+	}
+
+	ids := make(map[string]bool)
+	converted := make([]storage.LifecycleRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Status != "Enabled" && rule.Status != "Disabled" {
+			return nil, malformed
+		}
+		if rule.ID != "" {
+			if ids[rule.ID] {
+				return nil, &S3Error{Code: ErrInvalidArgument, Message: "Rule ID must be unique. Found same ID for more than one rule"}
+			}
+			ids[rule.ID] = true
+		}
+		if rule.Expiration == nil && rule.NoncurrentVersionExpiration == nil && rule.AbortIncompleteMultipartUpload == nil {
+			return nil, &S3Error{Code: ErrInvalidRequest, Message: "At least one action needs to be specified in a rule"}
+		}
+
+		converted = append(converted, storage.LifecycleRule{ID: rule.ID, Enabled: rule.Status == "Enabled"})
+		out := &converted[len(converted)-1]
+
+		if s3Err := convertLifecycleFilter(rule, out); s3Err != nil {
+			return nil, s3Err
+		}
+		if s3Err := convertLifecycleActions(rule, out); s3Err != nil {
+			return nil, s3Err
+		}
+	}
+
+	return converted, nil
+}
+
+// convertLifecycleFilter copies the filter of a rule, given either as Filter
+// or as the deprecated Prefix element, to out
+func convertLifecycleFilter(rule LifecycleRule, out *storage.LifecycleRule) *S3Error {
+	malformed := &S3Error{Code: ErrMalformedXML, Message: "The XML you provided was not well-formed or did not validate against our published schema"}
+	if rule.Filter != nil && rule.Prefix != nil {
+		return malformed
+	}
+	if rule.Prefix != nil {
+		out.Prefix = *rule.Prefix
+		return nil
+	}
+	if rule.Filter == nil {
+		return nil
+	}
+
+	filter := rule.Filter
+	conditions := 0
+	for _, set := range []bool{filter.Prefix != "", filter.Tag != nil, filter.ObjectSizeGreaterThan != 0, filter.ObjectSizeLessThan != 0, filter.And != nil} {
+		if set {
+			conditions++
+		}
+	}
+	if conditions > 1 {
+		// Several conditions must be combined with And
+		return malformed
+	}
+
+	var tags []Tag
+	if filter.And != nil {
+		out.Prefix = filter.And.Prefix
+		out.ObjectSizeGreaterThan = filter.And.ObjectSizeGreaterThan
+		out.ObjectSizeLessThan = filter.And.ObjectSizeLessThan
+		tags = filter.And.Tags
+	} else {
+		out.Prefix = filter.Prefix
+		out.ObjectSizeGreaterThan = filter.ObjectSizeGreaterThan
+		out.ObjectSizeLessThan = filter.ObjectSizeLessThan
+		if filter.Tag != nil {
+			tags = []Tag{*filter.Tag}
+		}
+	}
+
+	if out.ObjectSizeGreaterThan < 0 || out.ObjectSizeLessThan < 0 {
+		return &S3Error{Code: ErrInvalidArgument, Message: "Object size must be a non-negative integer"}
+	}
+	if out.ObjectSizeLessThan > 0 && out.ObjectSizeLessThan <= out.ObjectSizeGreaterThan {
+		return &S3Error{Code: ErrInvalidArgument, Message: "ObjectSizeLessThan must be greater than ObjectSizeGreaterThan"}
+	}
+
+	if len(tags) > 0 {
+		tagSet, s3Err := validateTags(tags, maxObjectTags)
+		if s3Err != nil {
+			return s3Err
+		}
+		out.Tags = tagSet
+	}
+	return nil
+}
+
+// convertLifecycleActions copies the actions of a rule to out
+func convertLifecycleActions(rule LifecycleRule, out *storage.LifecycleRule) *S3Error {
+	malformed := &S3Error{Code: ErrMalformedXML, Message: "The XML you provided was not well-formed or did not validate against our published schema"}
+
+	if expiration := rule.Expiration; expiration != nil {
+		set := 0
+		for _, isSet := range []bool{expiration.Days != nil, expiration.Date != "", expiration.ExpiredObjectDeleteMarker} {
+			if isSet {
+				set++
+			}
+		}
+		if set != 1 {
+			return malformed
+		}
+
+		switch {
+		case expiration.Days != nil:
+			if *expiration.Days <= 0 {
+				return &S3Error{Code: ErrInvalidArgument, Message: "'Days' for Expiration action must be a positive integer"}
+			}
+			out.ExpirationDays = *expiration.Days
+		case expiration.Date != "":
+			date, err := time.Parse(time.RFC3339, expiration.Date)
+			if err != nil {
+				return &S3Error{Code: ErrInvalidArgument, Message: "Invalid date provided for Expiration action"}
+			}
+			if date = date.UTC(); !date.Equal(date.Truncate(24 * time.Hour)) {
+				return &S3Error{Code: ErrInvalidArgument, Message: "'Date' must be at midnight GMT"}
+			}
+			out.ExpirationDate = date
+		default:
+			if len(out.Tags) > 0 {
+				return &S3Error{Code: ErrInvalidRequest, Message: "ExpiredObjectDeleteMarker cannot be specified with tags."}
+			}
+			out.ExpiredObjectDeleteMarker = true
+		}
+	}
+
+	if noncurrent := rule.NoncurrentVersionExpiration; noncurrent != nil {
+		if noncurrent.NoncurrentDays == nil || *noncurrent.NoncurrentDays <= 0 {
+			return &S3Error{Code: ErrInvalidArgument, Message: "'NoncurrentDays' for NoncurrentVersionExpiration action must be a positive integer"}
+		}
+		if noncurrent.NewerNoncurrentVersions < 0 {
+			return &S3Error{Code: ErrInvalidArgument, Message: "'NewerNoncurrentVersions' for NoncurrentVersionExpiration action must be a positive integer"}
+		}
+		out.NoncurrentVersionExpirationDays = *noncurrent.NoncurrentDays
+		out.NewerNoncurrentVersions = noncurrent.NewerNoncurrentVersions
+	}
+
+	if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+		if abort.DaysAfterInitiation == nil || *abort.DaysAfterInitiation <= 0 {
+			return &S3Error{Code: ErrInvalidArgument, Message: "'DaysAfterInitiation' for AbortIncompleteMultipartUpload action must be a positive integer"}
+		}
+		if len(out.Tags) > 0 || out.ObjectSizeGreaterThan > 0 || out.ObjectSizeLessThan > 0 {
+			return &S3Error{Code: ErrInvalidRequest, Message: "AbortIncompleteMultipartUpload cannot be specified with tags or object size."}
+		}
+		out.AbortIncompleteMultipartUploadDays = *abort.DaysAfterInitiation
+	}
+
+	return nil
+}
+
+// newLifecycleRule converts a storage rule back to its XML form. The filter
+// uses And whenever it combines several conditions.
+func newLifecycleRule(rule storage.LifecycleRule) LifecycleRule {
+	out := LifecycleRule{ID: rule.ID, Status: "Disabled", Filter: &LifecycleFilter{}}
+	if rule.Enabled {
+		out.Status = "Enabled"
+	}
+
+	tags := newTagging(rule.Tags).TagSet.Tags
+	conditions := len(tags)
+	for _, set := range []bool{rule.Prefix != "", rule.ObjectSizeGreaterThan > 0, rule.ObjectSizeLessThan > 0} {
+		if set {
+			conditions++
+		}
+	}
+	if conditions > 1 {
+		out.Filter.And = &LifecycleFilterAnd{
+			Prefix:                rule.Prefix,
+			Tags:                  tags,
+			ObjectSizeGreaterThan: rule.ObjectSizeGreaterThan,
+			ObjectSizeLessThan:    rule.ObjectSizeLessThan,
+		}
+	} else {
+		out.Filter.Prefix = rule.Prefix
+		out.Filter.ObjectSizeGreaterThan = rule.ObjectSizeGreaterThan
+		out.Filter.ObjectSizeLessThan = rule.ObjectSizeLessThan
+		if len(tags) == 1 {
+			out.Filter.Tag = &tags[0]
+		}
+	}
+
+	switch {
+	case rule.ExpirationDays > 0:
+		days := rule.ExpirationDays
+		out.Expiration = &LifecycleExpiration{Days: &days}
+	case !rule.ExpirationDate.IsZero():
+		out.Expiration = &LifecycleExpiration{Date: rule.ExpirationDate.UTC().Format(time.RFC3339)}
+	case rule.ExpiredObjectDeleteMarker:
+		out.Expiration = &LifecycleExpiration{ExpiredObjectDeleteMarker: true}
+	}
+	if rule.NoncurrentVersionExpirationDays > 0 {
+		days := rule.NoncurrentVersionExpirationDays
+		out.NoncurrentVersionExpiration = &NoncurrentVersionExpiration{
+			NoncurrentDays:          &days,
+			NewerNoncurrentVersions: rule.NewerNoncurrentVersions,
+		}
+	}
+	if rule.AbortIncompleteMultipartUploadDays > 0 {
+		days := rule.AbortIncompleteMultipartUploadDays
+		out.AbortIncompleteMultipartUpload = &AbortIncompleteMultipartUpload{DaysAfterInitiation: &days}
+	}
+	return out
+}
+
+func (h *Handler) PutBucketLifecycleConfiguration(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	var config LifecycleConfiguration
+	if err := c.ShouldBindXML(&config); err != nil {
+		h.sendError(c, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
+		return
+	}
+
+	rules, s3Err := validateLifecycleRules(config.Rules)
+	if s3Err != nil {
+		h.sendS3Error(c, *s3Err)
+		return
+	}
+
+	if err := h.getStorage(c).PutBucketLifecycle(bucket, rules); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetBucketLifecycleConfiguration(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	rules, err := h.getStorage(c).GetBucketLifecycle(bucket)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+	if len(rules) == 0 {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrNoSuchLifecycleConfiguration,
+			Message: "The lifecycle configuration does not exist",
+		})
+		return
+	}
+
+	config := LifecycleConfiguration{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	for _, rule := range rules {
+		config.Rules = append(config.Rules, newLifecycleRule(rule))
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, config)
+}
+
+func (h *Handler) DeleteBucketLifecycle(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	if err := h.getStorage(c).PutBucketLifecycle(bucket, nil); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
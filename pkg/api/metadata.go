@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// userMetadataPrefix is the header prefix for user-defined object metadata
+const userMetadataPrefix = "x-amz-meta-"
+
+// maxUserMetadataSize is the S3 limit for the combined size of user metadata
+// keys and values
+const maxUserMetadataSize = 2 * 1024
+
+// objectMetadataFromRequest collects the content type, standard headers,
+// x-amz-meta-* headers, x-amz-tagging tags and ACL headers of a write request.
+// It returns false when an error response has already been written.
+func (h *Handler) objectMetadataFromRequest(c *gin.Context) (*storage.ObjectMetadata, bool) {
+	contentType := c.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	meta := &storage.ObjectMetadata{
+		ContentType:        contentType,
+		CacheControl:       c.GetHeader("Cache-Control"),
+		ContentDisposition: c.GetHeader("Content-Disposition"),
+		ContentEncoding:    contentEncoding(c.GetHeader("Content-Encoding")),
+		ContentLanguage:    c.GetHeader("Content-Language"),
+		Expires:            c.GetHeader("Expires"),
+	}
+
+	userMetadataSize := 0
+	for name, values := range c.Request.Header {
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, userMetadataPrefix) || len(values) == 0 {
+			continue
+		}
+		if meta.Metadata == nil {
+			meta.Metadata = make(map[string]string)
+		}
+		key := strings.TrimPrefix(lower, userMetadataPrefix)
+		value := strings.Join(values, ",")
+		meta.Metadata[key] = value
+		userMetadataSize += len(key) + len(value)
+	}
+
+	if userMetadataSize > maxUserMetadataSize {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrMetadataTooLarge,
+			Message: "Your metadata headers exceed the maximum allowed metadata size",
+		})
+		return nil, false
+	}
+
+	tags, ok := h.tagsFromHeader(c)
+	if !ok {
+		return nil, false
+	}
+	meta.Tags = tags
+
+	acl, ok := h.aclFromHeaders(c)
+	if !ok {
+		return nil, false
+	}
+	meta.ACL = acl
+
+	return meta, true
+}
+
+// setObjectHeaders writes the stored object metadata as GET/HEAD response
+// headers. Content-Length is left to the caller because ranged responses
+// report the range length.
+func setObjectHeaders(c *gin.Context, meta *storage.ObjectMetadata) {
+	c.Header("Content-Type", meta.ContentType)
+	c.Header("ETag", meta.ETag)
+	c.Header("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+	c.Header("Accept-Ranges", "bytes")
+	if meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+
+	if meta.CacheControl != "" {
+		c.Header("Cache-Control", meta.CacheControl)
+	}
+	if meta.ContentDisposition != "" {
+		c.Header("Content-Disposition", meta.ContentDisposition)
+	}
+	if meta.ContentEncoding != "" {
+		c.Header("Content-Encoding", meta.ContentEncoding)
+	}
+	if meta.ContentLanguage != "" {
+		c.Header("Content-Language", meta.ContentLanguage)
+	}
+	if meta.Expires != "" {
+		c.Header("Expires", meta.Expires)
+	}
+	for key, value := range meta.Metadata {
+		c.Header(userMetadataPrefix+key, value)
+	}
+	if len(meta.Tags) > 0 {
+		c.Header("x-amz-tagging-count", strconv.Itoa(len(meta.Tags)))
+	}
+
+	// Checksums describe the whole object and are not returned for ranges
+	if strings.EqualFold(c.GetHeader("x-amz-checksum-mode"), "ENABLED") && c.GetHeader("Range") == "" {
+		setChecksumHeaders(c, meta.ChecksumAlgorithm, meta.Checksum, meta.ChecksumType)
+	}
+}
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+type CopyPartResult struct {
+	XMLName      xml.Name  `xml:"CopyPartResult"`
+	Xmlns        string    `xml:"xmlns,attr"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+}
+
+type ListMultipartUploadsResponse struct {
+	XMLName            xml.Name       `xml:"ListMultipartUploadsResult"`
+	Xmlns              string         `xml:"xmlns,attr"`
+	Bucket             string         `xml:"Bucket"`
+	KeyMarker          string         `xml:"KeyMarker"`
+	UploadIdMarker     string         `xml:"UploadIdMarker"`
+	NextKeyMarker      string         `xml:"NextKeyMarker,omitempty"`
+	NextUploadIdMarker string         `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string         `xml:"Prefix"`
+	Delimiter          string         `xml:"Delimiter,omitempty"`
+	MaxUploads         int            `xml:"MaxUploads"`
+	EncodingType       string         `xml:"EncodingType,omitempty"`
+	IsTruncated        bool           `xml:"IsTruncated"`
+	Uploads            []UploadInfo   `xml:"Upload"`
+	CommonPrefixes     []CommonPrefix `xml:"CommonPrefixes,omitempty"`
+}
+
+type UploadInfo struct {
+	Key               string    `xml:"Key"`
+	UploadId          string    `xml:"UploadId"`
+	Initiator         Owner     `xml:"Initiator"`
+	Owner             Owner     `xml:"Owner"`
+	StorageClass      string    `xml:"StorageClass"`
+	Initiated         time.Time `xml:"Initiated"`
+	ChecksumAlgorithm string    `xml:"ChecksumAlgorithm,omitempty"`
+	ChecksumType      string    `xml:"ChecksumType,omitempty"`
+}
+
+type ListPartsResponse struct {
+	XMLName              xml.Name   `xml:"ListPartsResult"`
+	Xmlns                string     `xml:"xmlns,attr"`
+	Bucket               string     `xml:"Bucket"`
+	Key                  string     `xml:"Key"`
+	UploadId             string     `xml:"UploadId"`
+	Initiator            Owner      `xml:"Initiator"`
+	Owner                Owner      `xml:"Owner"`
+	StorageClass         string     `xml:"StorageClass"`
+	PartNumberMarker     int        `xml:"PartNumberMarker"`
+	NextPartNumberMarker int        `xml:"NextPartNumberMarker"`
+	MaxParts             int        `xml:"MaxParts"`
+	IsTruncated          bool       `xml:"IsTruncated"`
+	Parts                []PartItem `xml:"Part"`
+	ChecksumAlgorithm    string     `xml:"ChecksumAlgorithm,omitempty"`
+	ChecksumType         string     `xml:"ChecksumType,omitempty"`
+}
+
+type PartItem struct {
+	PartNumber   int       `xml:"PartNumber"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+	Checksums
+}
+
+func (h *Handler) UploadPartCopy(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	uploadId := c.Query("uploadId")
+
+	if uploadId == "" {
+		h.sendError(c, "InvalidRequest", "uploadId is required", http.StatusBadRequest)
+		return
+	}
+
+	partNumber, err := strconv.Atoi(c.Query("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		h.sendError(c, "InvalidPartNumber", "Part number must be between 1 and 10000", http.StatusBadRequest)
+		return
+	}
+
+	sourceBucket, sourceKey, sourceVersionId, ok := h.parseCopySource(c)
+	if !ok {
+		return
+	}
+
+	sourceMeta, err := h.getStorage(c).GetObjectVersionMetadata(sourceBucket, sourceKey, sourceVersionId)
+	if err != nil {
+		h.sendCopySourceError(c, sourceVersionId, err)
+		return
+	}
+
+	if copySourcePreconditions(c).evaluate(sourceMeta) != http.StatusOK {
+		h.sendPreconditionFailed(c)
+		return
+	}
+
+	source := storage.CopySource{
+		Bucket:    sourceBucket,
+		Key:       sourceKey,
+		VersionId: sourceMeta.VersionId,
+		Length:    -1,
+	}
+	if header := c.GetHeader("x-amz-copy-source-range"); header != "" {
+		byteRange, ok := parseCopySourceRange(header, sourceMeta.Size)
+		if !ok {
+			h.sendS3Error(c, S3Error{
+				Code:    ErrInvalidArgument,
+				Message: "Range specified is not valid for source object of size: " + strconv.FormatInt(sourceMeta.Size, 10),
+			})
+			return
+		}
+		source.Offset = byteRange.start
+		source.Length = byteRange.length
+	}
+
+	etag, err := h.getStorage(c).UploadPartCopy(bucket, key, uploadId, partNumber, source)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	if sourceMeta.VersionId != "" {
+		c.Header("x-amz-copy-source-version-id", sourceMeta.VersionId)
+	}
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, CopyPartResult{
+		Xmlns:        "http://s3.amazonaws.com/doc/2006-03-01/",
+		LastModified: time.Now().UTC(),
+		ETag:         etag,
+	})
+}
+
+func (h *Handler) ListMultipartUploads(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	prefix := c.Query("prefix")
+	delimiter := c.Query("delimiter")
+	keyMarker := c.Query("key-marker")
+	uploadIdMarker := c.Query("upload-id-marker")
+	params := listParams{encodingType: c.Query("encoding-type")}
+	if params.encodingType != "" && params.encodingType != "url" {
+		h.sendError(c, "InvalidArgument", "Invalid Encoding Method specified in Request", http.StatusBadRequest)
+		return
+	}
+	maxUploads := 1000
+	if mu := c.Query("max-uploads"); mu != "" {
+		parsed, err := strconv.Atoi(mu)
+		if err != nil || parsed < 0 {
+			h.sendError(c, "InvalidArgument", "Argument max-uploads must be an integer between 0 and 2147483647", http.StatusBadRequest)
+			return
+		}
+		if parsed < maxUploads {
+			maxUploads = parsed
+		}
+	}
+
+	uploads, err := h.getStorage(c).ListMultipartUploads(bucket, prefix)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	response := ListMultipartUploadsResponse{
+		Xmlns:          "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket:         bucket,
+		KeyMarker:      params.encode(keyMarker),
+		UploadIdMarker: uploadIdMarker,
+		Prefix:         params.encode(prefix),
+		Delimiter:      params.encode(delimiter),
+		MaxUploads:     maxUploads,
+		EncodingType:   params.encodingType,
+	}
+
+	uploads = uploadsAfterMarker(uploads, keyMarker, uploadIdMarker, delimiter)
+
+	count := 0
+	seenPrefixes := make(map[string]bool)
+	for _, upload := range uploads {
+		if delimiter != "" {
+			if idx := strings.Index(upload.Key[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := upload.Key[:len(prefix)+idx+len(delimiter)]
+				if seenPrefixes[commonPrefix] {
+					continue
+				}
+				if count == maxUploads {
+					response.IsTruncated = true
+					break
+				}
+				seenPrefixes[commonPrefix] = true
+				response.CommonPrefixes = append(response.CommonPrefixes, CommonPrefix{Prefix: params.encode(commonPrefix)})
+				response.NextKeyMarker = commonPrefix
+				response.NextUploadIdMarker = ""
+				count++
+				continue
+			}
+		}
+
+		if count == maxUploads {
+			response.IsTruncated = true
+			break
+		}
+
+		response.Uploads = append(response.Uploads, UploadInfo{
+			Key:               params.encode(upload.Key),
+			UploadId:          upload.UploadId,
+			Initiator:         defaultOwner,
+			Owner:             defaultOwner,
+			StorageClass:      "STANDARD",
+			Initiated:         upload.Initiated,
+			ChecksumAlgorithm: upload.ChecksumAlgorithm,
+			ChecksumType:      upload.ChecksumType,
+		})
+		response.NextKeyMarker = upload.Key
+		response.NextUploadIdMarker = upload.UploadId
+		count++
+	}
+
+	if response.IsTruncated {
+		response.NextKeyMarker = params.encode(response.NextKeyMarker)
+	} else {
+		response.NextKeyMarker = ""
+		response.NextUploadIdMarker = ""
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, response)
+}
+
+// uploadsAfterMarker drops the uploads up to and including the position
+// described by key-marker and upload-id-marker. Uploads of the marker key are
+// kept only when their ID sorts after upload-id-marker, and a key marker that
+// names a common prefix skips every key below it.
+func uploadsAfterMarker(uploads []storage.MultipartUploadInfo, keyMarker, uploadIdMarker, delimiter string) []storage.MultipartUploadInfo {
+	if keyMarker == "" {
+		return uploads
+	}
+
+	var remaining []storage.MultipartUploadInfo
+	for _, upload := range uploads {
+		if delimiter != "" && strings.HasSuffix(keyMarker, delimiter) && strings.HasPrefix(upload.Key, keyMarker) {
+			continue
+		}
+		if upload.Key < keyMarker {
+			continue
+		}
+		if upload.Key == keyMarker && (uploadIdMarker == "" || upload.UploadId <= uploadIdMarker) {
+			continue
+		}
+		remaining = append(remaining, upload)
+	}
+	return remaining
+}
+
+func (h *Handler) ListParts(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	uploadId := c.Query("uploadId")
+
+	maxParts := 1000
+	if mp := c.Query("max-parts"); mp != "" {
+		parsed, err := strconv.Atoi(mp)
+		if err != nil || parsed < 0 {
+			h.sendError(c, "InvalidArgument", "Argument max-parts must be an integer between 0 and 2147483647", http.StatusBadRequest)
+			return
+		}
+		if parsed < maxParts {
+			maxParts = parsed
+		}
+	}
+	partNumberMarker := 0
+	if marker := c.Query("part-number-marker"); marker != "" {
+		parsed, err := strconv.Atoi(marker)
+		if err != nil || parsed < 0 {
+			h.sendError(c, "InvalidArgument", "Argument part-number-marker must be an integer between 0 and 2147483647", http.StatusBadRequest)
+			return
+		}
+		partNumberMarker = parsed
+	}
+
+	parts, err := h.getStorage(c).ListParts(bucket, key, uploadId)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	response := ListPartsResponse{
+		Xmlns:            "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket:           bucket,
+		Key:              key,
+		UploadId:         uploadId,
+		Initiator:        defaultOwner,
+		Owner:            defaultOwner,
+		StorageClass:     "STANDARD",
+		PartNumberMarker: partNumberMarker,
+		MaxParts:         maxParts,
+	}
+
+	// The checksum algorithm is a property of the upload, not of its parts
+	if uploads, err := h.getStorage(c).ListMultipartUploads(bucket, key); err == nil {
+		for _, upload := range uploads {
+			if upload.UploadId == uploadId {
+				response.ChecksumAlgorithm = upload.ChecksumAlgorithm
+				response.ChecksumType = upload.ChecksumType
+				break
+			}
+		}
+	}
+
+	for _, part := range parts {
+		if part.PartNumber <= partNumberMarker {
+			continue
+		}
+		if len(response.Parts) == maxParts {
+			response.IsTruncated = true
+			break
+		}
+		response.Parts = append(response.Parts, PartItem{
+			PartNumber:   part.PartNumber,
+			LastModified: part.LastModified,
+			ETag:         part.ETag,
+			Size:         part.Size,
+			Checksums:    newChecksums(response.ChecksumAlgorithm, part.Checksum),
+		})
+		response.NextPartNumberMarker = part.PartNumber
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, response)
+}
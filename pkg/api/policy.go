@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// maxPolicySize is the largest bucket policy S3 accepts
+const maxPolicySize = 20 * 1024
+
+// PolicyStatus is the response body of GetBucketPolicyStatus
+type PolicyStatus struct {
+	XMLName  xml.Name `xml:"PolicyStatus"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	IsPublic bool     `xml:"IsPublic"`
+}
+
+func (h *Handler) PutBucketPolicy(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPolicySize+1))
+	if err != nil {
+		h.sendError(c, "IncompleteBody", "The request body terminated unexpectedly", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPolicySize {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrMalformedPolicy,
+			Message: "Policies cannot exceed 20 KB in size",
+		})
+		return
+	}
+
+	policy, err := storage.ParseBucketPolicy(body)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+	if err := policy.Validate(bucket); err != nil {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrMalformedPolicy,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.getStorage(c).PutBucketPolicy(bucket, body); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetBucketPolicy(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	policy, ok := h.bucketPolicy(c, bucket)
+	if !ok {
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", policy)
+}
+
+func (h *Handler) DeleteBucketPolicy(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	if err := h.getStorage(c).PutBucketPolicy(bucket, nil); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetBucketPolicyStatus(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	data, ok := h.bucketPolicy(c, bucket)
+	if !ok {
+		return
+	}
+
+	policy, err := storage.ParseBucketPolicy(data)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, PolicyStatus{
+		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
+		IsPublic: policy.IsPublic(),
+	})
+}
+
+// bucketPolicy reads the policy document of a bucket. It returns false when
+// an error response has already been written.
+func (h *Handler) bucketPolicy(c *gin.Context, bucket string) ([]byte, bool) {
+	policy, err := h.getStorage(c).GetBucketPolicy(bucket)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return nil, false
+	}
+	if policy == nil {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrNoSuchBucketPolicy,
+			Message: "The bucket policy does not exist",
+		})
+		return nil, false
+	}
+	return policy, true
+}
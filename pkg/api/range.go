@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// errInvalidRange is returned when a syntactically valid range cannot be
+// satisfied by the object size
+var errInvalidRange = errors.New("the requested range is not satisfiable")
+
+// byteRange is a resolved, inclusive byte range within an object
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange formats the Content-Range header value for the range
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange parses a Range header against an object of the given size.
+// It returns nil when the header is absent, malformed or requests multiple
+// ranges, in which case S3 serves the whole object. errInvalidRange is
+// returned when the range is well-formed but unsatisfiable.
+func parseRange(header string, size int64) (*byteRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+	startStr = strings.TrimSpace(startStr)
+	endStr = strings.TrimSpace(endStr)
+
+	// Suffix range: bytes=-N returns the last N bytes
+	if startStr == "" {
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix < 0 {
+			return nil, nil
+		}
+		if suffix == 0 || size == 0 {
+			return nil, errInvalidRange
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return &byteRange{start: size - suffix, length: suffix}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return nil, errInvalidRange
+	}
+
+	return &byteRange{start: start, length: end - start + 1}, nil
+}
+
+// parseCopySourceRange parses an x-amz-copy-source-range header against a
+// source object of the given size. Unlike Range, the header must name both
+// ends of a single range that lies within the object; ok is false otherwise.
+func parseCopySourceRange(header string, size int64) (*byteRange, bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found {
+		return nil, false
+	}
+
+	startStr, endStr, found := strings.Cut(spec, "-")
+	if !found {
+		return nil, false
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, false
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start || end >= size {
+		return nil, false
+	}
+
+	return &byteRange{start: start, length: end - start + 1}, true
+}
+
+// ifRangeMatches reports whether an If-Range precondition allows the Range
+// header to be honoured. An If-Range value is either an ETag or an HTTP date.
+func ifRangeMatches(ifRange string, meta *storage.ObjectMetadata) bool {
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, "\"") || strings.HasPrefix(ifRange, "W/") {
+		// Weak validators never match for If-Range
+		if strings.HasPrefix(ifRange, "W/") {
+			return false
+		}
+		return storage.StripETagQuotes(ifRange) == storage.StripETagQuotes(meta.ETag)
+	}
+
+	// A date only matches when it is exactly the Last-Modified date, to the
+	// second, as HTTP dates carry no finer precision
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return meta.LastModified.Truncate(time.Second).Equal(t)
+}
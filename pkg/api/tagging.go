@@ -0,0 +1,240 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// S3 limits for tag sets
+const (
+	maxObjectTags     = 10
+	maxBucketTags     = 50
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// Tagging is the request and response body of the object and bucket tagging
+// operations
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  struct {
+		Tags []Tag `xml:"Tag"`
+	} `xml:"TagSet"`
+}
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// newTagging builds a Tagging response with the tags sorted by key
+func newTagging(tags map[string]string) Tagging {
+	tagging := Tagging{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	tagging.TagSet.Tags = []Tag{}
+	for key, value := range tags {
+		tagging.TagSet.Tags = append(tagging.TagSet.Tags, Tag{Key: key, Value: value})
+	}
+	sort.Slice(tagging.TagSet.Tags, func(i, j int) bool {
+		return tagging.TagSet.Tags[i].Key < tagging.TagSet.Tags[j].Key
+	})
+	return tagging
+}
+
+// validateTags checks the tag set of an object or bucket against the S3
+// limits and returns it as a map. The returned error is nil when the tags
+// are valid.
+func validateTags(tags []Tag, maxTags int) (map[string]string, *S3Error) {
+	if len(tags) > maxTags {
+		kind := "Object"
+		if maxTags == maxBucketTags {
+			kind = "Bucket"
+		}
+		return nil, &S3Error{
+			Code:    ErrBadRequest,
+			Message: fmt.Sprintf("%s tags cannot be greater than %d", kind, maxTags),
+		}
+	}
+
+	tagSet := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		switch {
+		case tag.Key == "":
+			return nil, &S3Error{Code: ErrInvalidTag, Message: "The TagKey you have provided is invalid"}
+		case utf8.RuneCountInString(tag.Key) > maxTagKeyLength:
+			return nil, &S3Error{Code: ErrInvalidTag, Message: "The TagKey you have provided is too long, max " + strconv.Itoa(maxTagKeyLength)}
+		case utf8.RuneCountInString(tag.Value) > maxTagValueLength:
+			return nil, &S3Error{Code: ErrInvalidTag, Message: "The TagValue you have provided is too long, max " + strconv.Itoa(maxTagValueLength)}
+		case strings.HasPrefix(strings.ToLower(tag.Key), "aws:"):
+			return nil, &S3Error{Code: ErrInvalidTag, Message: "Your TagKey cannot be prefixed with aws:"}
+		}
+		if _, exists := tagSet[tag.Key]; exists {
+			return nil, &S3Error{Code: ErrInvalidTag, Message: "Cannot provide multiple Tags with the same key"}
+		}
+		tagSet[tag.Key] = tag.Value
+	}
+
+	return tagSet, nil
+}
+
+// tagsFromHeader parses the URL-encoded x-amz-tagging header of a write
+// request. It returns false when an error response has already been written.
+func (h *Handler) tagsFromHeader(c *gin.Context) (map[string]string, bool) {
+	header := c.GetHeader("x-amz-tagging")
+	if header == "" {
+		return nil, true
+	}
+
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrInvalidArgument,
+			Message: "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.",
+		})
+		return nil, false
+	}
+
+	var tags []Tag
+	for key, vals := range values {
+		for _, value := range vals {
+			tags = append(tags, Tag{Key: key, Value: value})
+		}
+	}
+
+	tagSet, s3Err := validateTags(tags, maxObjectTags)
+	if s3Err != nil {
+		h.sendS3Error(c, *s3Err)
+		return nil, false
+	}
+	return tagSet, true
+}
+
+// bindTagging reads a Tagging request body. It returns false when an error
+// response has already been written.
+func (h *Handler) bindTagging(c *gin.Context, maxTags int) (map[string]string, bool) {
+	var tagging Tagging
+	if err := c.ShouldBindXML(&tagging); err != nil {
+		h.sendError(c, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
+		return nil, false
+	}
+
+	tagSet, s3Err := validateTags(tagging.TagSet.Tags, maxTags)
+	if s3Err != nil {
+		h.sendS3Error(c, *s3Err)
+		return nil, false
+	}
+	return tagSet, true
+}
+
+func (h *Handler) PutObjectTagging(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	versionId := c.Query("versionId")
+
+	tags, ok := h.bindTagging(c, maxObjectTags)
+	if !ok {
+		return
+	}
+
+	h.putObjectTags(c, bucket, key, versionId, tags, http.StatusOK)
+}
+
+func (h *Handler) DeleteObjectTagging(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	h.putObjectTags(c, bucket, key, c.Query("versionId"), nil, http.StatusNoContent)
+}
+
+// putObjectTags replaces the tags of an object version and reports the
+// version in the response
+func (h *Handler) putObjectTags(c *gin.Context, bucket, key, versionId string, tags map[string]string, status int) {
+	meta, err := h.getStorage(c).GetObjectVersionMetadata(bucket, key, versionId)
+	if err != nil {
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	if err := h.getStorage(c).PutObjectTagging(bucket, key, meta.VersionId, tags); err != nil {
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	if meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+	c.Status(status)
+}
+
+func (h *Handler) GetObjectTagging(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	versionId := c.Query("versionId")
+
+	meta, err := h.getStorage(c).GetObjectVersionMetadata(bucket, key, versionId)
+	if err != nil {
+		h.sendObjectVersionError(c, meta, versionId, err)
+		return
+	}
+
+	if meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, newTagging(meta.Tags))
+}
+
+func (h *Handler) PutBucketTagging(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	tags, ok := h.bindTagging(c, maxBucketTags)
+	if !ok {
+		return
+	}
+
+	if err := h.getStorage(c).PutBucketTagging(bucket, tags); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetBucketTagging(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	tags, err := h.getStorage(c).GetBucketTagging(bucket)
+	if err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+	if len(tags) == 0 {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrNoSuchTagSet,
+			Message: "The TagSet does not exist",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, newTagging(tags))
+}
+
+func (h *Handler) DeleteBucketTagging(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	if err := h.getStorage(c).PutBucketTagging(bucket, nil); err != nil {
+		h.sendStorageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// VersioningConfiguration is the request and response body of
+// PutBucketVersioning and GetBucketVersioning
+type VersioningConfiguration struct {
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Xmlns     string   `xml:"xmlns,attr,omitempty"`
+	Status    string   `xml:"Status,omitempty"`
+	MfaDelete string   `xml:"MfaDelete,omitempty"`
+}
+
+type ListVersionsResponse struct {
+	XMLName             xml.Name            `xml:"ListVersionsResult"`
+	Xmlns               string              `xml:"xmlns,attr"`
+	Name                string              `xml:"Name"`
+	Prefix              string              `xml:"Prefix"`
+	KeyMarker           string              `xml:"KeyMarker"`
+	VersionIdMarker     string              `xml:"VersionIdMarker"`
+	NextKeyMarker       string              `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string              `xml:"NextVersionIdMarker,omitempty"`
+	Delimiter           string              `xml:"Delimiter,omitempty"`
+	MaxKeys             int                 `xml:"MaxKeys"`
+	IsTruncated         bool                `xml:"IsTruncated"`
+	Versions            []ObjectVersionInfo `xml:"Version"`
+	DeleteMarkers       []DeleteMarkerInfo  `xml:"DeleteMarker"`
+	CommonPrefixes      []CommonPrefix      `xml:"CommonPrefixes,omitempty"`
+}
+
+type ObjectVersionInfo struct {
+	Key          string    `xml:"Key"`
+	VersionId    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+	StorageClass string    `xml:"StorageClass"`
+}
+
+type DeleteMarkerInfo struct {
+	Key          string    `xml:"Key"`
+	VersionId    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+func (h *Handler) PutBucketVersioning(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	var config VersioningConfiguration
+	if err := c.ShouldBindXML(&config); err != nil {
+		h.sendError(c, "MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest)
+		return
+	}
+
+	if config.Status != storage.VersioningEnabled && config.Status != storage.VersioningSuspended {
+		h.sendS3Error(c, S3Error{
+			Code:    ErrIllegalVersioningConfig,
+			Message: "The Versioning element must be specified",
+		})
+		return
+	}
+
+	if err := h.getStorage(c).PutBucketVersioning(bucket, config.Status); err != nil {
+		if err == storage.ErrBucketNotFound {
+			h.sendError(c, "NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound)
+			return
+		}
+		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) GetBucketVersioning(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	status, err := h.getStorage(c).GetBucketVersioning(bucket)
+	if err != nil {
+		if err == storage.ErrBucketNotFound {
+			h.sendError(c, "NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound)
+			return
+		}
+		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, VersioningConfiguration{
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Status: status,
+	})
+}
+
+func (h *Handler) ListObjectVersions(c *gin.Context) {
+	bucket := c.Param("bucket")
+
+	prefix := c.Query("prefix")
+	delimiter := c.Query("delimiter")
+	keyMarker := c.Query("key-marker")
+	versionIdMarker := c.Query("version-id-marker")
+	maxKeys := 1000
+	if mk := c.Query("max-keys"); mk != "" {
+		if parsed, err := strconv.Atoi(mk); err == nil && parsed > 0 && parsed < maxKeys {
+			maxKeys = parsed
+		}
+	}
+
+	versions, err := h.getStorage(c).ListObjectVersions(bucket, prefix)
+	if err != nil {
+		if err == storage.ErrBucketNotFound {
+			h.sendError(c, "NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound)
+			return
+		}
+		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := ListVersionsResponse{
+		Xmlns:           "http://s3.amazonaws.com/doc/2006-03-01/",
+		Name:            bucket,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		Delimiter:       delimiter,
+		MaxKeys:         maxKeys,
+	}
+
+	versions = versionsAfterMarker(versions, keyMarker, versionIdMarker, delimiter)
+
+	count := 0
+	seenPrefixes := make(map[string]bool)
+	for _, v := range versions {
+		if delimiter != "" {
+			if idx := strings.Index(v.Key[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := v.Key[:len(prefix)+idx+len(delimiter)]
+				if seenPrefixes[commonPrefix] {
+					continue
+				}
+				if count == maxKeys {
+					response.IsTruncated = true
+					break
+				}
+				seenPrefixes[commonPrefix] = true
+				response.CommonPrefixes = append(response.CommonPrefixes, CommonPrefix{Prefix: commonPrefix})
+				response.NextKeyMarker = commonPrefix
+				response.NextVersionIdMarker = ""
+				count++
+				continue
+			}
+		}
+
+		if count == maxKeys {
+			response.IsTruncated = true
+			break
+		}
+
+		if v.IsDeleteMarker {
+			response.DeleteMarkers = append(response.DeleteMarkers, DeleteMarkerInfo{
+				Key:          v.Key,
+				VersionId:    v.VersionId,
+				IsLatest:     v.IsLatest,
+				LastModified: v.LastModified,
+			})
+		} else {
+			response.Versions = append(response.Versions, ObjectVersionInfo{
+				Key:          v.Key,
+				VersionId:    v.VersionId,
+				IsLatest:     v.IsLatest,
+				LastModified: v.LastModified,
+				ETag:         v.ETag,
+				Size:         v.Size,
+				StorageClass: "STANDARD",
+			})
+		}
+		response.NextKeyMarker = v.Key
+		response.NextVersionIdMarker = v.VersionId
+		count++
+	}
+
+	if !response.IsTruncated {
+		response.NextKeyMarker = ""
+		response.NextVersionIdMarker = ""
+	}
+
+	c.Header("Content-Type", "application/xml")
+	c.XML(http.StatusOK, response)
+}
+
+// versionsAfterMarker drops the versions up to and including the position
+// described by key-marker and version-id-marker. A key marker that names a
+// common prefix skips every key below it.
+func versionsAfterMarker(versions []storage.ObjectVersion, keyMarker, versionIdMarker, delimiter string) []storage.ObjectVersion {
+	if keyMarker == "" {
+		return versions
+	}
+
+	for i, v := range versions {
+		if delimiter != "" && strings.HasSuffix(keyMarker, delimiter) && strings.HasPrefix(v.Key, keyMarker) {
+			continue
+		}
+		if v.Key < keyMarker {
+			continue
+		}
+		if v.Key == keyMarker {
+			if versionIdMarker == "" {
+				continue
+			}
+			// Skip this key's versions up to and including the marker
+			for j := i; j < len(versions) && versions[j].Key == keyMarker; j++ {
+				if versions[j].VersionId == versionIdMarker {
+					return versions[j+1:]
+				}
+			}
+			continue
+		}
+		return versions[i:]
+	}
+
+	return nil
+}
+
+// sendObjectVersionError writes the response for a failed version-aware
+// object lookup. Requests that resolve to a delete marker report it through
+// the x-amz-delete-marker header.
+func (h *Handler) sendObjectVersionError(c *gin.Context, meta *storage.ObjectMetadata, versionId string, err error) {
+	switch err {
+	case storage.ErrDeleteMarker:
+		c.Header("x-amz-delete-marker", "true")
+		c.Header("x-amz-version-id", meta.VersionId)
+		if versionId != "" {
+			c.Header("Last-Modified", meta.LastModified.Format(http.TimeFormat))
+			h.sendS3Error(c, S3Error{
+				Code:    ErrMethodNotAllowed,
+				Message: "The specified method is not allowed against this resource.",
+			})
+			return
+		}
+		h.sendError(c, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+	case storage.ErrObjectNotFound:
+		h.sendError(c, "NoSuchKey", "The specified key does not exist", http.StatusNotFound)
+	case storage.ErrVersionNotFound:
+		h.sendS3Error(c, S3Error{
+			Code:    ErrNoSuchVersion,
+			Message: "The specified version does not exist",
+		})
+	case storage.ErrBucketNotFound:
+		h.sendError(c, "NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound)
+	default:
+		h.sendError(c, "InternalError", err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// setVersionIdHeader reports the version created by a write in the
+// x-amz-version-id header. Buckets without versioning report no version.
+func (h *Handler) setVersionIdHeader(c *gin.Context, bucket, key string) {
+	meta, err := h.getStorage(c).GetObjectMetadata(bucket, key)
+	if err == nil && meta.VersionId != "" {
+		c.Header("x-amz-version-id", meta.VersionId)
+	}
+}
+
+// deleteObjectVersion deletes a key or one of its versions. It returns the
+// version that was removed or the delete marker that was created, if any, and
+// whether that version is a delete marker. Deleting a missing key or version
+// is not an error.
+func (h *Handler) deleteObjectVersion(c *gin.Context, bucket, key, versionId string) (string, bool, error) {
+	store := h.getStorage(c)
+
+	if versionId != "" {
+		meta, err := store.GetObjectVersionMetadata(bucket, key, versionId)
+		switch err {
+		case nil, storage.ErrDeleteMarker:
+		case storage.ErrVersionNotFound, storage.ErrObjectNotFound:
+			return versionId, false, nil
+		default:
+			return "", false, err
+		}
+		if err := store.DeleteObjectVersion(bucket, key, versionId); err != nil && err != storage.ErrVersionNotFound {
+			return "", false, err
+		}
+		return versionId, meta.DeleteMarker, nil
+	}
+
+	if err := store.DeleteObject(bucket, key); err != nil && err != storage.ErrObjectNotFound {
+		return "", false, err
+	}
+
+	if meta, err := store.GetObjectVersionMetadata(bucket, key, ""); err == storage.ErrDeleteMarker {
+		return meta.VersionId, true, nil
+	}
+	return "", false, nil
+}
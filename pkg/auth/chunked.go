@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	autherrors "github.com/wozozo/s3pit/pkg/errors"
+)
+
+// x-amz-content-sha256 values announcing an aws-chunked request body
+const (
+	StreamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// emptySHA256 is the hex SHA256 of an empty string, part of every chunk's
+// string to sign
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// maxChunkLineLength bounds chunk headers and trailer lines so a malformed
+// body cannot make the decoder buffer without limit
+const maxChunkLineLength = 4096
+
+// IsStreamingPayload reports whether an x-amz-content-sha256 value announces
+// an aws-chunked body
+func IsStreamingPayload(payloadHash string) bool {
+	return payloadHash == StreamingPayload ||
+		payloadHash == StreamingPayloadTrailer ||
+		payloadHash == StreamingUnsignedTrailer
+}
+
+// ChunkSigningContext holds what is needed to verify the chunk signatures of
+// an aws-chunked body: the signing key and scope of the request and the seed
+// signature from its Authorization header
+type ChunkSigningContext struct {
+	signingKey    []byte
+	amzDate       string
+	scope         string
+	seedSignature string
+}
+
+type chunkSigningContextKey struct{}
+
+// attachChunkSigningContext stores the signing context on an authenticated
+// request so the handler decoding its body can verify each chunk
+func attachChunkSigningContext(r *http.Request, signing *ChunkSigningContext) {
+	*r = *r.WithContext(context.WithValue(r.Context(), chunkSigningContextKey{}, signing))
+}
+
+// ChunkSigningContextFromRequest returns the signing context stored by the
+// authentication handler, or nil when the body was not signed chunk by chunk
+func ChunkSigningContextFromRequest(r *http.Request) *ChunkSigningContext {
+	signing, _ := r.Context().Value(chunkSigningContextKey{}).(*ChunkSigningContext)
+	return signing
+}
+
+// sign computes the signature of a chunk or trailer string to sign
+func (s *ChunkSigningContext) sign(algorithm, previousSignature, hashedContent string, extra ...string) string {
+	parts := append([]string{algorithm, s.amzDate, s.scope, previousSignature}, extra...)
+	parts = append(parts, hashedContent)
+	return hex.EncodeToString(hmacSHA256Multi(s.signingKey, []byte(strings.Join(parts, "\n"))))
+}
+
+// ChunkedReader decodes an aws-chunked request body. Each chunk is prefixed
+// with its hex size and, for signed payloads, a signature chained from the
+// seed signature of the request:
+//
+//	<hex-size>;chunk-signature=<signature>\r\n<data>\r\n
+//
+// The body ends with an empty chunk, optionally followed by trailing headers
+// such as x-amz-checksum-crc32. Signature failures, malformed framing and a
+// decoded length other than x-amz-decoded-content-length are returned from
+// Read in place of io.EOF.
+type ChunkedReader struct {
+	reader  *bufio.Reader
+	signing *ChunkSigningContext
+	signed  bool
+	trailer bool
+
+	previousSignature string
+	chunkSignature    string
+	chunkHash         hash.Hash
+	remaining         int64
+
+	decoded       int64
+	decodedLength int64
+	trailers      http.Header
+	err           error
+}
+
+// NewChunkedReader decodes body as announced by payloadHash. decodedLength is
+// the x-amz-decoded-content-length of the request, or -1 when unknown. When
+// signing is nil chunk signatures are parsed but not verified.
+func NewChunkedReader(body io.Reader, payloadHash string, decodedLength int64, signing *ChunkSigningContext) *ChunkedReader {
+	cr := &ChunkedReader{
+		reader:        bufio.NewReader(body),
+		signing:       signing,
+		signed:        payloadHash == StreamingPayload || payloadHash == StreamingPayloadTrailer,
+		trailer:       payloadHash == StreamingPayloadTrailer || payloadHash == StreamingUnsignedTrailer,
+		decodedLength: decodedLength,
+		chunkHash:     sha256.New(),
+	}
+	if signing != nil {
+		cr.previousSignature = signing.seedSignature
+	}
+	return cr
+}
+
+// Trailers returns the trailing headers of the body. It is only complete once
+// Read has returned io.EOF.
+func (cr *ChunkedReader) Trailers() http.Header {
+	return cr.trailers
+}
+
+func (cr *ChunkedReader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+
+	if cr.remaining == 0 {
+		if err := cr.nextChunk(); err != nil {
+			cr.err = err
+			return 0, err
+		}
+		if cr.err != nil {
+			return 0, cr.err
+		}
+	}
+
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.reader.Read(p)
+	cr.chunkHash.Write(p[:n])
+	cr.remaining -= int64(n)
+	cr.decoded += int64(n)
+
+	if cr.decodedLength >= 0 && cr.decoded > cr.decodedLength {
+		cr.err = autherrors.ErrDecodedLengthMismatch
+		return n, cr.err
+	}
+
+	if err == io.EOF {
+		cr.err = autherrors.ErrMalformedChunk
+		return n, cr.err
+	}
+	if err != nil {
+		cr.err = err
+		return n, err
+	}
+
+	if cr.remaining == 0 {
+		if err := cr.finishChunk(); err != nil {
+			cr.err = err
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// nextChunk reads a chunk header. The final, empty chunk ends the body: its
+// signature and the trailers are verified and io.EOF is recorded.
+func (cr *ChunkedReader) nextChunk() error {
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+
+	sizeField, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return autherrors.ErrMalformedChunk
+	}
+
+	cr.chunkSignature = ""
+	if cr.signed {
+		signature, ok := strings.CutPrefix(extension, "chunk-signature=")
+		if !ok || signature == "" {
+			return autherrors.ErrMalformedChunk
+		}
+		cr.chunkSignature = signature
+	}
+
+	cr.chunkHash.Reset()
+	cr.remaining = size
+	if size > 0 {
+		return nil
+	}
+
+	if err := cr.verifyChunk(); err != nil {
+		return err
+	}
+	if cr.trailer {
+		if err := cr.readTrailers(); err != nil {
+			return err
+		}
+	} else if _, err := cr.readLine(); err != nil && err != autherrors.ErrMalformedChunk {
+		// The blank line after the final chunk is optional
+		return err
+	}
+
+	if cr.decodedLength >= 0 && cr.decoded != cr.decodedLength {
+		return autherrors.ErrDecodedLengthMismatch
+	}
+
+	cr.err = io.EOF
+	return nil
+}
+
+// finishChunk consumes the CRLF after a chunk's data and verifies its signature
+func (cr *ChunkedReader) finishChunk() error {
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return autherrors.ErrMalformedChunk
+	}
+	return cr.verifyChunk()
+}
+
+// verifyChunk checks the signature of the chunk just read against the
+// signature chain
+func (cr *ChunkedReader) verifyChunk() error {
+	if !cr.signed || cr.signing == nil {
+		return nil
+	}
+
+	expected := cr.signing.sign("AWS4-HMAC-SHA256-PAYLOAD", cr.previousSignature,
+		hex.EncodeToString(cr.chunkHash.Sum(nil)), emptySHA256)
+	if !hmac.Equal([]byte(expected), []byte(cr.chunkSignature)) {
+		return autherrors.ErrChunkSignatureMismatch
+	}
+
+	cr.previousSignature = cr.chunkSignature
+	return nil
+}
+
+// readTrailers reads the trailing headers up to the terminating blank line.
+// Signed payloads end the trailers with x-amz-trailer-signature, which covers
+// the other trailing headers.
+func (cr *ChunkedReader) readTrailers() error {
+	cr.trailers = make(http.Header)
+
+	var canonical strings.Builder
+	var signature string
+	for {
+		line, err := cr.readLine()
+		if err == autherrors.ErrMalformedChunk && line == "" {
+			// Some clients omit the blank line ending the trailers
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return autherrors.ErrMalformedChunk
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+
+		if name == "x-amz-trailer-signature" {
+			signature = value
+			continue
+		}
+		cr.trailers.Add(name, value)
+		fmt.Fprintf(&canonical, "%s:%s\n", name, value)
+	}
+
+	if !cr.signed || cr.signing == nil {
+		return nil
+	}
+	if signature == "" {
+		return autherrors.ErrMalformedChunk
+	}
+
+	hashed := sha256.Sum256([]byte(canonical.String()))
+	expected := cr.signing.sign("AWS4-HMAC-SHA256-TRAILER", cr.previousSignature, hex.EncodeToString(hashed[:]))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return autherrors.ErrChunkSignatureMismatch
+	}
+
+	return nil
+}
+
+// readLine reads a CRLF terminated line without its terminator. A body that
+// ends before the line does is malformed.
+func (cr *ChunkedReader) readLine() (string, error) {
+	var line []byte
+	for {
+		fragment, isPrefix, err := cr.reader.ReadLine()
+		if err != nil {
+			if err == io.EOF {
+				return "", autherrors.ErrMalformedChunk
+			}
+			return "", err
+		}
+		line = append(line, fragment...)
+		if len(line) > maxChunkLineLength {
+			return "", autherrors.ErrMalformedChunk
+		}
+		if !isPrefix {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+	}
+}
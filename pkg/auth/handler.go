@@ -2,6 +2,7 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -26,6 +27,27 @@ const (
 	ModeSigV4 AuthMode = "sigv4"
 )
 
+type signedPathKey struct{}
+
+// WithSignedPath returns a shallow copy of r remembering the path the client
+// signed. Virtual-hosted-style requests are routed by a path with the bucket
+// prepended, but their signature covers the path they were sent to.
+func WithSignedPath(r *http.Request, path string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), signedPathKey{}, path))
+}
+
+// canonicalURI returns the canonical URI of a request's signature
+func canonicalURI(r *http.Request) string {
+	path, ok := r.Context().Value(signedPathKey{}).(string)
+	if !ok {
+		path = r.URL.Path
+	}
+	if path == "" {
+		path = "/"
+	}
+	return path
+}
+
 type handler struct {
 	mode            AuthMode
 	accessKeyID     string
@@ -196,10 +218,7 @@ func (h *handler) buildCanonicalRequest(r *http.Request, signedHeaders string) s
 	method := r.Method
 
 	// Canonical URI
-	uri := r.URL.Path
-	if uri == "" {
-		uri = "/"
-	}
+	uri := canonicalURI(r)
 
 	// Canonical Query String
 	queryKeys := make([]string, 0, len(r.URL.Query()))
@@ -249,10 +268,7 @@ func (h *handler) buildCanonicalRequest(r *http.Request, signedHeaders string) s
 func (h *handler) buildCanonicalRequestForQuery(r *http.Request, signedHeaders string) string {
 	// For presigned URLs, the canonical query string excludes the signature
 	method := r.Method
-	uri := r.URL.Path
-	if uri == "" {
-		uri = "/"
-	}
+	uri := canonicalURI(r)
 
 	// Build query string without signature
 	queryKeys := make([]string, 0, len(r.URL.Query()))
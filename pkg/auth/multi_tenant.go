@@ -157,6 +157,16 @@ func (h *MultiTenantHandler) authenticateSigV4(r *http.Request) (string, error)
 		return "", autherrors.ErrSignatureMismatch
 	}
 
+	// Streaming uploads sign each chunk of the body, chained from this signature
+	if IsStreamingPayload(r.Header.Get("X-Amz-Content-Sha256")) {
+		attachChunkSigningContext(r, &ChunkSigningContext{
+			signingKey:    h.getSigningKey(secretKey, credentialScope[0], credentialScope[1], credentialScope[2]),
+			amzDate:       requestAmzDate(r),
+			scope:         strings.Join(credentialScope, "/"),
+			seedSignature: signature,
+		})
+	}
+
 	return accessKey, nil
 }
 
@@ -230,16 +240,7 @@ func (h *MultiTenantHandler) calculateSignature(r *http.Request, accessKey, secr
 
 	// Step 2: Create string to sign
 	dateStamp := credentialScope[0]
-	amzDate := r.Header.Get("X-Amz-Date")
-	if amzDate == "" {
-		// Fallback to Date header
-		if dateHeader := r.Header.Get("Date"); dateHeader != "" {
-			t, err := time.Parse(time.RFC1123, dateHeader)
-			if err == nil {
-				amzDate = t.Format("20060102T150405Z")
-			}
-		}
-	}
+	amzDate := requestAmzDate(r)
 
 	credentialScopeStr := strings.Join(credentialScope, "/")
 	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%x",
@@ -254,6 +255,22 @@ func (h *MultiTenantHandler) calculateSignature(r *http.Request, accessKey, secr
 	return hex.EncodeToString(signature), nil
 }
 
+// requestAmzDate returns the X-Amz-Date of a header-signed request, falling
+// back to the Date header
+func requestAmzDate(r *http.Request) string {
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		// Fallback to Date header
+		if dateHeader := r.Header.Get("Date"); dateHeader != "" {
+			t, err := time.Parse(time.RFC1123, dateHeader)
+			if err == nil {
+				amzDate = t.Format("20060102T150405Z")
+			}
+		}
+	}
+	return amzDate
+}
+
 func (h *MultiTenantHandler) calculatePresignedSignature(r *http.Request, accessKey, secretKey string, credentialScope []string, signedHeaders string) (string, error) {
 	// For presigned URLs, create a modified request without the signature
 	modifiedURL := *r.URL
@@ -285,11 +302,9 @@ func (h *MultiTenantHandler) calculatePresignedSignature(r *http.Request, access
 }
 
 func (h *MultiTenantHandler) createCanonicalRequest(r *http.Request, signedHeaders string) string {
-	// Get canonical URI
-	canonicalURI := r.URL.Path
-	if canonicalURI == "" {
-		canonicalURI = "/"
-	}
+	// Get canonical URI, which for virtual-hosted-style requests is the path
+	// before the bucket was prepended
+	canonicalURI := canonicalURI(r)
 
 	// Get canonical query string
 	canonicalQueryString := h.getCanonicalQueryString(r.URL.Query())
@@ -326,10 +341,7 @@ func (h *MultiTenantHandler) createCanonicalRequest(r *http.Request, signedHeade
 
 func (h *MultiTenantHandler) createCanonicalRequestForPresigned(r *http.Request, signedHeaders string) string {
 	// Get canonical URI
-	canonicalURI := r.URL.Path
-	if canonicalURI == "" {
-		canonicalURI = "/"
-	}
+	canonicalURI := canonicalURI(r)
 
 	// Get canonical query string (includes all X-Amz-* parameters except Signature)
 	canonicalQueryString := h.getCanonicalQueryString(r.URL.Query())
@@ -1,12 +1,18 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	autherrors "github.com/wozozo/s3pit/pkg/errors"
 	"github.com/wozozo/s3pit/pkg/tenant"
 )
 
@@ -248,3 +254,198 @@ func TestMultiTenantHandler_PresignedURLExpiration(t *testing.T) {
 		})
 	}
 }
+
+// encodeChunked frames chunks as an aws-chunked body. Chunks are signed when
+// signing is not nil, and trailers are appended after the final chunk.
+func encodeChunked(signing *ChunkSigningContext, chunks []string, trailers []string) string {
+	var body strings.Builder
+	previous := ""
+	if signing != nil {
+		previous = signing.seedSignature
+	}
+
+	for _, chunk := range append(chunks, "") {
+		fmt.Fprintf(&body, "%x", len(chunk))
+		if signing != nil {
+			hashed := sha256.Sum256([]byte(chunk))
+			previous = signing.sign("AWS4-HMAC-SHA256-PAYLOAD", previous, hex.EncodeToString(hashed[:]), emptySHA256)
+			fmt.Fprintf(&body, ";chunk-signature=%s", previous)
+		}
+		body.WriteString("\r\n")
+		if chunk != "" {
+			body.WriteString(chunk + "\r\n")
+		}
+	}
+
+	if trailers == nil {
+		return body.String() + "\r\n"
+	}
+	var canonical string
+	for _, trailer := range trailers {
+		body.WriteString(trailer + "\r\n")
+		canonical += trailer + "\n"
+	}
+	if signing != nil {
+		hashed := sha256.Sum256([]byte(canonical))
+		signature := signing.sign("AWS4-HMAC-SHA256-TRAILER", previous, hex.EncodeToString(hashed[:]))
+		body.WriteString("x-amz-trailer-signature:" + signature + "\r\n")
+	}
+	return body.String() + "\r\n"
+}
+
+func TestMultiTenantHandler_ChunkedPayload(t *testing.T) {
+	tenantManager := tenant.NewManager("")
+	_ = tenantManager.AddTenant(&tenant.Tenant{
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+
+	handler := &MultiTenantHandler{
+		mode:          ModeSigV4,
+		tenantManager: tenantManager,
+	}
+
+	// signedRequest authenticates a streaming upload and returns the signing
+	// context the handler attached to it
+	signedRequest := func(t *testing.T, payloadHash string) *ChunkSigningContext {
+		req, _ := http.NewRequest("PUT", "http://localhost:3333/bucket/key", nil)
+		req.Header.Set("X-Amz-Date", "20250809T120000Z")
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+		scope := []string{"20250809", "us-east-1", "s3", "aws4_request"}
+		signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+		signature, _ := handler.calculateSignature(req, "test-key", "test-secret", scope, signedHeaders)
+		req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=test-key/%s, SignedHeaders=%s, Signature=%s",
+			strings.Join(scope, "/"), signedHeaders, signature))
+
+		if _, err := handler.Authenticate(req); err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+		signing := ChunkSigningContextFromRequest(req)
+		if signing == nil {
+			t.Fatal("Expected a chunk signing context on the request")
+		}
+		if signing.seedSignature != signature {
+			t.Errorf("Expected seed signature %s, got %s", signature, signing.seedSignature)
+		}
+		return signing
+	}
+
+	decode := func(body, payloadHash string, decodedLength int64, signing *ChunkSigningContext) (string, *ChunkedReader, error) {
+		reader := NewChunkedReader(strings.NewReader(body), payloadHash, decodedLength, signing)
+		data, err := io.ReadAll(reader)
+		return string(data), reader, err
+	}
+
+	t.Run("SignedChunks", func(t *testing.T) {
+		signing := signedRequest(t, StreamingPayload)
+		body := encodeChunked(signing, []string{"hello ", "chunked world"}, nil)
+
+		data, _, err := decode(body, StreamingPayload, 19, signing)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if data != "hello chunked world" {
+			t.Errorf("Expected decoded body, got %q", data)
+		}
+	})
+
+	t.Run("TamperedChunk", func(t *testing.T) {
+		signing := signedRequest(t, StreamingPayload)
+		body := strings.Replace(encodeChunked(signing, []string{"hello ", "chunked world"}, nil), "chunked", "CHUNKED", 1)
+
+		if _, _, err := decode(body, StreamingPayload, 19, signing); !errors.Is(err, autherrors.ErrChunkSignatureMismatch) {
+			t.Errorf("Expected ErrChunkSignatureMismatch, got %v", err)
+		}
+	})
+
+	t.Run("SignedTrailer", func(t *testing.T) {
+		signing := signedRequest(t, StreamingPayloadTrailer)
+		body := encodeChunked(signing, []string{"trailer body"}, []string{"x-amz-checksum-crc32:ZCNxvw=="})
+
+		data, reader, err := decode(body, StreamingPayloadTrailer, 12, signing)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if data != "trailer body" {
+			t.Errorf("Expected decoded body, got %q", data)
+		}
+		if got := reader.Trailers().Get("x-amz-checksum-crc32"); got != "ZCNxvw==" {
+			t.Errorf("Expected checksum trailer, got %q", got)
+		}
+
+		tampered := strings.Replace(body, "ZCNxvw==", "AAAAAA==", 1)
+		if _, _, err := decode(tampered, StreamingPayloadTrailer, 12, signing); !errors.Is(err, autherrors.ErrChunkSignatureMismatch) {
+			t.Errorf("Expected ErrChunkSignatureMismatch for a tampered trailer, got %v", err)
+		}
+	})
+
+	t.Run("UnsignedTrailer", func(t *testing.T) {
+		body := encodeChunked(nil, []string{"unsigned"}, []string{"x-amz-checksum-sha256:abc="})
+
+		data, reader, err := decode(body, StreamingUnsignedTrailer, 8, nil)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if data != "unsigned" || reader.Trailers().Get("x-amz-checksum-sha256") != "abc=" {
+			t.Errorf("Unexpected body %q or trailers %v", data, reader.Trailers())
+		}
+	})
+
+	t.Run("DecodedLengthMismatch", func(t *testing.T) {
+		body := encodeChunked(nil, []string{"short"}, []string{})
+
+		if _, _, err := decode(body, StreamingUnsignedTrailer, 10, nil); !errors.Is(err, autherrors.ErrDecodedLengthMismatch) {
+			t.Errorf("Expected ErrDecodedLengthMismatch, got %v", err)
+		}
+	})
+
+	t.Run("TruncatedBody", func(t *testing.T) {
+		signing := signedRequest(t, StreamingPayload)
+		body := encodeChunked(signing, []string{"hello world"}, nil)
+
+		if _, _, err := decode(body[:len(body)/2], StreamingPayload, 11, signing); !errors.Is(err, autherrors.ErrMalformedChunk) {
+			t.Errorf("Expected ErrMalformedChunk, got %v", err)
+		}
+	})
+}
+
+func TestMultiTenantHandler_VirtualHostedStyle(t *testing.T) {
+	tenantManager := tenant.NewManager("")
+	_ = tenantManager.AddTenant(&tenant.Tenant{
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+
+	handler := &MultiTenantHandler{
+		mode:          ModeSigV4,
+		tenantManager: tenantManager,
+	}
+
+	// The client signs the path it sends the request to, without the bucket
+	req, _ := http.NewRequest("GET", "http://bucket.s3pit.localhost:3333/dir/key.txt", nil)
+	req.Header.Set("X-Amz-Date", "20250809T120000Z")
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+
+	scope := []string{"20250809", "us-east-1", "s3", "aws4_request"}
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	signature, _ := handler.calculateSignature(req, "test-key", "test-secret", scope, signedHeaders)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=test-key/%s, SignedHeaders=%s, Signature=%s",
+		strings.Join(scope, "/"), signedHeaders, signature))
+
+	// routed returns the request as the server routes it, with the bucket
+	// prepended to the path
+	routed := func(r *http.Request) *http.Request {
+		u := *r.URL
+		u.Path = "/bucket" + u.Path
+		r.URL = &u
+		return r
+	}
+
+	if accessKey, err := handler.Authenticate(routed(WithSignedPath(req, req.URL.Path))); err != nil || accessKey != "test-key" {
+		t.Errorf("Expected the signed path to authenticate, got %q, %v", accessKey, err)
+	}
+	if _, err := handler.Authenticate(routed(req.Clone(req.Context()))); !errors.Is(err, autherrors.ErrSignatureMismatch) {
+		t.Errorf("Expected ErrSignatureMismatch against the rewritten path, got %v", err)
+	}
+}
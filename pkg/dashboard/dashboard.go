@@ -328,6 +328,11 @@ func (h *Handler) handleListObjects(c *gin.Context) {
 						return nil
 					}
 
+					if info.IsDir() && strings.Contains(info.Name(), ".s3pit_") {
+						// Skip version history directories
+						return filepath.SkipDir
+					}
+
 					if info.IsDir() || strings.Contains(info.Name(), ".s3pit_") {
 						return nil
 					}
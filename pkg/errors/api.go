@@ -16,7 +16,10 @@ func MapStorageErrorToS3(err error) (code string, message string) {
 		return "BucketAlreadyExists", "The requested bucket name is not available"
 	case errors.Is(err, ErrObjectNotFound):
 		return "NoSuchKey", "The specified key does not exist"
-	case errors.Is(err, ErrUploadNotFound):
+	case errors.Is(err, ErrVersionNotFound):
+		return "NoSuchVersion", "The specified version does not exist"
+	case errors.Is(err, ErrUploadNotFound),
+		errors.Is(err, ErrUploadMismatch):
 		return "NoSuchUpload", "The specified upload does not exist"
 	case errors.Is(err, ErrInvalidBucketName),
 		errors.Is(err, ErrBucketNameEmpty),
@@ -29,8 +32,31 @@ func MapStorageErrorToS3(err error) (code string, message string) {
 		errors.Is(err, ErrObjectKeyTooLong),
 		errors.Is(err, ErrObjectKeyNullBytes):
 		return "InvalidObjectName", "The specified key is not valid"
-	case errors.Is(err, ErrPartNotFound):
-		return "InvalidPart", "One or more of the specified parts could not be found"
+	case errors.Is(err, ErrEntityTooLarge):
+		return "EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size"
+	case errors.Is(err, ErrBadDigest):
+		return "BadDigest", "The Content-MD5 you specified did not match what we received"
+	case errors.Is(err, ErrChecksumMismatch):
+		return "BadDigest", "The checksum you specified did not match the calculated checksum"
+	case errors.Is(err, ErrContentSHA256Mismatch):
+		return "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed"
+	case errors.Is(err, ErrPartNotFound),
+		errors.Is(err, ErrInvalidPart):
+		return "InvalidPart", "One or more of the specified parts could not be found. The part may not have been uploaded, or the specified entity tag may not match the part's entity tag."
+	case errors.Is(err, ErrInvalidPartOrder):
+		return "InvalidPartOrder", "The list of parts was not in ascending order. Parts must be ordered by part number."
+	case errors.Is(err, ErrInvalidRange):
+		return "InvalidRange", "The requested range is not satisfiable"
+	case errors.Is(err, ErrPolicyParseFailed):
+		return "MalformedPolicy", "Policies must be valid JSON and the first byte must be '{'"
+	case errors.Is(err, ErrEntityTooSmall):
+		return "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size."
+	// aws-chunked decoding errors surface while the body is written
+	case errors.Is(err, ErrChunkSignatureMismatch):
+		return "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided"
+	case errors.Is(err, ErrMalformedChunk),
+		errors.Is(err, ErrDecodedLengthMismatch):
+		return "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header"
 	default:
 		// Default to internal error for unknown errors
 		return "InternalError", err.Error()
@@ -45,7 +71,8 @@ func MapAuthErrorToS3(err error) (code string, message string) {
 	case errors.Is(err, ErrInvalidAccessKey),
 		errors.Is(err, ErrAccessKeyNotFound):
 		return "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records"
-	case errors.Is(err, ErrSignatureMismatch):
+	case errors.Is(err, ErrSignatureMismatch),
+		errors.Is(err, ErrChunkSignatureMismatch):
 		return "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided"
 	case errors.Is(err, ErrPresignedURLExpired):
 		return "AccessDenied", "Request has expired"
@@ -31,6 +31,11 @@ var (
 	ErrSignatureMismatch    = errors.New("signature mismatch")
 	ErrMissingSignedHeaders = errors.New("missing signed headers")
 
+	// Streaming payload errors
+	ErrChunkSignatureMismatch = errors.New("chunk signature mismatch")
+	ErrMalformedChunk         = errors.New("malformed aws-chunked payload")
+	ErrDecodedLengthMismatch  = errors.New("decoded content length does not match x-amz-decoded-content-length")
+
 	// Date/time errors
 	ErrMissingDate          = errors.New("missing date")
 	ErrInvalidDateFormat    = errors.New("invalid date format")
@@ -75,7 +80,8 @@ func IsAuthenticationError(err error) bool {
 		errors.Is(err, ErrMissingAccessKey) ||
 		errors.Is(err, ErrInvalidAccessKey) ||
 		errors.Is(err, ErrAccessKeyNotFound) ||
-		errors.Is(err, ErrSignatureMismatch)
+		errors.Is(err, ErrSignatureMismatch) ||
+		errors.Is(err, ErrChunkSignatureMismatch)
 }
 
 // IsExpiredError checks if an error is due to expiration
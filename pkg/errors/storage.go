@@ -24,10 +24,25 @@ var (
 	ErrObjectKeyTooLong   = errors.New("object key is too long (max 1024 bytes)")
 	ErrObjectKeyNullBytes = errors.New("object key cannot contain null bytes")
 
+	// Object body errors
+	ErrEntityTooLarge        = errors.New("object exceeds the maximum allowed size")
+	ErrBadDigest             = errors.New("content MD5 does not match the received data")
+	ErrContentSHA256Mismatch = errors.New("content SHA256 does not match the received data")
+	ErrChecksumMismatch      = errors.New("checksum does not match the received data")
+
 	// Multipart upload errors
-	ErrUploadNotFound = errors.New("upload not found")
-	ErrUploadMismatch = errors.New("upload mismatch")
-	ErrPartNotFound   = errors.New("part not found")
+	ErrUploadNotFound   = errors.New("upload not found")
+	ErrUploadMismatch   = errors.New("upload mismatch")
+	ErrPartNotFound     = errors.New("part not found")
+	ErrInvalidPart      = errors.New("part etag does not match")
+	ErrInvalidPartOrder = errors.New("parts are not in ascending order")
+	ErrEntityTooSmall   = errors.New("part is smaller than the minimum allowed size")
+	ErrInvalidRange     = errors.New("range is not satisfiable for the source object")
+
+	// Versioning errors
+	ErrVersionNotFound         = errors.New("version not found")
+	ErrDeleteMarker            = errors.New("object version is a delete marker")
+	ErrInvalidVersioningStatus = errors.New("invalid versioning status")
 
 	// Directory/file system errors
 	ErrDirectoryCreation = errors.New("failed to create directory")
@@ -0,0 +1,269 @@
+// Package fault injects failures into S3 requests so clients can test their
+// retry and backoff behaviour against a misbehaving server.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Action is what a rule does to the requests it matches
+type Action string
+
+const (
+	ActionDelay    Action = "delay"    // Sleep before handling the request
+	ActionError    Action = "error"    // Answer with an S3 error instead of handling the request
+	ActionReset    Action = "reset"    // Reset the connection, optionally after part of the response body
+	ActionTruncate Action = "truncate" // Close the connection after part of the response body
+	ActionThrottle Action = "throttle" // Limit the bandwidth of the response body
+)
+
+// ErrRuleNotFound is returned when no rule has the given name
+var ErrRuleNotFound = errors.New("fault rule not found")
+
+// Rule matches requests and injects a fault into them. Empty match fields
+// match every request; bucket and key patterns may contain "*" and "?"
+// wildcards, where "*" also matches "/".
+type Rule struct {
+	Name     string `toml:"name" json:"name"`
+	Disabled bool   `toml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// Match
+	Tenant      string  `toml:"tenant,omitempty" json:"tenant,omitempty"`       // Access key of the tenant
+	Bucket      string  `toml:"bucket,omitempty" json:"bucket,omitempty"`       // Bucket pattern
+	Key         string  `toml:"key,omitempty" json:"key,omitempty"`             // Object key pattern
+	Operation   string  `toml:"operation,omitempty" json:"operation,omitempty"` // READ or WRITE
+	Method      string  `toml:"method,omitempty" json:"method,omitempty"`
+	Probability float64 `toml:"probability,omitempty" json:"probability,omitempty"` // Chance between 0 and 1 (0 = always)
+	Nth         int     `toml:"nth,omitempty" json:"nth,omitempty"`                 // Only the Nth matching request
+	Every       int     `toml:"every,omitempty" json:"every,omitempty"`             // Every Nth matching request
+	Times       int     `toml:"times,omitempty" json:"times,omitempty"`             // At most this many faults (0 = unlimited)
+
+	// Action
+	Action         Action `toml:"action" json:"action"`
+	DelayMs        int    `toml:"delayMs,omitempty" json:"delayMs,omitempty"`
+	Status         int    `toml:"status,omitempty" json:"status,omitempty"`
+	Code           string `toml:"code,omitempty" json:"code,omitempty"`
+	Message        string `toml:"message,omitempty" json:"message,omitempty"`
+	AfterBytes     int64  `toml:"afterBytes,omitempty" json:"afterBytes,omitempty"`         // Response bytes sent before a reset or truncation
+	BytesPerSecond int64  `toml:"bytesPerSecond,omitempty" json:"bytesPerSecond,omitempty"` // Bandwidth of a throttled response
+}
+
+// defaultStatus is the status of the S3 errors commonly injected
+var defaultStatus = map[string]int{
+	"AccessDenied":       http.StatusForbidden,
+	"InternalError":      http.StatusInternalServerError,
+	"NoSuchBucket":       http.StatusNotFound,
+	"NoSuchKey":          http.StatusNotFound,
+	"RequestTimeout":     http.StatusBadRequest,
+	"ServiceUnavailable": http.StatusServiceUnavailable,
+	"SlowDown":           http.StatusServiceUnavailable,
+}
+
+// Validate checks a rule and fills in the defaults of its action
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("%s: probability must be between 0 and 1", r.Name)
+	}
+	if r.Nth < 0 || r.Every < 0 || r.Times < 0 {
+		return fmt.Errorf("%s: nth, every and times must not be negative", r.Name)
+	}
+
+	r.Operation = strings.ToUpper(r.Operation)
+	if r.Operation != "" && r.Operation != "READ" && r.Operation != "WRITE" {
+		return fmt.Errorf("%s: operation must be READ or WRITE", r.Name)
+	}
+	r.Method = strings.ToUpper(r.Method)
+
+	switch r.Action {
+	case ActionDelay:
+		if r.DelayMs <= 0 {
+			return fmt.Errorf("%s: delayMs must be positive", r.Name)
+		}
+	case ActionError:
+		if r.Code == "" {
+			r.Code = "InternalError"
+		}
+		if r.Status == 0 {
+			r.Status = http.StatusInternalServerError
+			if status, ok := defaultStatus[r.Code]; ok {
+				r.Status = status
+			}
+		}
+		if r.Status < 400 || r.Status > 599 {
+			return fmt.Errorf("%s: status must be a 4xx or 5xx status", r.Name)
+		}
+		if r.Message == "" {
+			r.Message = "Fault injected by s3pit"
+		}
+	case ActionReset, ActionTruncate:
+		if r.AfterBytes < 0 {
+			return fmt.Errorf("%s: afterBytes must not be negative", r.Name)
+		}
+	case ActionThrottle:
+		if r.BytesPerSecond <= 0 {
+			return fmt.Errorf("%s: bytesPerSecond must be positive", r.Name)
+		}
+	default:
+		return fmt.Errorf("%s: unknown action %q", r.Name, r.Action)
+	}
+	return nil
+}
+
+// ValidateRules validates every rule and checks their names are unique
+func ValidateRules(rules []Rule) error {
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return fmt.Errorf("fault rule %d: %w", i, err)
+		}
+		if names[rules[i].Name] {
+			return fmt.Errorf("fault rule %d: duplicate name %s", i, rules[i].Name)
+		}
+		names[rules[i].Name] = true
+	}
+	return nil
+}
+
+// Request is what rules are matched against
+type Request struct {
+	Tenant    string
+	Bucket    string
+	Key       string
+	Operation string
+	Method    string
+}
+
+// matches reports whether the match fields of the rule cover a request
+func (r *Rule) matches(req Request) bool {
+	return (r.Tenant == "" || r.Tenant == req.Tenant) &&
+		(r.Bucket == "" || globMatch(r.Bucket, req.Bucket)) &&
+		(r.Key == "" || globMatch(r.Key, req.Key)) &&
+		(r.Operation == "" || r.Operation == req.Operation) &&
+		(r.Method == "" || r.Method == req.Method)
+}
+
+// RuleStatus is a rule together with how often it matched and fired
+type RuleStatus struct {
+	Rule
+	Matched  int `json:"matched"`
+	Injected int `json:"injected"`
+}
+
+// Engine evaluates fault rules against requests. Rules can be replaced and
+// toggled while the server is running. The zero value has no rules.
+type Engine struct {
+	mu    sync.Mutex
+	rules []RuleStatus
+}
+
+// NewEngine creates an engine with validated rules
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{}
+	if err := e.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetRules replaces the rules and resets their counters
+func (e *Engine) SetRules(rules []Rule) error {
+	validated := append([]Rule(nil), rules...)
+	if err := ValidateRules(validated); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = make([]RuleStatus, len(validated))
+	for i, rule := range validated {
+		e.rules[i] = RuleStatus{Rule: rule}
+	}
+	return nil
+}
+
+// Rules returns the rules and their counters
+func (e *Engine) Rules() []RuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]RuleStatus{}, e.rules...)
+}
+
+// SetEnabled enables or disables the rule with the given name
+func (e *Engine) SetEnabled(name string, enabled bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.rules {
+		if e.rules[i].Name == name {
+			e.rules[i].Disabled = !enabled
+			return nil
+		}
+	}
+	return ErrRuleNotFound
+}
+
+// Evaluate returns the first enabled rule that fires for a request, or nil
+// when the request is handled normally. Every matching rule counts the
+// request, so the Nth-request counters of later rules keep running.
+func (e *Engine) Evaluate(req Request) *Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var fired *Rule
+	for i := range e.rules {
+		status := &e.rules[i]
+		if status.Disabled || !status.matches(req) {
+			continue
+		}
+		status.Matched++
+		if fired != nil {
+			continue
+		}
+
+		switch {
+		case status.Nth > 0 && status.Matched != status.Nth:
+		case status.Every > 0 && status.Matched%status.Every != 0:
+		case status.Times > 0 && status.Injected >= status.Times:
+		case status.Probability > 0 && rand.Float64() >= status.Probability:
+		default:
+			status.Injected++
+			rule := status.Rule
+			fired = &rule
+		}
+	}
+	return fired
+}
+
+// globMatch reports whether value matches a pattern where "*" matches any
+// sequence of characters and "?" a single character
+func globMatch(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if globMatch(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(value) == 0 {
+				return false
+			}
+			pattern, value = pattern[1:], value[1:]
+		default:
+			if len(value) == 0 || pattern[0] != value[0] {
+				return false
+			}
+			pattern, value = pattern[1:], value[1:]
+		}
+	}
+	return len(value) == 0
+}
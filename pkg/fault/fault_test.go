@@ -0,0 +1,133 @@
+package fault
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestValidateRules(t *testing.T) {
+	rules := []Rule{
+		{Name: "slow-down", Action: ActionError, Code: "SlowDown"},
+		{Name: "internal", Action: ActionError},
+		{Name: "reads", Action: ActionDelay, DelayMs: 100, Operation: "read", Method: "get"},
+	}
+	if err := ValidateRules(rules); err != nil {
+		t.Fatalf("ValidateRules failed: %v", err)
+	}
+	if rules[0].Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected SlowDown to default to 503, got %d", rules[0].Status)
+	}
+	if rules[1].Code != "InternalError" || rules[1].Status != http.StatusInternalServerError {
+		t.Errorf("Expected an InternalError 500 by default, got %s %d", rules[1].Code, rules[1].Status)
+	}
+	if rules[2].Operation != "READ" || rules[2].Method != "GET" {
+		t.Errorf("Expected operation and method to be upper-cased, got %s %s", rules[2].Operation, rules[2].Method)
+	}
+
+	invalid := map[string][]Rule{
+		"missing name":      {{Action: ActionReset}},
+		"unknown action":    {{Name: "a", Action: "explode"}},
+		"duplicate names":   {{Name: "a", Action: ActionReset}, {Name: "a", Action: ActionTruncate}},
+		"probability":       {{Name: "a", Action: ActionReset, Probability: 1.5}},
+		"operation":         {{Name: "a", Action: ActionReset, Operation: "LIST"}},
+		"delay without ms":  {{Name: "a", Action: ActionDelay}},
+		"throttle":          {{Name: "a", Action: ActionThrottle}},
+		"success status":    {{Name: "a", Action: ActionError, Status: 200}},
+		"negative counters": {{Name: "a", Action: ActionReset, Times: -1}},
+	}
+	for name, rules := range invalid {
+		if err := ValidateRules(rules); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	request := Request{Tenant: "local-dev", Bucket: "uploads", Key: "logs/2025/app.log", Operation: "WRITE", Method: "PUT"}
+
+	t.Run("Match fields", func(t *testing.T) {
+		matching := map[string]Rule{
+			"everything":  {},
+			"tenant":      {Tenant: "local-dev"},
+			"bucket glob": {Bucket: "up*"},
+			"key glob":    {Key: "logs/*.log"},
+			"single char": {Key: "logs/202?/app.log"},
+			"operation":   {Operation: "WRITE", Method: "PUT"},
+		}
+		for name, rule := range matching {
+			if !rule.matches(request) {
+				t.Errorf("Expected %s to match", name)
+			}
+		}
+
+		other := map[string]Rule{
+			"tenant":    {Tenant: "other"},
+			"bucket":    {Bucket: "downloads"},
+			"key":       {Key: "logs/*.txt"},
+			"operation": {Operation: "READ"},
+			"method":    {Method: "GET"},
+		}
+		for name, rule := range other {
+			if rule.matches(request) {
+				t.Errorf("Expected %s not to match", name)
+			}
+		}
+	})
+
+	t.Run("Counters", func(t *testing.T) {
+		engine, err := NewEngine([]Rule{
+			{Name: "first-two", Action: ActionError, Code: "SlowDown", Times: 2},
+			{Name: "third", Action: ActionReset, Nth: 3},
+			{Name: "every-other", Action: ActionTruncate, Every: 2},
+		})
+		if err != nil {
+			t.Fatalf("NewEngine failed: %v", err)
+		}
+
+		var fired []string
+		for i := 0; i < 6; i++ {
+			name := ""
+			if rule := engine.Evaluate(request); rule != nil {
+				name = rule.Name
+			}
+			fired = append(fired, name)
+		}
+		expected := []string{"first-two", "first-two", "third", "every-other", "", "every-other"}
+		for i := range expected {
+			if fired[i] != expected[i] {
+				t.Errorf("Request %d: expected %q, got %q", i+1, expected[i], fired[i])
+			}
+		}
+
+		statuses := engine.Rules()
+		if statuses[0].Matched != 6 || statuses[0].Injected != 2 {
+			t.Errorf("Expected first-two to match 6 and inject 2, got %d / %d", statuses[0].Matched, statuses[0].Injected)
+		}
+	})
+
+	t.Run("Toggle", func(t *testing.T) {
+		engine, _ := NewEngine([]Rule{{Name: "errors", Action: ActionError}})
+		if err := engine.SetEnabled("errors", false); err != nil {
+			t.Fatalf("SetEnabled failed: %v", err)
+		}
+		if rule := engine.Evaluate(request); rule != nil {
+			t.Errorf("Expected a disabled rule not to fire, got %s", rule.Name)
+		}
+		if err := engine.SetEnabled("errors", true); err != nil {
+			t.Fatalf("SetEnabled failed: %v", err)
+		}
+		if rule := engine.Evaluate(request); rule == nil {
+			t.Error("Expected the enabled rule to fire")
+		}
+		if err := engine.SetEnabled("missing", true); !errors.Is(err, ErrRuleNotFound) {
+			t.Errorf("Expected ErrRuleNotFound, got %v", err)
+		}
+		if err := engine.SetRules([]Rule{{Name: "bad", Action: ActionDelay}}); err == nil {
+			t.Error("Expected SetRules to reject an invalid rule")
+		}
+		if len(engine.Rules()) != 1 {
+			t.Error("Expected a rejected SetRules to keep the previous rules")
+		}
+	})
+}
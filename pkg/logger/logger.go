@@ -1,10 +1,8 @@
 package logger
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -227,8 +225,13 @@ func (l *Logger) Log(level LogLevel, message string, context map[string]interfac
 	l.addEntry(entry)
 }
 
-// LogRequest logs an HTTP request with detailed information
-func (l *Logger) LogRequest(c *gin.Context, start time.Time, responseBody []byte) {
+// maxLoggedBody is the size from which request and response bodies are
+// left out of the log
+const maxLoggedBody = 10 * 1024
+
+// LogRequest logs an HTTP request with detailed information. The bodies are
+// the first bytes read and written, up to maxLoggedBody.
+func (l *Logger) LogRequest(c *gin.Context, start time.Time, requestBody, responseBody []byte) {
 	duration := time.Since(start)
 
 	// Extract request headers
@@ -240,23 +243,19 @@ func (l *Logger) LogRequest(c *gin.Context, start time.Time, responseBody []byte
 	}
 
 	// Extract request body (if not too large)
-	var requestBody string
-	if c.Request.Body != nil && c.Request.ContentLength > 0 && c.Request.ContentLength < 10*1024 { // 10KB limit
-		bodyBytes, _ := io.ReadAll(c.Request.Body)
-		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		if len(bodyBytes) > 0 {
-			requestBody = string(bodyBytes)
-		}
+	var reqBody string
+	if len(requestBody) > 0 && len(requestBody) < maxLoggedBody {
+		reqBody = string(requestBody)
 	}
 
 	// Extract response body (if not too large and not binary)
 	var respBody string
-	if len(responseBody) > 0 && len(responseBody) < 10*1024 && isTextContent(c.Writer.Header().Get("Content-Type")) {
+	if len(responseBody) > 0 && len(responseBody) < maxLoggedBody && isTextContent(c.Writer.Header().Get("Content-Type")) {
 		respBody = string(responseBody)
 	}
 
 	// Determine operation type
-	operation := determineOperation(c)
+	operation := DetermineOperation(c)
 
 	entry := LogEntry{
 		ID:             generateID(),
@@ -270,7 +269,7 @@ func (l *Logger) LogRequest(c *gin.Context, start time.Time, responseBody []byte
 		ClientIP:       c.ClientIP(),
 		UserAgent:      c.Request.UserAgent(),
 		RequestHeaders: headers,
-		RequestBody:    requestBody,
+		RequestBody:    reqBody,
 		ResponseBody:   respBody,
 		Bucket:         c.Param("bucket"),
 		Key:            c.Param("key"),
@@ -479,7 +478,9 @@ func isTextContent(contentType string) bool {
 	return false
 }
 
-func determineOperation(c *gin.Context) string {
+// DetermineOperation returns the S3 operation name of a request, such as
+// PutObject or ListObjects
+func DetermineOperation(c *gin.Context) string {
 	method := c.Request.Method
 	path := c.Request.URL.Path
 	query := c.Request.URL.Query()
@@ -488,10 +489,13 @@ func determineOperation(c *gin.Context) string {
 	if method == "GET" && path == "/" {
 		return "ListBuckets"
 	}
-	if query.Get("uploads") != "" {
+	if query.Has("uploads") {
 		if method == "POST" {
 			return "InitiateMultipartUpload"
 		}
+		if method == "GET" {
+			return "ListMultipartUploads"
+		}
 	}
 	if query.Get("uploadId") != "" {
 		if method == "POST" {
@@ -501,18 +505,51 @@ func determineOperation(c *gin.Context) string {
 			return "AbortMultipartUpload"
 		}
 		if method == "PUT" {
+			if c.GetHeader("x-amz-copy-source") != "" {
+				return "UploadPartCopy"
+			}
 			return "UploadPart"
 		}
+		if method == "GET" {
+			return "ListParts"
+		}
 	}
 	if c.GetHeader("x-amz-copy-source") != "" {
 		return "CopyObject"
 	}
-	if query.Get("cors") != "" {
+	if query.Has("cors") {
 		return method + "BucketCors"
 	}
-	if query.Get("policy") != "" {
+	if query.Has("lifecycle") {
+		return method + "BucketLifecycle"
+	}
+	if query.Has("policyStatus") {
+		return "GetBucketPolicyStatus"
+	}
+	if query.Has("policy") {
 		return method + "BucketPolicy"
 	}
+	if query.Has("versioning") {
+		return method + "BucketVersioning"
+	}
+	if query.Has("versions") {
+		return "ListObjectVersions"
+	}
+	if query.Has("tagging") {
+		if key := c.Param("key"); key != "" && key != "/" {
+			return method + "ObjectTagging"
+		}
+		return method + "BucketTagging"
+	}
+	if query.Has("acl") {
+		if key := c.Param("key"); key != "" && key != "/" {
+			return method + "ObjectAcl"
+		}
+		return method + "BucketAcl"
+	}
+	if query.Has("attributes") && method == "GET" {
+		return "GetObjectAttributes"
+	}
 
 	// Standard operations
 	bucket := c.Param("bucket")
@@ -8,17 +8,42 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// responseWriter wraps gin.ResponseWriter to capture response body
+// responseWriter wraps gin.ResponseWriter to capture the start of the
+// response body
 type responseWriter struct {
 	gin.ResponseWriter
 	body *bytes.Buffer
 }
 
 func (w *responseWriter) Write(data []byte) (int, error) {
-	w.body.Write(data)
+	keepBody(w.body, data)
 	return w.ResponseWriter.Write(data)
 }
 
+// requestBody captures the start of a request body as the handler reads
+// it, so the body is streamed rather than buffered for logging
+type requestBody struct {
+	io.ReadCloser
+	body bytes.Buffer
+}
+
+func (r *requestBody) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	keepBody(&r.body, p[:n])
+	return n, err
+}
+
+// keepBody appends data to buf until it holds maxLoggedBody bytes, the
+// size from which bodies are not logged
+func keepBody(buf *bytes.Buffer, data []byte) {
+	if room := maxLoggedBody - buf.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		buf.Write(data)
+	}
+}
+
 // S3APILoggingMiddleware provides detailed logging for S3 API requests
 func S3APILoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -39,11 +64,11 @@ func S3APILoggingMiddleware() gin.HandlerFunc {
 
 		start := time.Now()
 
-		// Capture request body
-		var requestBody []byte
+		// Capture the start of the request body as it is read
+		reqBody := &requestBody{}
 		if c.Request.Body != nil {
-			requestBody, _ = io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+			reqBody.ReadCloser = c.Request.Body
+			c.Request.Body = reqBody
 		}
 
 		// Wrap response writer to capture response
@@ -57,7 +82,7 @@ func S3APILoggingMiddleware() gin.HandlerFunc {
 		c.Next()
 
 		// Log the request
-		GetInstance().LogRequest(c, start, rw.body.Bytes())
+		GetInstance().LogRequest(c, start, reqBody.body.Bytes(), rw.body.Bytes())
 	}
 }
 
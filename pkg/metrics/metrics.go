@@ -0,0 +1,246 @@
+// Package metrics collects request and storage metrics and renders them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds of the request duration histogram in
+// seconds, the Prometheus client defaults
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Request is a handled S3 request
+type Request struct {
+	Operation string // e.g. PutObject
+	Tenant    string // Access key of the tenant, empty for anonymous requests
+	Bucket    string
+	Status    int
+	ErrorCode string // S3 error code of failed requests with an error body
+	Duration  time.Duration
+	BytesIn   int64 // Request body bytes read
+	BytesOut  int64 // Response body bytes written
+}
+
+// BucketStats is the content of a bucket when the metrics are scraped
+type BucketStats struct {
+	Tenant           string
+	Bucket           string
+	Objects          int64
+	Bytes            int64
+	MultipartUploads int64 // Uploads initiated and neither completed nor aborted
+}
+
+type requestKey struct {
+	operation, tenant, bucket string
+}
+
+type requestStats struct {
+	statuses    map[int]uint64
+	errors      map[string]uint64
+	buckets     []uint64 // Observations per duration bucket, not cumulative
+	count       uint64
+	durationSum float64
+	bytesIn     int64
+	bytesOut    int64
+}
+
+// Registry accumulates request metrics. The zero value is ready to use.
+type Registry struct {
+	mu       sync.Mutex
+	requests map[requestKey]*requestStats
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Observe adds a handled request to the metrics
+func (r *Registry) Observe(req Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.requests == nil {
+		r.requests = make(map[requestKey]*requestStats)
+	}
+	key := requestKey{req.Operation, req.Tenant, req.Bucket}
+	stats, ok := r.requests[key]
+	if !ok {
+		stats = &requestStats{
+			statuses: make(map[int]uint64),
+			errors:   make(map[string]uint64),
+			buckets:  make([]uint64, len(durationBuckets)),
+		}
+		r.requests[key] = stats
+	}
+
+	stats.statuses[req.Status]++
+	if req.ErrorCode != "" {
+		stats.errors[req.ErrorCode]++
+	}
+
+	seconds := req.Duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			stats.buckets[i]++
+			break
+		}
+	}
+	stats.count++
+	stats.durationSum += seconds
+
+	if req.BytesIn > 0 {
+		stats.bytesIn += req.BytesIn
+	}
+	if req.BytesOut > 0 {
+		stats.bytesOut += req.BytesOut
+	}
+}
+
+// Write renders the request metrics and the storage gauges of buckets in
+// the Prometheus text format
+func (r *Registry) Write(w io.Writer, buckets []BucketStats) error {
+	r.mu.Lock()
+	keys := make([]requestKey, 0, len(r.requests))
+	for key := range r.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.operation != b.operation {
+			return a.operation < b.operation
+		}
+		if a.tenant != b.tenant {
+			return a.tenant < b.tenant
+		}
+		return a.bucket < b.bucket
+	})
+
+	bw := bufio.NewWriter(w)
+
+	header(bw, "s3pit_requests_total", "counter", "S3 requests handled, by operation, tenant, bucket and HTTP status.")
+	for _, key := range keys {
+		stats := r.requests[key]
+		statuses := make([]int, 0, len(stats.statuses))
+		for status := range stats.statuses {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			sample(bw, "s3pit_requests_total", key.labels("status", strconv.Itoa(status)), float64(stats.statuses[status]))
+		}
+	}
+
+	header(bw, "s3pit_request_errors_total", "counter", "S3 error responses, by operation, tenant, bucket and S3 error code.")
+	for _, key := range keys {
+		stats := r.requests[key]
+		codes := make([]string, 0, len(stats.errors))
+		for code := range stats.errors {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			sample(bw, "s3pit_request_errors_total", key.labels("code", code), float64(stats.errors[code]))
+		}
+	}
+
+	header(bw, "s3pit_request_duration_seconds", "histogram", "Time taken to handle S3 requests.")
+	for _, key := range keys {
+		stats := r.requests[key]
+		var cumulative uint64
+		for i, bound := range durationBuckets {
+			cumulative += stats.buckets[i]
+			sample(bw, "s3pit_request_duration_seconds_bucket", key.labels("le", formatFloat(bound)), float64(cumulative))
+		}
+		sample(bw, "s3pit_request_duration_seconds_bucket", key.labels("le", "+Inf"), float64(stats.count))
+		sample(bw, "s3pit_request_duration_seconds_sum", key.labels(), stats.durationSum)
+		sample(bw, "s3pit_request_duration_seconds_count", key.labels(), float64(stats.count))
+	}
+
+	header(bw, "s3pit_received_bytes_total", "counter", "Request body bytes received.")
+	for _, key := range keys {
+		sample(bw, "s3pit_received_bytes_total", key.labels(), float64(r.requests[key].bytesIn))
+	}
+
+	header(bw, "s3pit_sent_bytes_total", "counter", "Response body bytes sent.")
+	for _, key := range keys {
+		sample(bw, "s3pit_sent_bytes_total", key.labels(), float64(r.requests[key].bytesOut))
+	}
+	r.mu.Unlock()
+
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Tenant != buckets[j].Tenant {
+			return buckets[i].Tenant < buckets[j].Tenant
+		}
+		return buckets[i].Bucket < buckets[j].Bucket
+	})
+
+	header(bw, "s3pit_bucket_objects", "gauge", "Objects stored in a bucket.")
+	for _, b := range buckets {
+		sample(bw, "s3pit_bucket_objects", bucketLabels(b), float64(b.Objects))
+	}
+
+	header(bw, "s3pit_bucket_bytes", "gauge", "Bytes of the objects stored in a bucket.")
+	for _, b := range buckets {
+		sample(bw, "s3pit_bucket_bytes", bucketLabels(b), float64(b.Bytes))
+	}
+
+	header(bw, "s3pit_multipart_uploads_in_progress", "gauge", "Multipart uploads initiated and neither completed nor aborted.")
+	for _, b := range buckets {
+		sample(bw, "s3pit_multipart_uploads_in_progress", bucketLabels(b), float64(b.MultipartUploads))
+	}
+
+	return bw.Flush()
+}
+
+// labels renders the labels of a request key followed by extra name/value
+// pairs
+func (k requestKey) labels(extra ...string) string {
+	return formatLabels(append([]string{"operation", k.operation, "tenant", k.tenant, "bucket", k.bucket}, extra...))
+}
+
+func bucketLabels(b BucketStats) string {
+	return formatLabels([]string{"tenant", b.Tenant, "bucket", b.Bucket})
+}
+
+func formatLabels(pairs []string) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(pairs[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(w io.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	r.Observe(Request{Operation: "PutObject", Tenant: "dev", Bucket: "photos", Status: 200, Duration: 20 * time.Millisecond, BytesIn: 1024})
+	r.Observe(Request{Operation: "PutObject", Tenant: "dev", Bucket: "photos", Status: 200, Duration: 2 * time.Second, BytesIn: 2048})
+	r.Observe(Request{Operation: "GetObject", Tenant: "dev", Bucket: "photos", Status: 404, ErrorCode: "NoSuchKey", Duration: time.Millisecond, BytesOut: 300})
+	r.Observe(Request{Operation: "GetObject", Bucket: `odd"name`, Status: 403, ErrorCode: "AccessDenied", Duration: time.Millisecond})
+
+	var sb strings.Builder
+	buckets := []BucketStats{
+		{Tenant: "dev", Bucket: "videos", Objects: 1, Bytes: 500},
+		{Tenant: "dev", Bucket: "photos", Objects: 2, Bytes: 3072, MultipartUploads: 1},
+	}
+	if err := r.Write(&sb, buckets); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := sb.String()
+
+	expected := []string{
+		"# TYPE s3pit_requests_total counter",
+		`s3pit_requests_total{operation="PutObject",tenant="dev",bucket="photos",status="200"} 2`,
+		`s3pit_requests_total{operation="GetObject",tenant="dev",bucket="photos",status="404"} 1`,
+		`s3pit_request_errors_total{operation="GetObject",tenant="dev",bucket="photos",code="NoSuchKey"} 1`,
+		`s3pit_request_errors_total{operation="GetObject",tenant="",bucket="odd\"name",code="AccessDenied"} 1`,
+		"# TYPE s3pit_request_duration_seconds histogram",
+		`s3pit_request_duration_seconds_bucket{operation="PutObject",tenant="dev",bucket="photos",le="0.01"} 0`,
+		`s3pit_request_duration_seconds_bucket{operation="PutObject",tenant="dev",bucket="photos",le="0.025"} 1`,
+		`s3pit_request_duration_seconds_bucket{operation="PutObject",tenant="dev",bucket="photos",le="2.5"} 2`,
+		`s3pit_request_duration_seconds_bucket{operation="PutObject",tenant="dev",bucket="photos",le="+Inf"} 2`,
+		`s3pit_request_duration_seconds_sum{operation="PutObject",tenant="dev",bucket="photos"} 2.02`,
+		`s3pit_request_duration_seconds_count{operation="PutObject",tenant="dev",bucket="photos"} 2`,
+		`s3pit_received_bytes_total{operation="PutObject",tenant="dev",bucket="photos"} 3072`,
+		`s3pit_sent_bytes_total{operation="GetObject",tenant="dev",bucket="photos"} 300`,
+		`s3pit_bucket_objects{tenant="dev",bucket="photos"} 2`,
+		`s3pit_bucket_bytes{tenant="dev",bucket="videos"} 500`,
+		`s3pit_multipart_uploads_in_progress{tenant="dev",bucket="photos"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected line %s in:\n%s", line, out)
+		}
+	}
+
+	// Series are sorted, so scrapes are stable
+	if strings.Index(out, `bucket="photos"} 2`) > strings.Index(out, `bucket="videos"} 1`) {
+		t.Errorf("Expected buckets to be sorted:\n%s", out)
+	}
+}
@@ -0,0 +1,99 @@
+// Package recorder records the S3 traffic of a server to a file and replays
+// it against another server, so a session can be turned into a regression
+// test.
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// MaxBodySize is how much of a request or response body is recorded. Longer
+// bodies are cut and marked as truncated.
+const MaxBodySize = 1 << 20
+
+// Exchange is a request together with the response the server sent. Bodies
+// are stored base64 encoded, so binary objects survive the trip through
+// JSON.
+type Exchange struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration"`
+	Request   Request       `json:"request"`
+	Response  Response      `json:"response"`
+}
+
+// Request is a request as the client sent it
+type Request struct {
+	Method string      `json:"method"`
+	URI    string      `json:"uri"` // Path and query
+	Host   string      `json:"host"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body,omitempty"`
+	// Truncated is set when only the first MaxBodySize bytes of the body
+	// were recorded
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Response is a response as the server sent it
+type Response struct {
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// Recorder appends exchanges to a JSON Lines file, one exchange per line
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewRecorder opens a recording, appending to the file if it exists
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	return &Recorder{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Record appends an exchange to the recording
+func (r *Recorder) Record(ex *Exchange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(ex)
+}
+
+// Close closes the recording file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// ReadFile reads the exchanges of a recording in the order they were recorded
+func ReadFile(path string) ([]Exchange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	var exchanges []Exchange
+	dec := json.NewDecoder(file)
+	for {
+		var ex Exchange
+		if err := dec.Decode(&ex); err == io.EOF {
+			return exchanges, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid exchange %d: %w", len(exchanges)+1, err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+}
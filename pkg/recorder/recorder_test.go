@@ -0,0 +1,209 @@
+package recorder
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	binary := []byte{0x00, 0xff, 0x10, '\n', 0x80}
+
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	exchanges := []Exchange{
+		{
+			Timestamp: time.Now(),
+			Request:   Request{Method: "PUT", URI: "/bucket/image.bin", Host: "localhost:3333", Body: binary},
+			Response:  Response{Status: http.StatusOK, Header: http.Header{"Etag": {`"abc"`}}},
+		},
+		{
+			Timestamp: time.Now(),
+			Request:   Request{Method: "GET", URI: "/bucket/image.bin?versionId=1", Host: "localhost:3333"},
+			Response:  Response{Status: http.StatusOK, Body: binary},
+		},
+	}
+	for i := range exchanges {
+		if err := rec.Record(&exchanges[i]); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 exchanges, got %d", len(got))
+	}
+	if !bytes.Equal(got[0].Request.Body, binary) || !bytes.Equal(got[1].Response.Body, binary) {
+		t.Errorf("Expected binary bodies to survive the recording")
+	}
+	if got[1].Request.URI != "/bucket/image.bin?versionId=1" {
+		t.Errorf("Expected the query to be kept, got %s", got[1].Request.URI)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var gotHost string
+	var gotBody []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	ex := &Exchange{Request: Request{
+		Method: "PUT",
+		URI:    "/key",
+		Host:   "bucket.s3pit.localhost:3333",
+		Header: http.Header{"X-Amz-Content-Sha256": {"UNSIGNED-PAYLOAD"}},
+		Body:   []byte("data"),
+	}}
+	resp, err := Replay(ts.Client(), ts.URL+"/", ex)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if resp.Status != http.StatusCreated || resp.Header.Get("ETag") != `"abc"` {
+		t.Errorf("Unexpected response: %d %v", resp.Status, resp.Header)
+	}
+	if gotHost != "bucket.s3pit.localhost:3333" {
+		t.Errorf("Expected the recorded Host header, got %s", gotHost)
+	}
+	if string(gotBody) != "data" {
+		t.Errorf("Expected the recorded body, got %q", gotBody)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	xmlHeader := http.Header{"Content-Type": {"application/xml"}}
+	listing := func(key, lastModified string) []byte {
+		return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult><Name>bucket</Name><Contents><Key>` + key + `</Key><LastModified>` + lastModified + `</LastModified></Contents></ListBucketResult>`)
+	}
+
+	tests := []struct {
+		name     string
+		expected Response
+		actual   Response
+		want     []string
+	}{
+		{
+			name:     "volatile values",
+			expected: Response{Status: 200, Header: http.Header{"Content-Type": {"application/xml"}, "Date": {"Mon"}}, Body: listing("a.txt", "2024-01-01T00:00:00Z")},
+			actual:   Response{Status: 200, Header: http.Header{"Content-Type": {"application/xml"}, "Date": {"Tue"}, "Server": {"s3pit"}}, Body: listing("a.txt", "2025-06-01T00:00:00Z")},
+		},
+		{
+			name:     "status and header",
+			expected: Response{Status: 200, Header: http.Header{"Etag": {`"abc"`}}},
+			actual:   Response{Status: 404, Header: http.Header{"Etag": {`"def"`}}},
+			want:     []string{"status: expected 200, got 404", `header Etag: expected "\"abc\"", got "\"def\""`},
+		},
+		{
+			name:     "ignored header",
+			expected: Response{Status: 200, Header: http.Header{"Etag": {`"abc"`}, "X-Custom": {"1"}}},
+			actual:   Response{Status: 200, Header: http.Header{"Etag": {`"abc"`}, "X-Custom": {"2"}}},
+		},
+		{
+			name:     "xml body",
+			expected: Response{Status: 200, Header: xmlHeader, Body: listing("a.txt", "2024-01-01T00:00:00Z")},
+			actual:   Response{Status: 200, Header: xmlHeader, Body: listing("b.txt", "2024-01-01T00:00:00Z")},
+			want:     []string{`body: expected "ListBucketResult/Contents/Key = a.txt", got "ListBucketResult/Contents/Key = b.txt"`},
+		},
+		{
+			name:     "binary body",
+			expected: Response{Status: 200, Body: []byte{0x01, 0x02}},
+			actual:   Response{Status: 200, Body: []byte{0x01}},
+			want:     []string{"body differs: expected 2 bytes, got 1"},
+		},
+		{
+			name:     "truncated body",
+			expected: Response{Status: 200, Body: []byte("start"), Truncated: true},
+			actual:   Response{Status: 200, Body: []byte("start and the rest")},
+		},
+		{
+			name:     "truncated body differs",
+			expected: Response{Status: 200, Body: []byte("start"), Truncated: true},
+			actual:   Response{Status: 200, Body: []byte("other start")},
+			want:     []string{"body differs within the first 5 bytes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(&tt.expected, &tt.actual, "x-custom")
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUnreplayable(t *testing.T) {
+	amzDate := func(t time.Time) string { return t.UTC().Format("20060102T150405Z") }
+
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{
+			name:    "plain request",
+			request: Request{Method: "GET", URI: "/bucket/key.txt"},
+		},
+		{
+			name:    "truncated body",
+			request: Request{Method: "PUT", URI: "/bucket/key.txt", Truncated: true},
+			want:    "request body was too large to record in full",
+		},
+		{
+			name:    "upload ID",
+			request: Request{Method: "PUT", URI: "/bucket/key.txt?partNumber=1&uploadId=abc"},
+			want:    "addresses multipart upload abc of the recording",
+		},
+		{
+			name:    "version ID",
+			request: Request{Method: "GET", URI: "/bucket/key.txt?versionId=v1"},
+			want:    "addresses object version v1 of the recording",
+		},
+		{
+			name:    "null version ID",
+			request: Request{Method: "GET", URI: "/bucket/key.txt?versionId=null"},
+		},
+		{
+			name:    "copy source version ID",
+			request: Request{Method: "PUT", URI: "/bucket/copy.txt", Header: http.Header{"X-Amz-Copy-Source": {"/bucket/key.txt?versionId=v1"}}},
+			want:    "copies object version v1 of the recording",
+		},
+		{
+			name:    "expired presigned URL",
+			request: Request{Method: "GET", URI: "/bucket/key.txt?X-Amz-Date=20240101T000000Z&X-Amz-Expires=3600&X-Amz-Signature=abc"},
+			want:    "presigned URL expired at 2024-01-01T01:00:00Z",
+		},
+		{
+			name:    "valid presigned URL",
+			request: Request{Method: "GET", URI: "/bucket/key.txt?X-Amz-Date=" + amzDate(time.Now()) + "&X-Amz-Expires=3600&X-Amz-Signature=abc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unreplayable(&Exchange{Request: tt.request}); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
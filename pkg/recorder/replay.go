@@ -0,0 +1,228 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// volatileHeaders change from one run to the next and are not compared.
+// Content-Length is covered by the body comparison.
+var volatileHeaders = map[string]bool{
+	"Content-Length":   true,
+	"Date":             true,
+	"Last-Modified":    true,
+	"X-Amz-Id-2":       true,
+	"X-Amz-Request-Id": true,
+	"X-Amz-Version-Id": true,
+}
+
+// volatileElements are XML elements whose values change from one run to the
+// next, such as timestamps and generated IDs
+var volatileElements = map[string]bool{
+	"CreationDate": true,
+	"HostId":       true,
+	"Initiated":    true,
+	"LastModified": true,
+	"RequestId":    true,
+	"UploadId":     true,
+	"VersionId":    true,
+}
+
+// Unreplayable returns why an exchange cannot be replayed, or "" when it
+// can be. The server replayed against generates its own upload and version
+// IDs, and the signature of a request covers the query naming them, so
+// requests addressing an ID of the recording cannot be rewritten to the new
+// one and are skipped. Presigned URLs that have expired are skipped as well.
+func Unreplayable(ex *Exchange) string {
+	if ex.Request.Truncated {
+		return "request body was too large to record in full"
+	}
+
+	u, err := url.ParseRequestURI(ex.Request.URI)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	if id := query.Get("uploadId"); id != "" {
+		return fmt.Sprintf("addresses multipart upload %s of the recording", id)
+	}
+	if id := query.Get("versionId"); id != "" && id != "null" {
+		return fmt.Sprintf("addresses object version %s of the recording", id)
+	}
+	if _, source, found := strings.Cut(ex.Request.Header.Get("X-Amz-Copy-Source"), "?"); found {
+		if values, err := url.ParseQuery(source); err == nil {
+			if id := values.Get("versionId"); id != "" && id != "null" {
+				return fmt.Sprintf("copies object version %s of the recording", id)
+			}
+		}
+	}
+	if expires, ok := presignedExpiry(query); ok && time.Now().After(expires) {
+		return fmt.Sprintf("presigned URL expired at %s", expires.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// presignedExpiry returns when a presigned URL expires, from its X-Amz-Date
+// and X-Amz-Expires parameters
+func presignedExpiry(query url.Values) (time.Time, bool) {
+	signed, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return signed.Add(time.Duration(seconds) * time.Second), true
+}
+
+// Replay sends the request of an exchange to a server and returns the
+// response. Like recorded ones, response bodies are read up to MaxBodySize
+// bytes. The recorded Host header is kept, so signed requests still
+// verify and virtual-hosted-style requests address the same bucket.
+func Replay(client *http.Client, endpoint string, ex *Exchange) (*Response, error) {
+	req, err := http.NewRequest(ex.Request.Method, strings.TrimSuffix(endpoint, "/")+ex.Request.URI, bytes.NewReader(ex.Request.Body))
+	if err != nil {
+		return nil, err
+	}
+	if ex.Request.Header != nil {
+		req.Header = ex.Request.Header.Clone()
+	}
+	req.Host = ex.Request.Host
+	req.ContentLength = int64(len(ex.Request.Body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	truncated := len(body) > MaxBodySize
+	if truncated {
+		body = body[:MaxBodySize]
+	}
+	return &Response{Status: resp.StatusCode, Header: resp.Header, Body: body, Truncated: truncated}, nil
+}
+
+// Diff compares a replayed response with the recorded one and describes
+// every difference. Only the headers of the recording are compared, leaving
+// out volatile headers and ignoreHeaders. XML bodies are compared element by
+// element without their volatile elements, other bodies byte by byte.
+func Diff(expected, actual *Response, ignoreHeaders ...string) []string {
+	var diffs []string
+	if expected.Status != actual.Status {
+		diffs = append(diffs, fmt.Sprintf("status: expected %d, got %d", expected.Status, actual.Status))
+	}
+
+	ignored := make(map[string]bool, len(ignoreHeaders))
+	for _, name := range ignoreHeaders {
+		ignored[http.CanonicalHeaderKey(name)] = true
+	}
+	names := make([]string, 0, len(expected.Header))
+	for name := range expected.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		canonical := http.CanonicalHeaderKey(name)
+		if volatileHeaders[canonical] || ignored[canonical] {
+			continue
+		}
+		want := strings.Join(expected.Header[name], ", ")
+		if got := strings.Join(actual.Header.Values(canonical), ", "); got != want {
+			diffs = append(diffs, fmt.Sprintf("header %s: expected %q, got %q", canonical, want, got))
+		}
+	}
+
+	if diff := diffBody(expected, actual); diff != "" {
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// diffBody describes the first difference between two bodies, or returns "".
+// Of a truncated recording only the recorded start is compared.
+func diffBody(expected, actual *Response) string {
+	if expected.Truncated {
+		if !bytes.HasPrefix(actual.Body, expected.Body) {
+			return fmt.Sprintf("body differs within the first %d bytes", len(expected.Body))
+		}
+		return ""
+	}
+
+	if isXML(expected) {
+		want, errWant := normalizeXML(expected.Body)
+		got, errGot := normalizeXML(actual.Body)
+		if errWant == nil && errGot == nil {
+			for i := 0; i < len(want) || i < len(got); i++ {
+				if w, g := lineAt(want, i), lineAt(got, i); w != g {
+					return fmt.Sprintf("body: expected %q, got %q", w, g)
+				}
+			}
+			return ""
+		}
+	}
+
+	if !bytes.Equal(expected.Body, actual.Body) {
+		return fmt.Sprintf("body differs: expected %d bytes, got %d", len(expected.Body), len(actual.Body))
+	}
+	return ""
+}
+
+func isXML(resp *Response) bool {
+	return strings.Contains(resp.Header.Get("Content-Type"), "xml") ||
+		bytes.HasPrefix(bytes.TrimSpace(resp.Body), []byte("<?xml"))
+}
+
+// normalizeXML flattens an XML document into one line per element and text,
+// e.g. "ListBucketResult/Contents/Key = photo.jpg", leaving out volatile
+// elements
+func normalizeXML(data []byte) ([]string, error) {
+	var lines, path []string
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if volatileElements[t.Name.Local] {
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			path = append(path, t.Name.Local)
+			lines = append(lines, strings.Join(path, "/"))
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				lines = append(lines, strings.Join(path, "/")+" = "+text)
+			}
+		}
+	}
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return "<end of body>"
+}
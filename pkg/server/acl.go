@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// aclPermission returns the ACL permission the AllUsers group needs for an
+// anonymous request, and whether it is checked on the object rather than on
+// the bucket. It returns false for actions ACLs cannot grant.
+func aclPermission(action string) (permission string, onObject bool, ok bool) {
+	switch action {
+	case "s3:GetObject", "s3:GetObjectVersion":
+		return storage.PermissionRead, true, true
+	case "s3:GetObjectAcl":
+		return storage.PermissionReadACP, true, true
+	case "s3:ListBucket", "s3:ListBucketVersions", "s3:ListBucketMultipartUploads":
+		return storage.PermissionRead, false, true
+	case "s3:GetBucketAcl":
+		return storage.PermissionReadACP, false, true
+	case "s3:PutObject", "s3:DeleteObject", "s3:DeleteObjectVersion", "s3:AbortMultipartUpload":
+		return storage.PermissionWrite, false, true
+	}
+	return "", false, false
+}
+
+// anonymousACLAllowed reports whether the ACL of the bucket or object an
+// anonymous request addresses, in the storage of the tenant owning the
+// bucket, grants the request to AllUsers
+func (s *Server) anonymousACLAllowed(c *gin.Context, owner, bucket string) bool {
+	permission, onObject, ok := aclPermission(policyAction(c))
+	if !ok {
+		return false
+	}
+	store := s.storageFor(owner)
+
+	if !onObject {
+		acl, err := store.GetBucketACL(bucket)
+		return err == nil && storage.GrantsAllUsers(acl, permission)
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	meta, err := store.GetObjectVersionMetadata(bucket, key, c.Query("versionId"))
+	return err == nil && storage.GrantsAllUsers(meta.ACL, permission)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/fault"
+)
+
+// adminPathPrefix is the path prefix of the endpoints controlling the
+// emulator itself. Bucket names cannot start with an underscore, so they
+// never collide with S3 requests. The endpoints are not authenticated and
+// are only registered when EnableAdmin is set.
+const adminPathPrefix = "/_s3pit/"
+
+// setupAdmin registers the admin endpoints, which let tests move the clock
+// the lifecycle rules are evaluated against, apply the rules on demand and
+// change the fault injection rules at runtime
+func (s *Server) setupAdmin() {
+	admin := s.router.Group(adminPathPrefix)
+	{
+		admin.GET("/clock", s.handleGetClock)
+		admin.POST("/clock/advance", s.handleAdvanceClock)
+		admin.POST("/clock/reset", s.handleResetClock)
+		admin.POST("/lifecycle/run", s.handleRunLifecycle)
+		admin.GET("/faults", s.handleListFaults)
+		admin.PUT("/faults", s.handleSetFaults)
+		admin.DELETE("/faults", s.handleClearFaults)
+		admin.POST("/faults/:name/enable", s.handleToggleFault(true))
+		admin.POST("/faults/:name/disable", s.handleToggleFault(false))
+	}
+}
+
+// setupAdminDisabled answers every admin path with 404 Not Found, so that
+// the requests are not handed to the S3 API, which does not authenticate
+// internal paths. The metrics endpoint is served from here when enabled, as
+// the router does not allow other routes next to a catch-all.
+func (s *Server) setupAdminDisabled() {
+	s.router.Any(adminPathPrefix+"*path", func(c *gin.Context) {
+		if s.metrics != nil && c.Request.Method == http.MethodGet && c.Request.URL.Path == metricsPath {
+			s.handleMetrics(c)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "admin endpoints are disabled; start s3pit with --admin"})
+	})
+}
+
+func (s *Server) handleGetClock(c *gin.Context) {
+	c.JSON(http.StatusOK, s.clockStatus())
+}
+
+// handleAdvanceClock moves the clock forward by the duration query
+// parameter, e.g. ?duration=48h
+func (s *Server) handleAdvanceClock(c *gin.Context) {
+	d, err := time.ParseDuration(c.Query("duration"))
+	if err != nil || d <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration must be a positive duration such as 36h"})
+		return
+	}
+
+	s.clock.Advance(d)
+	c.JSON(http.StatusOK, s.clockStatus())
+}
+
+func (s *Server) handleResetClock(c *gin.Context) {
+	s.clock.Reset()
+	c.JSON(http.StatusOK, s.clockStatus())
+}
+
+// handleRunLifecycle applies the lifecycle rules of every bucket right away
+// instead of waiting for the next scheduled run
+func (s *Server) handleRunLifecycle(c *gin.Context) {
+	removed := s.lifecycle.Run()
+	c.JSON(http.StatusOK, gin.H{"removed": removed, "now": s.clock.Now().Format(time.RFC3339)})
+}
+
+func (s *Server) handleListFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rules": s.faults.Rules()})
+}
+
+// handleSetFaults replaces the fault rules with the JSON array in the body
+func (s *Server) handleSetFaults(c *gin.Context) {
+	var rules []fault.Rule
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.faults.SetRules(rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": s.faults.Rules()})
+}
+
+func (s *Server) handleClearFaults(c *gin.Context) {
+	_ = s.faults.SetRules(nil)
+	c.JSON(http.StatusOK, gin.H{"rules": s.faults.Rules()})
+}
+
+func (s *Server) handleToggleFault(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.faults.SetEnabled(c.Param("name"), enabled); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"rules": s.faults.Rules()})
+	}
+}
+
+func (s *Server) clockStatus() gin.H {
+	return gin.H{
+		"now":    s.clock.Now().Format(time.RFC3339),
+		"offset": s.clock.Offset().String(),
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminLifecycleEndpoints(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	do := func(method, target, body, accessKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if accessKey != "" {
+			signRequestSimple(req, accessKey)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	config := `<LifecycleConfiguration>
+		<Rule>
+			<ID>tmp</ID>
+			<Filter><Prefix>tmp/</Prefix></Filter>
+			<Status>Enabled</Status>
+			<Expiration><Days>1</Days></Expiration>
+		</Rule>
+	</LifecycleConfiguration>`
+	w := do("PUT", "/private-bucket?lifecycle", config, "private-tenant")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/private-bucket/tmp/scratch.txt", "scratch", "private-tenant")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/private-bucket/keep/report.txt", "report", "private-tenant")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	run := func() int {
+		w := do("POST", "/_s3pit/lifecycle/run", "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result struct {
+			Removed int `json:"removed"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result.Removed
+	}
+
+	// Nothing is due yet
+	assert.Equal(t, 0, run())
+	assert.Equal(t, http.StatusOK, do("GET", "/private-bucket/tmp/scratch.txt", "", "private-tenant").Code)
+
+	w = do("POST", "/_s3pit/clock/advance?duration=48h", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"offset":"48h0m0s"`)
+
+	assert.Equal(t, 1, run())
+	assert.Equal(t, http.StatusNotFound, do("GET", "/private-bucket/tmp/scratch.txt", "", "private-tenant").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/private-bucket/keep/report.txt", "", "private-tenant").Code)
+
+	w = do("POST", "/_s3pit/clock/advance?duration=soon", "", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do("POST", "/_s3pit/clock/reset", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"offset":"0s"`)
+	assert.Contains(t, do("GET", "/_s3pit/clock", "", "").Body.String(), `"offset":"0s"`)
+}
+
+func TestAdminEndpointsDisabled(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+	server.config.EnableAdmin = false
+	server.router = gin.New()
+	server.setupRoutes()
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, do("POST", "/_s3pit/clock/advance?duration=48h").Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/_s3pit/lifecycle/run").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/_s3pit/clock").Code)
+	assert.Equal(t, "0s", server.clock.Offset().String())
+
+	// Unknown admin paths are not handed to the S3 API either
+	assert.Equal(t, http.StatusNotFound, do("PUT", "/_s3pit/object.txt").Code)
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// throttledBodyKey is the context key of the request body wrapped by
+// requestBodyMiddleware
+const throttledBodyKey = "throttledBody"
+
+// requestBodyMiddleware wraps the request body as it arrives from the
+// client, before the logging, recording or authentication middlewares read
+// or buffer it, so that bandwidthMiddleware can limit the upload itself.
+// The body is not throttled until the limit is known.
+func (s *Server) requestBodyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isInternalPath(c.Request.URL.Path) || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		body := &throttledReader{ReadCloser: c.Request.Body, start: time.Now()}
+		c.Request.Body = body
+		c.Set(throttledBodyKey, body)
+		c.Next()
+	}
+}
+
+// bandwidthMiddleware limits the throughput of request and response bodies,
+// so transfers take as long as they would on a slow network. It runs after
+// authentication so that tenant and bucket limits apply. Limits apply to
+// each request separately.
+func (s *Server) bandwidthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Skip throttling for the dashboard, admin and metrics endpoints
+		if isInternalPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		upload, download := s.bandwidthLimits(c.GetString("accessKey"), c.Param("bucket"))
+		if body, ok := c.Get(throttledBodyKey); ok && upload > 0 {
+			body.(*throttledReader).setLimit(upload)
+		}
+		if download > 0 {
+			c.Writer = &throttledWriter{ResponseWriter: c.Writer, limiter: newRateLimiter(download)}
+		}
+
+		c.Next()
+	}
+}
+
+// bandwidthLimits returns the upload and download limits of a request in
+// bytes per second, 0 meaning unlimited. Bucket limits take precedence over
+// tenant limits, which take precedence over the server limits.
+func (s *Server) bandwidthLimits(accessKey, bucket string) (upload, download int64) {
+	upload, download = s.config.UploadBytesPerSecond, s.config.DownloadBytesPerSecond
+	if accessKey == "" || s.tenantManager == nil {
+		return upload, download
+	}
+	t, ok := s.tenantManager.GetTenant(accessKey)
+	if !ok {
+		return upload, download
+	}
+
+	limits := t.Bandwidth(bucket)
+	if limits.UploadBytesPerSecond > 0 {
+		upload = limits.UploadBytesPerSecond
+	}
+	if limits.DownloadBytesPerSecond > 0 {
+		download = limits.DownloadBytesPerSecond
+	}
+	return upload, download
+}
+
+// throttleTick is how often a throttled body transfers a slice of its data
+const throttleTick = 100 * time.Millisecond
+
+// rateLimiter is a token bucket refilled at bytesPerSecond. It holds at most
+// one tick worth of tokens, so a transfer cannot burst after a pause.
+type rateLimiter struct {
+	bytesPerSecond int64
+	tokens         float64
+	last           time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{bytesPerSecond: bytesPerSecond, last: time.Now()}
+}
+
+// chunk returns how many bytes are transferred at a time
+func (l *rateLimiter) chunk() int {
+	chunk := int(l.bytesPerSecond * int64(throttleTick) / int64(time.Second))
+	if chunk < 1 {
+		chunk = 1
+	}
+	return chunk
+}
+
+// wait takes the tokens for n transferred bytes, sleeping until the bucket
+// has refilled when there were not enough
+func (l *rateLimiter) wait(n int) {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.bytesPerSecond)
+	if burst := float64(l.chunk()); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens < 0 {
+		time.Sleep(time.Duration(-l.tokens / float64(l.bytesPerSecond) * float64(time.Second)))
+	}
+}
+
+// throttledReader limits the bandwidth of a request body. Without a
+// limiter it only counts the bytes read.
+type throttledReader struct {
+	io.ReadCloser
+	limiter *rateLimiter
+	start   time.Time
+	read    int64
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if r.limiter == nil {
+		n, err := r.ReadCloser.Read(p)
+		r.read += int64(n)
+		return n, err
+	}
+
+	if chunk := r.limiter.chunk(); len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
+
+// setLimit throttles the rest of the body. The bytes read before the limit
+// was known, such as a body buffered to verify its signature, are accounted
+// for by waiting until they would have arrived at the limit.
+func (r *throttledReader) setLimit(bytesPerSecond int64) {
+	r.limiter = newRateLimiter(bytesPerSecond)
+	due := time.Duration(float64(r.read) / float64(bytesPerSecond) * float64(time.Second))
+	if wait := due - time.Since(r.start); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// throttledWriter limits the bandwidth of a response body
+type throttledWriter struct {
+	gin.ResponseWriter
+	limiter *rateLimiter
+}
+
+func (w *throttledWriter) Write(data []byte) (int, error) {
+	chunk := w.limiter.chunk()
+
+	written := 0
+	for written < len(data) {
+		end := written + chunk
+		if end > len(data) {
+			end = len(data)
+		}
+		n, err := w.ResponseWriter.Write(data[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		w.Flush()
+		w.limiter.wait(n)
+	}
+	return written, nil
+}
+
+func (w *throttledWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wozozo/s3pit/pkg/tenant"
+)
+
+func TestBandwidthLimits(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	server.config.UploadBytesPerSecond = 1000
+	server.config.DownloadBytesPerSecond = 2000
+
+	tnt, ok := server.tenantManager.GetTenant("public-tenant")
+	require.True(t, ok)
+	tnt.DownloadBytesPerSecond = 3000
+	tnt.BucketBandwidth = map[string]tenant.Bandwidth{
+		"videos": {UploadBytesPerSecond: 4000},
+	}
+
+	tests := []struct {
+		name      string
+		accessKey string
+		bucket    string
+		upload    int64
+		download  int64
+	}{
+		{"server limits", "private-tenant", "bucket", 1000, 2000},
+		{"unknown tenant", "unknown", "bucket", 1000, 2000},
+		{"tenant limits", "public-tenant", "bucket", 1000, 3000},
+		{"bucket limits", "public-tenant", "videos", 4000, 3000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload, download := server.bandwidthLimits(tt.accessKey, tt.bucket)
+			assert.Equal(t, tt.upload, upload)
+			assert.Equal(t, tt.download, download)
+		})
+	}
+}
+
+func TestBandwidthThrottling(t *testing.T) {
+	t.Run("Download", func(t *testing.T) {
+		server, _, cleanup := setupTestServerWithPublicBuckets(t)
+		defer cleanup()
+		server.config.DownloadBytesPerSecond = 100
+
+		req := httptest.NewRequest("GET", "/public-bucket/test.txt", nil)
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		server.router.ServeHTTP(w, req)
+
+		// 14 bytes at 100 bytes per second
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "public content", w.Body.String())
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("Upload", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 300)
+		r := &throttledReader{ReadCloser: io.NopCloser(bytes.NewReader(data)), limiter: newRateLimiter(1000)}
+
+		start := time.Now()
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+
+		// 300 bytes at 1000 bytes per second
+		assert.Equal(t, data, got)
+		assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+	})
+
+	t.Run("Upload through the router", func(t *testing.T) {
+		server, _, cleanup := setupTestServerWithPublicBuckets(t)
+		defer cleanup()
+		server.config.UploadBytesPerSecond = 1000
+
+		data := bytes.Repeat([]byte("a"), 300)
+		req := httptest.NewRequest("PUT", "/private-bucket/upload.bin", bytes.NewReader(data))
+		signRequestSimple(req, "private-tenant")
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		server.router.ServeHTTP(w, req)
+
+		// 300 bytes at 1000 bytes per second, although the logging
+		// middleware wraps the body before the limit is known
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+	})
+
+	t.Run("Upload read before the limit is known", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 300)
+		r := &throttledReader{ReadCloser: io.NopCloser(bytes.NewReader(data)), start: time.Now()}
+
+		// The body is buffered unthrottled, as to verify its signature
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+
+		start := time.Now()
+		r.setLimit(1000)
+		assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+	})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// corsMiddleware answers CORS preflight requests and adds CORS headers to
+// cross-origin requests according to the CORS configuration of the bucket
+// they address. In permissive mode, enabled globally or for the tenant
+// owning the bucket, every request gets wildcard CORS headers instead.
+func (s *Server) corsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The dashboard and admin endpoints are served from the same origin,
+		// and requests without an Origin header are not cross-origin requests
+		origin := c.GetHeader("Origin")
+		if strings.HasPrefix(c.Request.URL.Path, "/dashboard") ||
+			strings.HasPrefix(c.Request.URL.Path, adminPathPrefix) ||
+			(origin == "" && c.Request.Method != http.MethodOptions && !s.config.PermissiveCORS) {
+			c.Next()
+			return
+		}
+
+		// Preflight requests match no route, so the bucket is taken from the
+		// path rather than from the route parameters
+		bucket := strings.SplitN(strings.TrimPrefix(c.Request.URL.Path, "/"), "/", 2)[0]
+		owner, exists := "", false
+		if bucket != "" {
+			owner, exists = s.bucketOwner(bucket)
+		}
+
+		if s.permissiveCORS(owner, exists) {
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, HEAD")
+			c.Header("Access-Control-Allow-Headers", "*")
+			c.Header("Access-Control-Expose-Headers", "*")
+
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusOK)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			s.handlePreflight(c, origin, bucket, owner, exists)
+			return
+		}
+
+		if exists {
+			rules, err := s.storageFor(owner).GetBucketCORS(bucket)
+			if err == nil && len(rules) > 0 {
+				c.Header("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+				if rule := storage.MatchCORSRule(rules, origin, c.Request.Method, nil); rule != nil {
+					setCORSHeaders(c, rule, origin)
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// permissiveCORS reports whether CORS requests for a bucket are answered
+// with wildcard headers rather than evaluated against its CORS rules
+func (s *Server) permissiveCORS(owner string, exists bool) bool {
+	if s.config.PermissiveCORS {
+		return true
+	}
+	if !exists || owner == "" || s.tenantManager == nil {
+		return false
+	}
+	t, ok := s.tenantManager.GetTenant(owner)
+	return ok && t.PermissiveCORS
+}
+
+// handlePreflight answers a preflight OPTIONS request by evaluating it
+// against the CORS configuration of the bucket
+func (s *Server) handlePreflight(c *gin.Context, origin, bucket, owner string, exists bool) {
+	if origin == "" {
+		abortWithError(c, http.StatusBadRequest, "BadRequest", "Insufficient information. Origin request header needed.")
+		return
+	}
+
+	method := c.GetHeader("Access-Control-Request-Method")
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodHead:
+	default:
+		abortWithError(c, http.StatusBadRequest, "BadRequest", "Invalid Access-Control-Request-Method: "+method)
+		return
+	}
+
+	var rules []storage.CORSRule
+	if exists {
+		rules, _ = s.storageFor(owner).GetBucketCORS(bucket)
+	}
+	if len(rules) == 0 {
+		abortWithError(c, http.StatusForbidden, "AccessForbidden", "CORSResponse: CORS is not enabled for this bucket.")
+		return
+	}
+
+	var headers []string
+	for _, header := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+
+	c.Header("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+	rule := storage.MatchCORSRule(rules, origin, method, headers)
+	if rule == nil {
+		abortWithError(c, http.StatusForbidden, "AccessForbidden", "CORSResponse: This CORS request is not allowed. This is usually because the evalution of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.")
+		return
+	}
+
+	setCORSHeaders(c, rule, origin)
+	if len(headers) > 0 {
+		c.Header("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	c.AbortWithStatus(http.StatusOK)
+}
+
+// setCORSHeaders adds the response headers of a CORS rule that allowed a
+// request from origin. A rule allowing any origin answers with "*", which
+// browsers do not accept together with credentials.
+func setCORSHeaders(c *gin.Context, rule *storage.CORSRule, origin string) {
+	allowAny := false
+	for _, allowed := range rule.AllowedOrigins {
+		if allowed == "*" {
+			allowAny = true
+			break
+		}
+	}
+
+	if allowAny {
+		c.Header("Access-Control-Allow-Origin", "*")
+	} else {
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+	c.Header("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(rule.ExposeHeaders) > 0 {
+		c.Header("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	if rule.MaxAgeSeconds > 0 {
+		c.Header("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketCORSRequests(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	do := func(method, target, body, accessKey string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if accessKey != "" {
+			signRequestSimple(req, accessKey)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	config := `<CORSConfiguration>
+		<CORSRule>
+			<AllowedOrigin>https://*.example.com</AllowedOrigin>
+			<AllowedMethod>PUT</AllowedMethod>
+			<AllowedMethod>GET</AllowedMethod>
+			<AllowedHeader>Content-Type</AllowedHeader>
+			<AllowedHeader>x-amz-*</AllowedHeader>
+			<ExposeHeader>ETag</ExposeHeader>
+			<MaxAgeSeconds>600</MaxAgeSeconds>
+		</CORSRule>
+	</CORSConfiguration>`
+	w := do("PUT", "/private-bucket?cors", config, "private-tenant", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("Preflight allowed by a rule", func(t *testing.T) {
+		w := do("OPTIONS", "/private-bucket/upload.txt", "", "", map[string]string{
+			"Origin":                         "https://app.example.com",
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "content-type, x-amz-meta-owner",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "PUT, GET", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "content-type, x-amz-meta-owner", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("Preflight rejected by the rules", func(t *testing.T) {
+		for name, headers := range map[string]map[string]string{
+			"origin": {"Origin": "https://evil.test", "Access-Control-Request-Method": "PUT"},
+			"method": {"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			"header": {"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "authorization"},
+		} {
+			w := do("OPTIONS", "/private-bucket/upload.txt", "", "", headers)
+			assert.Equal(t, http.StatusForbidden, w.Code, name)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), name)
+		}
+	})
+
+	t.Run("Preflight without CORS configuration", func(t *testing.T) {
+		w := do("OPTIONS", "/public-bucket/test.txt", "", "", map[string]string{
+			"Origin":                        "https://app.example.com",
+			"Access-Control-Request-Method": "GET",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("OPTIONS", "/private-bucket/upload.txt", "", "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Actual requests get the headers of the matching rule", func(t *testing.T) {
+		w := do("GET", "/private-bucket/secret.txt", "", "private-tenant", map[string]string{"Origin": "https://app.example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+
+		w = do("GET", "/private-bucket/secret.txt", "", "private-tenant", map[string]string{"Origin": "https://evil.test"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+		w = do("GET", "/private-bucket/secret.txt", "", "private-tenant", nil)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Permissive mode per tenant", func(t *testing.T) {
+		tenant, ok := server.tenantManager.GetTenant("public-tenant")
+		require.True(t, ok)
+		tenant.PermissiveCORS = true
+		defer func() { tenant.PermissiveCORS = false }()
+
+		w := do("OPTIONS", "/public-bucket/test.txt", "", "", map[string]string{"Origin": "https://anywhere.test"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+		// Other tenants' buckets keep evaluating their rules
+		w = do("OPTIONS", "/private-bucket/upload.txt", "", "", map[string]string{
+			"Origin":                        "https://anywhere.test",
+			"Access-Control-Request-Method": "PUT",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Permissive mode globally", func(t *testing.T) {
+		server.config.PermissiveCORS = true
+		defer func() { server.config.PermissiveCORS = false }()
+
+		w := do("OPTIONS", "/private-bucket/upload.txt", "", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+		w = do("GET", "/public-bucket/test.txt", "", "", nil)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
@@ -3,19 +3,17 @@ package server
 import (
 	"log"
 	"math/rand"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/tracing"
 )
 
 // delayMiddleware adds configurable delays to S3 operations for testing purposes
 func (s *Server) delayMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip delay for dashboard and static files
-		if strings.HasPrefix(c.Request.URL.Path, "/dashboard") ||
-			strings.HasPrefix(c.Request.URL.Path, "/static/") ||
-			c.Request.URL.Path == "/health" {
+		// Skip delay for the dashboard, admin and metrics endpoints
+		if isInternalPath(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
@@ -49,7 +47,10 @@ func (s *Server) delayMiddleware() gin.HandlerFunc {
 
 		// Apply the delay
 		if delayMs > 0 {
+			_, span := tracing.Start(c.Request.Context(), "delay")
+			span.SetAttribute("s3pit.delay_ms", delayMs)
 			time.Sleep(time.Duration(delayMs) * time.Millisecond)
+			span.Finish()
 		}
 
 		c.Next()
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/fault"
+)
+
+// faultMiddleware injects the fault of the first fault rule firing for a
+// request. It runs after authentication so that rules can match by tenant.
+func (s *Server) faultMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Skip faults for the dashboard, admin and metrics endpoints
+		if s.faults == nil || isInternalPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		rule := s.faults.Evaluate(fault.Request{
+			Tenant:    c.GetString("accessKey"),
+			Bucket:    c.Param("bucket"),
+			Key:       strings.TrimPrefix(c.Param("key"), "/"),
+			Operation: string(GetOperationType(c)),
+			Method:    c.Request.Method,
+		})
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		log.Printf("[FAULT] Injecting %s from rule %s into %s %s",
+			rule.Action, rule.Name, c.Request.Method, c.Request.URL.Path)
+
+		switch rule.Action {
+		case fault.ActionDelay:
+			time.Sleep(time.Duration(rule.DelayMs) * time.Millisecond)
+		case fault.ActionError:
+			abortWithError(c, rule.Status, rule.Code, rule.Message)
+			return
+		case fault.ActionReset, fault.ActionTruncate:
+			reset := rule.Action == fault.ActionReset
+			if rule.AfterBytes == 0 {
+				closeConnection(c.Writer, reset)
+				c.Abort()
+				return
+			}
+			c.Writer = &cutoffWriter{ResponseWriter: c.Writer, remaining: rule.AfterBytes, reset: reset}
+		case fault.ActionThrottle:
+			c.Writer = &throttledWriter{ResponseWriter: c.Writer, limiter: newRateLimiter(rule.BytesPerSecond)}
+		}
+
+		c.Next()
+	}
+}
+
+// closeConnection closes the client connection of a request, sending what
+// was written so far. With reset the connection is reset rather than closed
+// gracefully.
+func closeConnection(w gin.ResponseWriter, reset bool) {
+	if w.Written() {
+		w.Flush()
+	}
+	conn, buf, err := w.Hijack()
+	if err != nil {
+		log.Printf("[FAULT] Failed to close the connection: %v", err)
+		return
+	}
+	_ = buf.Flush()
+	if tcpConn, ok := conn.(*net.TCPConn); ok && reset {
+		_ = tcpConn.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// cutoffWriter passes the first bytes of a response body through and then
+// closes the connection, so clients see a truncated body or a reset
+type cutoffWriter struct {
+	gin.ResponseWriter
+	remaining int64
+	reset     bool
+	closed    bool
+}
+
+func (w *cutoffWriter) Write(data []byte) (int, error) {
+	if w.closed {
+		// Pretend the rest was sent so the handler finishes normally
+		return len(data), nil
+	}
+	if int64(len(data)) < w.remaining {
+		n, err := w.ResponseWriter.Write(data)
+		w.remaining -= int64(n)
+		return n, err
+	}
+
+	if _, err := w.ResponseWriter.Write(data[:w.remaining]); err != nil {
+		return 0, err
+	}
+	w.closed = true
+	closeConnection(w.ResponseWriter, w.reset)
+	return len(data), nil
+}
+
+func (w *cutoffWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wozozo/s3pit/pkg/fault"
+)
+
+func TestFaultInjection(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	// Resets and truncations need a real connection
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// Every request gets a fresh connection so a closed one is not reused
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	setFaults := func(rules string) {
+		req, _ := http.NewRequest("PUT", ts.URL+"/_s3pit/faults", strings.NewReader(rules))
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	}
+	get := func() (*http.Response, string, error) {
+		resp, err := client.Get(ts.URL + "/public-bucket/test.txt")
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, string(body), err
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		setFaults(`[{"name": "slow-down", "bucket": "public-*", "key": "*.txt", "operation": "READ", "action": "error", "code": "SlowDown", "times": 1}]`)
+
+		resp, body, err := get()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Contains(t, body, "<Code>SlowDown</Code>")
+
+		// The rule fires once, so the retry succeeds
+		resp, body, err = get()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "public content", body)
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		setFaults(`[{"name": "truncate", "action": "truncate", "afterBytes": 6}]`)
+
+		resp, body, err := get()
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, "public", body)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		setFaults(`[{"name": "reset", "method": "GET", "action": "reset"}]`)
+
+		_, _, err := get()
+		assert.Error(t, err)
+	})
+
+	t.Run("Throttle", func(t *testing.T) {
+		setFaults(`[{"name": "throttle", "action": "throttle", "bytesPerSecond": 100}]`)
+
+		start := time.Now()
+		resp, body, err := get()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "public content", body)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("Other tenants and disabled rules", func(t *testing.T) {
+		setFaults(`[{"name": "private", "tenant": "private-tenant", "action": "error"}, {"name": "all", "action": "error"}]`)
+
+		req, _ := http.NewRequest("POST", ts.URL+"/_s3pit/faults/all/disable", nil)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, body, err := get()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "public content", body)
+
+		req, _ = http.NewRequest("POST", ts.URL+"/_s3pit/faults/missing/enable", nil)
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Invalid rules", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", ts.URL+"/_s3pit/faults", strings.NewReader(`[{"name": "bad", "action": "explode"}]`))
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	req, _ := http.NewRequest("DELETE", ts.URL+"/_s3pit/faults", nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, server.faults.Rules())
+}
+
+func TestFaultAdminEndpointsDisabled(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+	server.config.EnableAdmin = false
+	server.router = gin.New()
+	server.setupRoutes()
+
+	// Rules from config.toml still apply without the admin endpoints
+	require.NoError(t, server.faults.SetRules([]fault.Rule{{Name: "slow-down", Action: "error", Code: "SlowDown"}}))
+
+	for _, tc := range []struct{ method, target, body string }{
+		{"GET", "/_s3pit/faults", ""},
+		{"PUT", "/_s3pit/faults", `[]`},
+		{"DELETE", "/_s3pit/faults", ""},
+		{"POST", "/_s3pit/faults/slow-down/disable", ""},
+	} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		assert.Equal(t, http.StatusNotFound, w.Code, "%s %s", tc.method, tc.target)
+	}
+
+	rules := server.faults.Rules()
+	require.Len(t, rules, 1)
+	assert.False(t, rules[0].Disabled)
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/public-bucket/test.txt", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/logger"
+	"github.com/wozozo/s3pit/pkg/metrics"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// unknownBucketLabel is the bucket label of requests that were denied or
+// failed on a bucket that does not exist. Their bucket names come from the
+// client, so labelling them with it would let any client create series
+// without bound. Bucket names cannot start with an underscore.
+const unknownBucketLabel = "_unknown"
+
+// metricsMiddleware counts every S3 request with its duration, status, S3
+// error code and body sizes. It runs before authentication so that denied
+// requests are counted too; the tenant is known once the request is handled.
+func (s *Server) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.metrics == nil || isInternalPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		body := &countingReader{}
+		if c.Request.Body != nil {
+			body.ReadCloser = c.Request.Body
+			c.Request.Body = body
+		}
+		rw := &errorCodeWriter{ResponseWriter: c.Writer}
+		c.Writer = rw
+
+		c.Next()
+
+		s.metrics.Observe(metrics.Request{
+			Operation: logger.DetermineOperation(c),
+			Tenant:    c.GetString("accessKey"),
+			Bucket:    s.bucketLabel(c, rw.Status()),
+			Status:    rw.Status(),
+			ErrorCode: rw.errorCode(),
+			Duration:  time.Since(start),
+			BytesIn:   body.n,
+			BytesOut:  int64(rw.Size()),
+		})
+	}
+}
+
+// bucketLabel returns the bucket label of a handled request: the bucket
+// name, unless the request was denied or failed on a bucket that does not
+// exist
+func (s *Server) bucketLabel(c *gin.Context, status int) string {
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		return ""
+	}
+	accessKey := c.GetString("accessKey")
+	if accessKey == "" && !c.GetBool("publicAccess") {
+		return unknownBucketLabel
+	}
+	if status >= 400 {
+		if exists, err := s.storageFor(accessKey).BucketExists(bucket); err != nil || !exists {
+			return unknownBucketLabel
+		}
+	}
+	return bucket
+}
+
+// bucketStatsTTL is how long the storage gauges are reused between scrapes,
+// as computing them lists every object of every bucket
+const bucketStatsTTL = 30 * time.Second
+
+// bucketStatsCache holds the storage gauges of the last scrape
+type bucketStatsCache struct {
+	mu       sync.Mutex
+	stats    []metrics.BucketStats
+	computed time.Time
+}
+
+// handleMetrics serves the metrics in the Prometheus text format
+func (s *Server) handleMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := s.metrics.Write(c.Writer, s.cachedBucketStats()); err != nil {
+		log.Printf("[METRICS] Failed to write metrics: %v", err)
+	}
+}
+
+// cachedBucketStats returns the storage gauges, computing them again once
+// they are older than bucketStatsTTL. Concurrent scrapes wait for the same
+// computation rather than each listing the buckets.
+func (s *Server) cachedBucketStats() []metrics.BucketStats {
+	s.bucketStats.mu.Lock()
+	defer s.bucketStats.mu.Unlock()
+
+	if s.bucketStats.computed.IsZero() || time.Since(s.bucketStats.computed) >= bucketStatsTTL {
+		s.bucketStats.stats = s.computeBucketStats()
+		s.bucketStats.computed = time.Now()
+	}
+	return s.bucketStats.stats
+}
+
+// computeBucketStats counts the objects, bytes and multipart uploads in progress
+// of every bucket, for every tenant when tenants are configured
+func (s *Server) computeBucketStats() []metrics.BucketStats {
+	stores := map[string]storage.Storage{"": s.storage}
+	if tenantStorage, ok := s.storage.(*storage.TenantAwareStorage); ok && s.tenantManager != nil {
+		stores = make(map[string]storage.Storage)
+		for _, t := range s.tenantManager.ListTenants() {
+			if store, err := tenantStorage.GetStorageForTenant(t.AccessKeyID); err == nil {
+				stores[t.AccessKeyID] = store
+			}
+		}
+	}
+
+	var stats []metrics.BucketStats
+	for tenant, store := range stores {
+		buckets, err := store.ListBuckets()
+		if err != nil {
+			log.Printf("[METRICS] Failed to list buckets of tenant %q: %v", tenant, err)
+			continue
+		}
+		for _, b := range buckets {
+			stat := metrics.BucketStats{Tenant: tenant, Bucket: b.Name}
+
+			token := ""
+			for {
+				objects, _, next, err := store.ListObjects(b.Name, "", "", 1000, token)
+				if err != nil {
+					break
+				}
+				for _, obj := range objects {
+					stat.Objects++
+					stat.Bytes += obj.Size
+				}
+				if next == "" {
+					break
+				}
+				token = next
+			}
+
+			if uploads, err := store.ListMultipartUploads(b.Name, ""); err == nil {
+				stat.MultipartUploads = int64(len(uploads))
+			}
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// maxErrorBody is how much of an error response is kept to find its code
+const maxErrorBody = 4096
+
+// errorCodeWriter keeps the start of error responses to find their S3 error
+// code, for the metrics and trace middlewares
+type errorCodeWriter struct {
+	gin.ResponseWriter
+	errorBody bytes.Buffer
+}
+
+func (w *errorCodeWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorCodeWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorCodeWriter) keep(data []byte) {
+	if w.Status() < 400 || w.errorBody.Len() >= maxErrorBody {
+		return
+	}
+	if room := maxErrorBody - w.errorBody.Len(); len(data) > room {
+		data = data[:room]
+	}
+	w.errorBody.Write(data)
+}
+
+// errorCode returns the first Code element of an S3 error response, or ""
+// when there is none, as for HEAD requests
+func (w *errorCodeWriter) errorCode() string {
+	dec := xml.NewDecoder(bytes.NewReader(w.errorBody.Bytes()))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "Code" {
+			var code string
+			if err := dec.DecodeElement(&code, &start); err != nil {
+				return ""
+			}
+			return code
+		}
+	}
+}
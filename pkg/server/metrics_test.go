@@ -0,0 +1,137 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	for _, path := range []string{"/public-bucket/test.txt", "/public-bucket/missing.txt"} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	}
+
+	// Denied writes are counted too, without a tenant or bucket
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/private-bucket/secret.txt", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/made-up-bucket/secret.txt", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Requests for buckets that do not exist are not labelled with the name
+	req := httptest.NewRequest("GET", "/no-such-bucket/key.txt", nil)
+	signRequestSimple(req, "private-tenant")
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// The metrics endpoint needs no authentication
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", metricsPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	body := w.Body.String()
+	assert.Contains(t, body, `s3pit_requests_total{operation="GetObject",tenant="public-tenant",bucket="public-bucket",status="200"} 1`)
+	assert.Contains(t, body, `s3pit_requests_total{operation="GetObject",tenant="public-tenant",bucket="public-bucket",status="404"} 1`)
+	assert.Contains(t, body, `s3pit_request_errors_total{operation="GetObject",tenant="public-tenant",bucket="public-bucket",code="NoSuchKey"} 1`)
+	assert.Contains(t, body, `s3pit_request_errors_total{operation="DeleteObject",tenant="",bucket="_unknown",code="AccessDenied"} 2`)
+	assert.Contains(t, body, `s3pit_requests_total{operation="GetObject",tenant="private-tenant",bucket="_unknown",status="404"} 1`)
+	assert.NotContains(t, body, `bucket="private-bucket",code="AccessDenied"`)
+	assert.NotContains(t, body, "made-up-bucket")
+	assert.NotContains(t, body, "no-such-bucket")
+	assert.Contains(t, body, `s3pit_request_duration_seconds_count{operation="GetObject",tenant="public-tenant",bucket="public-bucket"} 2`)
+	assert.Contains(t, body, `s3pit_bucket_objects{tenant="public-tenant",bucket="public-bucket"}`)
+	assert.Contains(t, body, `s3pit_multipart_uploads_in_progress{tenant="private-tenant",bucket="private-bucket"} 0`)
+
+	// Scrapes are not counted as requests
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", metricsPath, nil))
+	assert.NotContains(t, w.Body.String(), `bucket="",status=`)
+}
+
+func TestMetricsBucketStatsAreCached(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", metricsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	gauge := `s3pit_bucket_objects{tenant="public-tenant",bucket="public-bucket"} `
+
+	assert.Contains(t, scrape(), gauge+"1")
+
+	req := httptest.NewRequest("PUT", "/public-bucket/new.txt", strings.NewReader("new"))
+	signRequestSimple(req, "public-tenant")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The gauges of the previous scrape are reused until they expire
+	assert.Contains(t, scrape(), gauge+"1")
+
+	server.bucketStats.computed = time.Now().Add(-bucketStatsTTL)
+	assert.Contains(t, scrape(), gauge+"2")
+}
+
+func TestMetricsEndpointRouting(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	get := func(path, accessKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accessKey != "" {
+			signRequestSimple(req, accessKey)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A bucket named metrics is not shadowed by the endpoint
+	req := httptest.NewRequest("PUT", "/metrics", nil)
+	signRequestSimple(req, "public-tenant")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = get("/metrics", "public-tenant")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<ListBucketResult")
+
+	t.Run("Admin endpoints disabled", func(t *testing.T) {
+		server.config.EnableAdmin = false
+		server.router = gin.New()
+		server.setupRoutes()
+
+		w := get(metricsPath, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "s3pit_requests_total")
+		assert.Equal(t, http.StatusNotFound, get(adminPathPrefix+"clock", "").Code)
+	})
+
+	t.Run("Metrics disabled", func(t *testing.T) {
+		server.metrics = nil
+		for _, admin := range []bool{true, false} {
+			server.config.EnableAdmin = admin
+			server.router = gin.New()
+			server.setupRoutes()
+
+			assert.Equal(t, http.StatusNotFound, get(metricsPath, "").Code)
+			assert.Equal(t, http.StatusOK, get("/metrics", "public-tenant").Code)
+		}
+	})
+}
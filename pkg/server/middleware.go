@@ -2,23 +2,75 @@ package server
 
 import (
 	"log"
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/storage"
+	"github.com/wozozo/s3pit/pkg/tracing"
 )
 
+// metricsPath is the path of the Prometheus metrics endpoint. It is under
+// the admin prefix so it never shadows a bucket, but is served when
+// EnableMetrics is set whether or not the admin endpoints are enabled.
+const metricsPath = adminPathPrefix + "metrics"
+
+// isInternalPath reports whether a request is addressed to the emulator
+// itself, such as the dashboard, admin and metrics endpoints, static files
+// and the health check, rather than to the S3 API
+func isInternalPath(path string) bool {
+	return strings.HasPrefix(path, "/dashboard") ||
+		strings.HasPrefix(path, adminPathPrefix) ||
+		strings.HasPrefix(path, "/static/") ||
+		path == "/health"
+}
+
 // authMiddleware performs authentication for S3 API requests
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip authentication for dashboard, static files, and health check endpoints
-		if strings.HasPrefix(c.Request.URL.Path, "/dashboard") ||
-			strings.HasPrefix(c.Request.URL.Path, "/static/") ||
-			c.Request.URL.Path == "/health" {
+		// Skip authentication for the dashboard, admin and metrics endpoints
+		if isInternalPath(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
 
+		// Anonymous requests are granted or refused by the bucket policy
+		// first, then by the bucket or object ACL, and finally fall back to
+		// the public bucket list. A copy must also be allowed to read its
+		// source.
+		hasAuthCredentials := c.Request.Header.Get("Authorization") != "" ||
+			c.Request.URL.Query().Get("X-Amz-Signature") != ""
+		if bucket := c.Param("bucket"); bucket != "" && !hasAuthCredentials {
+			// Tenants may have buckets of the same name; the policy and ACL
+			// of the one bucketOwner finds decide, as it serves the request
+			if owner, exists := s.bucketOwner(bucket); exists {
+				switch s.anonymousPolicyDecision(c, owner, bucket) {
+				case storage.PolicyDeny:
+					log.Printf("[AUTH] Access denied - Method: %s, Bucket: %s, Reason: Denied by bucket policy",
+						c.Request.Method, bucket)
+					sendAccessDenied(c, "Access Denied")
+					return
+				case storage.PolicyAllow:
+					if !s.checkAnonymousCopySource(c, bucket, owner) {
+						return
+					}
+					log.Printf("[AUTH] Access granted - Method: %s, Bucket: %s, Type: policy, Tenant: %s",
+						c.Request.Method, bucket, owner)
+					s.grantAnonymousAccess(c, owner)
+					return
+				}
+
+				if s.anonymousACLAllowed(c, owner, bucket) {
+					if !s.checkAnonymousCopySource(c, bucket, owner) {
+						return
+					}
+					log.Printf("[AUTH] Access granted - Method: %s, Bucket: %s, Type: acl, Tenant: %s",
+						c.Request.Method, bucket, owner)
+					s.grantAnonymousAccess(c, owner)
+					return
+				}
+			}
+		}
+
 		// Check if this is a request for a public bucket
 		if c.Request.Method == "GET" || c.Request.Method == "HEAD" {
 			bucket := c.Param("bucket")
@@ -43,10 +95,6 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			// For non-GET/HEAD methods, check if authentication credentials are present
 			bucket := c.Param("bucket")
 
-			// Check if authentication credentials are present (header auth or presigned URL)
-			hasAuthCredentials := c.Request.Header.Get("Authorization") != "" ||
-				c.Request.URL.Query().Get("X-Amz-Signature") != ""
-
 			if !hasAuthCredentials && bucket != "" && s.tenantManager != nil {
 				// No credentials provided - check if this is a public bucket
 				isPublic, _ := s.tenantManager.IsPublicBucket(bucket)
@@ -54,14 +102,7 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 					// Public buckets require authentication for write operations
 					log.Printf("[AUTH] Access denied - Method: %s, Bucket: %s, Reason: Public buckets require authentication for write operations",
 						c.Request.Method, bucket)
-					c.Header("Content-Type", "application/xml")
-					c.XML(http.StatusForbidden, gin.H{
-						"Error": gin.H{
-							"Code":    "AccessDenied",
-							"Message": "Public buckets require authentication for write operations",
-						},
-					})
-					c.Abort()
+					sendAccessDenied(c, "Public buckets require authentication for write operations")
 					return
 				}
 			}
@@ -69,17 +110,16 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 		}
 
 		// Perform authentication
+		_, span := tracing.Start(c.Request.Context(), "auth.Authenticate")
 		accessKey, err := s.authHandler.Authenticate(c.Request)
+		if err != nil {
+			span.SetError(err.Error())
+		}
+		span.SetAttribute("s3pit.tenant", accessKey)
+		span.Finish()
 		if err != nil {
 			// Send S3-compatible error response
-			c.Header("Content-Type", "application/xml")
-			c.XML(http.StatusForbidden, gin.H{
-				"Error": gin.H{
-					"Code":    "AccessDenied",
-					"Message": err.Error(),
-				},
-			})
-			c.Abort()
+			sendAccessDenied(c, err.Error())
 			return
 		}
 
@@ -89,8 +129,18 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			authType = "presigned"
 		}
 
-		// Log access with authentication type
+		// An explicit Deny in the bucket policy applies to the owner too
 		bucket := c.Param("bucket")
+		if bucket != "" {
+			if s.deniedByPolicy(c, accessKey, bucket) {
+				log.Printf("[AUTH] Access denied - Method: %s, Bucket: %s, Reason: Denied by bucket policy, AccessKey: %s",
+					c.Request.Method, bucket, accessKey)
+				sendAccessDenied(c, "Access Denied")
+				return
+			}
+		}
+
+		// Log access with authentication type
 		log.Printf("[AUTH] Access granted - Method: %s, Bucket: %s, Type: %s, AccessKey: %s",
 			c.Request.Method, bucket, authType, accessKey)
 
@@ -105,3 +155,28 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// checkAnonymousCopySource refuses an anonymous copy whose source the
+// request may not read. It returns false when the request was refused.
+func (s *Server) checkAnonymousCopySource(c *gin.Context, bucket, owner string) bool {
+	if !isCopyRequest(c) || s.anonymousCopySourceAllowed(c, owner) {
+		return true
+	}
+	log.Printf("[AUTH] Access denied - Method: %s, Bucket: %s, Reason: Copy source not readable, Source: %s",
+		c.Request.Method, bucket, c.GetHeader("x-amz-copy-source"))
+	sendAccessDenied(c, "Access Denied")
+	return false
+}
+
+// grantAnonymousAccess lets an anonymous request through to the storage of
+// the tenant owning the bucket
+func (s *Server) grantAnonymousAccess(c *gin.Context, owner string) {
+	c.Set("publicAccess", true)
+	if owner != "" {
+		c.Set("accessKey", owner)
+		if s.tenantManager != nil {
+			c.Set("tenantDirectory", s.tenantManager.GetDirectory(owner))
+		}
+	}
+	c.Next()
+}
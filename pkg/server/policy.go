@@ -0,0 +1,292 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/api"
+	"github.com/wozozo/s3pit/pkg/storage"
+)
+
+// storageFor returns the storage holding the buckets of a tenant
+func (s *Server) storageFor(accessKey string) storage.Storage {
+	if tenantStorage, ok := s.storage.(*storage.TenantAwareStorage); ok && accessKey != "" {
+		if tenant, err := tenantStorage.GetStorageForTenant(accessKey); err == nil {
+			return tenant
+		}
+	}
+	return s.storage
+}
+
+// bucketPolicy returns the policy of a tenant's bucket, or nil when the
+// bucket does not exist or has no valid policy
+func (s *Server) bucketPolicy(accessKey, bucket string) *storage.BucketPolicy {
+	data, err := s.storageFor(accessKey).GetBucketPolicy(bucket)
+	if err != nil || data == nil {
+		return nil
+	}
+	policy, err := storage.ParseBucketPolicy(data)
+	if err != nil {
+		log.Printf("[AUTH] Ignoring unreadable policy of bucket %s: %v", bucket, err)
+		return nil
+	}
+	return policy
+}
+
+// bucketOwners returns the access keys whose storage an anonymous request
+// may address, in the order they are searched. Without tenant-aware storage
+// the only owner is the shared storage, named by "".
+func (s *Server) bucketOwners() []string {
+	if _, ok := s.storage.(*storage.TenantAwareStorage); !ok || s.tenantManager == nil {
+		return []string{""}
+	}
+
+	var owners []string
+	for _, t := range s.tenantManager.ListTenants() {
+		owners = append(owners, t.AccessKeyID)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// bucketOwner returns the access key of the first tenant, in bucketOwners
+// order, that has a bucket with the given name
+func (s *Server) bucketOwner(bucket string) (string, bool) {
+	for _, owner := range s.bucketOwners() {
+		if exists, err := s.storageFor(owner).BucketExists(bucket); err == nil && exists {
+			return owner, true
+		}
+	}
+	return "", false
+}
+
+// anonymousPolicyDecision evaluates the policy of the bucket an anonymous
+// request addresses, in the storage of the tenant owning the bucket as
+// found by bucketOwner
+func (s *Server) anonymousPolicyDecision(c *gin.Context, owner, bucket string) storage.PolicyDecision {
+	return s.bucketPolicy(owner, bucket).Evaluate(newPolicyRequest(c, "", bucket))
+}
+
+// anonymousCopySourceAllowed reports whether an anonymous CopyObject or
+// UploadPartCopy may read its source, which is authorized like a GetObject
+// of the source: the policy of the source bucket is evaluated first, then
+// the ACL of the source object and finally the public bucket list. A copy
+// reads its source from the storage of the tenant owning the destination,
+// so only that tenant's buckets are considered.
+func (s *Server) anonymousCopySourceAllowed(c *gin.Context, owner string) bool {
+	bucket, key, versionId, invalid := api.ParseCopySource(c.GetHeader("x-amz-copy-source"))
+	if invalid != nil {
+		// The handler rejects the request for the malformed header
+		return true
+	}
+
+	req := storage.PolicyRequest{
+		Action:  "s3:GetObject",
+		Bucket:  bucket,
+		Key:     key,
+		Context: policyContext(c),
+	}
+	delete(req.Context, "s3:VersionId")
+	if versionId != "" {
+		req.Action = "s3:GetObjectVersion"
+		req.Context["s3:VersionId"] = versionId
+	}
+	switch s.bucketPolicy(owner, bucket).Evaluate(req) {
+	case storage.PolicyDeny:
+		return false
+	case storage.PolicyAllow:
+		return true
+	}
+
+	meta, err := s.storageFor(owner).GetObjectVersionMetadata(bucket, key, versionId)
+	if err == nil && storage.GrantsAllUsers(meta.ACL, storage.PermissionRead) {
+		return true
+	}
+
+	if s.tenantManager != nil {
+		isPublic, publicOwner := s.tenantManager.IsPublicBucket(bucket)
+		return isPublic && publicOwner == owner
+	}
+	return false
+}
+
+// isCopyRequest reports whether a request copies an object, as CopyObject
+// and UploadPartCopy do
+func isCopyRequest(c *gin.Context) bool {
+	return c.Request.Method == http.MethodPut &&
+		strings.TrimPrefix(c.Param("key"), "/") != "" &&
+		c.GetHeader("x-amz-copy-source") != ""
+}
+
+// deniedByPolicy reports whether the policy of a tenant's own bucket
+// explicitly denies an authenticated request. Managing the policy itself is
+// never denied, so a tenant cannot lock itself out of its bucket.
+func (s *Server) deniedByPolicy(c *gin.Context, accessKey, bucket string) bool {
+	req := newPolicyRequest(c, accessKey, bucket)
+	switch req.Action {
+	case "s3:GetBucketPolicy", "s3:PutBucketPolicy", "s3:DeleteBucketPolicy":
+		return false
+	}
+	return s.bucketPolicy(accessKey, bucket).Evaluate(req) == storage.PolicyDeny
+}
+
+// newPolicyRequest describes a request for bucket policy evaluation
+func newPolicyRequest(c *gin.Context, accessKey, bucket string) storage.PolicyRequest {
+	return storage.PolicyRequest{
+		Principal: accessKey,
+		Action:    policyAction(c),
+		Bucket:    bucket,
+		Key:       strings.TrimPrefix(c.Param("key"), "/"),
+		Context:   policyContext(c),
+	}
+}
+
+// policyAction returns the policy action a request performs
+func policyAction(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	if key == "" {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			switch {
+			case query.Has("policyStatus"):
+				return "s3:GetBucketPolicyStatus"
+			case query.Has("policy"):
+				return "s3:GetBucketPolicy"
+			case query.Has("versioning"):
+				return "s3:GetBucketVersioning"
+			case query.Has("tagging"):
+				return "s3:GetBucketTagging"
+			case query.Has("acl"):
+				return "s3:GetBucketAcl"
+			case query.Has("cors"):
+				return "s3:GetBucketCORS"
+			case query.Has("lifecycle"):
+				return "s3:GetLifecycleConfiguration"
+			case query.Has("versions"):
+				return "s3:ListBucketVersions"
+			case query.Has("uploads"):
+				return "s3:ListBucketMultipartUploads"
+			}
+			return "s3:ListBucket"
+		case http.MethodPut:
+			switch {
+			case query.Has("policy"):
+				return "s3:PutBucketPolicy"
+			case query.Has("versioning"):
+				return "s3:PutBucketVersioning"
+			case query.Has("tagging"):
+				return "s3:PutBucketTagging"
+			case query.Has("acl"):
+				return "s3:PutBucketAcl"
+			case query.Has("cors"):
+				return "s3:PutBucketCORS"
+			case query.Has("lifecycle"):
+				return "s3:PutLifecycleConfiguration"
+			}
+			return "s3:CreateBucket"
+		case http.MethodDelete:
+			switch {
+			case query.Has("policy"):
+				return "s3:DeleteBucketPolicy"
+			case query.Has("tagging"):
+				return "s3:PutBucketTagging"
+			case query.Has("cors"):
+				return "s3:PutBucketCORS"
+			case query.Has("lifecycle"):
+				return "s3:PutLifecycleConfiguration"
+			}
+			return "s3:DeleteBucket"
+		case http.MethodPost:
+			if query.Has("delete") {
+				return "s3:DeleteObject"
+			}
+			return "s3:PutObject"
+		}
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case query.Has("tagging"):
+			return "s3:GetObjectTagging"
+		case query.Has("acl"):
+			return "s3:GetObjectAcl"
+		case query.Get("uploadId") != "":
+			return "s3:ListMultipartUploadParts"
+		case query.Get("versionId") != "":
+			return "s3:GetObjectVersion"
+		}
+		return "s3:GetObject"
+	case http.MethodDelete:
+		switch {
+		case query.Has("tagging"):
+			return "s3:DeleteObjectTagging"
+		case query.Get("uploadId") != "":
+			return "s3:AbortMultipartUpload"
+		case query.Get("versionId") != "":
+			return "s3:DeleteObjectVersion"
+		}
+		return "s3:DeleteObject"
+	case http.MethodPut:
+		switch {
+		case query.Has("tagging"):
+			return "s3:PutObjectTagging"
+		case query.Has("acl"):
+			return "s3:PutObjectAcl"
+		}
+	}
+	return "s3:PutObject"
+}
+
+// policyContext returns the condition keys of a request
+func policyContext(c *gin.Context) map[string]string {
+	secure := c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+	context := map[string]string{
+		"aws:SourceIp":        c.ClientIP(),
+		"aws:SecureTransport": strconv.FormatBool(secure),
+	}
+	if userAgent := c.GetHeader("User-Agent"); userAgent != "" {
+		context["aws:UserAgent"] = userAgent
+	}
+	if referer := c.GetHeader("Referer"); referer != "" {
+		context["aws:Referer"] = referer
+	}
+
+	query := c.Request.URL.Query()
+	if versionId := query.Get("versionId"); versionId != "" {
+		context["s3:VersionId"] = versionId
+	}
+	if strings.HasPrefix(policyAction(c), "s3:ListBucket") {
+		context["s3:prefix"] = query.Get("prefix")
+		if query.Has("delimiter") {
+			context["s3:delimiter"] = query.Get("delimiter")
+		}
+		if query.Has("max-keys") {
+			context["s3:max-keys"] = query.Get("max-keys")
+		}
+	}
+	return context
+}
+
+// sendAccessDenied aborts a request with an AccessDenied error
+func sendAccessDenied(c *gin.Context, message string) {
+	abortWithError(c, http.StatusForbidden, "AccessDenied", message)
+}
+
+// abortWithError aborts a request with an S3 error response
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.Header("Content-Type", "application/xml")
+	c.XML(status, gin.H{
+		"Error": gin.H{
+			"Code":    code,
+			"Message": message,
+		},
+	})
+	c.Abort()
+}
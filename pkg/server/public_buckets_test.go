@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"encoding/xml"
 	"fmt"
 	"log"
 	"net/http"
@@ -18,6 +19,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wozozo/s3pit/internal/config"
+	"github.com/wozozo/s3pit/pkg/fault"
+	"github.com/wozozo/s3pit/pkg/metrics"
 	"github.com/wozozo/s3pit/pkg/storage"
 	"github.com/wozozo/s3pit/pkg/tenant"
 	"github.com/wozozo/s3pit/pkg/testutil"
@@ -93,14 +96,14 @@ func setupTestServerWithPublicBuckets(t *testing.T) (*Server, string, func()) {
 			{
 				AccessKeyID:     "public-tenant",
 				SecretAccessKey: "public-secret",
-				CustomDir:       "tenant-public",
+				CustomDir:       filepath.Join(tmpDir, "tenant-public"),
 				Description:     "Tenant with public buckets",
 				PublicBuckets:   []string{"public-bucket", "public-*"},
 			},
 			{
 				AccessKeyID:     "private-tenant",
 				SecretAccessKey: "private-secret",
-				CustomDir:       "tenant-private",
+				CustomDir:       filepath.Join(tmpDir, "tenant-private"),
 				Description:     "Tenant without public buckets",
 				PublicBuckets:   []string{},
 			},
@@ -134,16 +137,23 @@ func setupTestServerWithPublicBuckets(t *testing.T) (*Server, string, func()) {
 		ConfigFile:       configFile,
 		InMemory:         false,
 		EnableDashboard:  false,
+		EnableAdmin:      true,
+		EnableMetrics:    true,
 		AutoCreateBucket: true,
 	}
 
 	// Create server
+	clock := storage.NewVirtualClock()
 	server := &Server{
 		config:        cfg,
 		router:        gin.New(),
 		storage:       storageBackend,
 		authHandler:   authHandler,
 		tenantManager: tenantMgr,
+		clock:         clock,
+		lifecycle:     storage.NewLifecycleScheduler(storageBackend, clock, lifecycleInterval),
+		faults:        &fault.Engine{},
+		metrics:       metrics.NewRegistry(),
 	}
 
 	// Setup routes
@@ -634,3 +644,363 @@ func signRequestSimple(req *http.Request, accessKey string) {
 		credential)
 	req.Header.Set("Authorization", authHeader)
 }
+
+func TestBucketPolicyAccess(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	do := func(method, target, body, accessKey string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if accessKey != "" {
+			signRequestSimple(req, accessKey)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	policy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Effect": "Allow",
+				"Principal": "*",
+				"Action": "s3:GetObject",
+				"Resource": "arn:aws:s3:::private-bucket/*",
+				"Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}
+			},
+			{
+				"Effect": "Allow",
+				"Principal": {"AWS": "*"},
+				"Action": ["s3:ListBucket", "s3:PutObject"],
+				"Resource": ["arn:aws:s3:::private-bucket", "arn:aws:s3:::private-bucket/drop/*"],
+				"Condition": {"StringLikeIfExists": {"s3:prefix": "drop/*"}}
+			},
+			{
+				"Effect": "Deny",
+				"Principal": "*",
+				"Action": "s3:DeleteObject",
+				"Resource": "arn:aws:s3:::private-bucket/*"
+			}
+		]
+	}`
+	w := do("PUT", "/private-bucket?policy", policy, "private-tenant", nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	t.Run("Policy is stored per tenant", func(t *testing.T) {
+		w := do("GET", "/private-bucket?policy", "", "private-tenant", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("GET", "/private-bucket?policy", "", "public-tenant", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Anonymous GetObject honours aws:SourceIp", func(t *testing.T) {
+		w := do("GET", "/private-bucket/secret.txt", "", "", map[string]string{"X-Forwarded-For": "10.1.2.3"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "private content", w.Body.String())
+
+		w = do("GET", "/private-bucket/secret.txt", "", "", map[string]string{"X-Forwarded-For": "192.0.2.10"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Anonymous ListBucket honours s3:prefix", func(t *testing.T) {
+		w := do("GET", "/private-bucket?list-type=2&prefix=drop/", "", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("GET", "/private-bucket?list-type=2&prefix=secret", "", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Anonymous PutObject is limited to the allowed resource", func(t *testing.T) {
+		w := do("PUT", "/private-bucket/drop/upload.txt", "dropped", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("GET", "/private-bucket/drop/upload.txt", "", "private-tenant", nil)
+		assert.Equal(t, "dropped", w.Body.String())
+
+		w = do("PUT", "/private-bucket/elsewhere.txt", "dropped", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Deny applies to authenticated requests", func(t *testing.T) {
+		w := do("DELETE", "/private-bucket/secret.txt", "", "private-tenant", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "AccessDenied")
+
+		w = do("DELETE", "/private-bucket/secret.txt", "", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Policy status reports conditional grants as not public", func(t *testing.T) {
+		w := do("GET", "/private-bucket?policyStatus", "", "private-tenant", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<IsPublic>false</IsPublic>")
+	})
+
+	t.Run("Owner can remove the policy", func(t *testing.T) {
+		w := do("DELETE", "/private-bucket?policy", "", "private-tenant", nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = do("GET", "/private-bucket/secret.txt", "", "", map[string]string{"X-Forwarded-For": "10.1.2.3"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("DELETE", "/private-bucket/drop/upload.txt", "", "private-tenant", nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+}
+
+func TestBucketPolicyDenyOverridesPublicBuckets(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	policy := `{"Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::public-bucket/test.txt"}]}`
+	req := httptest.NewRequest("PUT", "/public-bucket?policy", strings.NewReader(policy))
+	signRequestSimple(req, "public-tenant")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/public-bucket/test.txt", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Requests the policy does not cover still use the public bucket list
+	req = httptest.NewRequest("GET", "/public-bucket", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestObjectACLPublicRead(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	do := func(method, target, body, accessKey string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if accessKey != "" {
+			signRequestSimple(req, accessKey)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/private-bucket/shared.txt", "shared content", "private-tenant", map[string]string{"x-amz-acl": "public-read"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	t.Run("AllUsers READ allows anonymous reads of the object", func(t *testing.T) {
+		w := do("GET", "/private-bucket/shared.txt", "", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "shared content", w.Body.String())
+
+		w = do("HEAD", "/private-bucket/shared.txt", "", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Other objects stay private", func(t *testing.T) {
+		w := do("GET", "/private-bucket/secret.txt", "", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("PUT", "/private-bucket/shared.txt", "overwritten", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("GET", "/private-bucket?list-type=2", "", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Bucket ACL allows anonymous listing", func(t *testing.T) {
+		w := do("PUT", "/private-bucket?acl", "", "private-tenant", map[string]string{"x-amz-acl": "public-read"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = do("GET", "/private-bucket?list-type=2", "", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "shared.txt")
+
+		// Bucket READ does not extend to the objects
+		w = do("GET", "/private-bucket/secret.txt", "", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Revoking the object ACL restores private access", func(t *testing.T) {
+		w := do("PUT", "/private-bucket/shared.txt?acl", "", "private-tenant", map[string]string{"x-amz-acl": "private"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = do("GET", "/private-bucket/shared.txt", "", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestAnonymousCopyNeedsReadOnSource(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	do := func(method, target, body, accessKey string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if accessKey != "" {
+			signRequestSimple(req, accessKey)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/private-bucket/topsecret.txt", "TOP SECRET", "private-tenant", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/private-bucket/shared.txt", "shared content", "private-tenant", map[string]string{"x-amz-acl": "public-read"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The dropbox bucket takes anonymous uploads through its policy, the
+	// outbox bucket through its ACL
+	w = do("PUT", "/dropbox", "", "private-tenant", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	policy := `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": "*",
+			"Action": ["s3:GetObject", "s3:PutObject"],
+			"Resource": "arn:aws:s3:::dropbox/*"
+		}]
+	}`
+	w = do("PUT", "/dropbox?policy", policy, "private-tenant", nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	w = do("PUT", "/outbox", "", "private-tenant", map[string]string{"x-amz-acl": "public-read-write"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do("GET", "/private-bucket/topsecret.txt", "", "", nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	for _, bucket := range []string{"dropbox", "outbox"} {
+		t.Run(bucket, func(t *testing.T) {
+			w := do("PUT", "/"+bucket+"/upload.txt", "uploaded", "", nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			w = do("PUT", "/"+bucket+"/leak.txt", "", "", map[string]string{
+				"x-amz-copy-source": "/private-bucket/topsecret.txt",
+				"x-amz-acl":         "public-read",
+			})
+			assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+			w = do("GET", "/"+bucket+"/leak.txt", "", "private-tenant", nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			// An object anyone may read can be copied
+			w = do("PUT", "/"+bucket+"/copy.txt", "", "", map[string]string{
+				"x-amz-copy-source": "/private-bucket/shared.txt",
+			})
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("UploadPartCopy", func(t *testing.T) {
+		w := do("POST", "/dropbox/parts.txt?uploads", "", "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var initiate struct {
+			UploadId string `xml:"UploadId"`
+		}
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &initiate))
+
+		w = do("PUT", "/dropbox/parts.txt?partNumber=1&uploadId="+initiate.UploadId, "", "", map[string]string{
+			"x-amz-copy-source": "/private-bucket/topsecret.txt",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+		w = do("PUT", "/dropbox/parts.txt?partNumber=1&uploadId="+initiate.UploadId, "", "", map[string]string{
+			"x-amz-copy-source": "/private-bucket/shared.txt",
+		})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Source policy Deny wins over its ACL", func(t *testing.T) {
+		policy := `{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Deny",
+				"Principal": "*",
+				"Action": "s3:GetObject",
+				"Resource": "arn:aws:s3:::private-bucket/shared.txt"
+			}]
+		}`
+		w := do("PUT", "/private-bucket?policy", policy, "private-tenant", nil)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+		w = do("PUT", "/dropbox/denied.txt", "", "", map[string]string{
+			"x-amz-copy-source": "/private-bucket/shared.txt",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	})
+}
+
+func TestAnonymousAccessToSharedBucketName(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	do := func(method, target, body, accessKey string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if accessKey != "" {
+			signRequestSimple(req, accessKey)
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	allowGet := `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": "*",
+			"Action": "s3:GetObject",
+			"Resource": "arn:aws:s3:::shared/*"
+		}]
+	}`
+
+	// Both tenants have a bucket named shared; private-tenant sorts first
+	// and owns it for anonymous requests
+	for _, tenant := range []string{"private-tenant", "public-tenant"} {
+		w := do("PUT", "/shared", "", tenant, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = do("PUT", "/shared/doc.txt", "content of "+tenant, tenant, map[string]string{"x-amz-acl": "public-read"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	w := do("PUT", "/shared/other.txt", "other", "public-tenant", map[string]string{"x-amz-acl": "public-read"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do("PUT", "/shared?policy", allowGet, "public-tenant", nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	t.Run("Policy of the other tenant does not apply", func(t *testing.T) {
+		w := do("GET", "/shared/other.txt", "", "", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("ACL of the owning tenant applies", func(t *testing.T) {
+		w := do("GET", "/shared/doc.txt", "", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "content of private-tenant", w.Body.String())
+	})
+
+	t.Run("Policy of the owning tenant applies", func(t *testing.T) {
+		w := do("PUT", "/shared/doc.txt?acl", "", "private-tenant", map[string]string{"x-amz-acl": "private"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = do("GET", "/shared/doc.txt", "", "", nil)
+		require.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("PUT", "/shared?policy", allowGet, "private-tenant", nil)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		w = do("GET", "/shared/doc.txt", "", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "content of private-tenant", w.Body.String())
+	})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/pkg/recorder"
+)
+
+// recordMiddleware records every S3 request together with its response,
+// so that the session can be replayed later. Requests are recorded as the
+// client sent them, before authentication and the other middlewares.
+func (s *Server) recordMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Skip recording for the dashboard, admin and metrics endpoints
+		if s.recorder == nil || isInternalPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		req := recorder.Request{
+			Method: c.Request.Method,
+			URI:    c.Request.RequestURI,
+			Host:   c.Request.Host,
+			Header: c.Request.Header.Clone(),
+		}
+		if req.URI == "" {
+			req.URI = c.Request.URL.RequestURI()
+		}
+		// Bodies are captured as they stream, up to recorder.MaxBodySize
+		// bytes, rather than buffered
+		body := &recordingReader{}
+		if c.Request.Body != nil {
+			body.ReadCloser = c.Request.Body
+			c.Request.Body = body
+		}
+
+		rw := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = rw
+
+		c.Next()
+
+		// Record the part of the body the handler left unread, such as the
+		// body of a denied request, as far as it fits
+		if body.ReadCloser != nil && !body.truncated {
+			_, _ = io.Copy(io.Discard, io.LimitReader(body, recorder.MaxBodySize+1))
+		}
+		req.Body, req.Truncated = body.body.Bytes(), body.truncated
+
+		err := s.recorder.Record(&recorder.Exchange{
+			Timestamp: start,
+			Duration:  time.Since(start),
+			Request:   req,
+			Response: recorder.Response{
+				Status:    rw.Status(),
+				Header:    rw.Header().Clone(),
+				Body:      rw.body.Bytes(),
+				Truncated: rw.truncated,
+			},
+		})
+		if err != nil {
+			log.Printf("[RECORD] Failed to record %s %s: %v", req.Method, req.URI, err)
+		}
+	}
+}
+
+// recordingReader keeps a copy of the start of the request body
+type recordingReader struct {
+	io.ReadCloser
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.truncated = keepBody(&r.body, p[:n]) || r.truncated
+	return n, err
+}
+
+// recordingWriter keeps a copy of the start of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.truncated = keepBody(&w.body, data) || w.truncated
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.truncated = keepBody(&w.body, []byte(s)) || w.truncated
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keepBody appends data to a recorded body up to recorder.MaxBodySize bytes
+// and reports whether any of it had to be left out
+func keepBody(buf *bytes.Buffer, data []byte) bool {
+	room := recorder.MaxBodySize - buf.Len()
+	if len(data) <= room {
+		buf.Write(data)
+		return false
+	}
+	buf.Write(data[:room])
+	return true
+}
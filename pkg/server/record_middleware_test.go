@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wozozo/s3pit/pkg/recorder"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	recording := filepath.Join(t.TempDir(), "session.jsonl")
+	rec, err := recorder.NewRecorder(recording)
+	require.NoError(t, err)
+	server.recorder = rec
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	for _, path := range []string{"/public-bucket/test.txt", "/public-bucket?list-type=2", "/public-bucket/missing.txt", "/_s3pit/clock"} {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		_, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	require.NoError(t, rec.Close())
+	server.recorder = nil
+
+	exchanges, err := recorder.ReadFile(recording)
+	require.NoError(t, err)
+
+	// Admin requests are not recorded
+	require.Len(t, exchanges, 3)
+	assert.Equal(t, "/public-bucket?list-type=2", exchanges[1].Request.URI)
+	assert.Equal(t, "public content", string(exchanges[0].Response.Body))
+	assert.Equal(t, http.StatusNotFound, exchanges[2].Response.Status)
+
+	// Replaying the session reproduces every response
+	for i := range exchanges {
+		resp, err := recorder.Replay(ts.Client(), ts.URL, &exchanges[i])
+		require.NoError(t, err)
+		assert.Empty(t, recorder.Diff(&exchanges[i].Response, resp), exchanges[i].Request.URI)
+	}
+
+	// A changed object shows up as a difference
+	exchanges[0].Response.Body = []byte("old content")
+	resp, err := recorder.Replay(ts.Client(), ts.URL, &exchanges[0])
+	require.NoError(t, err)
+	assert.NotEmpty(t, recorder.Diff(&exchanges[0].Response, resp))
+}
+
+func TestRecordTruncatesLargeBodies(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	recording := filepath.Join(t.TempDir(), "session.jsonl")
+	rec, err := recorder.NewRecorder(recording)
+	require.NoError(t, err)
+	server.recorder = rec
+
+	large := bytes.Repeat([]byte("x"), recorder.MaxBodySize+100)
+	req := httptest.NewRequest("PUT", "/private-bucket/large.bin", bytes.NewReader(large))
+	signRequestSimple(req, "private-tenant")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/private-bucket/large.bin", nil)
+	signRequestSimple(req, "private-tenant")
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, len(large), w.Body.Len())
+
+	// The body of a denied request is recorded even though it is never read
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("PUT", "/private-bucket/denied.txt", strings.NewReader("denied")))
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	require.NoError(t, rec.Close())
+	server.recorder = nil
+
+	exchanges, err := recorder.ReadFile(recording)
+	require.NoError(t, err)
+	require.Len(t, exchanges, 3)
+
+	assert.True(t, exchanges[0].Request.Truncated)
+	assert.Len(t, exchanges[0].Request.Body, recorder.MaxBodySize)
+	assert.NotEmpty(t, recorder.Unreplayable(&exchanges[0]))
+
+	assert.True(t, exchanges[1].Response.Truncated)
+	assert.Len(t, exchanges[1].Response.Body, recorder.MaxBodySize)
+
+	assert.False(t, exchanges[2].Request.Truncated)
+	assert.Equal(t, "denied", string(exchanges[2].Request.Body))
+	assert.Empty(t, recorder.Unreplayable(&exchanges[2]))
+}
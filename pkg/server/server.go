@@ -3,15 +3,28 @@ package server
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wozozo/s3pit/internal/config"
 	"github.com/wozozo/s3pit/pkg/api"
 	"github.com/wozozo/s3pit/pkg/auth"
 	"github.com/wozozo/s3pit/pkg/dashboard"
+	"github.com/wozozo/s3pit/pkg/fault"
 	"github.com/wozozo/s3pit/pkg/logger"
+	"github.com/wozozo/s3pit/pkg/metrics"
+	"github.com/wozozo/s3pit/pkg/recorder"
 	"github.com/wozozo/s3pit/pkg/storage"
 	"github.com/wozozo/s3pit/pkg/tenant"
+	"github.com/wozozo/s3pit/pkg/tracing"
+)
+
+const (
+	// uploadReapInterval is how often stale multipart uploads are looked for
+	uploadReapInterval = 10 * time.Minute
+	// lifecycleInterval is how often the bucket lifecycle rules are applied
+	lifecycleInterval = time.Hour
 )
 
 type Server struct {
@@ -20,6 +33,13 @@ type Server struct {
 	storage       storage.Storage
 	authHandler   auth.Handler
 	tenantManager *tenant.Manager
+	clock         *storage.VirtualClock
+	lifecycle     *storage.LifecycleScheduler
+	faults        *fault.Engine
+	recorder      *recorder.Recorder // nil unless traffic is recorded
+	metrics       *metrics.Registry  // nil unless metrics are enabled
+	bucketStats   bucketStatsCache
+	tracer        *tracing.Tracer // nil unless tracing is enabled
 }
 
 func New(cfg *config.Config) (*Server, error) {
@@ -90,12 +110,41 @@ func NewWithCmdLineOverrides(cfg *config.Config, cmdLineOverrides map[string]boo
 		return nil, fmt.Errorf("failed to initialize auth handler: %w", err)
 	}
 
+	faults, err := fault.NewEngine(tenantMgr.GetFaultRules())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fault rules: %w", err)
+	}
+
+	var rec *recorder.Recorder
+	if cfg.RecordFile != "" {
+		if rec, err = recorder.NewRecorder(cfg.RecordFile); err != nil {
+			return nil, err
+		}
+	}
+
+	tracer, err := newTracer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var registry *metrics.Registry
+	if cfg.EnableMetrics {
+		registry = metrics.NewRegistry()
+	}
+
+	clock := storage.NewVirtualClock()
 	s := &Server{
 		config:        cfg,
 		router:        gin.New(),
 		storage:       storageBackend,
 		authHandler:   authHandler,
 		tenantManager: tenantMgr,
+		clock:         clock,
+		lifecycle:     storage.NewLifecycleScheduler(storageBackend, clock, lifecycleInterval),
+		faults:        faults,
+		recorder:      rec,
+		metrics:       registry,
+		tracer:        tracer,
 	}
 
 	s.setupRoutes()
@@ -105,56 +154,158 @@ func NewWithCmdLineOverrides(cfg *config.Config, cmdLineOverrides map[string]boo
 
 func (s *Server) setupRoutes() {
 	s.router.Use(gin.Recovery())
+	s.router.Use(s.requestBodyMiddleware()) // Wrap the request body before anything reads it, for upload limits
 	s.router.Use(logger.S3APILoggingMiddleware())
 	s.router.Use(dashboard.LoggingMiddleware())
+	s.router.Use(s.traceMiddleware())   // Start the request span first so it covers the other middlewares
+	s.router.Use(s.recordMiddleware())  // Record requests before any middleware changes them
+	s.router.Use(s.metricsMiddleware()) // Count requests before auth so denied requests are included
 	s.router.Use(s.corsMiddleware())
 	s.router.Use(s.delayMiddleware()) // Add delay middleware before auth
 	s.router.Use(s.authMiddleware())  // Add authentication middleware
+	s.router.Use(s.faultMiddleware()) // Add fault injection after auth so rules can match tenants
+	s.router.Use(s.bandwidthMiddleware())
 
-	// Setup dashboard routes BEFORE S3 API routes to avoid conflicts
+	// Setup dashboard, admin and metrics routes BEFORE S3 API routes to avoid conflicts
+	if s.config.EnableAdmin {
+		s.setupAdmin()
+	} else {
+		s.setupAdminDisabled()
+	}
+	if s.metrics != nil && s.config.EnableAdmin {
+		s.router.GET(metricsPath, s.handleMetrics)
+	}
 	if s.config.EnableDashboard {
 		s.setupDashboard()
 	}
 
 	apiHandler := api.NewHandler(s.storage, s.authHandler, s.tenantManager, s.config)
 
+	// Bucket-level requests dispatch on subresource query parameters
+	getBucket := func(c *gin.Context) {
+		if _, exists := c.GetQuery("versioning"); exists {
+			apiHandler.GetBucketVersioning(c)
+		} else if _, exists := c.GetQuery("tagging"); exists {
+			apiHandler.GetBucketTagging(c)
+		} else if _, exists := c.GetQuery("acl"); exists {
+			apiHandler.GetBucketAcl(c)
+		} else if _, exists := c.GetQuery("cors"); exists {
+			apiHandler.GetBucketCors(c)
+		} else if _, exists := c.GetQuery("lifecycle"); exists {
+			apiHandler.GetBucketLifecycleConfiguration(c)
+		} else if _, exists := c.GetQuery("policy"); exists {
+			apiHandler.GetBucketPolicy(c)
+		} else if _, exists := c.GetQuery("policyStatus"); exists {
+			apiHandler.GetBucketPolicyStatus(c)
+		} else if _, exists := c.GetQuery("versions"); exists {
+			apiHandler.ListObjectVersions(c)
+		} else if _, exists := c.GetQuery("uploads"); exists {
+			apiHandler.ListMultipartUploads(c)
+		} else {
+			apiHandler.ListObjects(c)
+		}
+	}
+	putBucket := func(c *gin.Context) {
+		if _, exists := c.GetQuery("versioning"); exists {
+			apiHandler.PutBucketVersioning(c)
+		} else if _, exists := c.GetQuery("tagging"); exists {
+			apiHandler.PutBucketTagging(c)
+		} else if _, exists := c.GetQuery("acl"); exists {
+			apiHandler.PutBucketAcl(c)
+		} else if _, exists := c.GetQuery("cors"); exists {
+			apiHandler.PutBucketCors(c)
+		} else if _, exists := c.GetQuery("lifecycle"); exists {
+			apiHandler.PutBucketLifecycleConfiguration(c)
+		} else if _, exists := c.GetQuery("policy"); exists {
+			apiHandler.PutBucketPolicy(c)
+		} else {
+			apiHandler.CreateBucket(c)
+		}
+	}
+	deleteBucket := func(c *gin.Context) {
+		if _, exists := c.GetQuery("tagging"); exists {
+			apiHandler.DeleteBucketTagging(c)
+		} else if _, exists := c.GetQuery("cors"); exists {
+			apiHandler.DeleteBucketCors(c)
+		} else if _, exists := c.GetQuery("lifecycle"); exists {
+			apiHandler.DeleteBucketLifecycle(c)
+		} else if _, exists := c.GetQuery("policy"); exists {
+			apiHandler.DeleteBucketPolicy(c)
+		} else {
+			apiHandler.DeleteBucket(c)
+		}
+	}
+
 	s.router.GET("/", apiHandler.ListBuckets)
 	s.router.HEAD("/:bucket", apiHandler.HeadBucket)
-	s.router.PUT("/:bucket", apiHandler.CreateBucket)
-	s.router.DELETE("/:bucket", apiHandler.DeleteBucket)
-	s.router.GET("/:bucket", apiHandler.ListObjectsV2)
+	s.router.PUT("/:bucket", putBucket)
+	s.router.DELETE("/:bucket", deleteBucket)
+	s.router.GET("/:bucket", getBucket)
 
 	s.router.HEAD("/:bucket/*key", apiHandler.HeadObject)
 	s.router.GET("/:bucket/*key", func(c *gin.Context) {
 		key := c.Param("key")
-		// If key is empty or just "/", this is actually a ListObjectsV2 request
+		// If key is empty or just "/", this is actually a bucket-level request
 		if key == "" || key == "/" {
-			apiHandler.ListObjectsV2(c)
+			getBucket(c)
 			return
 		}
-		apiHandler.GetObject(c)
+		if _, exists := c.GetQuery("attributes"); exists {
+			apiHandler.GetObjectAttributes(c)
+		} else if _, exists := c.GetQuery("tagging"); exists {
+			apiHandler.GetObjectTagging(c)
+		} else if _, exists := c.GetQuery("acl"); exists {
+			apiHandler.GetObjectAcl(c)
+		} else if c.Query("uploadId") != "" {
+			apiHandler.ListParts(c)
+		} else {
+			apiHandler.GetObject(c)
+		}
 	})
 	s.router.PUT("/:bucket/*key", func(c *gin.Context) {
 		key := c.Param("key")
-		// If key is empty or just "/", this is actually a bucket creation request
+		// If key is empty or just "/", this is actually a bucket-level request
 		if key == "" || key == "/" {
-			apiHandler.CreateBucket(c)
+			putBucket(c)
 			return
 		}
 
-		// Check if this is a copy operation
-		if c.GetHeader("x-amz-copy-source") != "" {
-			apiHandler.CopyObject(c)
-		} else if c.Query("partNumber") != "" && c.Query("uploadId") != "" {
-			// This is a part upload for multipart upload
+		if _, exists := c.GetQuery("tagging"); exists {
+			apiHandler.PutObjectTagging(c)
+			return
+		}
+		if _, exists := c.GetQuery("acl"); exists {
+			apiHandler.PutObjectAcl(c)
+			return
+		}
+
+		isPart := c.Query("partNumber") != "" && c.Query("uploadId") != ""
+		isCopy := c.GetHeader("x-amz-copy-source") != ""
+
+		// Check if this is a part upload for multipart upload, either
+		// with a body or copied from an existing object
+		if isPart && isCopy {
+			apiHandler.UploadPartCopy(c)
+		} else if isPart {
 			apiHandler.UploadPart(c)
+		} else if isCopy {
+			apiHandler.CopyObject(c)
 		} else {
 			apiHandler.PutObject(c)
 		}
 	})
 	s.router.DELETE("/:bucket/*key", func(c *gin.Context) {
-		// Check if this is an abort multipart upload
-		if c.Query("uploadId") != "" {
+		key := c.Param("key")
+		// If key is empty or just "/", this is actually a bucket-level request
+		if key == "" || key == "/" {
+			deleteBucket(c)
+			return
+		}
+
+		// Check if this is a tagging removal or an abort multipart upload
+		if _, exists := c.GetQuery("tagging"); exists {
+			apiHandler.DeleteObjectTagging(c)
+		} else if c.Query("uploadId") != "" {
 			apiHandler.AbortMultipartUpload(c)
 		} else {
 			apiHandler.DeleteObject(c)
@@ -183,22 +334,6 @@ func (s *Server) setupRoutes() {
 	})
 }
 
-func (s *Server) corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, HEAD")
-		c.Header("Access-Control-Allow-Headers", "*")
-		c.Header("Access-Control-Expose-Headers", "*")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(200)
-			return
-		}
-
-		c.Next()
-	}
-}
-
 func (s *Server) setupDashboard() {
 	// Pass auth configuration to dashboard
 	region := s.config.Region
@@ -240,11 +375,48 @@ func (s *Server) Start() error {
 	if s.config.AutoCreateBucket {
 		log.Printf("Auto-create bucket: enabled")
 	}
+	if s.config.MultipartExpiryHours > 0 {
+		log.Printf("Multipart uploads expire after %d hours", s.config.MultipartExpiryHours)
+		reaper := storage.NewUploadReaper(s.storage, time.Duration(s.config.MultipartExpiryHours)*time.Hour, uploadReapInterval)
+		reaper.Start()
+		defer reaper.Stop()
+	}
+	s.lifecycle.Start()
+	defer s.lifecycle.Stop()
+	if s.config.Domain != "" {
+		log.Printf("Virtual-hosted-style requests: *.%s", s.config.Domain)
+	}
+	if s.config.UploadBytesPerSecond > 0 || s.config.DownloadBytesPerSecond > 0 {
+		log.Printf("Bandwidth limits: upload %d B/s, download %d B/s (0 = unlimited)",
+			s.config.UploadBytesPerSecond, s.config.DownloadBytesPerSecond)
+	}
+	if s.recorder != nil {
+		log.Printf("Recording traffic to %s", s.config.RecordFile)
+		defer s.recorder.Close()
+	}
+	if s.tracer != nil {
+		if s.config.OTLPEndpoint != "" {
+			log.Printf("Exporting traces to %s", s.config.OTLPEndpoint)
+		}
+		if s.config.TraceFile != "" {
+			log.Printf("Writing traces to %s", s.config.TraceFile)
+		}
+		defer s.tracer.Close()
+	}
+	if rules := s.faults.Rules(); len(rules) > 0 {
+		log.Printf("Fault injection: %d rule(s)", len(rules))
+	}
+	if s.config.EnableAdmin {
+		log.Printf("Admin endpoints: http://%s%s (unauthenticated)", addr, adminPathPrefix)
+	}
 	if s.config.EnableDashboard {
 		log.Printf("Dashboard: http://%s/dashboard", addr)
 	}
+	if s.metrics != nil {
+		log.Printf("Metrics: http://%s%s (unauthenticated)", addr, metricsPath)
+	}
 
-	return s.router.Run(addr)
+	return http.ListenAndServe(addr, s)
 }
 
 func (s *Server) getStorageType() string {
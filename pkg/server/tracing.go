@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wozozo/s3pit/internal/config"
+	"github.com/wozozo/s3pit/pkg/logger"
+	"github.com/wozozo/s3pit/pkg/tracing"
+)
+
+// newTracer creates the tracer for the configured exporters, or nil when
+// tracing is disabled
+func newTracer(cfg *config.Config) (*tracing.Tracer, error) {
+	var exporters []tracing.Exporter
+	if cfg.OTLPEndpoint != "" {
+		exporters = append(exporters, tracing.NewOTLPExporter(cfg.OTLPEndpoint))
+	}
+	if cfg.TraceFile != "" {
+		file, err := tracing.NewFileExporter(cfg.TraceFile)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, file)
+	}
+	if len(exporters) == 0 {
+		return nil, nil
+	}
+	return tracing.NewTracer(exporters...), nil
+}
+
+// traceMiddleware records a span per S3 request, continuing the trace of an
+// incoming traceparent header. The delay, authentication and storage calls
+// of the request are recorded as its children.
+func (s *Server) traceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Skip tracing for the dashboard, admin and metrics endpoints
+		if s.tracer == nil || isInternalPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		remote, hasRemote := tracing.ParseTraceparent(c.GetHeader("traceparent"))
+		ctx, span := s.tracer.StartRequest(c.Request.Context(), "S3", remote, hasRemote)
+		if span == nil {
+			c.Next()
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		rw := &errorCodeWriter{ResponseWriter: c.Writer}
+		c.Writer = rw
+
+		c.Next()
+
+		// The operation and tenant are known once the request is handled
+		operation := logger.DetermineOperation(c)
+		span.SetName("S3." + operation)
+		span.SetAttribute("rpc.system", "aws-api")
+		span.SetAttribute("rpc.service", "S3")
+		span.SetAttribute("rpc.method", operation)
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("url.path", c.Request.URL.Path)
+		span.SetAttribute("http.response.status_code", rw.Status())
+		span.SetAttribute("s3pit.tenant", c.GetString("accessKey"))
+		span.SetAttribute("aws.s3.bucket", c.Param("bucket"))
+		span.SetAttribute("aws.s3.key", strings.TrimPrefix(c.Param("key"), "/"))
+
+		// Client errors are the caller's mistake and leave the span status
+		// unset, as for any server span; their code is still recorded
+		code := rw.errorCode()
+		span.SetAttribute("s3pit.error_code", code)
+		if rw.Status() >= 500 {
+			if code == "" {
+				code = http.StatusText(rw.Status())
+			}
+			span.SetError(code)
+		}
+		span.Finish()
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wozozo/s3pit/pkg/tracing"
+)
+
+func TestTraceMiddleware(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+
+	traceFile := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := tracing.NewFileExporter(traceFile)
+	require.NoError(t, err)
+	server.tracer = tracing.NewTracer(exporter)
+	server.config.ReadDelayMs = 1
+
+	req := httptest.NewRequest("GET", "/public-bucket/test.txt", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/public-bucket/missing.txt", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// Anonymous writes to private buckets fail authentication
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/private-bucket/secret.txt", nil))
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	// Internal endpoints are not traced
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", metricsPath, nil))
+
+	require.NoError(t, server.tracer.Close())
+	server.tracer = nil
+
+	spans, err := tracing.ReadFile(traceFile)
+	require.NoError(t, err)
+
+	byID := make(map[string]tracing.SpanData)
+	var requests []tracing.SpanData
+	for _, span := range spans {
+		byID[span.SpanID] = span
+		if span.Kind == tracing.KindServer {
+			requests = append(requests, span)
+		}
+	}
+	require.Len(t, requests, 3)
+
+	// The first request continues the caller's trace
+	get := requests[0]
+	assert.Equal(t, "S3.GetObject", get.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", get.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", get.ParentSpanID)
+	assert.Equal(t, "public-tenant", get.StringAttribute("s3pit.tenant"))
+	assert.Equal(t, "public-bucket", get.StringAttribute("aws.s3.bucket"))
+	assert.Equal(t, "test.txt", get.StringAttribute("aws.s3.key"))
+	assert.Equal(t, "200", get.StringAttribute("http.response.status_code"))
+
+	missing := requests[1]
+	assert.NotEqual(t, get.TraceID, missing.TraceID)
+	assert.Empty(t, missing.ParentSpanID)
+	assert.Equal(t, "NoSuchKey", missing.StringAttribute("s3pit.error_code"))
+	assert.Equal(t, 0, missing.Status.Code, "client errors leave the status unset")
+
+	denied := requests[2]
+	assert.Equal(t, "AccessDenied", denied.StringAttribute("s3pit.error_code"))
+
+	// Delay, authentication and storage calls are children of their request
+	children := make(map[string][]tracing.SpanData)
+	for _, span := range spans {
+		if parent, ok := byID[span.ParentSpanID]; ok {
+			children[parent.SpanID] = append(children[parent.SpanID], span)
+		}
+	}
+	names := func(spans []tracing.SpanData) []string {
+		var names []string
+		for _, span := range spans {
+			names = append(names, span.Name)
+		}
+		return names
+	}
+	assert.Contains(t, names(children[get.SpanID]), "delay")
+	assert.Contains(t, names(children[get.SpanID]), "storage.GetObjectVersion")
+	assert.Contains(t, names(children[denied.SpanID]), "auth.Authenticate")
+
+	failed := make(map[string]string)
+	for _, span := range append(children[missing.SpanID], children[denied.SpanID]...) {
+		if span.Status.Code == tracing.StatusError {
+			failed[span.Name] = span.StringAttribute("aws.s3.key")
+		}
+	}
+	assert.Equal(t, map[string]string{"storage.GetObjectVersion": "missing.txt", "auth.Authenticate": ""}, failed)
+}
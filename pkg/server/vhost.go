@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/wozozo/s3pit/pkg/auth"
+)
+
+// ServeHTTP serves a request, routing virtual-hosted-style requests such as
+// Host: my-bucket.s3pit.localhost like their path-style equivalent. The path
+// is rewritten before routing, so every middleware sees the bucket in it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if bucket := s.virtualHostBucket(r.Host); bucket != "" {
+		signedPath := r.URL.Path
+		r = auth.WithSignedPath(r, signedPath)
+
+		u := *r.URL
+		if signedPath == "" || signedPath == "/" {
+			u.Path = "/" + bucket
+		} else {
+			u.Path = "/" + bucket + signedPath
+		}
+		if u.RawPath != "" {
+			u.RawPath = "/" + bucket + u.RawPath
+		}
+		r.URL = &u
+	}
+
+	s.router.ServeHTTP(w, r)
+}
+
+// virtualHostBucket returns the bucket a Host header addresses below the
+// configured base domain, or "" for path-style requests
+func (s *Server) virtualHostBucket(host string) string {
+	domain := strings.Trim(strings.ToLower(s.config.Domain), ".")
+	if domain == "" {
+		return ""
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	bucket, found := strings.CutSuffix(host, "."+domain)
+	if !found {
+		return ""
+	}
+	return bucket
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualHostedStyleRequests(t *testing.T) {
+	server, _, cleanup := setupTestServerWithPublicBuckets(t)
+	defer cleanup()
+	server.config.Domain = "s3pit.localhost"
+
+	do := func(host, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Object", func(t *testing.T) {
+		w := do("public-bucket.s3pit.localhost:3333", "/test.txt")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "public content", w.Body.String())
+	})
+
+	t.Run("Bucket", func(t *testing.T) {
+		w := do("Public-Bucket.S3pit.Localhost", "/?list-type=2")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "<Name>public-bucket</Name>")
+		assert.Contains(t, w.Body.String(), "<Key>test.txt</Key>")
+	})
+
+	t.Run("Path-style on the base domain", func(t *testing.T) {
+		w := do("s3pit.localhost:3333", "/public-bucket/test.txt")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "public content", w.Body.String())
+	})
+
+	t.Run("Other hosts", func(t *testing.T) {
+		w := do("public-bucket.example.com", "/public-bucket/test.txt")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Disabled without a base domain", func(t *testing.T) {
+		server.config.Domain = ""
+		defer func() { server.config.Domain = "s3pit.localhost" }()
+
+		w := do("public-bucket.s3pit.localhost", "/test.txt")
+		assert.NotEqual(t, "public content", w.Body.String())
+	})
+}
@@ -0,0 +1,80 @@
+package storage
+
+// Grantee types of an ACL grant
+const (
+	GranteeCanonicalUser = "CanonicalUser"
+	GranteeGroup         = "Group"
+	GranteeEmail         = "AmazonCustomerByEmail"
+)
+
+// Predefined groups a grant can name
+const (
+	AllUsersGroup           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	AuthenticatedUsersGroup = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	LogDeliveryGroup        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+// Permissions an ACL grant can give
+const (
+	PermissionRead        = "READ"
+	PermissionWrite       = "WRITE"
+	PermissionReadACP     = "READ_ACP"
+	PermissionWriteACP    = "WRITE_ACP"
+	PermissionFullControl = "FULL_CONTROL"
+)
+
+// Grant gives a single permission on a bucket or object to a grantee. An
+// empty ACL stands for the default private ACL, which gives the owner full
+// control.
+type Grant struct {
+	GranteeType string
+	Grantee     string // Canonical user ID, group URI or email address
+	Permission  string
+}
+
+// CannedACL expands a canned ACL such as "public-read" into its grants. It
+// returns false for unknown canned ACLs.
+func CannedACL(name, ownerID string) ([]Grant, bool) {
+	grants := []Grant{{GranteeType: GranteeCanonicalUser, Grantee: ownerID, Permission: PermissionFullControl}}
+
+	switch name {
+	case "private", "bucket-owner-read", "bucket-owner-full-control", "aws-exec-read":
+		// Buckets and objects always have the same owner here
+	case "public-read":
+		grants = append(grants, groupGrant(AllUsersGroup, PermissionRead))
+	case "public-read-write":
+		grants = append(grants, groupGrant(AllUsersGroup, PermissionRead), groupGrant(AllUsersGroup, PermissionWrite))
+	case "authenticated-read":
+		grants = append(grants, groupGrant(AuthenticatedUsersGroup, PermissionRead))
+	case "log-delivery-write":
+		grants = append(grants, groupGrant(LogDeliveryGroup, PermissionWrite), groupGrant(LogDeliveryGroup, PermissionReadACP))
+	default:
+		return nil, false
+	}
+
+	return grants, true
+}
+
+func groupGrant(group, permission string) Grant {
+	return Grant{GranteeType: GranteeGroup, Grantee: group, Permission: permission}
+}
+
+// GrantsAllUsers reports whether an ACL gives everyone, including anonymous
+// callers, a permission
+func GrantsAllUsers(acl []Grant, permission string) bool {
+	for _, grant := range acl {
+		if grant.GranteeType == GranteeGroup && grant.Grantee == AllUsersGroup &&
+			(grant.Permission == permission || grant.Permission == PermissionFullControl) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneACL returns a copy of an ACL, or nil when it is empty
+func cloneACL(acl []Grant) []Grant {
+	if len(acl) == 0 {
+		return nil
+	}
+	return append([]Grant(nil), acl...)
+}
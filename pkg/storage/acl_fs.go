@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PutObjectACL replaces the ACL of an object version in its sidecar
+func (fs *FileSystemStorage) PutObjectACL(bucket, key, versionId string, acl []Grant) error {
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta, path, err := resolveVersion(filepath.Join(fs.baseDir, bucket, key), versionId)
+	if err != nil {
+		return err
+	}
+
+	meta.ACL = cloneACL(acl)
+	return writeMetadataFile(metadataPath(path), meta)
+}
+
+// PutBucketACL replaces the ACL of a bucket
+func (fs *FileSystemStorage) PutBucketACL(bucket string, acl []Grant) error {
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta := fs.loadBucketMeta(bucket)
+	if meta.Name == "" {
+		meta.Name = bucket
+		meta.Created = time.Now().UTC()
+	}
+	meta.ACL = newGrantFiles(acl)
+
+	return fs.saveBucketMeta(bucket, meta)
+}
+
+// GetBucketACL returns the ACL of a bucket
+func (fs *FileSystemStorage) GetBucketACL(bucket string) ([]Grant, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	return grantsFromFiles(fs.loadBucketMeta(bucket).ACL), nil
+}
@@ -3,8 +3,10 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
-	storageerrors "github.com/wozozo/s3pit/pkg/errors"
+	"net"
 	"strings"
+
+	storageerrors "github.com/wozozo/s3pit/pkg/errors"
 )
 
 // BucketPolicy represents an S3 bucket policy
@@ -15,107 +17,346 @@ type BucketPolicy struct {
 
 // PolicyStatement represents a single statement in a bucket policy
 type PolicyStatement struct {
-	Sid       string      `json:"Sid,omitempty"`
-	Effect    string      `json:"Effect"`
-	Principal interface{} `json:"Principal"`
-	Action    interface{} `json:"Action"`
-	Resource  interface{} `json:"Resource"`
+	Sid       string                            `json:"Sid,omitempty"`
+	Effect    string                            `json:"Effect"`
+	Principal interface{}                       `json:"Principal"`
+	Action    interface{}                       `json:"Action"`
+	Resource  interface{}                       `json:"Resource"`
+	Condition map[string]map[string]interface{} `json:"Condition,omitempty"`
+}
+
+// PolicyRequest describes a request a bucket policy is evaluated against
+type PolicyRequest struct {
+	Principal string            // Access key of the caller, "" for anonymous requests
+	Action    string            // e.g. "s3:GetObject"
+	Bucket    string            // Bucket the request addresses
+	Key       string            // Object key, "" for bucket-level actions
+	Context   map[string]string // Condition keys such as "aws:SourceIp" and "s3:prefix"
+}
+
+// PolicyDecision is the outcome of evaluating a bucket policy
+type PolicyDecision int
+
+const (
+	// PolicyNoMatch means no statement applies to the request
+	PolicyNoMatch PolicyDecision = iota
+	// PolicyAllow means an Allow statement applies and no Deny statement does
+	PolicyAllow
+	// PolicyDeny means a Deny statement applies
+	PolicyDeny
+)
+
+// conditionOperators are the supported condition operators, without the
+// IfExists suffix
+var conditionOperators = map[string]bool{
+	"StringEquals":              true,
+	"StringNotEquals":           true,
+	"StringEqualsIgnoreCase":    true,
+	"StringNotEqualsIgnoreCase": true,
+	"StringLike":                true,
+	"StringNotLike":             true,
+	"IpAddress":                 true,
+	"NotIpAddress":              true,
+	"Bool":                      true,
+}
+
+// Evaluate returns the decision of the policy for a request. An explicit
+// Deny takes precedence over any Allow.
+func (bp *BucketPolicy) Evaluate(req PolicyRequest) PolicyDecision {
+	if bp == nil {
+		return PolicyNoMatch
+	}
+
+	decision := PolicyNoMatch
+	for _, stmt := range bp.Statement {
+		if !stmt.matches(req) {
+			continue
+		}
+		if stmt.Effect == "Deny" {
+			return PolicyDeny
+		}
+		if stmt.Effect == "Allow" {
+			decision = PolicyAllow
+		}
+	}
+
+	return decision
 }
 
 // IsPublicReadable checks if the bucket policy allows public read access
 func (bp *BucketPolicy) IsPublicReadable(bucket, key string) bool {
+	return bp.Evaluate(PolicyRequest{
+		Action: "s3:GetObject",
+		Bucket: bucket,
+		Key:    key,
+	}) == PolicyAllow
+}
+
+// IsPublic reports whether the policy grants anything to everyone without a
+// condition restricting who can use it
+func (bp *BucketPolicy) IsPublic() bool {
 	if bp == nil {
 		return false
 	}
 
 	for _, stmt := range bp.Statement {
-		// Check if this statement allows public access
-		if stmt.Effect != "Allow" {
-			continue
+		if stmt.Effect == "Allow" && isPublicPrincipal(stmt.Principal) && len(stmt.Condition) == 0 {
+			return true
 		}
+	}
+	return false
+}
 
-		// Check if principal is public ("*" or {"AWS": "*"})
-		if !isPublicPrincipal(stmt.Principal) {
-			continue
+// Validate checks that the policy is well-formed and only addresses bucket
+func (bp *BucketPolicy) Validate(bucket string) error {
+	if len(bp.Statement) == 0 {
+		return fmt.Errorf("Missing required field Statement")
+	}
+
+	for _, stmt := range bp.Statement {
+		if stmt.Effect != "Allow" && stmt.Effect != "Deny" {
+			return fmt.Errorf("Invalid effect: %s", stmt.Effect)
+		}
+		if stmt.Principal == nil {
+			return fmt.Errorf("Missing required field Principal")
+		}
+		if !isPublicPrincipal(stmt.Principal) && len(principalValues(stmt.Principal)) == 0 {
+			return fmt.Errorf("Invalid principal in policy")
 		}
 
-		// Check if action allows GetObject
-		if !allowsGetObject(stmt.Action) {
-			continue
+		actions := policyValues(stmt.Action)
+		if len(actions) == 0 {
+			return fmt.Errorf("Missing required field Action")
+		}
+		for _, action := range actions {
+			if !strings.HasPrefix(strings.ToLower(action), "s3:") && action != "*" {
+				return fmt.Errorf("Policy has invalid action")
+			}
 		}
 
-		// Check if resource matches
-		if matchesResource(stmt.Resource, bucket, key) {
-			return true
+		resources := policyValues(stmt.Resource)
+		if len(resources) == 0 {
+			return fmt.Errorf("Missing required field Resource")
+		}
+		arn := "arn:aws:s3:::" + bucket
+		for _, resource := range resources {
+			if resource != arn && !strings.HasPrefix(resource, arn+"/") {
+				return fmt.Errorf("Action does not apply to any resource(s) in statement")
+			}
+		}
+
+		for operator, conditions := range stmt.Condition {
+			if !conditionOperators[strings.TrimSuffix(operator, "IfExists")] {
+				return fmt.Errorf("Invalid Condition type : %s", operator)
+			}
+			for key, values := range conditions {
+				if len(policyValues(values)) == 0 {
+					return fmt.Errorf("Invalid Condition value for key %s", key)
+				}
+			}
 		}
 	}
 
-	return false
+	return nil
 }
 
-func isPublicPrincipal(principal interface{}) bool {
-	switch p := principal.(type) {
-	case string:
-		return p == "*"
-	case map[string]interface{}:
-		if aws, ok := p["AWS"]; ok {
-			switch aws := aws.(type) {
-			case string:
-				return aws == "*"
-			case []interface{}:
-				for _, v := range aws {
-					if str, ok := v.(string); ok && str == "*" {
-						return true
-					}
-				}
+// matches reports whether the statement applies to a request
+func (stmt *PolicyStatement) matches(req PolicyRequest) bool {
+	if !matchesPrincipal(stmt.Principal, req.Principal) {
+		return false
+	}
+
+	actionMatched := false
+	for _, action := range policyValues(stmt.Action) {
+		if wildcardMatch(strings.ToLower(action), strings.ToLower(req.Action)) {
+			actionMatched = true
+			break
+		}
+	}
+	if !actionMatched {
+		return false
+	}
+
+	if !matchesResource(stmt.Resource, req.Bucket, req.Key) {
+		return false
+	}
+
+	for operator, conditions := range stmt.Condition {
+		for key, values := range conditions {
+			if !evaluateCondition(operator, req.Context, key, policyValues(values)) {
+				return false
 			}
 		}
 	}
-	return false
+
+	return true
 }
 
-func allowsGetObject(action interface{}) bool {
-	switch a := action.(type) {
+// policyValues returns a policy element that may be a single value or a list
+// of values as a list of strings
+func policyValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
 	case string:
-		return a == "s3:GetObject" || a == "s3:*"
+		return []string{v}
+	case []string:
+		return v
 	case []interface{}:
-		for _, v := range a {
-			if str, ok := v.(string); ok {
-				if str == "s3:GetObject" || str == "s3:*" {
-					return true
-				}
-			}
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// principalValues returns the AWS principals a statement names
+func principalValues(principal interface{}) []string {
+	if p, ok := principal.(map[string]interface{}); ok {
+		return policyValues(p["AWS"])
+	}
+	return nil
+}
+
+func isPublicPrincipal(principal interface{}) bool {
+	if p, ok := principal.(string); ok {
+		return p == "*"
+	}
+	for _, aws := range principalValues(principal) {
+		if aws == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPrincipal reports whether a statement principal covers the caller.
+// Anonymous callers are only covered by the public principal; an access key
+// also matches an ARN ending in it, such as "arn:aws:iam::123:user/<key>".
+func matchesPrincipal(principal interface{}, accessKey string) bool {
+	if isPublicPrincipal(principal) {
+		return true
+	}
+	if accessKey == "" {
+		return false
+	}
+	for _, aws := range principalValues(principal) {
+		if aws == accessKey || strings.HasSuffix(aws, "/"+accessKey) || strings.HasSuffix(aws, ":"+accessKey) {
+			return true
 		}
 	}
 	return false
 }
 
+// matchesResource reports whether a statement resource covers the object, or
+// the bucket itself when key is empty
 func matchesResource(resource interface{}, bucket, key string) bool {
-	resourceStr := fmt.Sprintf("arn:aws:s3:::%s/%s", bucket, key)
+	resourceStr := "arn:aws:s3:::" + bucket
+	if key != "" {
+		resourceStr += "/" + key
+	}
 
-	switch r := resource.(type) {
-	case string:
-		return matchesResourcePattern(r, resourceStr)
-	case []interface{}:
-		for _, v := range r {
-			if str, ok := v.(string); ok {
-				if matchesResourcePattern(str, resourceStr) {
-					return true
-				}
-			}
+	for _, pattern := range policyValues(resource) {
+		if wildcardMatch(pattern, resourceStr) {
+			return true
 		}
 	}
 	return false
 }
 
-func matchesResourcePattern(pattern, resource string) bool {
-	// Simple wildcard matching
-	// e.g., "arn:aws:s3:::my-bucket/*" matches any object in my-bucket
-	if strings.HasSuffix(pattern, "/*") {
-		prefix := strings.TrimSuffix(pattern, "/*")
-		return strings.HasPrefix(resource, prefix+"/")
+// wildcardMatch matches s against a pattern in which "*" matches any run of
+// characters, including "/", and "?" matches a single character
+func wildcardMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// evaluateCondition evaluates one condition key of a statement. A key absent
+// from the request context satisfies negated operators and IfExists
+// conditions only.
+func evaluateCondition(operator string, context map[string]string, key string, values []string) bool {
+	ifExists := strings.HasSuffix(operator, "IfExists")
+	operator = strings.TrimSuffix(operator, "IfExists")
+	negated := strings.Contains(operator, "Not")
+
+	actual, exists := lookupConditionKey(context, key)
+	if !exists {
+		return ifExists || negated
+	}
+
+	matched := false
+	for _, value := range values {
+		switch operator {
+		case "StringEquals", "StringNotEquals":
+			matched = actual == value
+		case "StringEqualsIgnoreCase", "StringNotEqualsIgnoreCase":
+			matched = strings.EqualFold(actual, value)
+		case "StringLike", "StringNotLike":
+			matched = wildcardMatch(value, actual)
+		case "IpAddress", "NotIpAddress":
+			matched = ipInRange(actual, value)
+		case "Bool":
+			matched = strings.EqualFold(actual, value)
+		default:
+			return false
+		}
+		if matched {
+			break
+		}
+	}
+
+	return matched != negated
+}
+
+// lookupConditionKey finds a condition key in the request context. Condition
+// keys are case-insensitive.
+func lookupConditionKey(context map[string]string, key string) (string, bool) {
+	if value, exists := context[key]; exists {
+		return value, true
+	}
+	for k, value := range context {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// ipInRange reports whether ip lies in a CIDR block, or equals a plain IP
+func ipInRange(ip, cidr string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	if !strings.Contains(cidr, "/") {
+		other := net.ParseIP(cidr)
+		return other != nil && other.Equal(addr)
 	}
-	return pattern == resource
+	_, network, err := net.ParseCIDR(cidr)
+	return err == nil && network.Contains(addr)
 }
 
 // DefaultPublicReadPolicy creates a default public read policy for a bucket
@@ -138,7 +379,7 @@ func DefaultPublicReadPolicy(bucket string) *BucketPolicy {
 func ParseBucketPolicy(data []byte) (*BucketPolicy, error) {
 	var policy BucketPolicy
 	if err := json.Unmarshal(data, &policy); err != nil {
-		return nil, storageerrors.WrapStorageError("parse bucket policy", err)
+		return nil, storageerrors.WrapStorageError("parse bucket policy", fmt.Errorf("%w: %v", storageerrors.ErrPolicyParseFailed, err))
 	}
 	return &policy, nil
 }
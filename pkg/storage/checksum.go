@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"strings"
+)
+
+// Additional checksum algorithms (x-amz-checksum-algorithm)
+const (
+	ChecksumCRC32     = "CRC32"
+	ChecksumCRC32C    = "CRC32C"
+	ChecksumCRC64NVME = "CRC64NVME"
+	ChecksumSHA1      = "SHA1"
+	ChecksumSHA256    = "SHA256"
+)
+
+// Checksum types. A composite checksum of a multipart object is the checksum
+// of its part checksums; a full object checksum covers the object data.
+const (
+	ChecksumTypeComposite  = "COMPOSITE"
+	ChecksumTypeFullObject = "FULL_OBJECT"
+)
+
+// ChecksumAlgorithms lists the supported algorithms
+var ChecksumAlgorithms = []string{ChecksumCRC32, ChecksumCRC32C, ChecksumCRC64NVME, ChecksumSHA1, ChecksumSHA256}
+
+var (
+	crc32cTable    = crc32.MakeTable(crc32.Castagnoli)
+	crc64NVMETable = crc64.MakeTable(0x9A6C9329AC4BC9B5)
+)
+
+// NewChecksumHash returns a hash computing the named checksum, or nil for an
+// unknown algorithm. Names are case-insensitive.
+func NewChecksumHash(algorithm string) hash.Hash {
+	switch strings.ToUpper(algorithm) {
+	case ChecksumCRC32:
+		return crc32.NewIEEE()
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable)
+	case ChecksumCRC64NVME:
+		return crc64.New(crc64NVMETable)
+	case ChecksumSHA1:
+		return sha1.New()
+	case ChecksumSHA256:
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// SupportsFullObjectChecksum reports whether a multipart upload may use a
+// full object checksum of the algorithm. Only the CRC checksums can be
+// combined across parts.
+func SupportsFullObjectChecksum(algorithm string) bool {
+	return algorithm == ChecksumCRC32 || algorithm == ChecksumCRC32C || algorithm == ChecksumCRC64NVME
+}
+
+// ChecksumReader is implemented by request bodies that compute an additional
+// checksum while they are read, such as VerifyingReader
+type ChecksumReader interface {
+	Checksum() (algorithm, value string)
+}
+
+// encodeChecksum returns the base64 encoding used for checksum values
+func encodeChecksum(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// checksumTee arranges for the additional checksum of a body to be computed
+// while it is streamed. An empty algorithm keeps whatever checksum the body
+// computes itself; otherwise the body's checksum is reused when it has the
+// same algorithm and a hash is teed in when it does not. The returned function
+// reports the checksum once the body has been read.
+func checksumTee(reader io.Reader, algorithm string) (io.Reader, func() (string, string)) {
+	checksummed, ok := reader.(ChecksumReader)
+	if algorithm == "" {
+		if !ok {
+			return reader, func() (string, string) { return "", "" }
+		}
+		return reader, checksummed.Checksum
+	}
+
+	if ok {
+		if computed, _ := checksummed.Checksum(); computed == algorithm {
+			return reader, checksummed.Checksum
+		}
+	}
+
+	h := NewChecksumHash(algorithm)
+	if h == nil {
+		return reader, func() (string, string) { return "", "" }
+	}
+	return io.TeeReader(reader, h), func() (string, string) {
+		return algorithm, encodeChecksum(h)
+	}
+}
+
+// compositeChecksum computes the checksum of the concatenated part checksums
+// of a multipart object, suffixed with the part count
+func compositeChecksum(algorithm string, partChecksums []string) (string, error) {
+	h := NewChecksumHash(algorithm)
+	if h == nil {
+		return "", fmt.Errorf("unknown checksum algorithm %q", algorithm)
+	}
+
+	for _, checksum := range partChecksums {
+		raw, err := base64.StdEncoding.DecodeString(checksum)
+		if err != nil || checksum == "" {
+			return "", ErrBadDigest
+		}
+		h.Write(raw)
+	}
+
+	return fmt.Sprintf("%s-%d", encodeChecksum(h), len(partChecksums)), nil
+}
+
+// multipartChecksum returns the hash used for the full object checksum of a
+// completed multipart upload, or nil when the upload uses a composite checksum
+// or none at all
+func multipartChecksum(upload *MultipartUpload) hash.Hash {
+	if upload.Metadata == nil || upload.Metadata.ChecksumType != ChecksumTypeFullObject {
+		return nil
+	}
+	return NewChecksumHash(upload.Metadata.ChecksumAlgorithm)
+}
+
+// applyMultipartChecksum records the checksum of a completed multipart object.
+// full is the hash of the combined data for full object checksums; composite
+// checksums are derived from the checksums stored with the parts.
+func applyMultipartChecksum(meta *ObjectMetadata, upload *MultipartUpload, parts []CompletedPart, full hash.Hash) error {
+	if meta.ChecksumAlgorithm == "" {
+		meta.Checksum = ""
+		meta.ChecksumType = ""
+		return nil
+	}
+
+	if full != nil {
+		meta.Checksum = encodeChecksum(full)
+		meta.ChecksumType = ChecksumTypeFullObject
+		return nil
+	}
+
+	partChecksums := make([]string, 0, len(parts))
+	for _, part := range parts {
+		partChecksums = append(partChecksums, upload.Parts[part.PartNumber].Checksum)
+	}
+	checksum, err := compositeChecksum(meta.ChecksumAlgorithm, partChecksums)
+	if err != nil {
+		return err
+	}
+	meta.Checksum = checksum
+	meta.ChecksumType = ChecksumTypeComposite
+	return nil
+}
+
+// checksumAlgorithm returns the checksum algorithm requested for a write, if any
+func (m *ObjectMetadata) checksumAlgorithm() string {
+	if m == nil {
+		return ""
+	}
+	return m.ChecksumAlgorithm
+}
+
+// checksumType returns the checksum type requested for a multipart upload, if any
+func (m *ObjectMetadata) checksumType() string {
+	if m == nil {
+		return ""
+	}
+	return m.ChecksumType
+}
+
+// setChecksum records the full object checksum computed for a single write
+func (m *ObjectMetadata) setChecksum(algorithm, value string) {
+	m.ChecksumAlgorithm = algorithm
+	m.Checksum = value
+	m.ChecksumType = ""
+	if algorithm != "" {
+		m.ChecksumType = ChecksumTypeFullObject
+	}
+}
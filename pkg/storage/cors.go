@@ -0,0 +1,80 @@
+package storage
+
+import "strings"
+
+// CORSRule is one rule of a bucket's CORS configuration. Origins and headers
+// may contain a "*" wildcard.
+type CORSRule struct {
+	ID             string
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposeHeaders  []string
+	MaxAgeSeconds  int // 0 when the rule does not set one
+}
+
+// MatchCORSRule returns the first rule that allows a cross-origin request
+// from origin using method and sending the given request headers, or nil when
+// no rule does. Like S3, rules are evaluated in order.
+func MatchCORSRule(rules []CORSRule, origin, method string, headers []string) *CORSRule {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.AllowsOrigin(origin) && rule.AllowsMethod(method) && rule.AllowsHeaders(headers) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// AllowsOrigin reports whether the rule allows requests from origin
+func (r *CORSRule) AllowsOrigin(origin string) bool {
+	for _, allowed := range r.AllowedOrigins {
+		if wildcardMatch(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMethod reports whether the rule allows the HTTP method
+func (r *CORSRule) AllowsMethod(method string) bool {
+	for _, allowed := range r.AllowedMethods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsHeaders reports whether the rule allows every request header. Header
+// names are case-insensitive.
+func (r *CORSRule) AllowsHeaders(headers []string) bool {
+	for _, header := range headers {
+		allowed := false
+		for _, pattern := range r.AllowedHeaders {
+			if wildcardMatch(strings.ToLower(pattern), strings.ToLower(header)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func cloneCORSRules(rules []CORSRule) []CORSRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	clone := make([]CORSRule, len(rules))
+	for i, rule := range rules {
+		clone[i] = rule
+		clone[i].AllowedOrigins = append([]string(nil), rule.AllowedOrigins...)
+		clone[i].AllowedMethods = append([]string(nil), rule.AllowedMethods...)
+		clone[i].AllowedHeaders = append([]string(nil), rule.AllowedHeaders...)
+		clone[i].ExposeHeaders = append([]string(nil), rule.ExposeHeaders...)
+	}
+	return clone
+}
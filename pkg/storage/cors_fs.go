@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PutBucketCORS replaces the CORS configuration of a bucket, or removes it
+// when rules is nil
+func (fs *FileSystemStorage) PutBucketCORS(bucket string, rules []CORSRule) error {
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta := fs.loadBucketMeta(bucket)
+	if meta.Name == "" {
+		meta.Name = bucket
+		meta.Created = time.Now().UTC()
+	}
+	meta.CORS = newCORSRuleFiles(rules)
+
+	return fs.saveBucketMeta(bucket, meta)
+}
+
+// GetBucketCORS returns the CORS configuration of a bucket
+func (fs *FileSystemStorage) GetBucketCORS(bucket string) ([]CORSRule, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	return corsRulesFromFiles(fs.loadBucketMeta(bucket).CORS), nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -42,19 +43,208 @@ func (fs *FileSystemStorage) getBucketLock(bucket string) *sync.RWMutex {
 	return lock.(*sync.RWMutex)
 }
 
+// objectMetaFile is the on-disk format of the .s3pit_meta.json sidecar
+// written next to every object
+type objectMetaFile struct {
+	ContentType        string            `json:"content-type"`
+	ETag               string            `json:"etag"`
+	Size               int64             `json:"size"`
+	Modified           time.Time         `json:"modified"`
+	CacheControl       string            `json:"cache-control,omitempty"`
+	ContentDisposition string            `json:"content-disposition,omitempty"`
+	ContentEncoding    string            `json:"content-encoding,omitempty"`
+	ContentLanguage    string            `json:"content-language,omitempty"`
+	Expires            string            `json:"expires,omitempty"`
+	UserMetadata       map[string]string `json:"user-metadata,omitempty"`
+	VersionId          string            `json:"version-id,omitempty"`
+	DeleteMarker       bool              `json:"delete-marker,omitempty"`
+	ChecksumAlgorithm  string            `json:"checksum-algorithm,omitempty"`
+	Checksum           string            `json:"checksum,omitempty"`
+	ChecksumType       string            `json:"checksum-type,omitempty"`
+	Parts              []objectPartFile  `json:"parts,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	ACL                []grantFile       `json:"acl,omitempty"`
+}
+
+// objectPartFile is the on-disk format of one part of a multipart object
+type objectPartFile struct {
+	PartNumber int    `json:"part-number"`
+	Size       int64  `json:"size"`
+	Checksum   string `json:"checksum,omitempty"`
+}
+
+// grantFile is the on-disk format of one ACL grant
+type grantFile struct {
+	Type       string `json:"type"`
+	Grantee    string `json:"grantee"`
+	Permission string `json:"permission"`
+}
+
+// newGrantFiles converts an ACL to its on-disk format
+func newGrantFiles(acl []Grant) []grantFile {
+	var files []grantFile
+	for _, grant := range acl {
+		files = append(files, grantFile{Type: grant.GranteeType, Grantee: grant.Grantee, Permission: grant.Permission})
+	}
+	return files
+}
+
+// grantsFromFiles converts a stored ACL back to grants
+func grantsFromFiles(files []grantFile) []Grant {
+	var acl []Grant
+	for _, file := range files {
+		acl = append(acl, Grant{GranteeType: file.Type, Grantee: file.Grantee, Permission: file.Permission})
+	}
+	return acl
+}
+
+// corsRuleFile is the on-disk format of one CORS rule
+type corsRuleFile struct {
+	ID             string   `json:"id,omitempty"`
+	AllowedOrigins []string `json:"allowed-origins"`
+	AllowedMethods []string `json:"allowed-methods"`
+	AllowedHeaders []string `json:"allowed-headers,omitempty"`
+	ExposeHeaders  []string `json:"expose-headers,omitempty"`
+	MaxAgeSeconds  int      `json:"max-age-seconds,omitempty"`
+}
+
+// newCORSRuleFiles converts a CORS configuration to its on-disk format
+func newCORSRuleFiles(rules []CORSRule) []corsRuleFile {
+	var files []corsRuleFile
+	for _, rule := range cloneCORSRules(rules) {
+		files = append(files, corsRuleFile(rule))
+	}
+	return files
+}
+
+// corsRulesFromFiles converts a stored CORS configuration back to rules
+func corsRulesFromFiles(files []corsRuleFile) []CORSRule {
+	var rules []CORSRule
+	for _, file := range files {
+		rules = append(rules, CORSRule(file))
+	}
+	return rules
+}
+
+// lifecycleRuleFile is the on-disk format of one lifecycle rule
+type lifecycleRuleFile struct {
+	ID                        string            `json:"id,omitempty"`
+	Enabled                   bool              `json:"enabled"`
+	Prefix                    string            `json:"prefix,omitempty"`
+	Tags                      map[string]string `json:"tags,omitempty"`
+	ObjectSizeGreaterThan     int64             `json:"object-size-greater-than,omitempty"`
+	ObjectSizeLessThan        int64             `json:"object-size-less-than,omitempty"`
+	ExpirationDays            int               `json:"expiration-days,omitempty"`
+	ExpirationDate            *time.Time        `json:"expiration-date,omitempty"`
+	ExpiredObjectDeleteMarker bool              `json:"expired-object-delete-marker,omitempty"`
+	NoncurrentDays            int               `json:"noncurrent-days,omitempty"`
+	NewerNoncurrentVersions   int               `json:"newer-noncurrent-versions,omitempty"`
+	AbortIncompleteUploadDays int               `json:"abort-incomplete-upload-days,omitempty"`
+}
+
+// newLifecycleRuleFiles converts a lifecycle configuration to its on-disk
+// format
+func newLifecycleRuleFiles(rules []LifecycleRule) []lifecycleRuleFile {
+	var files []lifecycleRuleFile
+	for _, rule := range cloneLifecycleRules(rules) {
+		file := lifecycleRuleFile{
+			ID:                        rule.ID,
+			Enabled:                   rule.Enabled,
+			Prefix:                    rule.Prefix,
+			Tags:                      rule.Tags,
+			ObjectSizeGreaterThan:     rule.ObjectSizeGreaterThan,
+			ObjectSizeLessThan:        rule.ObjectSizeLessThan,
+			ExpirationDays:            rule.ExpirationDays,
+			ExpiredObjectDeleteMarker: rule.ExpiredObjectDeleteMarker,
+			NoncurrentDays:            rule.NoncurrentVersionExpirationDays,
+			NewerNoncurrentVersions:   rule.NewerNoncurrentVersions,
+			AbortIncompleteUploadDays: rule.AbortIncompleteMultipartUploadDays,
+		}
+		if !rule.ExpirationDate.IsZero() {
+			date := rule.ExpirationDate
+			file.ExpirationDate = &date
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// lifecycleRulesFromFiles converts a stored lifecycle configuration back to
+// rules
+func lifecycleRulesFromFiles(files []lifecycleRuleFile) []LifecycleRule {
+	var rules []LifecycleRule
+	for _, file := range files {
+		rule := LifecycleRule{
+			ID:                                 file.ID,
+			Enabled:                            file.Enabled,
+			Prefix:                             file.Prefix,
+			Tags:                               file.Tags,
+			ObjectSizeGreaterThan:              file.ObjectSizeGreaterThan,
+			ObjectSizeLessThan:                 file.ObjectSizeLessThan,
+			ExpirationDays:                     file.ExpirationDays,
+			ExpiredObjectDeleteMarker:          file.ExpiredObjectDeleteMarker,
+			NoncurrentVersionExpirationDays:    file.NoncurrentDays,
+			NewerNoncurrentVersions:            file.NewerNoncurrentVersions,
+			AbortIncompleteMultipartUploadDays: file.AbortIncompleteUploadDays,
+		}
+		if file.ExpirationDate != nil {
+			rule.ExpirationDate = *file.ExpirationDate
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// bucketMetaFile is the on-disk format of .s3pit_bucket_meta.json
+type bucketMetaFile struct {
+	Created    time.Time           `json:"created"`
+	Name       string              `json:"name"`
+	Versioning string              `json:"versioning,omitempty"`
+	Tags       map[string]string   `json:"tags,omitempty"`
+	Policy     json.RawMessage     `json:"policy,omitempty"`
+	ACL        []grantFile         `json:"acl,omitempty"`
+	CORS       []corsRuleFile      `json:"cors,omitempty"`
+	Lifecycle  []lifecycleRuleFile `json:"lifecycle,omitempty"`
+}
+
+// bucketMetaPath returns the path of a bucket's metadata file
+func (fs *FileSystemStorage) bucketMetaPath(bucket string) string {
+	return filepath.Join(fs.baseDir, bucket, ".s3pit_bucket_meta.json")
+}
+
+// loadBucketMeta reads a bucket's metadata file. A missing or unreadable
+// file yields zero values.
+func (fs *FileSystemStorage) loadBucketMeta(bucket string) bucketMetaFile {
+	var meta bucketMetaFile
+	if data, err := os.ReadFile(fs.bucketMetaPath(bucket)); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// saveBucketMeta writes a bucket's metadata file
+func (fs *FileSystemStorage) saveBucketMeta(bucket string, meta bucketMetaFile) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fs.bucketMetaPath(bucket), data, 0644)
+}
+
+// metadataPath returns the sidecar path for an object path
+func metadataPath(objectPath string) string {
+	return objectPath + ".s3pit_meta.json"
+}
+
 // saveMetadata saves object metadata to a JSON file
 func (fs *FileSystemStorage) saveMetadata(bucket, key string, metadata *ObjectMetadata) error {
 	objectPath := filepath.Join(fs.baseDir, bucket, key)
-	metaPath := objectPath + ".s3pit_meta.json"
-
-	meta := map[string]interface{}{
-		"content-type": metadata.ContentType,
-		"etag":         StripETagQuotes(metadata.ETag),
-		"size":         metadata.Size,
-		"modified":     metadata.LastModified,
-	}
+	return writeMetadataFile(metadataPath(objectPath), metadata)
+}
 
-	metaData, err := json.Marshal(meta)
+// writeMetadataFile writes the sidecar for the given metadata
+func writeMetadataFile(metaPath string, metadata *ObjectMetadata) error {
+	metaData, err := json.Marshal(newObjectMetaFile(metadata))
 	if err != nil {
 		return err
 	}
@@ -62,6 +252,104 @@ func (fs *FileSystemStorage) saveMetadata(bucket, key string, metadata *ObjectMe
 	return os.WriteFile(metaPath, metaData, 0644)
 }
 
+// newObjectMetaFile converts object metadata to its on-disk format
+func newObjectMetaFile(metadata *ObjectMetadata) objectMetaFile {
+	meta := objectMetaFile{
+		ContentType:        metadata.ContentType,
+		ETag:               StripETagQuotes(metadata.ETag),
+		Size:               metadata.Size,
+		Modified:           metadata.LastModified,
+		CacheControl:       metadata.CacheControl,
+		ContentDisposition: metadata.ContentDisposition,
+		ContentEncoding:    metadata.ContentEncoding,
+		ContentLanguage:    metadata.ContentLanguage,
+		Expires:            metadata.Expires,
+		UserMetadata:       metadata.Metadata,
+		VersionId:          metadata.VersionId,
+		DeleteMarker:       metadata.DeleteMarker,
+		ChecksumAlgorithm:  metadata.ChecksumAlgorithm,
+		Checksum:           metadata.Checksum,
+		ChecksumType:       metadata.ChecksumType,
+		Tags:               metadata.Tags,
+		ACL:                newGrantFiles(metadata.ACL),
+	}
+	for _, part := range metadata.Parts {
+		meta.Parts = append(meta.Parts, objectPartFile{
+			PartNumber: part.PartNumber,
+			Size:       part.Size,
+			Checksum:   part.Checksum,
+		})
+	}
+	return meta
+}
+
+// loadMetadata builds object metadata from the file info and the sidecar.
+// Missing or unreadable sidecars fall back to defaults derived from the file.
+func loadMetadata(objectPath string, stat os.FileInfo) *ObjectMetadata {
+	meta := &ObjectMetadata{
+		Size:         stat.Size(),
+		LastModified: stat.ModTime(),
+		ContentType:  "application/octet-stream",
+	}
+
+	stored, err := readMetadataFile(metadataPath(objectPath))
+	if err != nil {
+		return meta
+	}
+	stored.applyTo(meta)
+
+	return meta
+}
+
+// readMetadataFile reads and decodes a sidecar file
+func readMetadataFile(metaPath string) (*objectMetaFile, error) {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored objectMetaFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// applyTo copies the stored sidecar fields onto object metadata
+func (stored *objectMetaFile) applyTo(meta *ObjectMetadata) {
+	if stored.ContentType != "" {
+		meta.ContentType = stored.ContentType
+	}
+	if stored.ETag != "" {
+		meta.ETag = FormatETag(stored.ETag)
+	}
+	meta.CacheControl = stored.CacheControl
+	meta.ContentDisposition = stored.ContentDisposition
+	meta.ContentEncoding = stored.ContentEncoding
+	meta.ContentLanguage = stored.ContentLanguage
+	meta.Expires = stored.Expires
+	meta.Metadata = stored.UserMetadata
+	meta.VersionId = stored.VersionId
+	meta.DeleteMarker = stored.DeleteMarker
+	meta.ChecksumAlgorithm = stored.ChecksumAlgorithm
+	meta.Checksum = stored.Checksum
+	meta.ChecksumType = stored.ChecksumType
+	meta.Parts = nil
+	for _, part := range stored.Parts {
+		meta.Parts = append(meta.Parts, ObjectPart{
+			PartNumber: part.PartNumber,
+			Size:       part.Size,
+			Checksum:   part.Checksum,
+		})
+	}
+	meta.Tags = stored.Tags
+	meta.ACL = grantsFromFiles(stored.ACL)
+	if !stored.Modified.IsZero() {
+		meta.LastModified = stored.Modified
+	}
+}
+
 func (fs *FileSystemStorage) CreateBucket(bucket string) (bool, error) {
 	lock := fs.getBucketLock(bucket)
 	lock.Lock()
@@ -77,18 +365,12 @@ func (fs *FileSystemStorage) CreateBucket(bucket string) (bool, error) {
 		return false, storageerrors.WrapFileSystemError(bucketPath, "create directory", err)
 	}
 
-	metaPath := filepath.Join(bucketPath, ".s3pit_bucket_meta.json")
-	meta := map[string]interface{}{
-		"created": time.Now().UTC(),
-		"name":    bucket,
+	meta := bucketMetaFile{
+		Created: time.Now().UTC(),
+		Name:    bucket,
 	}
 
-	data, err := json.Marshal(meta)
-	if err != nil {
-		return false, err
-	}
-
-	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+	if err := fs.saveBucketMeta(bucket, meta); err != nil {
 		return false, err
 	}
 
@@ -96,21 +378,25 @@ func (fs *FileSystemStorage) CreateBucket(bucket string) (bool, error) {
 }
 
 func (fs *FileSystemStorage) PutObject(bucket, key string, reader io.Reader, size int64, contentType string) (string, error) {
-	lock := fs.getBucketLock(bucket)
-	lock.Lock()
-	defer lock.Unlock()
+	return fs.PutObjectWithMetadata(bucket, key, reader, size, &ObjectMetadata{ContentType: contentType})
+}
 
-	objectPath := filepath.Join(fs.baseDir, bucket, key)
+// PutObjectWithMetadata stores an object together with its content type,
+// standard headers and user metadata
+func (fs *FileSystemStorage) PutObjectWithMetadata(bucket, key string, reader io.Reader, size int64, metadata *ObjectMetadata) (string, error) {
+	bucketPath := filepath.Join(fs.baseDir, bucket)
+	objectPath := filepath.Join(bucketPath, key)
 	objectDir := filepath.Dir(objectPath)
 
-	if err := os.MkdirAll(objectDir, 0755); err != nil {
-		return "", storageerrors.WrapFileSystemError(objectDir, "create directory", err)
-	}
-
-	// Use a temporary file for atomic writes
-	tempFile, err := os.CreateTemp(objectDir, ".upload_*")
+	// Stream the body into a temp file at the top of the bucket without
+	// holding the bucket lock, so a slow upload does not block readers. The
+	// .s3pit_ prefix keeps the temp file out of listings.
+	tempFile, err := os.CreateTemp(bucketPath, ".s3pit_upload_*")
 	if err != nil {
-		return "", storageerrors.WrapFileSystemError(objectDir, "create temp file", err)
+		if os.IsNotExist(err) {
+			return "", ErrBucketNotFound
+		}
+		return "", storageerrors.WrapFileSystemError(bucketPath, "create temp file", err)
 	}
 	tempPath := tempFile.Name()
 
@@ -122,50 +408,66 @@ func (fs *FileSystemStorage) PutObject(bucket, key string, reader io.Reader, siz
 		}
 	}()
 
-	// Read all data to calculate ETag
-	data, err := io.ReadAll(reader)
+	// Stream the body into the temp file, hashing it on the way
+	body, checksum := checksumTee(reader, metadata.checksumAlgorithm())
+	hash := md5.New()
+	written, err := io.Copy(tempFile, io.TeeReader(body, hash))
 	if err != nil {
-		return "", storageerrors.WrapStorageError("read object data", err)
+		return "", storageerrors.WrapStorageError("write object data", err)
 	}
+	etag := hashETag(hash)
 
-	// Calculate ETag using helper
-	etag := CalculateETag(data)
+	if err := tempFile.Close(); err != nil {
+		return "", err
+	}
+	tempFile = nil // Mark as closed
 
-	// Write data to temp file
-	if _, err := tempFile.Write(data); err != nil {
-		return "", storageerrors.WrapFileSystemError(tempPath, "write file", err)
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// The temp file is gone when the bucket was deleted during the upload
+	if _, err := os.Stat(tempPath); err != nil {
+		return "", ErrBucketNotFound
+	}
+	if err := os.MkdirAll(objectDir, 0755); err != nil {
+		os.Remove(tempPath)
+		return "", storageerrors.WrapFileSystemError(objectDir, "create directory", err)
 	}
 
-	if err := tempFile.Close(); err != nil {
+	status := fs.loadBucketMeta(bucket).Versioning
+	if err := archiveCurrentVersion(objectPath, status); err != nil {
+		os.Remove(tempPath)
 		return "", err
 	}
-	tempFile = nil // Mark as closed
 
 	// Atomic rename
 	if err := os.Rename(tempPath, objectPath); err != nil {
+		os.Remove(tempPath)
 		return "", storageerrors.WrapFileSystemError(objectPath, "move file", err)
 	}
 
 	// Save metadata
-	metaPath := objectPath + ".s3pit_meta.json"
-	meta := map[string]interface{}{
-		"content-type": contentType,
-		"etag":         StripETagQuotes(etag),
-		"size":         int64(len(data)),
-		"modified":     time.Now().UTC(),
-	}
-
-	metaData, err := json.Marshal(meta)
-	if err != nil {
-		return etag, nil
+	meta := metadata.Clone()
+	if meta == nil {
+		meta = &ObjectMetadata{}
+	}
+	meta.ETag = etag
+	meta.Size = written
+	meta.LastModified = time.Now().UTC()
+	meta.VersionId = nextVersionId(status)
+	meta.DeleteMarker = false
+	meta.Parts = nil
+	meta.setChecksum(checksum())
+
+	if err := writeMetadataFile(metadataPath(objectPath), meta); err != nil {
+		return "", err
 	}
 
-	_ = os.WriteFile(metaPath, metaData, 0644)
-
 	return etag, nil
 }
 
-func (fs *FileSystemStorage) GetObject(bucket, key string) (io.ReadCloser, *ObjectMetadata, error) {
+func (fs *FileSystemStorage) GetObject(bucket, key string) (io.ReadSeekCloser, *ObjectMetadata, error) {
 	lock := fs.getBucketLock(bucket)
 	lock.RLock()
 	defer lock.RUnlock()
@@ -187,29 +489,7 @@ func (fs *FileSystemStorage) GetObject(bucket, key string) (io.ReadCloser, *Obje
 		return nil, nil, err
 	}
 
-	meta := &ObjectMetadata{
-		Size:         stat.Size(),
-		LastModified: stat.ModTime(),
-		ContentType:  "application/octet-stream",
-	}
-
-	metaPath := objectPath + ".s3pit_meta.json"
-	if data, err := os.ReadFile(metaPath); err == nil {
-		var storedMeta map[string]interface{}
-		if json.Unmarshal(data, &storedMeta) == nil {
-			if ct, ok := storedMeta["content-type"].(string); ok {
-				meta.ContentType = ct
-			}
-			if etag, ok := storedMeta["etag"].(string); ok {
-				if !strings.HasPrefix(etag, "\"") {
-					etag = fmt.Sprintf("\"%s\"", etag)
-				}
-				meta.ETag = etag
-			}
-		}
-	}
-
-	return file, meta, nil
+	return file, loadMetadata(objectPath, stat), nil
 }
 
 func (fs *FileSystemStorage) GetObjectMetadata(bucket, key string) (*ObjectMetadata, error) {
@@ -227,29 +507,7 @@ func (fs *FileSystemStorage) GetObjectMetadata(bucket, key string) (*ObjectMetad
 		return nil, err
 	}
 
-	meta := &ObjectMetadata{
-		Size:         stat.Size(),
-		LastModified: stat.ModTime(),
-		ContentType:  "application/octet-stream",
-	}
-
-	metaPath := objectPath + ".s3pit_meta.json"
-	if data, err := os.ReadFile(metaPath); err == nil {
-		var storedMeta map[string]interface{}
-		if json.Unmarshal(data, &storedMeta) == nil {
-			if ct, ok := storedMeta["content-type"].(string); ok {
-				meta.ContentType = ct
-			}
-			if etag, ok := storedMeta["etag"].(string); ok {
-				if !strings.HasPrefix(etag, "\"") {
-					etag = fmt.Sprintf("\"%s\"", etag)
-				}
-				meta.ETag = etag
-			}
-		}
-	}
-
-	return meta, nil
+	return loadMetadata(objectPath, stat), nil
 }
 
 func (fs *FileSystemStorage) DeleteObject(bucket, key string) error {
@@ -259,6 +517,10 @@ func (fs *FileSystemStorage) DeleteObject(bucket, key string) error {
 
 	objectPath := filepath.Join(fs.baseDir, bucket, key)
 
+	if status := fs.loadBucketMeta(bucket).Versioning; status != "" {
+		return deleteVersioned(objectPath, status)
+	}
+
 	if err := os.Remove(objectPath); err != nil {
 		if os.IsNotExist(err) {
 			return ErrObjectNotFound
@@ -266,8 +528,7 @@ func (fs *FileSystemStorage) DeleteObject(bucket, key string) error {
 		return err
 	}
 
-	metaPath := objectPath + ".s3pit_meta.json"
-	os.Remove(metaPath)
+	os.Remove(metadataPath(objectPath))
 
 	return nil
 }
@@ -312,16 +573,8 @@ func (fs *FileSystemStorage) ListBuckets() ([]BucketInfo, error) {
 			}
 
 			creationTime := info.ModTime()
-			metaPath := filepath.Join(fs.baseDir, entry.Name(), ".s3pit_bucket_meta.json")
-			if data, err := os.ReadFile(metaPath); err == nil {
-				var meta map[string]interface{}
-				if json.Unmarshal(data, &meta) == nil {
-					if created, ok := meta["created"].(string); ok {
-						if t, err := time.Parse(time.RFC3339, created); err == nil {
-							creationTime = t
-						}
-					}
-				}
+			if meta := fs.loadBucketMeta(entry.Name()); !meta.Created.IsZero() {
+				creationTime = meta.Created
 			}
 
 			buckets = append(buckets, BucketInfo{
@@ -356,16 +609,20 @@ func (fs *FileSystemStorage) ListObjects(bucket, prefix, delimiter string, maxKe
 		return nil, nil, "", ErrBucketNotFound
 	}
 
-	var objects []ObjectInfo
-	commonPrefixes := make(map[string]bool)
-
-	startAfter := continuationToken
+	var keys []string
+	files := make(map[string]os.FileInfo)
+	paths := make(map[string]string)
 
 	err := filepath.Walk(bucketPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
 
+		if info.IsDir() && strings.Contains(info.Name(), ".s3pit_") {
+			// Version history is not part of the current listing
+			return filepath.SkipDir
+		}
+
 		if info.IsDir() || strings.Contains(info.Name(), ".s3pit_") {
 			return nil
 		}
@@ -381,47 +638,9 @@ func (fs *FileSystemStorage) ListObjects(bucket, prefix, delimiter string, maxKe
 			return nil
 		}
 
-		if startAfter != "" && key <= startAfter {
-			return nil
-		}
-
-		if delimiter != "" && prefix != "" {
-			afterPrefix := strings.TrimPrefix(key, prefix)
-			if idx := strings.Index(afterPrefix, delimiter); idx >= 0 {
-				commonPrefix := prefix + afterPrefix[:idx+len(delimiter)]
-				commonPrefixes[commonPrefix] = true
-				return nil
-			}
-		} else if delimiter != "" {
-			if idx := strings.Index(key, delimiter); idx >= 0 {
-				commonPrefix := key[:idx+len(delimiter)]
-				commonPrefixes[commonPrefix] = true
-				return nil
-			}
-		}
-
-		// Get metadata from disk
-		var etag string
-		metaPath := path + ".s3pit_meta.json"
-		if data, err := os.ReadFile(metaPath); err == nil {
-			var meta map[string]interface{}
-			if json.Unmarshal(data, &meta) == nil {
-				if e, ok := meta["etag"].(string); ok {
-					if !strings.HasPrefix(e, "\"") {
-						etag = fmt.Sprintf("\"%s\"", e)
-					} else {
-						etag = e
-					}
-				}
-			}
-		}
-
-		objects = append(objects, ObjectInfo{
-			Key:          key,
-			Size:         info.Size(),
-			LastModified: info.ModTime(),
-			ETag:         etag,
-		})
+		keys = append(keys, key)
+		files[key] = info
+		paths[key] = path
 
 		return nil
 	})
@@ -430,26 +649,36 @@ func (fs *FileSystemStorage) ListObjects(bucket, prefix, delimiter string, maxKe
 		return nil, nil, "", err
 	}
 
-	sort.Slice(objects, func(i, j int) bool {
-		return objects[i].Key < objects[j].Key
-	})
-
-	var nextToken string
-	if len(objects) > maxKeys {
-		nextToken = objects[maxKeys-1].Key
-		objects = objects[:maxKeys]
-	}
+	sort.Strings(keys)
+	page := paginateKeys(keys, prefix, delimiter, maxKeys, continuationToken)
 
-	var prefixes []string
-	for p := range commonPrefixes {
-		prefixes = append(prefixes, p)
+	objects := make([]ObjectInfo, 0, len(page.keys))
+	for _, key := range page.keys {
+		// Get metadata from disk
+		meta := loadMetadata(paths[key], files[key])
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         meta.Size,
+			LastModified: meta.LastModified,
+			ETag:         meta.ETag,
+		})
 	}
-	sort.Strings(prefixes)
 
-	return objects, prefixes, nextToken, nil
+	return objects, page.prefixes, page.nextMarker, nil
 }
 
 func (fs *FileSystemStorage) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, error) {
+	meta, err := fs.CopyObjectVersion(srcBucket, srcKey, "", dstBucket, dstKey, nil)
+	if err != nil {
+		return "", err
+	}
+	return meta.ETag, nil
+}
+
+// CopyObjectVersion copies a version of an object, replacing its metadata
+// when metadata is not nil. The data is linked or cloned on disk rather than
+// read through the process.
+func (fs *FileSystemStorage) CopyObjectVersion(srcBucket, srcKey, srcVersionId, dstBucket, dstKey string, metadata *ObjectMetadata) (*ObjectMetadata, error) {
 	// Use per-bucket locks to avoid deadlock
 	srcLock := fs.getBucketLock(srcBucket)
 	dstLock := fs.getBucketLock(dstBucket)
@@ -470,64 +699,125 @@ func (fs *FileSystemStorage) CopyObject(srcBucket, srcKey, dstBucket, dstKey str
 		defer srcLock.Unlock()
 	}
 
-	srcPath := filepath.Join(fs.baseDir, srcBucket, srcKey)
-	dstPath := filepath.Join(fs.baseDir, dstBucket, dstKey)
-
-	srcFile, err := os.Open(srcPath)
+	srcMeta, srcPath, err := resolveVersion(filepath.Join(fs.baseDir, srcBucket, srcKey), srcVersionId)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return "", ErrObjectNotFound
-		}
-		return "", err
+		return nil, err
 	}
-	defer srcFile.Close()
 
+	if _, err := os.Stat(filepath.Join(fs.baseDir, dstBucket)); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	dstPath := filepath.Join(fs.baseDir, dstBucket, dstKey)
 	dstDir := filepath.Dir(dstPath)
 	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	dstFile, err := os.Create(dstPath)
+	// Clone the source before the destination is touched, since archiving
+	// the destination may move the source when they are the same. Like
+	// uploads, the clone is made in the bucket directory, so a clone left
+	// behind never keeps a key's directory from being removed.
+	tempPath, err := cloneFile(srcPath, filepath.Join(fs.baseDir, dstBucket))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer dstFile.Close()
+	defer os.Remove(tempPath)
 
-	// Read source file data
-	srcData, err := io.ReadAll(srcFile)
+	meta := copiedMetadata(srcMeta, metadata)
+	if meta.ETag == "" {
+		if meta.ETag, err = fileETag(tempPath); err != nil {
+			return nil, err
+		}
+	}
+
+	status := fs.loadBucketMeta(dstBucket).Versioning
+	if err := archiveCurrentVersion(dstPath, status); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tempPath, dstPath); err != nil {
+		return nil, storageerrors.WrapFileSystemError(dstPath, "move file", err)
+	}
+
+	meta.VersionId = nextVersionId(status)
+	if err := writeMetadataFile(metadataPath(dstPath), meta); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// cloneFile creates a copy of srcPath in dir and returns its path. The copy
+// is a hard link when possible, which is safe because stored objects are
+// always replaced by renaming a new file over them, never rewritten in place.
+// Otherwise the data is copied with copy_file_range, which reflinks on
+// filesystems that support it. The .s3pit_ prefix keeps the copy out of
+// listings should it be left behind.
+func cloneFile(srcPath, dir string) (string, error) {
+	tempFile, err := os.CreateTemp(dir, ".s3pit_copy_*")
 	if err != nil {
-		return "", err
+		return "", storageerrors.WrapFileSystemError(dir, "create temp file", err)
 	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
 
-	// Write to destination
-	if _, err := dstFile.Write(srcData); err != nil {
+	// Take the temp file's unique name for the link
+	if err := os.Remove(tempPath); err != nil {
 		return "", err
 	}
+	if err := os.Link(srcPath, tempPath); err == nil {
+		return tempPath, nil
+	}
 
-	// Calculate ETag using helper
-	etag := CalculateETag(srcData)
+	src, err := os.Open(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrObjectNotFound
+		}
+		return "", err
+	}
+	defer src.Close()
 
-	// Copy and update metadata
-	srcMetaPath := srcPath + ".s3pit_meta.json"
-	dstMetaPath := dstPath + ".s3pit_meta.json"
+	dst, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", storageerrors.WrapFileSystemError(tempPath, "create temp file", err)
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return "", storageerrors.WrapFileSystemError(tempPath, "write file", err)
+	}
 
-	if srcMetaData, err := os.ReadFile(srcMetaPath); err == nil {
-		var meta map[string]interface{}
-		if json.Unmarshal(srcMetaData, &meta) == nil {
-			meta["etag"] = StripETagQuotes(etag)
-			meta["modified"] = time.Now().UTC()
+	return tempPath, nil
+}
 
-			if data, err := json.Marshal(meta); err == nil {
-				_ = os.WriteFile(dstMetaPath, data, 0644)
-			}
-		}
+// fileETag computes the ETag of a file's contents
+func fileETag(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
 	}
+	defer file.Close()
 
-	return etag, nil
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hashETag(hash), nil
 }
 
 // InitiateMultipartUpload starts a new multipart upload
 func (fs *FileSystemStorage) InitiateMultipartUpload(bucket, key string) (string, error) {
+	return fs.InitiateMultipartUploadWithMetadata(bucket, key, nil)
+}
+
+// InitiateMultipartUploadWithMetadata starts a new multipart upload whose
+// completed object will carry the given metadata
+func (fs *FileSystemStorage) InitiateMultipartUploadWithMetadata(bucket, key string, metadata *ObjectMetadata) (string, error) {
 	lock := fs.getBucketLock(bucket)
 	lock.RLock()
 	bucketPath := filepath.Join(fs.baseDir, bucket)
@@ -537,7 +827,7 @@ func (fs *FileSystemStorage) InitiateMultipartUpload(bucket, key string) (string
 	}
 	lock.RUnlock()
 
-	return fs.multipartMgr.InitiateUpload(bucket, key)
+	return fs.multipartMgr.InitiateUpload(bucket, key, metadata)
 }
 
 // UploadPart uploads a part for a multipart upload
@@ -554,8 +844,9 @@ func (fs *FileSystemStorage) UploadPart(bucket, key, uploadId string, partNumber
 	return fs.multipartMgr.StorePart(uploadId, partNumber, reader, size)
 }
 
-// CompleteMultipartUpload completes a multipart upload
-func (fs *FileSystemStorage) CompleteMultipartUpload(bucket, key, uploadId string, parts []CompletedPart) (string, error) {
+// UploadPartCopy stores a byte range of an existing object as a part of a
+// multipart upload
+func (fs *FileSystemStorage) UploadPartCopy(bucket, key, uploadId string, partNumber int, source CopySource) (string, error) {
 	upload, exists := fs.multipartMgr.GetUpload(uploadId)
 	if !exists {
 		return "", storageerrors.WrapMultipartError(uploadId, storageerrors.ErrUploadNotFound)
@@ -565,12 +856,51 @@ func (fs *FileSystemStorage) CompleteMultipartUpload(bucket, key, uploadId strin
 		return "", storageerrors.ErrUploadMismatch
 	}
 
+	reader, size, err := openCopySource(fs, source)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	return fs.multipartMgr.StorePart(uploadId, partNumber, reader, size)
+}
+
+// CompleteMultipartUpload completes a multipart upload
+func (fs *FileSystemStorage) CompleteMultipartUpload(bucket, key, uploadId string, parts []CompletedPart) (string, error) {
+	upload, partFiles, err := fs.multipartMgr.Snapshot(uploadId, parts)
+	if err != nil {
+		return "", err
+	}
+	defer closeParts(partFiles)
+
+	if upload.Bucket != bucket || upload.Key != key {
+		return "", storageerrors.ErrUploadMismatch
+	}
+
+	if err := validateCompletedParts(upload, parts); err != nil {
+		return "", err
+	}
+
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Combine all parts
 	objectPath := filepath.Join(fs.baseDir, bucket, key)
 	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
 		return "", err
 	}
 
+	status := fs.loadBucketMeta(bucket).Versioning
+	if err := archiveCurrentVersion(objectPath, status); err != nil {
+		return "", err
+	}
+
+	// Replace rather than truncate the current file, which copies of the
+	// object may share through a hard link
+	if err := os.Remove(objectPath); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
 	outFile, err := os.Create(objectPath)
 	if err != nil {
 		return "", err
@@ -578,39 +908,36 @@ func (fs *FileSystemStorage) CompleteMultipartUpload(bucket, key, uploadId strin
 	defer outFile.Close()
 
 	// Copy each part to the final file
+	var out io.Writer = outFile
+	fullChecksum := multipartChecksum(upload)
+	if fullChecksum != nil {
+		out = io.MultiWriter(out, fullChecksum)
+	}
+	var size int64
 	for _, part := range parts {
-		partPath := fs.multipartMgr.GetPartPath(uploadId, part.PartNumber)
-		partFile, err := os.Open(partPath)
+		n, err := io.Copy(out, partFiles[part.PartNumber])
 		if err != nil {
-			return "", storageerrors.WrapMultipartError(fmt.Sprintf("part %d", part.PartNumber), err)
-		}
-
-		if _, err := io.Copy(outFile, partFile); err != nil {
-			partFile.Close()
 			return "", storageerrors.WrapMultipartError(fmt.Sprintf("part %d copy", part.PartNumber), err)
 		}
-		partFile.Close()
+		size += n
 	}
-
-	// Calculate final ETag
-	finalFile, err := os.Open(objectPath)
-	if err != nil {
-		return "", err
-	}
-	defer finalFile.Close()
-
-	data, err := io.ReadAll(finalFile)
-	if err != nil {
-		return "", err
-	}
-	etag := CalculateETag(data)
+	etag := multipartETag(upload, parts)
 
 	// Save metadata
-	metadata := &ObjectMetadata{
-		ContentType:  "application/octet-stream",
-		Size:         int64(len(data)),
-		LastModified: time.Now().UTC(),
-		ETag:         etag,
+	metadata := upload.Metadata.Clone()
+	if metadata == nil {
+		metadata = &ObjectMetadata{}
+	}
+	if metadata.ContentType == "" {
+		metadata.ContentType = "application/octet-stream"
+	}
+	metadata.Size = size
+	metadata.LastModified = time.Now().UTC()
+	metadata.ETag = etag
+	metadata.VersionId = nextVersionId(status)
+	metadata.Parts = objectParts(upload, parts)
+	if err := applyMultipartChecksum(metadata, upload, parts, fullChecksum); err != nil {
+		return "", err
 	}
 
 	if err := fs.saveMetadata(bucket, key, metadata); err != nil {
@@ -650,3 +977,32 @@ func (fs *FileSystemStorage) ListParts(bucket, key, uploadId string) ([]PartInfo
 
 	return fs.multipartMgr.ListParts(uploadId)
 }
+
+// ListMultipartUploads lists the in-progress uploads of a bucket whose key
+// starts with prefix
+func (fs *FileSystemStorage) ListMultipartUploads(bucket, prefix string) ([]MultipartUploadInfo, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	return uploadInfos(fs.multipartMgr.ListUploads(bucket), prefix), nil
+}
+
+// AbortStaleMultipartUploads aborts the uploads initiated before the cutoff
+// and removes part directories no upload owns any more
+func (fs *FileSystemStorage) AbortStaleMultipartUploads(cutoff time.Time) (int, error) {
+	aborted := 0
+	for _, upload := range fs.multipartMgr.StaleUploads(cutoff) {
+		if err := fs.multipartMgr.DeleteUpload(upload.UploadId); err != nil {
+			return aborted, err
+		}
+		aborted++
+	}
+
+	orphaned, err := fs.multipartMgr.RemoveOrphanedUploads(cutoff)
+	return aborted + orphaned, err
+}
@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// LifecycleRule is one rule of a bucket's lifecycle configuration. The filter
+// fields narrow the objects the rule applies to; the action fields are zero
+// when the rule does not take that action.
+type LifecycleRule struct {
+	ID      string
+	Enabled bool
+
+	// Filter
+	Prefix                string
+	Tags                  map[string]string // All tags must be present on the object
+	ObjectSizeGreaterThan int64
+	ObjectSizeLessThan    int64 // 0 when unset
+
+	// Actions
+	ExpirationDays                     int       // Expire current versions this many days after creation
+	ExpirationDate                     time.Time // Expire current versions from this date on
+	ExpiredObjectDeleteMarker          bool      // Remove delete markers no other version is left behind
+	NoncurrentVersionExpirationDays    int       // Remove versions this many days after they became noncurrent
+	NewerNoncurrentVersions            int       // Noncurrent versions kept regardless of their age
+	AbortIncompleteMultipartUploadDays int       // Abort uploads this many days after they were initiated
+}
+
+// LifecycleScheduler periodically applies the lifecycle rules of every
+// bucket. Its clock decides which objects are due, so moving a VirtualClock
+// forward makes objects expire without waiting.
+type LifecycleScheduler struct {
+	storage  Storage
+	clock    Clock
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewLifecycleScheduler creates a scheduler that applies the lifecycle rules
+// every interval
+func NewLifecycleScheduler(storage Storage, clock Clock, interval time.Duration) *LifecycleScheduler {
+	return &LifecycleScheduler{
+		storage:  storage,
+		clock:    clock,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler in the background until Stop is called
+func (s *LifecycleScheduler) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.Run()
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background scheduler and waits for it to finish
+func (s *LifecycleScheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// Run applies the lifecycle rules once and returns how many objects,
+// versions and uploads were removed
+func (s *LifecycleScheduler) Run() int {
+	removed, err := s.storage.ApplyLifecycle(s.clock.Now())
+	if err != nil {
+		log.Printf("Failed to apply lifecycle rules: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("Lifecycle rules removed %d object(s), version(s) and upload(s)", removed)
+	}
+	return removed
+}
+
+// lifecycleDue returns when an action taken days after t becomes due. Like
+// S3, the time is rounded up to the next midnight UTC.
+func lifecycleDue(t time.Time, days int) time.Time {
+	due := t.UTC().AddDate(0, 0, days)
+	if midnight := due.Truncate(24 * time.Hour); !midnight.Equal(due) {
+		return midnight.Add(24 * time.Hour)
+	}
+	return due
+}
+
+// matchesObject reports whether the rule's filter covers an object. tags is
+// only called when the filter names tags.
+func (r *LifecycleRule) matchesObject(key string, size int64, tags func() map[string]string) bool {
+	if !strings.HasPrefix(key, r.Prefix) {
+		return false
+	}
+	if r.ObjectSizeGreaterThan > 0 && size <= r.ObjectSizeGreaterThan {
+		return false
+	}
+	if r.ObjectSizeLessThan > 0 && size >= r.ObjectSizeLessThan {
+		return false
+	}
+	if len(r.Tags) > 0 {
+		objectTags := tags()
+		for k, v := range r.Tags {
+			if value, exists := objectTags[k]; !exists || value != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// expiresCurrent reports whether the rule expires a current version last
+// modified at modified
+func (r *LifecycleRule) expiresCurrent(modified, now time.Time) bool {
+	if r.ExpirationDays > 0 && !now.Before(lifecycleDue(modified, r.ExpirationDays)) {
+		return true
+	}
+	return !r.ExpirationDate.IsZero() && !now.Before(r.ExpirationDate)
+}
+
+// applyLifecycle applies the lifecycle rules of every bucket of a storage as
+// of now. It backs the ApplyLifecycle method of the storage backends.
+func applyLifecycle(store Storage, now time.Time) (int, error) {
+	buckets, err := store.ListBuckets()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, bucket := range buckets {
+		rules, err := store.GetBucketLifecycle(bucket.Name)
+		if err != nil {
+			return removed, err
+		}
+
+		var enabled []LifecycleRule
+		for _, rule := range rules {
+			if rule.Enabled {
+				enabled = append(enabled, rule)
+			}
+		}
+		if len(enabled) == 0 {
+			continue
+		}
+
+		n, err := applyBucketLifecycle(store, bucket.Name, enabled, now)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// applyBucketLifecycle applies enabled rules to the objects and uploads of a
+// bucket
+func applyBucketLifecycle(store Storage, bucket string, rules []LifecycleRule, now time.Time) (int, error) {
+	versions, err := store.ListObjectVersions(bucket, "")
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	// Listings group the versions of a key, newest first
+	for start := 0; start < len(versions); {
+		end := start + 1
+		for end < len(versions) && versions[end].Key == versions[start].Key {
+			end++
+		}
+		n, err := applyKeyLifecycle(store, bucket, rules, versions[start:end], now)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+		start = end
+	}
+
+	uploads, err := store.ListMultipartUploads(bucket, "")
+	if err != nil {
+		return removed, err
+	}
+	for _, upload := range uploads {
+		for _, rule := range rules {
+			if rule.AbortIncompleteMultipartUploadDays == 0 || !strings.HasPrefix(upload.Key, rule.Prefix) {
+				continue
+			}
+			if now.Before(lifecycleDue(upload.Initiated, rule.AbortIncompleteMultipartUploadDays)) {
+				continue
+			}
+			if err := store.AbortMultipartUpload(bucket, upload.Key, upload.UploadId); err != nil {
+				return removed, err
+			}
+			removed++
+			break
+		}
+	}
+
+	return removed, nil
+}
+
+// applyKeyLifecycle applies the rules to the versions of a single key,
+// newest first
+func applyKeyLifecycle(store Storage, bucket string, rules []LifecycleRule, versions []ObjectVersion, now time.Time) (int, error) {
+	tagsOf := func(version ObjectVersion) func() map[string]string {
+		return func() map[string]string {
+			meta, err := store.GetObjectVersionMetadata(bucket, version.Key, version.VersionId)
+			if err != nil || meta == nil {
+				return nil
+			}
+			return meta.Tags
+		}
+	}
+
+	removed := 0
+	current := versions[0]
+	for _, rule := range rules {
+		if current.IsDeleteMarker {
+			// A delete marker with no versions behind it is expired
+			// outright; filters on tags or size never match markers
+			if rule.ExpiredObjectDeleteMarker && len(versions) == 1 && len(rule.Tags) == 0 &&
+				rule.ObjectSizeGreaterThan == 0 && rule.ObjectSizeLessThan == 0 &&
+				strings.HasPrefix(current.Key, rule.Prefix) {
+				if err := store.DeleteObjectVersion(bucket, current.Key, current.VersionId); err != nil {
+					return removed, err
+				}
+				return removed + 1, nil
+			}
+			continue
+		}
+
+		if rule.expiresCurrent(current.LastModified, now) && rule.matchesObject(current.Key, current.Size, tagsOf(current)) {
+			if err := store.DeleteObject(bucket, current.Key); err != nil && err != ErrObjectNotFound {
+				return removed, err
+			}
+			removed++
+			break
+		}
+	}
+
+	// A version becomes noncurrent when the next newer version is created
+	expired := make(map[int]bool)
+	for _, rule := range rules {
+		if rule.NoncurrentVersionExpirationDays == 0 {
+			continue
+		}
+		for i := 1; i < len(versions); i++ {
+			if expired[i] || i-1 < rule.NewerNoncurrentVersions {
+				continue
+			}
+			version := versions[i]
+			if now.Before(lifecycleDue(versions[i-1].LastModified, rule.NoncurrentVersionExpirationDays)) {
+				continue
+			}
+			if !rule.matchesObject(version.Key, version.Size, tagsOf(version)) {
+				continue
+			}
+			if err := store.DeleteObjectVersion(bucket, version.Key, version.VersionId); err != nil && err != ErrVersionNotFound {
+				return removed, err
+			}
+			expired[i] = true
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func cloneLifecycleRules(rules []LifecycleRule) []LifecycleRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	clone := make([]LifecycleRule, len(rules))
+	for i, rule := range rules {
+		clone[i] = rule
+		clone[i].Tags = cloneTags(rule.Tags)
+	}
+	return clone
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PutBucketLifecycle replaces the lifecycle configuration of a bucket, or
+// removes it when rules is nil
+func (fs *FileSystemStorage) PutBucketLifecycle(bucket string, rules []LifecycleRule) error {
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta := fs.loadBucketMeta(bucket)
+	if meta.Name == "" {
+		meta.Name = bucket
+		meta.Created = time.Now().UTC()
+	}
+	meta.Lifecycle = newLifecycleRuleFiles(rules)
+
+	return fs.saveBucketMeta(bucket, meta)
+}
+
+// GetBucketLifecycle returns the lifecycle configuration of a bucket
+func (fs *FileSystemStorage) GetBucketLifecycle(bucket string) ([]LifecycleRule, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	return lifecycleRulesFromFiles(fs.loadBucketMeta(bucket).Lifecycle), nil
+}
+
+// ApplyLifecycle applies the lifecycle rules of every bucket as of now
+func (fs *FileSystemStorage) ApplyLifecycle(now time.Time) (int, error) {
+	return applyLifecycle(fs, now)
+}
@@ -0,0 +1,56 @@
+package storage
+
+import "strings"
+
+// listPage is one page of a bucket listing
+type listPage struct {
+	keys       []string // Object keys to return, in order
+	prefixes   []string // Common prefixes to return, in order
+	nextMarker string   // Last key or prefix returned when the page is truncated
+}
+
+// paginateKeys groups sorted, prefix-filtered keys by delimiter and cuts the
+// result after maxKeys entries. As in S3, objects and common prefixes both
+// count toward maxKeys. Keys up to and including startAfter are skipped; a
+// startAfter naming a common prefix skips every key below that prefix, so the
+// nextMarker of one page can be passed as startAfter of the next.
+func paginateKeys(keys []string, prefix, delimiter string, maxKeys int, startAfter string) listPage {
+	var page listPage
+	seenPrefixes := make(map[string]bool)
+	count := 0
+	last := ""
+
+	for _, key := range keys {
+		if startAfter != "" && key <= startAfter {
+			continue
+		}
+
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := key[:len(prefix)+idx+len(delimiter)]
+				if commonPrefix == startAfter || seenPrefixes[commonPrefix] {
+					continue
+				}
+				if count >= maxKeys {
+					page.nextMarker = last
+					return page
+				}
+				seenPrefixes[commonPrefix] = true
+				page.prefixes = append(page.prefixes, commonPrefix)
+				last = commonPrefix
+				count++
+				continue
+			}
+		}
+
+		if count >= maxKeys {
+			page.nextMarker = last
+			return page
+		}
+		page.keys = append(page.keys, key)
+		last = key
+		count++
+	}
+
+	return page
+}
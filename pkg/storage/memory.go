@@ -12,15 +12,107 @@ import (
 )
 
 type memoryObject struct {
-	data         []byte
-	contentType  string
-	lastModified time.Time
-	etag         string
+	data     []byte
+	metadata ObjectMetadata
 }
 
+// objectMetadata returns a copy of the stored metadata that callers may modify
+func (o *memoryObject) objectMetadata() *ObjectMetadata {
+	return o.metadata.Clone()
+}
+
+// memoryReader adapts a bytes.Reader to io.ReadSeekCloser so in-memory
+// objects can be served with byte ranges like files on disk
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error { return nil }
+
 type memoryBucket struct {
 	creationDate time.Time
 	objects      map[string]*memoryObject
+	versioning   string
+	versions     map[string][]*memoryObject // Noncurrent versions and delete markers, newest first
+	tags         map[string]string
+	policy       []byte
+	acl          []Grant
+	cors         []CORSRule
+	lifecycle    []LifecycleRule
+}
+
+// storeObject makes obj the current version of key, archiving the previous
+// current version according to the bucket versioning state. The caller must
+// hold the storage lock.
+func (b *memoryBucket) storeObject(key string, obj *memoryObject) {
+	b.archiveCurrent(key)
+	obj.metadata.VersionId = nextVersionId(b.versioning)
+	b.objects[key] = obj
+}
+
+// archiveCurrent moves the current version of key into its version history.
+// With versioning suspended the null version is replaced rather than kept.
+func (b *memoryBucket) archiveCurrent(key string) {
+	if b.versioning == "" {
+		return
+	}
+	if b.versioning == VersioningSuspended {
+		b.removeHistoryVersion(key, NullVersionId)
+	}
+
+	current, exists := b.objects[key]
+	if !exists {
+		return
+	}
+	delete(b.objects, key)
+
+	versionId := versionIdOrNull(current.metadata.VersionId)
+	if b.versioning == VersioningSuspended && versionId == NullVersionId {
+		return
+	}
+	current.metadata.VersionId = versionId
+	b.versions[key] = append([]*memoryObject{current}, b.versions[key]...)
+}
+
+// removeHistoryVersion drops a version from the history of key
+func (b *memoryBucket) removeHistoryVersion(key, versionId string) bool {
+	history := b.versions[key]
+	for i, obj := range history {
+		if versionIdOrNull(obj.metadata.VersionId) == versionId {
+			history = append(history[:i:i], history[i+1:]...)
+			if len(history) == 0 {
+				delete(b.versions, key)
+			} else {
+				b.versions[key] = history
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// findVersion resolves a version of key. An empty versionId returns the
+// current object, or the delete marker that hides it.
+func (b *memoryBucket) findVersion(key, versionId string) (*memoryObject, bool) {
+	current, hasCurrent := b.objects[key]
+	if hasCurrent && (versionId == "" || versionId == versionIdOrNull(current.metadata.VersionId)) {
+		return current, true
+	}
+
+	history := b.versions[key]
+	if versionId == "" {
+		if len(history) > 0 && history[0].metadata.DeleteMarker {
+			return history[0], true
+		}
+		return nil, false
+	}
+
+	for _, obj := range history {
+		if versionIdOrNull(obj.metadata.VersionId) == versionId {
+			return obj, true
+		}
+	}
+	return nil, false
 }
 
 type MemoryStorage struct {
@@ -47,6 +139,7 @@ func (m *MemoryStorage) CreateBucket(bucket string) (bool, error) {
 	m.buckets[bucket] = &memoryBucket{
 		creationDate: time.Now().UTC(),
 		objects:      make(map[string]*memoryObject),
+		versions:     make(map[string][]*memoryObject),
 	}
 
 	return true, nil
@@ -61,7 +154,7 @@ func (m *MemoryStorage) DeleteBucket(bucket string) error {
 		return ErrBucketNotFound
 	}
 
-	if len(b.objects) > 0 {
+	if len(b.objects) > 0 || len(b.versions) > 0 {
 		return ErrBucketNotEmpty
 	}
 
@@ -97,32 +190,50 @@ func (m *MemoryStorage) BucketExists(bucket string) (bool, error) {
 }
 
 func (m *MemoryStorage) PutObject(bucket, key string, reader io.Reader, size int64, contentType string) (string, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return m.PutObjectWithMetadata(bucket, key, reader, size, &ObjectMetadata{ContentType: contentType})
+}
 
-	b, exists := m.buckets[bucket]
-	if !exists {
+// PutObjectWithMetadata stores an object together with its content type,
+// standard headers and user metadata
+func (m *MemoryStorage) PutObjectWithMetadata(bucket, key string, reader io.Reader, size int64, metadata *ObjectMetadata) (string, error) {
+	if exists, _ := m.BucketExists(bucket); !exists {
 		return "", ErrBucketNotFound
 	}
 
-	data, err := io.ReadAll(reader)
+	// Read the body before taking the lock, so a slow upload does not block
+	// every other request
+	body, checksum := checksumTee(reader, metadata.checksumAlgorithm())
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return "", err
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The bucket may have been deleted while the body was read
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return "", ErrBucketNotFound
+	}
+
 	etag := CalculateETag(data)
 
-	b.objects[key] = &memoryObject{
-		data:         data,
-		contentType:  contentType,
-		lastModified: time.Now().UTC(),
-		etag:         etag,
+	obj := &memoryObject{data: data}
+	if metadata != nil {
+		obj.metadata = *metadata.Clone()
 	}
+	obj.metadata.Size = int64(len(data))
+	obj.metadata.LastModified = time.Now().UTC()
+	obj.metadata.ETag = etag
+	obj.metadata.Parts = nil
+	obj.metadata.setChecksum(checksum())
+	b.storeObject(key, obj)
 
 	return etag, nil
 }
 
-func (m *MemoryStorage) GetObject(bucket, key string) (io.ReadCloser, *ObjectMetadata, error) {
+func (m *MemoryStorage) GetObject(bucket, key string) (io.ReadSeekCloser, *ObjectMetadata, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -136,14 +247,7 @@ func (m *MemoryStorage) GetObject(bucket, key string) (io.ReadCloser, *ObjectMet
 		return nil, nil, ErrObjectNotFound
 	}
 
-	meta := &ObjectMetadata{
-		Size:         int64(len(obj.data)),
-		ContentType:  obj.contentType,
-		LastModified: obj.lastModified,
-		ETag:         obj.etag,
-	}
-
-	return io.NopCloser(bytes.NewReader(obj.data)), meta, nil
+	return memoryReader{bytes.NewReader(obj.data)}, obj.objectMetadata(), nil
 }
 
 func (m *MemoryStorage) GetObjectMetadata(bucket, key string) (*ObjectMetadata, error) {
@@ -160,12 +264,7 @@ func (m *MemoryStorage) GetObjectMetadata(bucket, key string) (*ObjectMetadata,
 		return nil, ErrObjectNotFound
 	}
 
-	return &ObjectMetadata{
-		Size:         int64(len(obj.data)),
-		ContentType:  obj.contentType,
-		LastModified: obj.lastModified,
-		ETag:         obj.etag,
-	}, nil
+	return obj.objectMetadata(), nil
 }
 
 func (m *MemoryStorage) DeleteObject(bucket, key string) error {
@@ -177,6 +276,18 @@ func (m *MemoryStorage) DeleteObject(bucket, key string) error {
 		return ErrBucketNotFound
 	}
 
+	if b.versioning != "" {
+		// Versioned deletes hide the object behind a new delete marker
+		b.archiveCurrent(key)
+		marker := &memoryObject{metadata: ObjectMetadata{
+			LastModified: time.Now().UTC(),
+			VersionId:    nextVersionId(b.versioning),
+			DeleteMarker: true,
+		}}
+		b.versions[key] = append([]*memoryObject{marker}, b.versions[key]...)
+		return nil
+	}
+
 	if _, exists := b.objects[key]; !exists {
 		return ErrObjectNotFound
 	}
@@ -196,98 +307,76 @@ func (m *MemoryStorage) ListObjects(bucket, prefix, delimiter string, maxKeys in
 
 	var keys []string
 	for k := range b.objects {
-		if prefix == "" || strings.HasPrefix(k, prefix) {
-			if continuationToken == "" || k > continuationToken {
-				keys = append(keys, k)
-			}
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
 		}
 	}
 
 	sort.Strings(keys)
+	page := paginateKeys(keys, prefix, delimiter, maxKeys, continuationToken)
 
-	var objects []ObjectInfo
-	commonPrefixes := make(map[string]bool)
-
-	for _, key := range keys {
-		if len(objects) >= maxKeys {
-			break
-		}
-
-		if delimiter != "" {
-			afterPrefix := key
-			if prefix != "" {
-				afterPrefix = strings.TrimPrefix(key, prefix)
-			}
-
-			if idx := strings.Index(afterPrefix, delimiter); idx >= 0 {
-				commonPrefix := key[:len(key)-len(afterPrefix)+idx+len(delimiter)]
-				if prefix != "" {
-					commonPrefix = prefix + afterPrefix[:idx+len(delimiter)]
-				}
-				commonPrefixes[commonPrefix] = true
-				continue
-			}
-		}
-
+	objects := make([]ObjectInfo, 0, len(page.keys))
+	for _, key := range page.keys {
 		obj := b.objects[key]
 		objects = append(objects, ObjectInfo{
 			Key:          key,
-			Size:         int64(len(obj.data)),
-			LastModified: obj.lastModified,
-			ETag:         obj.etag,
+			Size:         obj.metadata.Size,
+			LastModified: obj.metadata.LastModified,
+			ETag:         obj.metadata.ETag,
 		})
 	}
 
-	var nextToken string
-	if len(objects) > 0 && len(keys) > len(objects) {
-		nextToken = objects[len(objects)-1].Key
-	}
+	return objects, page.prefixes, page.nextMarker, nil
+}
 
-	var prefixes []string
-	for p := range commonPrefixes {
-		prefixes = append(prefixes, p)
+func (m *MemoryStorage) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, error) {
+	meta, err := m.CopyObjectVersion(srcBucket, srcKey, "", dstBucket, dstKey, nil)
+	if err != nil {
+		return "", err
 	}
-	sort.Strings(prefixes)
-
-	return objects, prefixes, nextToken, nil
+	return meta.ETag, nil
 }
 
-func (m *MemoryStorage) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, error) {
+// CopyObjectVersion copies a version of an object, replacing its metadata
+// when metadata is not nil. The copy shares the source's data, which is never
+// modified once stored.
+func (m *MemoryStorage) CopyObjectVersion(srcBucket, srcKey, srcVersionId, dstBucket, dstKey string, metadata *ObjectMetadata) (*ObjectMetadata, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	srcB, exists := m.buckets[srcBucket]
-	if !exists {
-		return "", ErrBucketNotFound
+	srcObj, err := m.lookupVersion(srcBucket, srcKey, srcVersionId)
+	if err != nil {
+		return nil, err
 	}
-
-	srcObj, exists := srcB.objects[srcKey]
-	if !exists {
-		return "", ErrObjectNotFound
+	if srcObj.metadata.DeleteMarker {
+		return nil, ErrDeleteMarker
 	}
 
 	dstB, exists := m.buckets[dstBucket]
 	if !exists {
-		return "", ErrBucketNotFound
+		return nil, ErrBucketNotFound
 	}
 
-	dataCopy := make([]byte, len(srcObj.data))
-	copy(dataCopy, srcObj.data)
-
-	etag := CalculateETag(dataCopy)
-
-	dstB.objects[dstKey] = &memoryObject{
-		data:         dataCopy,
-		contentType:  srcObj.contentType,
-		lastModified: time.Now().UTC(),
-		etag:         etag,
+	dstObj := &memoryObject{
+		data:     srcObj.data,
+		metadata: *copiedMetadata(&srcObj.metadata, metadata),
+	}
+	if dstObj.metadata.ETag == "" {
+		dstObj.metadata.ETag = CalculateETag(dstObj.data)
 	}
+	dstB.storeObject(dstKey, dstObj)
 
-	return etag, nil
+	return dstObj.objectMetadata(), nil
 }
 
 // InitiateMultipartUpload starts a new multipart upload
 func (m *MemoryStorage) InitiateMultipartUpload(bucket, key string) (string, error) {
+	return m.InitiateMultipartUploadWithMetadata(bucket, key, nil)
+}
+
+// InitiateMultipartUploadWithMetadata starts a new multipart upload whose
+// completed object will carry the given metadata
+func (m *MemoryStorage) InitiateMultipartUploadWithMetadata(bucket, key string, metadata *ObjectMetadata) (string, error) {
 	m.mu.RLock()
 	if _, exists := m.buckets[bucket]; !exists {
 		m.mu.RUnlock()
@@ -295,7 +384,7 @@ func (m *MemoryStorage) InitiateMultipartUpload(bucket, key string) (string, err
 	}
 	m.mu.RUnlock()
 
-	return m.multipartMgr.InitiateUpload(bucket, key)
+	return m.multipartMgr.InitiateUpload(bucket, key, metadata)
 }
 
 // UploadPart uploads a part for a multipart upload
@@ -316,21 +405,44 @@ func (m *MemoryStorage) UploadPart(bucket, key, uploadId string, partNumber int,
 		return "", storageerrors.ErrUploadMismatch
 	}
 
-	// Read the data from the reader
-	data := make([]byte, size)
-	_, err := io.ReadFull(reader, data)
+	// Read the data from the reader up to EOF so verifying readers can
+	// check the digest of the complete part
+	body, checksum := checksumTee(reader, upload.Metadata.checksumAlgorithm())
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return "", storageerrors.WrapStorageError("read part data", err)
 	}
 
 	etag := CalculateETag(data)
-	if err := m.multipartMgr.StorePart(uploadId, partNumber, data, etag); err != nil {
+	_, partChecksum := checksum()
+	if err := m.multipartMgr.StorePart(uploadId, partNumber, data, etag, partChecksum); err != nil {
 		return "", err
 	}
 
 	return etag, nil
 }
 
+// UploadPartCopy stores a byte range of an existing object as a part of a
+// multipart upload
+func (m *MemoryStorage) UploadPartCopy(bucket, key, uploadId string, partNumber int, source CopySource) (string, error) {
+	upload, exists := m.multipartMgr.GetUpload(uploadId)
+	if !exists {
+		return "", storageerrors.ErrUploadNotFound
+	}
+
+	if upload.Bucket != bucket || upload.Key != key {
+		return "", storageerrors.ErrUploadMismatch
+	}
+
+	reader, size, err := openCopySource(m, source)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	return m.UploadPart(bucket, key, uploadId, partNumber, reader, size)
+}
+
 // CompleteMultipartUpload completes a multipart upload
 func (m *MemoryStorage) CompleteMultipartUpload(bucket, key, uploadId string, parts []CompletedPart) (string, error) {
 	m.mu.Lock()
@@ -340,7 +452,7 @@ func (m *MemoryStorage) CompleteMultipartUpload(bucket, key, uploadId string, pa
 		return "", ErrBucketNotFound
 	}
 
-	upload, exists := m.multipartMgr.GetUpload(uploadId)
+	upload, uploadParts, exists := m.multipartMgr.Snapshot(uploadId)
 	if !exists {
 		return "", storageerrors.ErrUploadNotFound
 	}
@@ -349,28 +461,42 @@ func (m *MemoryStorage) CompleteMultipartUpload(bucket, key, uploadId string, pa
 		return "", storageerrors.ErrUploadMismatch
 	}
 
-	uploadParts, _ := m.multipartMgr.GetParts(uploadId)
+	if err := validateCompletedParts(upload, parts); err != nil {
+		return "", err
+	}
 
 	// Combine all parts
 	var finalData []byte
+	fullChecksum := multipartChecksum(upload)
 	for _, part := range parts {
 		partData, exists := uploadParts[part.PartNumber]
 		if !exists {
 			return "", storageerrors.WrapMultipartError(fmt.Sprintf("part %d", part.PartNumber), storageerrors.ErrPartNotFound)
 		}
 		finalData = append(finalData, partData...)
+		if fullChecksum != nil {
+			fullChecksum.Write(partData)
+		}
 	}
 
-	// Calculate final ETag
-	etag := CalculateETag(finalData)
+	etag := multipartETag(upload, parts)
 
-	// Store the combined object
-	m.buckets[bucket].objects[key] = &memoryObject{
-		data:         finalData,
-		contentType:  "application/octet-stream",
-		lastModified: time.Now().UTC(),
-		etag:         etag,
+	// Store the combined object with the metadata captured at initiate time
+	obj := &memoryObject{data: finalData}
+	if upload.Metadata != nil {
+		obj.metadata = *upload.Metadata.Clone()
+	}
+	if obj.metadata.ContentType == "" {
+		obj.metadata.ContentType = "application/octet-stream"
 	}
+	obj.metadata.Size = int64(len(finalData))
+	obj.metadata.LastModified = time.Now().UTC()
+	obj.metadata.ETag = etag
+	obj.metadata.Parts = objectParts(upload, parts)
+	if err := applyMultipartChecksum(&obj.metadata, upload, parts, fullChecksum); err != nil {
+		return "", err
+	}
+	m.buckets[bucket].storeObject(key, obj)
 
 	// Clean up the multipart upload
 	_ = m.multipartMgr.DeleteUpload(uploadId)
@@ -419,3 +545,363 @@ func (m *MemoryStorage) ListParts(bucket, key, uploadId string) ([]PartInfo, err
 
 	return m.multipartMgr.ListParts(uploadId)
 }
+
+// ListMultipartUploads lists the in-progress uploads of a bucket whose key
+// starts with prefix
+func (m *MemoryStorage) ListMultipartUploads(bucket, prefix string) ([]MultipartUploadInfo, error) {
+	m.mu.RLock()
+	if _, exists := m.buckets[bucket]; !exists {
+		m.mu.RUnlock()
+		return nil, ErrBucketNotFound
+	}
+	m.mu.RUnlock()
+
+	return uploadInfos(m.multipartMgr.ListUploads(bucket), prefix), nil
+}
+
+// AbortStaleMultipartUploads aborts the uploads initiated before the cutoff
+func (m *MemoryStorage) AbortStaleMultipartUploads(cutoff time.Time) (int, error) {
+	aborted := 0
+	for _, upload := range m.multipartMgr.StaleUploads(cutoff) {
+		if err := m.multipartMgr.DeleteUpload(upload.UploadId); err != nil {
+			return aborted, err
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+// PutBucketVersioning sets the versioning state of a bucket
+func (m *MemoryStorage) PutBucketVersioning(bucket, status string) error {
+	if err := validateVersioningStatus(status); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	b.versioning = status
+	return nil
+}
+
+// GetBucketVersioning returns the versioning state of a bucket
+func (m *MemoryStorage) GetBucketVersioning(bucket string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return "", ErrBucketNotFound
+	}
+
+	return b.versioning, nil
+}
+
+// PutObjectTagging replaces the tag set of an object version
+func (m *MemoryStorage) PutObjectTagging(bucket, key, versionId string, tags map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, err := m.lookupVersion(bucket, key, versionId)
+	if err != nil {
+		return err
+	}
+	if obj.metadata.DeleteMarker {
+		return ErrDeleteMarker
+	}
+
+	obj.metadata.Tags = cloneTags(tags)
+	return nil
+}
+
+// PutBucketTagging replaces the tag set of a bucket
+func (m *MemoryStorage) PutBucketTagging(bucket string, tags map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	b.tags = cloneTags(tags)
+	return nil
+}
+
+// GetBucketTagging returns the tag set of a bucket
+func (m *MemoryStorage) GetBucketTagging(bucket string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	return cloneTags(b.tags), nil
+}
+
+// PutBucketPolicy replaces the policy document of a bucket
+func (m *MemoryStorage) PutBucketPolicy(bucket string, policy []byte) error {
+	if policy != nil {
+		if _, err := ParseBucketPolicy(policy); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	b.policy = append([]byte(nil), policy...)
+	return nil
+}
+
+// GetBucketPolicy returns the policy document of a bucket
+func (m *MemoryStorage) GetBucketPolicy(bucket string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	if b.policy == nil {
+		return nil, nil
+	}
+	return append([]byte(nil), b.policy...), nil
+}
+
+// PutObjectACL replaces the ACL of an object version
+func (m *MemoryStorage) PutObjectACL(bucket, key, versionId string, acl []Grant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, err := m.lookupVersion(bucket, key, versionId)
+	if err != nil {
+		return err
+	}
+	if obj.metadata.DeleteMarker {
+		return ErrDeleteMarker
+	}
+
+	obj.metadata.ACL = cloneACL(acl)
+	return nil
+}
+
+// PutBucketACL replaces the ACL of a bucket
+func (m *MemoryStorage) PutBucketACL(bucket string, acl []Grant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	b.acl = cloneACL(acl)
+	return nil
+}
+
+// GetBucketACL returns the ACL of a bucket
+func (m *MemoryStorage) GetBucketACL(bucket string) ([]Grant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	return cloneACL(b.acl), nil
+}
+
+// PutBucketCORS replaces the CORS configuration of a bucket
+func (m *MemoryStorage) PutBucketCORS(bucket string, rules []CORSRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	b.cors = cloneCORSRules(rules)
+	return nil
+}
+
+// GetBucketCORS returns the CORS configuration of a bucket
+func (m *MemoryStorage) GetBucketCORS(bucket string) ([]CORSRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	return cloneCORSRules(b.cors), nil
+}
+
+// PutBucketLifecycle replaces the lifecycle configuration of a bucket
+func (m *MemoryStorage) PutBucketLifecycle(bucket string, rules []LifecycleRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	b.lifecycle = cloneLifecycleRules(rules)
+	return nil
+}
+
+// GetBucketLifecycle returns the lifecycle configuration of a bucket
+func (m *MemoryStorage) GetBucketLifecycle(bucket string) ([]LifecycleRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	return cloneLifecycleRules(b.lifecycle), nil
+}
+
+// ApplyLifecycle applies the lifecycle rules of every bucket as of now
+func (m *MemoryStorage) ApplyLifecycle(now time.Time) (int, error) {
+	return applyLifecycle(m, now)
+}
+
+// ListObjectVersions lists every version and delete marker of the keys
+// matching prefix, ordered by key and then newest first
+func (m *MemoryStorage) ListObjectVersions(bucket, prefix string) ([]ObjectVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	keySet := make(map[string]bool)
+	for k := range b.objects {
+		keySet[k] = true
+	}
+	for k := range b.versions {
+		keySet[k] = true
+	}
+
+	var keys []string
+	for k := range keySet {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var versions []ObjectVersion
+	for _, key := range keys {
+		var current *ObjectMetadata
+		if obj, exists := b.objects[key]; exists {
+			current = &obj.metadata
+		}
+		history := make([]*ObjectMetadata, 0, len(b.versions[key]))
+		for _, obj := range b.versions[key] {
+			history = append(history, &obj.metadata)
+		}
+		versions = appendVersions(versions, key, current, history)
+	}
+
+	return versions, nil
+}
+
+// GetObjectVersion retrieves a specific version of an object
+func (m *MemoryStorage) GetObjectVersion(bucket, key, versionId string) (io.ReadSeekCloser, *ObjectMetadata, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, err := m.lookupVersion(bucket, key, versionId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if obj.metadata.DeleteMarker {
+		return nil, obj.objectMetadata(), ErrDeleteMarker
+	}
+
+	return memoryReader{bytes.NewReader(obj.data)}, obj.objectMetadata(), nil
+}
+
+// GetObjectVersionMetadata retrieves the metadata of a specific version
+func (m *MemoryStorage) GetObjectVersionMetadata(bucket, key, versionId string) (*ObjectMetadata, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, err := m.lookupVersion(bucket, key, versionId)
+	if err != nil {
+		return nil, err
+	}
+	if obj.metadata.DeleteMarker {
+		return obj.objectMetadata(), ErrDeleteMarker
+	}
+
+	return obj.objectMetadata(), nil
+}
+
+// lookupVersion resolves a version while the caller holds the storage lock
+func (m *MemoryStorage) lookupVersion(bucket, key, versionId string) (*memoryObject, error) {
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	obj, exists := b.findVersion(key, versionId)
+	if !exists {
+		if versionId == "" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, ErrVersionNotFound
+	}
+
+	return obj, nil
+}
+
+// DeleteObjectVersion permanently removes a version or delete marker. When
+// the latest version is removed the next newest one becomes current.
+func (m *MemoryStorage) DeleteObjectVersion(bucket, key, versionId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[bucket]
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	if current, exists := b.objects[key]; exists && versionIdOrNull(current.metadata.VersionId) == versionId {
+		delete(b.objects, key)
+	} else if !b.removeHistoryVersion(key, versionId) {
+		return ErrVersionNotFound
+	}
+
+	// Promote the newest remaining version unless a delete marker hides it
+	if _, exists := b.objects[key]; !exists {
+		if history := b.versions[key]; len(history) > 0 && !history[0].metadata.DeleteMarker {
+			b.objects[key] = history[0]
+			b.removeHistoryVersion(key, versionIdOrNull(history[0].metadata.VersionId))
+		}
+	}
+
+	return nil
+}
@@ -1,12 +1,23 @@
 package storage
 
 import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	storageerrors "github.com/wozozo/s3pit/pkg/errors"
+	"io"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	storageerrors "github.com/wozozo/s3pit/pkg/errors"
 )
 
+// MinPartSize is the smallest size allowed for any part of a multipart
+// upload except the last one
+const MinPartSize = 5 * 1024 * 1024
+
 // MultipartManager handles multipart upload operations with thread-safe access
 type MultipartManager struct {
 	uploads map[string]*MultipartUpload
@@ -22,9 +33,18 @@ func NewMultipartManager() *MultipartManager {
 	}
 }
 
-// InitiateUpload creates a new multipart upload
-func (m *MultipartManager) InitiateUpload(bucket, key string) (string, error) {
-	uploadId := fmt.Sprintf("upload-%d-%s", time.Now().UnixNano(), key)
+// newUploadId returns a unique upload ID. IDs sort by creation time and are
+// safe to use as a directory name.
+func newUploadId() string {
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("upload-%d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+}
+
+// InitiateUpload creates a new multipart upload. The metadata is applied to
+// the object when the upload is completed and may be nil.
+func (m *MultipartManager) InitiateUpload(bucket, key string, metadata *ObjectMetadata) (string, error) {
+	uploadId := newUploadId()
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -35,6 +55,7 @@ func (m *MultipartManager) InitiateUpload(bucket, key string) (string, error) {
 		UploadId:  uploadId,
 		Initiated: time.Now().UTC(),
 		Parts:     make(map[int]PartInfo),
+		Metadata:  metadata.Clone(),
 	}
 	m.parts[uploadId] = make(map[int][]byte)
 
@@ -51,7 +72,7 @@ func (m *MultipartManager) GetUpload(uploadId string) (*MultipartUpload, bool) {
 }
 
 // StorePart stores a part for a multipart upload
-func (m *MultipartManager) StorePart(uploadId string, partNumber int, data []byte, etag string) error {
+func (m *MultipartManager) StorePart(uploadId string, partNumber int, data []byte, etag, checksum string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -68,14 +89,128 @@ func (m *MultipartManager) StorePart(uploadId string, partNumber int, data []byt
 
 	// Update part info
 	upload.Parts[partNumber] = PartInfo{
-		PartNumber: partNumber,
-		ETag:       etag,
-		Size:       int64(len(data)),
+		PartNumber:   partNumber,
+		ETag:         etag,
+		Size:         int64(len(data)),
+		LastModified: time.Now().UTC(),
+		Checksum:     checksum,
 	}
 
 	return nil
 }
 
+// openCopySource opens the byte range of an object named by a copy source
+// and returns it with its length
+func openCopySource(s Storage, source CopySource) (io.ReadCloser, int64, error) {
+	reader, meta, err := s.GetObjectVersion(source.Bucket, source.Key, source.VersionId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	length := source.Length
+	if length < 0 {
+		length = meta.Size - source.Offset
+	}
+	if source.Offset < 0 || length < 0 || source.Offset+length > meta.Size {
+		reader.Close()
+		return nil, 0, storageerrors.ErrInvalidRange
+	}
+
+	if _, err := reader.Seek(source.Offset, io.SeekStart); err != nil {
+		reader.Close()
+		return nil, 0, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, length, nil
+}
+
+// validateCompletedParts checks the part list of a CompleteMultipartUpload
+// request against the uploaded parts. Part numbers must be in ascending
+// order, each part must have been uploaded with the given ETag, and every part
+// but the last must be at least MinPartSize.
+func validateCompletedParts(upload *MultipartUpload, parts []CompletedPart) error {
+	if len(parts) == 0 {
+		return storageerrors.WrapMultipartError(upload.UploadId, storageerrors.ErrInvalidPart)
+	}
+
+	for i := 1; i < len(parts); i++ {
+		if parts[i].PartNumber <= parts[i-1].PartNumber {
+			return storageerrors.WrapMultipartError(upload.UploadId, storageerrors.ErrInvalidPartOrder)
+		}
+	}
+
+	for i, part := range parts {
+		info, exists := upload.Parts[part.PartNumber]
+		if !exists {
+			return storageerrors.WrapMultipartError(fmt.Sprintf("part %d", part.PartNumber), storageerrors.ErrPartNotFound)
+		}
+		if StripETagQuotes(part.ETag) != StripETagQuotes(info.ETag) {
+			return storageerrors.WrapMultipartError(fmt.Sprintf("part %d", part.PartNumber), storageerrors.ErrInvalidPart)
+		}
+		if i < len(parts)-1 && info.Size < MinPartSize {
+			return storageerrors.WrapMultipartError(fmt.Sprintf("part %d", part.PartNumber), storageerrors.ErrEntityTooSmall)
+		}
+	}
+
+	return nil
+}
+
+// multipartETag returns the ETag S3 gives a multipart object: the MD5 of the
+// concatenated binary part MD5s, suffixed with the part count
+func multipartETag(upload *MultipartUpload, parts []CompletedPart) string {
+	hash := md5.New()
+	for _, part := range parts {
+		digest, _ := hex.DecodeString(StripETagQuotes(upload.Parts[part.PartNumber].ETag))
+		hash.Write(digest)
+	}
+	return fmt.Sprintf("\"%s-%d\"", hex.EncodeToString(hash.Sum(nil)), len(parts))
+}
+
+// objectParts returns the part layout of the object completed from parts
+func objectParts(upload *MultipartUpload, parts []CompletedPart) []ObjectPart {
+	layout := make([]ObjectPart, 0, len(parts))
+	for _, part := range parts {
+		info := upload.Parts[part.PartNumber]
+		layout = append(layout, ObjectPart{
+			PartNumber: part.PartNumber,
+			Size:       info.Size,
+			Checksum:   info.Checksum,
+		})
+	}
+	return layout
+}
+
+// Snapshot returns a copy of an upload and its part data, taken under the
+// lock StorePart holds, so a completion validates, hashes and assembles the
+// same parts while the client keeps uploading
+func (m *MultipartManager) Snapshot(uploadId string) (*MultipartUpload, map[int][]byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	upload, exists := m.uploads[uploadId]
+	if !exists {
+		return nil, nil, false
+	}
+	data := make(map[int][]byte, len(m.parts[uploadId]))
+	for partNumber, part := range m.parts[uploadId] {
+		data[partNumber] = part
+	}
+	return upload.clone(), data, true
+}
+
+// clone returns a copy of the upload with its own part map
+func (u *MultipartUpload) clone() *MultipartUpload {
+	c := *u
+	c.Parts = make(map[int]PartInfo, len(u.Parts))
+	for partNumber, part := range u.Parts {
+		c.Parts[partNumber] = part
+	}
+	return &c
+}
+
 // GetParts retrieves all parts for an upload
 func (m *MultipartManager) GetParts(uploadId string) (map[int][]byte, bool) {
 	m.mu.RLock()
@@ -95,12 +230,47 @@ func (m *MultipartManager) ListParts(uploadId string) ([]PartInfo, error) {
 		return nil, storageerrors.WrapMultipartError(uploadId, storageerrors.ErrUploadNotFound)
 	}
 
+	return sortedParts(upload), nil
+}
+
+// sortedParts returns the parts of an upload ordered by part number
+func sortedParts(upload *MultipartUpload) []PartInfo {
 	parts := make([]PartInfo, 0, len(upload.Parts))
 	for _, part := range upload.Parts {
 		parts = append(parts, part)
 	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	return parts
+}
 
-	return parts, nil
+// uploadInfos returns the uploads whose key starts with prefix, ordered by
+// key and then by initiation time as S3 lists them
+func uploadInfos(uploads []*MultipartUpload, prefix string) []MultipartUploadInfo {
+	infos := make([]MultipartUploadInfo, 0, len(uploads))
+	for _, upload := range uploads {
+		if !strings.HasPrefix(upload.Key, prefix) {
+			continue
+		}
+		infos = append(infos, MultipartUploadInfo{
+			Key:               upload.Key,
+			UploadId:          upload.UploadId,
+			Initiated:         upload.Initiated,
+			ChecksumAlgorithm: upload.Metadata.checksumAlgorithm(),
+			ChecksumType:      upload.Metadata.checksumType(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Key != infos[j].Key {
+			return infos[i].Key < infos[j].Key
+		}
+		if !infos[i].Initiated.Equal(infos[j].Initiated) {
+			return infos[i].Initiated.Before(infos[j].Initiated)
+		}
+		return infos[i].UploadId < infos[j].UploadId
+	})
+	return infos
 }
 
 // DeleteUpload removes an upload and its parts
@@ -128,3 +298,18 @@ func (m *MultipartManager) ListUploads(bucket string) []*MultipartUpload {
 
 	return uploads
 }
+
+// StaleUploads returns the uploads of all buckets initiated before the cutoff
+func (m *MultipartManager) StaleUploads(cutoff time.Time) []*MultipartUpload {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var uploads []*MultipartUpload
+	for _, upload := range m.uploads {
+		if upload.Initiated.Before(cutoff) {
+			uploads = append(uploads, upload)
+		}
+	}
+
+	return uploads
+}
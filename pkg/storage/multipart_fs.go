@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -11,22 +13,51 @@ import (
 	storageerrors "github.com/wozozo/s3pit/pkg/errors"
 )
 
+// uploadManifestName is the file in an upload's directory that records the
+// upload, so it can be resumed after a restart
+const uploadManifestName = "manifest.json"
+
 // FileSystemMultipartManager handles multipart uploads with filesystem storage
 type FileSystemMultipartManager struct {
 	baseDir string
-	uploads sync.Map // uploadId -> *MultipartUpload
+	uploads sync.Map   // uploadId -> *MultipartUpload
+	mu      sync.Mutex // Serialises part updates and manifest writes
+}
+
+// uploadManifestFile is the on-disk format of an upload's manifest.json
+type uploadManifestFile struct {
+	Bucket    string           `json:"bucket"`
+	Key       string           `json:"key"`
+	UploadId  string           `json:"upload-id"`
+	Initiated time.Time        `json:"initiated"`
+	Metadata  *objectMetaFile  `json:"metadata,omitempty"`
+	Parts     []uploadPartFile `json:"parts,omitempty"`
 }
 
-// NewFileSystemMultipartManager creates a new filesystem-based multipart manager
+// uploadPartFile is the on-disk format of one uploaded part
+type uploadPartFile struct {
+	PartNumber int       `json:"part-number"`
+	ETag       string    `json:"etag"`
+	Size       int64     `json:"size"`
+	Modified   time.Time `json:"modified"`
+	Checksum   string    `json:"checksum,omitempty"`
+}
+
+// NewFileSystemMultipartManager creates a new filesystem-based multipart
+// manager and resumes the uploads whose manifests are found under baseDir
 func NewFileSystemMultipartManager(baseDir string) *FileSystemMultipartManager {
-	return &FileSystemMultipartManager{
+	m := &FileSystemMultipartManager{
 		baseDir: baseDir,
 	}
+	m.loadUploads()
+	return m
 }
 
-// InitiateUpload creates a new multipart upload and prepares filesystem
-func (m *FileSystemMultipartManager) InitiateUpload(bucket, key string) (string, error) {
-	uploadId := fmt.Sprintf("upload-%d-%s", time.Now().UnixNano(), key)
+// InitiateUpload creates a new multipart upload and the directory its parts
+// are stored in. The metadata, which may be nil, is applied to the object
+// when the upload is completed.
+func (m *FileSystemMultipartManager) InitiateUpload(bucket, key string, metadata *ObjectMetadata) (string, error) {
+	uploadId := newUploadId()
 
 	upload := &MultipartUpload{
 		Bucket:    bucket,
@@ -34,17 +65,22 @@ func (m *FileSystemMultipartManager) InitiateUpload(bucket, key string) (string,
 		UploadId:  uploadId,
 		Initiated: time.Now().UTC(),
 		Parts:     make(map[int]PartInfo),
+		Metadata:  metadata.Clone(),
 	}
 
-	m.uploads.Store(uploadId, upload)
-
 	// Create temporary directory for parts
-	tempDir := filepath.Join(m.baseDir, ".s3pit_uploads", uploadId)
+	tempDir := m.uploadDir(uploadId)
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		m.uploads.Delete(uploadId)
 		return "", storageerrors.WrapFileSystemError(tempDir, "create directory", err)
 	}
 
+	if err := m.saveManifest(upload); err != nil {
+		_ = os.RemoveAll(tempDir)
+		return "", err
+	}
+
+	m.uploads.Store(uploadId, upload)
+
 	return uploadId, nil
 }
 
@@ -64,46 +100,187 @@ func (m *FileSystemMultipartManager) StorePart(uploadId string, partNumber int,
 		return "", storageerrors.WrapMultipartError(uploadId, storageerrors.ErrUploadNotFound)
 	}
 
-	// Save part to temporary file
-	partPath := filepath.Join(m.baseDir, ".s3pit_uploads", uploadId, fmt.Sprintf("part-%d", partNumber))
-
-	partFile, err := os.Create(partPath)
+	// Stream the part into a temp file so a failed upload leaves any
+	// previously stored copy of the part intact
+	partPath := m.GetPartPath(uploadId, partNumber)
+	partFile, err := os.CreateTemp(filepath.Dir(partPath), ".part_*")
 	if err != nil {
 		return "", err
 	}
-	defer partFile.Close()
+	tempPath := partFile.Name()
+	defer os.Remove(tempPath)
 
-	// Read part data
-	data := make([]byte, size)
-	n, err := io.ReadFull(reader, data)
-	if err != nil && err != io.ErrUnexpectedEOF {
-		return "", err
+	body, checksum := checksumTee(reader, upload.Metadata.checksumAlgorithm())
+	hash := md5.New()
+	written, err := io.Copy(partFile, io.TeeReader(body, hash))
+	if closeErr := partFile.Close(); err == nil {
+		err = closeErr
 	}
-	data = data[:n]
-
-	// Write to part file
-	written, err := partFile.Write(data)
 	if err != nil {
 		return "", err
 	}
 
-	// Calculate ETag
-	etag := CalculateETag(data)
+	etag := hashETag(hash)
+	_, partChecksum := checksum()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.Rename(tempPath, partPath); err != nil {
+		return "", err
+	}
 
 	// Update upload parts
 	upload.Parts[partNumber] = PartInfo{
 		PartNumber:   partNumber,
-		Size:         int64(written),
+		Size:         written,
 		ETag:         etag,
 		LastModified: time.Now().UTC(),
+		Checksum:     partChecksum,
+	}
+
+	if err := m.saveManifest(upload); err != nil {
+		return "", err
 	}
 
 	return etag, nil
 }
 
+// Snapshot returns a copy of an upload and opens the files of the listed
+// parts, both under the lock StorePart replaces parts with, so a completion
+// validates, hashes and assembles the same parts while the client keeps
+// uploading. Parts that were never uploaded have no file. The caller closes
+// the files.
+func (m *FileSystemMultipartManager) Snapshot(uploadId string, parts []CompletedPart) (*MultipartUpload, map[int]*os.File, error) {
+	upload, exists := m.GetUpload(uploadId)
+	if !exists {
+		return nil, nil, storageerrors.WrapMultipartError(uploadId, storageerrors.ErrUploadNotFound)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make(map[int]*os.File, len(parts))
+	for _, part := range parts {
+		if _, uploaded := upload.Parts[part.PartNumber]; !uploaded || files[part.PartNumber] != nil {
+			continue
+		}
+		file, err := os.Open(m.GetPartPath(uploadId, part.PartNumber))
+		if err != nil {
+			closeParts(files)
+			return nil, nil, storageerrors.WrapMultipartError(fmt.Sprintf("part %d", part.PartNumber), err)
+		}
+		files[part.PartNumber] = file
+	}
+	return upload.clone(), files, nil
+}
+
+// closeParts closes the part files of a snapshot
+func closeParts(files map[int]*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
+
+// uploadDir returns the directory holding an upload's parts and manifest
+func (m *FileSystemMultipartManager) uploadDir(uploadId string) string {
+	return filepath.Join(m.baseDir, ".s3pit_uploads", uploadId)
+}
+
 // GetPartPath returns the filesystem path for a part
 func (m *FileSystemMultipartManager) GetPartPath(uploadId string, partNumber int) string {
-	return filepath.Join(m.baseDir, ".s3pit_uploads", uploadId, fmt.Sprintf("part-%d", partNumber))
+	return filepath.Join(m.uploadDir(uploadId), fmt.Sprintf("part-%d", partNumber))
+}
+
+// saveManifest writes the manifest of an upload. It is written to a temp
+// file and renamed into place so a crash never leaves a partial manifest.
+func (m *FileSystemMultipartManager) saveManifest(upload *MultipartUpload) error {
+	manifest := uploadManifestFile{
+		Bucket:    upload.Bucket,
+		Key:       upload.Key,
+		UploadId:  upload.UploadId,
+		Initiated: upload.Initiated,
+	}
+	if upload.Metadata != nil {
+		meta := newObjectMetaFile(upload.Metadata)
+		manifest.Metadata = &meta
+	}
+	for _, part := range sortedParts(upload) {
+		manifest.Parts = append(manifest.Parts, uploadPartFile{
+			PartNumber: part.PartNumber,
+			ETag:       StripETagQuotes(part.ETag),
+			Size:       part.Size,
+			Modified:   part.LastModified,
+			Checksum:   part.Checksum,
+		})
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	manifestPath := filepath.Join(m.uploadDir(upload.UploadId), uploadManifestName)
+	tempPath := manifestPath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return storageerrors.WrapFileSystemError(tempPath, "write file", err)
+	}
+	if err := os.Rename(tempPath, manifestPath); err != nil {
+		_ = os.Remove(tempPath)
+		return storageerrors.WrapFileSystemError(manifestPath, "move file", err)
+	}
+	return nil
+}
+
+// loadUploads resumes the uploads recorded by manifests under the uploads
+// directory. Directories without a readable manifest are left for the upload
+// reaper; parts whose file is missing are dropped.
+func (m *FileSystemMultipartManager) loadUploads() {
+	entries, err := os.ReadDir(filepath.Join(m.baseDir, ".s3pit_uploads"))
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(m.uploadDir(entry.Name()), uploadManifestName))
+		if err != nil {
+			continue
+		}
+		var manifest uploadManifestFile
+		if err := json.Unmarshal(data, &manifest); err != nil || manifest.UploadId != entry.Name() {
+			continue
+		}
+
+		upload := &MultipartUpload{
+			Bucket:    manifest.Bucket,
+			Key:       manifest.Key,
+			UploadId:  manifest.UploadId,
+			Initiated: manifest.Initiated,
+			Parts:     make(map[int]PartInfo),
+		}
+		if manifest.Metadata != nil {
+			upload.Metadata = &ObjectMetadata{}
+			manifest.Metadata.applyTo(upload.Metadata)
+		}
+		for _, part := range manifest.Parts {
+			if _, err := os.Stat(m.GetPartPath(upload.UploadId, part.PartNumber)); err != nil {
+				continue
+			}
+			upload.Parts[part.PartNumber] = PartInfo{
+				PartNumber:   part.PartNumber,
+				Size:         part.Size,
+				ETag:         FormatETag(part.ETag),
+				LastModified: part.Modified,
+				Checksum:     part.Checksum,
+			}
+		}
+
+		m.uploads.Store(upload.UploadId, upload)
+	}
 }
 
 // ListParts lists all parts for an upload
@@ -113,12 +290,10 @@ func (m *FileSystemMultipartManager) ListParts(uploadId string) ([]PartInfo, err
 		return nil, storageerrors.WrapMultipartError(uploadId, storageerrors.ErrUploadNotFound)
 	}
 
-	parts := make([]PartInfo, 0, len(upload.Parts))
-	for _, part := range upload.Parts {
-		parts = append(parts, part)
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	return parts, nil
+	return sortedParts(upload), nil
 }
 
 // DeleteUpload removes an upload and its parts from filesystem
@@ -126,8 +301,7 @@ func (m *FileSystemMultipartManager) DeleteUpload(uploadId string) error {
 	m.uploads.Delete(uploadId)
 
 	// Remove temporary directory
-	uploadDir := filepath.Join(m.baseDir, ".s3pit_uploads", uploadId)
-	return os.RemoveAll(uploadDir)
+	return os.RemoveAll(m.uploadDir(uploadId))
 }
 
 // ListUploads returns all active uploads for a bucket
@@ -144,3 +318,52 @@ func (m *FileSystemMultipartManager) ListUploads(bucket string) []*MultipartUplo
 
 	return uploads
 }
+
+// StaleUploads returns the uploads of all buckets initiated before the cutoff
+func (m *FileSystemMultipartManager) StaleUploads(cutoff time.Time) []*MultipartUpload {
+	var uploads []*MultipartUpload
+
+	m.uploads.Range(func(key, value interface{}) bool {
+		upload := value.(*MultipartUpload)
+		if upload.Initiated.Before(cutoff) {
+			uploads = append(uploads, upload)
+		}
+		return true
+	})
+
+	return uploads
+}
+
+// RemoveOrphanedUploads deletes part directories that belong to no known
+// upload, such as those without a readable manifest, once they were last
+// modified before the cutoff. It returns how many directories were removed.
+func (m *FileSystemMultipartManager) RemoveOrphanedUploads(cutoff time.Time) (int, error) {
+	uploadsDir := filepath.Join(m.baseDir, ".s3pit_uploads")
+	entries, err := os.ReadDir(uploadsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, storageerrors.WrapFileSystemError(uploadsDir, "read directory", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, known := m.uploads.Load(entry.Name()); known {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(uploadsDir, entry.Name())); err != nil {
+			return removed, storageerrors.WrapFileSystemError(entry.Name(), "remove directory", err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PutBucketPolicy stores the policy document of a bucket in its metadata
+// file, or removes it when policy is nil
+func (fs *FileSystemStorage) PutBucketPolicy(bucket string, policy []byte) error {
+	if policy != nil {
+		if _, err := ParseBucketPolicy(policy); err != nil {
+			return err
+		}
+	}
+
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta := fs.loadBucketMeta(bucket)
+	if meta.Name == "" {
+		meta.Name = bucket
+		meta.Created = time.Now().UTC()
+	}
+	meta.Policy = append([]byte(nil), policy...)
+
+	return fs.saveBucketMeta(bucket, meta)
+}
+
+// GetBucketPolicy returns the policy document of a bucket
+func (fs *FileSystemStorage) GetBucketPolicy(bucket string) ([]byte, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	return fs.loadBucketMeta(bucket).Policy, nil
+}
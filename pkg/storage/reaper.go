@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"log"
+	"time"
+)
+
+// UploadReaper periodically aborts multipart uploads that were initiated
+// longer ago than a maximum age, so abandoned uploads do not keep their parts
+// around forever
+type UploadReaper struct {
+	storage  Storage
+	maxAge   time.Duration
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewUploadReaper creates a reaper for uploads older than maxAge that runs
+// every interval
+func NewUploadReaper(storage Storage, maxAge, interval time.Duration) *UploadReaper {
+	return &UploadReaper{
+		storage:  storage,
+		maxAge:   maxAge,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the reaper in the background until Stop is called
+func (r *UploadReaper) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.Reap()
+			select {
+			case <-ticker.C:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background reaper and waits for it to finish
+func (r *UploadReaper) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Reap aborts the stale uploads once and returns how many were aborted
+func (r *UploadReaper) Reap() int {
+	aborted, err := r.storage.AbortStaleMultipartUploads(time.Now().Add(-r.maxAge))
+	if err != nil {
+		log.Printf("Failed to abort stale multipart uploads: %v", err)
+	}
+	if aborted > 0 {
+		log.Printf("Aborted %d stale multipart upload(s)", aborted)
+	}
+	return aborted
+}
@@ -13,6 +13,21 @@ var (
 	ErrBucketNotEmpty = storageerrors.ErrBucketNotEmpty
 	ErrObjectNotFound = storageerrors.ErrObjectNotFound
 	ErrBucketExists   = storageerrors.ErrBucketExists
+
+	ErrVersionNotFound = storageerrors.ErrVersionNotFound
+	ErrDeleteMarker    = storageerrors.ErrDeleteMarker
+
+	ErrEntityTooLarge        = storageerrors.ErrEntityTooLarge
+	ErrBadDigest             = storageerrors.ErrBadDigest
+	ErrContentSHA256Mismatch = storageerrors.ErrContentSHA256Mismatch
+	ErrChecksumMismatch      = storageerrors.ErrChecksumMismatch
+
+	ErrInvalidPart      = storageerrors.ErrInvalidPart
+	ErrInvalidPartOrder = storageerrors.ErrInvalidPartOrder
+	ErrEntityTooSmall   = storageerrors.ErrEntityTooSmall
+	ErrInvalidRange     = storageerrors.ErrInvalidRange
+
+	ErrPolicyParseFailed = storageerrors.ErrPolicyParseFailed
 )
 
 type Storage interface {
@@ -22,19 +37,68 @@ type Storage interface {
 	BucketExists(bucket string) (bool, error)
 
 	PutObject(bucket, key string, reader io.Reader, size int64, contentType string) (string, error)
-	GetObject(bucket, key string) (io.ReadCloser, *ObjectMetadata, error)
+	PutObjectWithMetadata(bucket, key string, reader io.Reader, size int64, metadata *ObjectMetadata) (string, error)
+	GetObject(bucket, key string) (io.ReadSeekCloser, *ObjectMetadata, error)
 	GetObjectMetadata(bucket, key string) (*ObjectMetadata, error)
 	DeleteObject(bucket, key string) error
 	ListObjects(bucket, prefix, delimiter string, maxKeys int, continuationToken string) ([]ObjectInfo, []string, string, error)
 
 	CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, error)
+	CopyObjectVersion(srcBucket, srcKey, srcVersionId, dstBucket, dstKey string, metadata *ObjectMetadata) (*ObjectMetadata, error)
 
 	// Multipart upload operations
 	InitiateMultipartUpload(bucket, key string) (string, error)
+	InitiateMultipartUploadWithMetadata(bucket, key string, metadata *ObjectMetadata) (string, error)
 	UploadPart(bucket, key, uploadId string, partNumber int, reader io.Reader, size int64) (string, error)
+	UploadPartCopy(bucket, key, uploadId string, partNumber int, source CopySource) (string, error)
 	CompleteMultipartUpload(bucket, key, uploadId string, parts []CompletedPart) (string, error)
 	AbortMultipartUpload(bucket, key, uploadId string) error
 	ListParts(bucket, key, uploadId string) ([]PartInfo, error)
+	ListMultipartUploads(bucket, prefix string) ([]MultipartUploadInfo, error)
+	// AbortStaleMultipartUploads aborts every upload initiated before the
+	// cutoff and returns how many were aborted
+	AbortStaleMultipartUploads(cutoff time.Time) (int, error)
+
+	// Versioning operations. A versionId of "" addresses the latest version.
+	// Lookups that resolve to a delete marker return ErrDeleteMarker together
+	// with the marker's metadata.
+	PutBucketVersioning(bucket, status string) error
+	GetBucketVersioning(bucket string) (string, error)
+	ListObjectVersions(bucket, prefix string) ([]ObjectVersion, error)
+	GetObjectVersion(bucket, key, versionId string) (io.ReadSeekCloser, *ObjectMetadata, error)
+	GetObjectVersionMetadata(bucket, key, versionId string) (*ObjectMetadata, error)
+	DeleteObjectVersion(bucket, key, versionId string) error
+
+	// Tagging operations. A tag set replaces the previous one and a nil tag
+	// set removes it. Object tags are read from ObjectMetadata.Tags.
+	PutObjectTagging(bucket, key, versionId string, tags map[string]string) error
+	PutBucketTagging(bucket string, tags map[string]string) error
+	GetBucketTagging(bucket string) (map[string]string, error)
+
+	// Bucket policy operations. The policy is stored as the JSON document it
+	// was put with and a nil policy removes it. GetBucketPolicy returns nil
+	// when the bucket has no policy.
+	PutBucketPolicy(bucket string, policy []byte) error
+	GetBucketPolicy(bucket string) ([]byte, error)
+
+	// ACL operations. An ACL replaces the previous one and a nil ACL restores
+	// the default private ACL. Object ACLs are read from ObjectMetadata.ACL.
+	PutObjectACL(bucket, key, versionId string, acl []Grant) error
+	PutBucketACL(bucket string, acl []Grant) error
+	GetBucketACL(bucket string) ([]Grant, error)
+
+	// CORS operations. A configuration replaces the previous one and nil rules
+	// remove it. GetBucketCORS returns nil when the bucket has no configuration.
+	PutBucketCORS(bucket string, rules []CORSRule) error
+	GetBucketCORS(bucket string) ([]CORSRule, error)
+
+	// Lifecycle operations. A configuration replaces the previous one and nil
+	// rules remove it. GetBucketLifecycle returns nil when the bucket has no
+	// configuration. ApplyLifecycle applies the rules of every bucket as of
+	// now and returns how many objects, versions and uploads were removed.
+	PutBucketLifecycle(bucket string, rules []LifecycleRule) error
+	GetBucketLifecycle(bucket string) ([]LifecycleRule, error)
+	ApplyLifecycle(now time.Time) (int, error)
 }
 
 type BucketInfo struct {
@@ -54,7 +118,109 @@ type ObjectMetadata struct {
 	ContentType  string
 	LastModified time.Time
 	ETag         string
-	Metadata     map[string]string
+	Metadata     map[string]string // User metadata (x-amz-meta-*), keys are lowercase
+
+	// Standard HTTP headers stored with the object and returned on GET/HEAD
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	Expires            string
+
+	VersionId    string // Empty for objects written while versioning was never enabled
+	DeleteMarker bool
+
+	// Additional checksum (x-amz-checksum-*), base64 encoded. Composite
+	// checksums of multipart objects are suffixed with the part count.
+	ChecksumAlgorithm string
+	Checksum          string
+	ChecksumType      string
+
+	// Part layout of objects created by a multipart upload
+	Parts []ObjectPart
+
+	Tags map[string]string // Object tags (x-amz-tagging)
+	ACL  []Grant           // Access control list, nil for the default private ACL
+}
+
+// Clone returns a deep copy of the metadata so callers cannot mutate the
+// stored object through shared maps
+func (m *ObjectMetadata) Clone() *ObjectMetadata {
+	if m == nil {
+		return nil
+	}
+	clone := *m
+	if m.Metadata != nil {
+		clone.Metadata = make(map[string]string, len(m.Metadata))
+		for k, v := range m.Metadata {
+			clone.Metadata[k] = v
+		}
+	}
+	if m.Parts != nil {
+		clone.Parts = append([]ObjectPart(nil), m.Parts...)
+	}
+	clone.Tags = cloneTags(m.Tags)
+	clone.ACL = cloneACL(m.ACL)
+	return &clone
+}
+
+// cloneTags returns a copy of a tag set, or nil when it is empty
+func cloneTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	clone := make(map[string]string, len(tags))
+	for k, v := range tags {
+		clone[k] = v
+	}
+	return clone
+}
+
+// copiedMetadata returns the metadata for a copy of the source object. The
+// copy keeps the source's data attributes and takes the rest of its metadata
+// from replacement when one is given. ETag is left empty when it has to be
+// recomputed from the data, since a copy is always a single part object.
+func copiedMetadata(source, replacement *ObjectMetadata) *ObjectMetadata {
+	meta := source.Clone()
+	meta.ACL = nil // ACLs are never copied
+	if replacement != nil {
+		meta = replacement.Clone()
+		meta.ChecksumAlgorithm = source.ChecksumAlgorithm
+		meta.Checksum = source.Checksum
+		meta.ChecksumType = source.ChecksumType
+	}
+
+	meta.Size = source.Size
+	meta.ETag = source.ETag
+	if len(source.Parts) > 0 {
+		meta.ETag = ""
+	}
+	if meta.ChecksumType == ChecksumTypeComposite {
+		// Part checksums do not describe a single part copy
+		meta.setChecksum("", "")
+	}
+	meta.Parts = nil
+	meta.VersionId = ""
+	meta.DeleteMarker = false
+	meta.LastModified = time.Now().UTC()
+
+	return meta
+}
+
+// ObjectPart describes one part of an object created by a multipart upload
+type ObjectPart struct {
+	PartNumber int
+	Size       int64
+	Checksum   string // Base64 checksum in the object's checksum algorithm
+}
+
+// CopySource identifies the object data copied into a part by UploadPartCopy
+type CopySource struct {
+	Bucket    string
+	Key       string
+	VersionId string // Empty for the latest version
+	Offset    int64
+	Length    int64 // Negative to copy up to the end of the object
 }
 
 type CompletedPart struct {
@@ -67,6 +233,16 @@ type PartInfo struct {
 	Size         int64
 	ETag         string
 	LastModified time.Time
+	Checksum     string // Base64 checksum in the upload's checksum algorithm
+}
+
+// MultipartUploadInfo describes an in-progress multipart upload
+type MultipartUploadInfo struct {
+	Key               string
+	UploadId          string
+	Initiated         time.Time
+	ChecksumAlgorithm string
+	ChecksumType      string
 }
 
 type MultipartUpload struct {
@@ -75,4 +251,5 @@ type MultipartUpload struct {
 	UploadId  string
 	Initiated time.Time
 	Parts     map[int]PartInfo
+	Metadata  *ObjectMetadata // Content type, headers, user metadata and checksum algorithm captured at initiate time
 }
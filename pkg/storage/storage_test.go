@@ -2,9 +2,18 @@ package storage
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMemoryStorage(t *testing.T) {
@@ -177,18 +186,21 @@ func TestMemoryStorage(t *testing.T) {
 			t.Fatalf("Failed to initiate multipart upload: %v", err)
 		}
 
-		// Upload parts
+		// Upload parts. All but the last must be at least MinPartSize.
 		parts := []struct {
 			partNumber int
 			content    []byte
 		}{
-			{1, []byte("Part 1 ")},
-			{2, []byte("Part 2 ")},
+			{1, bytes.Repeat([]byte("1"), MinPartSize)},
+			{2, bytes.Repeat([]byte("2"), MinPartSize)},
 			{3, []byte("Part 3")},
 		}
 
 		var completedParts []CompletedPart
+		partDigests := md5.New()
 		for _, part := range parts {
+			digest := md5.Sum(part.content)
+			partDigests.Write(digest[:])
 			etag, err := store.UploadPart(bucket, key, uploadID, part.partNumber,
 				bytes.NewReader(part.content), int64(len(part.content)))
 			if err != nil {
@@ -201,10 +213,14 @@ func TestMemoryStorage(t *testing.T) {
 		}
 
 		// Complete multipart upload
-		_, err = store.CompleteMultipartUpload(bucket, key, uploadID, completedParts)
+		etag, err := store.CompleteMultipartUpload(bucket, key, uploadID, completedParts)
 		if err != nil {
 			t.Fatalf("Failed to complete multipart upload: %v", err)
 		}
+		expectedETag := fmt.Sprintf("\"%s-3\"", hex.EncodeToString(partDigests.Sum(nil)))
+		if etag != expectedETag {
+			t.Errorf("Expected multipart ETag %s, got %s", expectedETag, etag)
+		}
 
 		// Verify the assembled object
 		reader, _, err := store.GetObject(bucket, key)
@@ -218,9 +234,12 @@ func TestMemoryStorage(t *testing.T) {
 			t.Fatalf("Failed to read multipart object: %v", err)
 		}
 
-		expected := "Part 1 Part 2 Part 3"
-		if string(data) != expected {
-			t.Errorf("Multipart content mismatch. Got %s, want %s", data, expected)
+		var expected []byte
+		for _, part := range parts {
+			expected = append(expected, part.content...)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Multipart content mismatch. Got %d bytes, want %d", len(data), len(expected))
 		}
 	})
 
@@ -359,6 +378,309 @@ func TestFileSystemStorage(t *testing.T) {
 			t.Errorf("Expected 2 common prefixes, got %d", len(prefixes))
 		}
 	})
+
+	t.Run("PutObjectWithMetadata", func(t *testing.T) {
+		bucket := "fs-test-bucket-4"
+		key := "meta/object.txt"
+		content := []byte("metadata")
+		_, _ = store.CreateBucket(bucket)
+
+		_, err := store.PutObjectWithMetadata(bucket, key, bytes.NewReader(content), int64(len(content)), &ObjectMetadata{
+			ContentType:        "text/plain",
+			CacheControl:       "max-age=60",
+			ContentDisposition: "attachment; filename=\"object.txt\"",
+			Metadata:           map[string]string{"owner": "s3pit"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to put object: %v", err)
+		}
+
+		// Metadata must survive a restart, so read it through a fresh instance
+		reopened, err := NewFileSystemStorage(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to reopen storage: %v", err)
+		}
+		meta, err := reopened.GetObjectMetadata(bucket, key)
+		if err != nil {
+			t.Fatalf("Failed to get object metadata: %v", err)
+		}
+
+		if meta.CacheControl != "max-age=60" {
+			t.Errorf("Cache-Control not preserved. Got %q", meta.CacheControl)
+		}
+		if meta.ContentDisposition != "attachment; filename=\"object.txt\"" {
+			t.Errorf("Content-Disposition not preserved. Got %q", meta.ContentDisposition)
+		}
+		if meta.Metadata["owner"] != "s3pit" {
+			t.Errorf("User metadata not preserved. Got %v", meta.Metadata)
+		}
+	})
+}
+
+func TestVerifyingReader(t *testing.T) {
+	content := []byte("streamed content")
+	md5Sum := md5.Sum(content)
+
+	t.Run("RejectedBodyKeepsPreviousObject", func(t *testing.T) {
+		store, err := NewFileSystemStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		_, _ = store.CreateBucket("bucket")
+		_, _ = store.PutObject("bucket", "key", bytes.NewReader(content), int64(len(content)), "text/plain")
+
+		body := NewVerifyingReader(strings.NewReader("tampered content"), 0)
+		body.ExpectMD5(md5Sum[:])
+		if _, err := store.PutObject("bucket", "key", body, 16, "text/plain"); !errors.Is(err, ErrBadDigest) {
+			t.Fatalf("Expected ErrBadDigest, got %v", err)
+		}
+
+		reader, _, err := store.GetObject("bucket", "key")
+		if err != nil {
+			t.Fatalf("Failed to get object: %v", err)
+		}
+		defer reader.Close()
+		data, _ := io.ReadAll(reader)
+		if !bytes.Equal(data, content) {
+			t.Errorf("Previous object was overwritten: %q", data)
+		}
+	})
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		body := NewVerifyingReader(bytes.NewReader(content), int64(len(content)-1))
+		if _, err := io.Copy(io.Discard, body); !errors.Is(err, ErrEntityTooLarge) {
+			t.Errorf("Expected ErrEntityTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("MatchingDigest", func(t *testing.T) {
+		body := NewVerifyingReader(bytes.NewReader(content), int64(len(content)))
+		body.ExpectMD5(md5Sum[:])
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+}
+
+func TestChecksums(t *testing.T) {
+	t.Run("CheckValues", func(t *testing.T) {
+		tests := map[string]string{
+			ChecksumCRC32:     "cbf43926",
+			ChecksumCRC32C:    "e3069283",
+			ChecksumCRC64NVME: "ae8b14860a799888",
+		}
+		for algorithm, want := range tests {
+			h := NewChecksumHash(algorithm)
+			h.Write([]byte("123456789"))
+			if got := hex.EncodeToString(h.Sum(nil)); got != want {
+				t.Errorf("%s: expected %s, got %s", algorithm, want, got)
+			}
+		}
+	})
+
+	checksumOf := func(algorithm string, data ...string) string {
+		h := NewChecksumHash(algorithm)
+		for _, d := range data {
+			h.Write([]byte(d))
+		}
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			_, _ = store.CreateBucket("bucket")
+
+			body := NewVerifyingReader(strings.NewReader("checksummed"), 0)
+			body.ExpectChecksum(ChecksumSHA256, func() string { return checksumOf(ChecksumSHA256, "checksummed") })
+			if _, err := store.PutObjectWithMetadata("bucket", "object", body, 11, &ObjectMetadata{}); err != nil {
+				t.Fatalf("Failed to put object: %v", err)
+			}
+			meta, _ := store.GetObjectMetadata("bucket", "object")
+			if meta.ChecksumAlgorithm != ChecksumSHA256 || meta.Checksum != checksumOf(ChecksumSHA256, "checksummed") ||
+				meta.ChecksumType != ChecksumTypeFullObject {
+				t.Errorf("Unexpected stored checksum %s %s %s", meta.ChecksumAlgorithm, meta.Checksum, meta.ChecksumType)
+			}
+
+			body = NewVerifyingReader(strings.NewReader("tampered"), 0)
+			body.ExpectChecksum(ChecksumCRC32, func() string { return checksumOf(ChecksumCRC32, "original") })
+			if _, err := store.PutObjectWithMetadata("bucket", "bad", body, 8, &ObjectMetadata{}); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+			}
+
+			partOne := "part one " + strings.Repeat("x", MinPartSize)
+			for _, checksumType := range []string{ChecksumTypeComposite, ChecksumTypeFullObject} {
+				uploadId, _ := store.InitiateMultipartUploadWithMetadata("bucket", checksumType, &ObjectMetadata{
+					ChecksumAlgorithm: ChecksumCRC32C,
+					ChecksumType:      checksumType,
+				})
+				etag1, _ := store.UploadPart("bucket", checksumType, uploadId, 1, strings.NewReader(partOne), int64(len(partOne)))
+				etag2, _ := store.UploadPart("bucket", checksumType, uploadId, 2, strings.NewReader("part two"), 8)
+				completed := []CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}}
+				if _, err := store.CompleteMultipartUpload("bucket", checksumType, uploadId, completed); err != nil {
+					t.Fatalf("Failed to complete upload: %v", err)
+				}
+
+				want := checksumOf(ChecksumCRC32C, partOne, "part two")
+				if checksumType == ChecksumTypeComposite {
+					part1, _ := base64.StdEncoding.DecodeString(checksumOf(ChecksumCRC32C, partOne))
+					part2, _ := base64.StdEncoding.DecodeString(checksumOf(ChecksumCRC32C, "part two"))
+					want = checksumOf(ChecksumCRC32C, string(part1), string(part2)) + "-2"
+				}
+
+				meta, _ := store.GetObjectMetadata("bucket", checksumType)
+				if meta.Checksum != want || meta.ChecksumType != checksumType {
+					t.Errorf("%s: expected checksum %s, got %s (%s)", checksumType, want, meta.Checksum, meta.ChecksumType)
+				}
+				wantParts := []ObjectPart{
+					{PartNumber: 1, Size: int64(len(partOne)), Checksum: checksumOf(ChecksumCRC32C, partOne)},
+					{PartNumber: 2, Size: 8, Checksum: checksumOf(ChecksumCRC32C, "part two")},
+				}
+				if !reflect.DeepEqual(meta.Parts, wantParts) {
+					t.Errorf("%s: expected parts %+v, got %+v", checksumType, wantParts, meta.Parts)
+				}
+			}
+		})
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			bucket := "versioned-bucket"
+			key := "dir/object.txt"
+			_, _ = store.CreateBucket(bucket)
+
+			put := func(content string) {
+				t.Helper()
+				if _, err := store.PutObject(bucket, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+					t.Fatalf("Failed to put object: %v", err)
+				}
+			}
+			read := func(versionId string) string {
+				t.Helper()
+				reader, _, err := store.GetObjectVersion(bucket, key, versionId)
+				if err != nil {
+					t.Fatalf("Failed to get version %q: %v", versionId, err)
+				}
+				defer reader.Close()
+				data, _ := io.ReadAll(reader)
+				return string(data)
+			}
+
+			// Written before versioning, becomes the null version
+			put("v0")
+
+			if err := store.PutBucketVersioning(bucket, VersioningEnabled); err != nil {
+				t.Fatalf("Failed to enable versioning: %v", err)
+			}
+			status, _ := store.GetBucketVersioning(bucket)
+			if status != VersioningEnabled {
+				t.Errorf("Expected status %q, got %q", VersioningEnabled, status)
+			}
+
+			put("v1")
+			put("v2")
+
+			versions, err := store.ListObjectVersions(bucket, "")
+			if err != nil {
+				t.Fatalf("Failed to list versions: %v", err)
+			}
+			if len(versions) != 3 {
+				t.Fatalf("Expected 3 versions, got %d", len(versions))
+			}
+			if !versions[0].IsLatest || versions[2].VersionId != NullVersionId {
+				t.Errorf("Unexpected version order: %+v", versions)
+			}
+			if got := read(versions[1].VersionId); got != "v1" {
+				t.Errorf("Expected v1, got %q", got)
+			}
+			if got := read(NullVersionId); got != "v0" {
+				t.Errorf("Expected v0 for the null version, got %q", got)
+			}
+
+			// Current listings only show the latest version
+			objects, _, _, _ := store.ListObjects(bucket, "", "", 1000, "")
+			if len(objects) != 1 || objects[0].Key != key {
+				t.Errorf("Expected only the current object, got %+v", objects)
+			}
+
+			// A plain delete hides the object behind a delete marker
+			if err := store.DeleteObject(bucket, key); err != nil {
+				t.Fatalf("Failed to delete object: %v", err)
+			}
+			if _, _, err := store.GetObject(bucket, key); err != ErrObjectNotFound {
+				t.Errorf("Expected ErrObjectNotFound, got %v", err)
+			}
+			marker, err := store.GetObjectVersionMetadata(bucket, key, "")
+			if err != ErrDeleteMarker || marker == nil || !marker.DeleteMarker {
+				t.Fatalf("Expected the latest version to be a delete marker, got %v", err)
+			}
+			if err := store.DeleteBucket(bucket); err != ErrBucketNotEmpty {
+				t.Errorf("Expected ErrBucketNotEmpty while versions remain, got %v", err)
+			}
+
+			// Removing the marker restores the previous version
+			if err := store.DeleteObjectVersion(bucket, key, marker.VersionId); err != nil {
+				t.Fatalf("Failed to delete marker: %v", err)
+			}
+			if got := read(""); got != "v2" {
+				t.Errorf("Expected v2 after removing the delete marker, got %q", got)
+			}
+
+			// Removing the current version promotes the next newest one
+			if err := store.DeleteObjectVersion(bucket, key, versions[0].VersionId); err != nil {
+				t.Fatalf("Failed to delete version: %v", err)
+			}
+			if got := read(""); got != "v1" {
+				t.Errorf("Expected v1 after deleting v2, got %q", got)
+			}
+			if err := store.DeleteObjectVersion(bucket, key, "missing"); err != ErrVersionNotFound {
+				t.Errorf("Expected ErrVersionNotFound, got %v", err)
+			}
+
+			// Suspended buckets overwrite the null version in place
+			if err := store.PutBucketVersioning(bucket, VersioningSuspended); err != nil {
+				t.Fatalf("Failed to suspend versioning: %v", err)
+			}
+			put("s1")
+			put("s2")
+			versions, _ = store.ListObjectVersions(bucket, "")
+			if len(versions) != 2 || versions[0].VersionId != NullVersionId || !versions[0].IsLatest {
+				t.Errorf("Expected the null version on top of v1, got %+v", versions)
+			}
+			if got := read(NullVersionId); got != "s2" {
+				t.Errorf("Expected s2 for the null version, got %q", got)
+			}
+
+			if err := store.PutBucketVersioning(bucket, "Disabled"); err == nil {
+				t.Error("Expected an error for an invalid versioning status")
+			}
+		})
+	}
 }
 
 func TestObjectMetadata(t *testing.T) {
@@ -424,4 +746,1094 @@ func TestPagination(t *testing.T) {
 			t.Errorf("Expected 3 objects in second page, got %d", len(contents2))
 		}
 	})
+
+	t.Run("CommonPrefixesCountTowardMaxKeys", func(t *testing.T) {
+		for _, key := range []string{"a/1.txt", "a/2.txt", "b/1.txt", "c.txt"} {
+			_, _ = store.PutObject(bucket, key, bytes.NewReader([]byte("test")), 4, "text/plain")
+		}
+
+		var pages [][]string
+		token := ""
+		for {
+			contents, prefixes, nextToken, err := store.ListObjects(bucket, "", "/", 2, token)
+			if err != nil {
+				t.Fatalf("Failed to list objects: %v", err)
+			}
+			var page []string
+			page = append(page, prefixes...)
+			for _, obj := range contents {
+				page = append(page, obj.Key)
+			}
+			if len(page) > 2 {
+				t.Fatalf("Page exceeds max-keys: %v", page)
+			}
+			pages = append(pages, page)
+			if nextToken == "" {
+				break
+			}
+			token = nextToken
+		}
+
+		// a/, b/, c.txt and the ten object-NN.txt keys
+		total := 0
+		for _, page := range pages {
+			total += len(page)
+		}
+		if total != 13 {
+			t.Errorf("Expected 13 entries across pages, got %d: %v", total, pages)
+		}
+		if pages[0][0] != "a/" || pages[0][1] != "b/" {
+			t.Errorf("Expected first page to hold the common prefixes, got %v", pages[0])
+		}
+	})
+}
+
+func TestSlowUploadDoesNotBlockReaders(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			_, _ = store.CreateBucket("bucket")
+			_, _ = store.PutObject("bucket", "existing.txt", strings.NewReader("existing"), 8, "text/plain")
+
+			// The upload stays in progress until the pipe is closed
+			pr, pw := io.Pipe()
+			uploaded := make(chan error, 1)
+			go func() {
+				_, err := store.PutObject("bucket", "slow.txt", pr, -1, "text/plain")
+				uploaded <- err
+			}()
+			_, _ = pw.Write([]byte("first half, "))
+
+			read := make(chan error, 1)
+			go func() {
+				if _, err := store.GetObjectMetadata("bucket", "existing.txt"); err != nil {
+					read <- err
+					return
+				}
+				objects, _, _, err := store.ListObjects("bucket", "", "", 1000, "")
+				if err == nil && len(objects) != 1 {
+					err = fmt.Errorf("expected only the existing object to be listed, got %d", len(objects))
+				}
+				read <- err
+			}()
+			select {
+			case err := <-read:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Expected reads to proceed during an upload")
+			}
+
+			_, _ = pw.Write([]byte("second half"))
+			pw.Close()
+			if err := <-uploaded; err != nil {
+				t.Fatalf("PutObject failed: %v", err)
+			}
+			reader, _, err := store.GetObject("bucket", "slow.txt")
+			if err != nil {
+				t.Fatalf("GetObject failed: %v", err)
+			}
+			defer reader.Close()
+			if data, _ := io.ReadAll(reader); string(data) != "first half, second half" {
+				t.Errorf("Unexpected content %q", data)
+			}
+		})
+	}
+}
+
+func TestAbortStaleMultipartUploads(t *testing.T) {
+	backends := map[string]func(t *testing.T) (Storage, string){
+		"Memory": func(t *testing.T) (Storage, string) { return NewMemoryStorage(), "" },
+		"FileSystem": func(t *testing.T) (Storage, string) {
+			dir := t.TempDir()
+			store, err := NewFileSystemStorage(dir)
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store, dir
+		},
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store, dir := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+
+			uploadId, _ := store.InitiateMultipartUpload("bucket", "stale.bin")
+			_, _ = store.UploadPart("bucket", "stale.bin", uploadId, 1, strings.NewReader("part"), 4)
+
+			aborted, err := store.AbortStaleMultipartUploads(time.Now().Add(-time.Hour))
+			if err != nil || aborted != 0 {
+				t.Fatalf("Expected no stale uploads, got %d (%v)", aborted, err)
+			}
+
+			aborted, err = store.AbortStaleMultipartUploads(time.Now().Add(time.Second))
+			if err != nil || aborted != 1 {
+				t.Fatalf("Expected one aborted upload, got %d (%v)", aborted, err)
+			}
+			uploads, _ := store.ListMultipartUploads("bucket", "")
+			if len(uploads) != 0 {
+				t.Errorf("Expected no uploads after reaping, got %d", len(uploads))
+			}
+			if _, err := store.ListParts("bucket", "stale.bin", uploadId); err == nil {
+				t.Errorf("Expected the aborted upload to be gone")
+			}
+
+			if dir == "" {
+				return
+			}
+			// Part directories left behind by an earlier run are removed too
+			orphan := filepath.Join(dir, ".s3pit_uploads", "upload-orphan")
+			if err := os.MkdirAll(orphan, 0755); err != nil {
+				t.Fatalf("Failed to create orphaned upload: %v", err)
+			}
+			old := time.Now().Add(-2 * time.Hour)
+			_ = os.Chtimes(orphan, old, old)
+
+			aborted, err = store.AbortStaleMultipartUploads(time.Now().Add(-time.Hour))
+			if err != nil || aborted != 1 {
+				t.Fatalf("Expected the orphaned directory to be removed, got %d (%v)", aborted, err)
+			}
+			if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be removed", orphan)
+			}
+		})
+	}
+}
+
+func TestCompleteMultipartUploadWhileUploadingParts(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	first := bytes.Repeat([]byte("a"), MinPartSize)
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+
+			for i := 0; i < 10; i++ {
+				uploadId, _ := store.InitiateMultipartUpload("bucket", "object.bin")
+				etag1, err := store.UploadPart("bucket", "object.bin", uploadId, 1, bytes.NewReader(first), int64(len(first)))
+				if err != nil {
+					t.Fatalf("Failed to upload part 1: %v", err)
+				}
+				etag2, err := store.UploadPart("bucket", "object.bin", uploadId, 2, strings.NewReader("original"), 8)
+				if err != nil {
+					t.Fatalf("Failed to upload part 2: %v", err)
+				}
+
+				// Part 2 is uploaded again while the upload is completed
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					for j := 0; j < 5; j++ {
+						_, _ = store.UploadPart("bucket", "object.bin", uploadId, 2, strings.NewReader("replaced"), 8)
+					}
+				}()
+				etag, err := store.CompleteMultipartUpload("bucket", "object.bin", uploadId, []CompletedPart{
+					{PartNumber: 1, ETag: etag1},
+					{PartNumber: 2, ETag: etag2},
+				})
+				<-done
+				if err != nil {
+					// The part was replaced before the upload was completed
+					continue
+				}
+
+				reader, meta, err := store.GetObject("bucket", "object.bin")
+				if err != nil {
+					t.Fatalf("Failed to get completed object: %v", err)
+				}
+				data, _ := io.ReadAll(reader)
+				reader.Close()
+				if want := string(first) + "original"; string(data) != want {
+					t.Fatalf("Completed object holds %q, want the validated parts", data[len(first):])
+				}
+				if meta.ETag != etag {
+					t.Errorf("Expected ETag %s, got %s", etag, meta.ETag)
+				}
+			}
+		})
+	}
+}
+
+func TestLeftoverCopyIsHidden(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSystemStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create filesystem storage: %v", err)
+	}
+	_, _ = store.CreateBucket("bucket")
+	_, _ = store.PutObject("bucket", "a.txt", strings.NewReader("data"), 4, "text/plain")
+
+	// A copy interrupted before its clone was renamed into place
+	if _, err := cloneFile(filepath.Join(dir, "bucket", "a.txt"), filepath.Join(dir, "bucket")); err != nil {
+		t.Fatalf("Failed to clone object: %v", err)
+	}
+
+	objects, _, _, err := store.ListObjects("bucket", "", "", 1000, "")
+	if err != nil {
+		t.Fatalf("Failed to list objects: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != "a.txt" {
+		t.Fatalf("Expected only a.txt to be listed, got %+v", objects)
+	}
+
+	if err := store.DeleteObject("bucket", "a.txt"); err != nil {
+		t.Fatalf("Failed to delete object: %v", err)
+	}
+	if err := store.DeleteBucket("bucket"); err != nil {
+		t.Errorf("Expected the bucket to be deletable, got %v", err)
+	}
+}
+
+func TestMetadataWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSystemStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create filesystem storage: %v", err)
+	}
+	_, _ = store.CreateBucket("bucket")
+	_, _ = store.PutObject("bucket", "src.txt", strings.NewReader("data"), 4, "text/plain")
+
+	// A directory in place of the sidecar makes writing it fail
+	for _, key := range []string{"put.txt", "copy.txt"} {
+		if err := os.MkdirAll(metadataPath(filepath.Join(dir, "bucket", key)), 0755); err != nil {
+			t.Fatalf("Failed to block sidecar: %v", err)
+		}
+	}
+
+	if _, err := store.PutObject("bucket", "put.txt", strings.NewReader("data"), 4, "text/plain"); err == nil {
+		t.Errorf("Expected PutObject to fail when its metadata cannot be written")
+	}
+	if _, err := store.CopyObject("bucket", "src.txt", "bucket", "copy.txt"); err == nil {
+		t.Errorf("Expected CopyObject to fail when its metadata cannot be written")
+	}
+}
+
+func TestCopyObjectVersion(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	readObject := func(t *testing.T, store Storage, key string) string {
+		t.Helper()
+		reader, _, err := store.GetObject("bucket", key)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", key, err)
+		}
+		defer reader.Close()
+		data, _ := io.ReadAll(reader)
+		return string(data)
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+			_ = store.PutBucketVersioning("bucket", VersioningEnabled)
+
+			_, _ = store.PutObjectWithMetadata("bucket", "source.txt", strings.NewReader("first"), 5, &ObjectMetadata{
+				ContentType: "text/plain",
+				Metadata:    map[string]string{"owner": "me"},
+			})
+			first, _ := store.GetObjectMetadata("bucket", "source.txt")
+			_, _ = store.PutObject("bucket", "source.txt", strings.NewReader("second"), 6, "text/plain")
+
+			meta, err := store.CopyObjectVersion("bucket", "source.txt", first.VersionId, "bucket", "copy.txt", nil)
+			if err != nil {
+				t.Fatalf("CopyObjectVersion failed: %v", err)
+			}
+			if meta.ETag != first.ETag || meta.Metadata["owner"] != "me" || meta.VersionId == "" || meta.VersionId == first.VersionId {
+				t.Errorf("Unexpected copy metadata: %+v", meta)
+			}
+			if got := readObject(t, store, "copy.txt"); got != "first" {
+				t.Errorf("Expected the copied version, got %q", got)
+			}
+
+			replaced, err := store.CopyObjectVersion("bucket", "source.txt", "", "bucket", "replaced.txt", &ObjectMetadata{ContentType: "application/json"})
+			if err != nil {
+				t.Fatalf("CopyObjectVersion failed: %v", err)
+			}
+			if replaced.ContentType != "application/json" || replaced.Metadata != nil || replaced.Size != 6 {
+				t.Errorf("Expected replaced metadata, got %+v", replaced)
+			}
+
+			// Later writes to the source never show through the copy
+			_, _ = store.PutObject("bucket", "source.txt", strings.NewReader("third"), 5, "text/plain")
+			uploadId, _ := store.InitiateMultipartUpload("bucket", "replaced.txt")
+			etag, _ := store.UploadPart("bucket", "replaced.txt", uploadId, 1, strings.NewReader("fourth"), 6)
+			if _, err := store.CompleteMultipartUpload("bucket", "replaced.txt", uploadId, []CompletedPart{{PartNumber: 1, ETag: etag}}); err != nil {
+				t.Fatalf("CompleteMultipartUpload failed: %v", err)
+			}
+			if got := readObject(t, store, "copy.txt"); got != "first" {
+				t.Errorf("Expected the copy to be unchanged, got %q", got)
+			}
+			versions, _ := store.ListObjectVersions("bucket", "replaced.txt")
+			if len(versions) != 2 {
+				t.Fatalf("Expected 2 versions of replaced.txt, got %d", len(versions))
+			}
+			reader, _, err := store.GetObjectVersion("bucket", "replaced.txt", versions[1].VersionId)
+			if err != nil {
+				t.Fatalf("Failed to get archived version: %v", err)
+			}
+			data, _ := io.ReadAll(reader)
+			reader.Close()
+			if string(data) != "second" {
+				t.Errorf("Expected the archived copy to be unchanged, got %q", data)
+			}
+
+			// Nor do writes to the copy show through the source, without
+			// versioning to move the previous data out of the way
+			_, _ = store.CreateBucket("plain")
+			if _, err := store.CopyObjectVersion("bucket", "source.txt", "", "plain", "copy.txt", nil); err != nil {
+				t.Fatalf("CopyObjectVersion failed: %v", err)
+			}
+			uploadId, _ = store.InitiateMultipartUpload("plain", "copy.txt")
+			etag, _ = store.UploadPart("plain", "copy.txt", uploadId, 1, strings.NewReader("fifth"), 5)
+			if _, err := store.CompleteMultipartUpload("plain", "copy.txt", uploadId, []CompletedPart{{PartNumber: 1, ETag: etag}}); err != nil {
+				t.Fatalf("CompleteMultipartUpload failed: %v", err)
+			}
+			if got := readObject(t, store, "source.txt"); got != "third" {
+				t.Errorf("Expected the source to be unchanged, got %q", got)
+			}
+
+			if _, err := store.CopyObjectVersion("bucket", "missing.txt", "", "bucket", "copy.txt", nil); err != ErrObjectNotFound {
+				t.Errorf("Expected ErrObjectNotFound, got %v", err)
+			}
+			if _, err := store.CopyObjectVersion("bucket", "source.txt", "", "missing-bucket", "copy.txt", nil); err != ErrBucketNotFound {
+				t.Errorf("Expected ErrBucketNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTagging(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+			_ = store.PutBucketVersioning("bucket", VersioningEnabled)
+
+			_, _ = store.PutObjectWithMetadata("bucket", "key", strings.NewReader("v1"), 2, &ObjectMetadata{
+				Tags: map[string]string{"version": "1"},
+			})
+			first, _ := store.GetObjectMetadata("bucket", "key")
+			_, _ = store.PutObject("bucket", "key", strings.NewReader("v2"), 2, "text/plain")
+			before, _ := store.GetObjectMetadata("bucket", "key")
+
+			if err := store.PutObjectTagging("bucket", "key", "", map[string]string{"version": "2"}); err != nil {
+				t.Fatalf("PutObjectTagging failed: %v", err)
+			}
+			current, _ := store.GetObjectMetadata("bucket", "key")
+			if current.Tags["version"] != "2" {
+				t.Errorf("Expected the current version to be tagged, got %v", current.Tags)
+			}
+			old, _ := store.GetObjectVersionMetadata("bucket", "key", first.VersionId)
+			if old.Tags["version"] != "1" {
+				t.Errorf("Expected the noncurrent version to keep its tags, got %v", old.Tags)
+			}
+			if !current.LastModified.Equal(before.LastModified) || current.VersionId != before.VersionId {
+				t.Errorf("Tagging must not change the object: %+v", current)
+			}
+
+			if err := store.PutObjectTagging("bucket", "key", first.VersionId, nil); err != nil {
+				t.Fatalf("PutObjectTagging failed: %v", err)
+			}
+			if old, _ := store.GetObjectVersionMetadata("bucket", "key", first.VersionId); len(old.Tags) != 0 {
+				t.Errorf("Expected the version's tags to be removed, got %v", old.Tags)
+			}
+
+			if err := store.PutObjectTagging("bucket", "missing", "", nil); err != ErrObjectNotFound {
+				t.Errorf("Expected ErrObjectNotFound, got %v", err)
+			}
+
+			if err := store.PutBucketTagging("bucket", map[string]string{"team": "storage"}); err != nil {
+				t.Fatalf("PutBucketTagging failed: %v", err)
+			}
+			tags, err := store.GetBucketTagging("bucket")
+			if err != nil || tags["team"] != "storage" {
+				t.Errorf("Unexpected bucket tags %v (%v)", tags, err)
+			}
+			if status, _ := store.GetBucketVersioning("bucket"); status != VersioningEnabled {
+				t.Errorf("Bucket tagging must keep the versioning state, got %q", status)
+			}
+			if _, err := store.GetBucketTagging("missing"); err != ErrBucketNotFound {
+				t.Errorf("Expected ErrBucketNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUploadPartCopy(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+			_, _ = store.PutObject("bucket", "source.txt", strings.NewReader("0123456789"), 10, "text/plain")
+
+			uploadId, _ := store.InitiateMultipartUpload("bucket", "dest.txt")
+			etag, err := store.UploadPartCopy("bucket", "dest.txt", uploadId, 1, CopySource{
+				Bucket: "bucket",
+				Key:    "source.txt",
+				Offset: 2,
+				Length: 5,
+			})
+			if err != nil {
+				t.Fatalf("UploadPartCopy failed: %v", err)
+			}
+			if etag != CalculateETag([]byte("23456")) {
+				t.Errorf("Expected the ETag of the copied range, got %s", etag)
+			}
+
+			parts, _ := store.ListParts("bucket", "dest.txt", uploadId)
+			if len(parts) != 1 || parts[0].Size != 5 {
+				t.Errorf("Unexpected parts: %+v", parts)
+			}
+
+			_, err = store.UploadPartCopy("bucket", "dest.txt", uploadId, 2, CopySource{
+				Bucket: "bucket",
+				Key:    "source.txt",
+				Offset: 8,
+				Length: 5,
+			})
+			if err != ErrInvalidRange {
+				t.Errorf("Expected ErrInvalidRange, got %v", err)
+			}
+
+			_, err = store.UploadPartCopy("bucket", "dest.txt", uploadId, 2, CopySource{
+				Bucket: "bucket",
+				Key:    "missing.txt",
+				Length: -1,
+			})
+			if err != ErrObjectNotFound {
+				t.Errorf("Expected ErrObjectNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMultipartUploadSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSystemStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create filesystem storage: %v", err)
+	}
+	_, _ = store.CreateBucket("bucket")
+
+	key := "nested/resumable.bin"
+	uploadId, err := store.InitiateMultipartUploadWithMetadata("bucket", key, &ObjectMetadata{
+		ContentType:       "application/x-resumable",
+		Metadata:          map[string]string{"origin": "before-restart"},
+		ChecksumAlgorithm: ChecksumCRC32,
+		ChecksumType:      ChecksumTypeComposite,
+	})
+	if err != nil {
+		t.Fatalf("Failed to initiate upload: %v", err)
+	}
+	first := strings.Repeat("1", MinPartSize)
+	etag1, err := store.UploadPart("bucket", key, uploadId, 1, strings.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatalf("Failed to upload part: %v", err)
+	}
+
+	// A directory without a manifest is not resumed
+	_ = os.MkdirAll(filepath.Join(dir, ".s3pit_uploads", "upload-unknown"), 0755)
+
+	reopened, err := NewFileSystemStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen filesystem storage: %v", err)
+	}
+
+	uploads, _ := reopened.ListMultipartUploads("bucket", "")
+	if len(uploads) != 1 || uploads[0].UploadId != uploadId || uploads[0].Key != key ||
+		uploads[0].ChecksumAlgorithm != ChecksumCRC32 {
+		t.Fatalf("Expected the upload to be resumed, got %+v", uploads)
+	}
+
+	parts, err := reopened.ListParts("bucket", key, uploadId)
+	if err != nil || len(parts) != 1 || parts[0].ETag != etag1 || parts[0].Size != int64(len(first)) || parts[0].Checksum == "" {
+		t.Fatalf("Expected the uploaded part to be resumed, got %+v (%v)", parts, err)
+	}
+
+	etag2, err := reopened.UploadPart("bucket", key, uploadId, 2, strings.NewReader("after restart"), 13)
+	if err != nil {
+		t.Fatalf("Failed to upload part after restart: %v", err)
+	}
+	if _, err := reopened.CompleteMultipartUpload("bucket", key, uploadId,
+		[]CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}}); err != nil {
+		t.Fatalf("Failed to complete upload after restart: %v", err)
+	}
+
+	reader, meta, err := reopened.GetObject("bucket", key)
+	if err != nil {
+		t.Fatalf("Failed to get object: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != first+"after restart" {
+		t.Errorf("Unexpected object content of %d bytes", len(data))
+	}
+	if meta.ContentType != "application/x-resumable" || meta.Metadata["origin"] != "before-restart" ||
+		!strings.HasSuffix(meta.Checksum, "-2") {
+		t.Errorf("Expected metadata captured at initiate time, got %+v", meta)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".s3pit_uploads", uploadId)); !os.IsNotExist(err) {
+		t.Errorf("Expected the upload directory to be removed after completion")
+	}
+}
+
+func TestBucketPolicyPersistence(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	policy := []byte(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`)
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+			_ = store.PutBucketTagging("bucket", map[string]string{"team": "storage"})
+
+			if data, err := store.GetBucketPolicy("bucket"); err != nil || data != nil {
+				t.Errorf("Expected no policy, got %s (%v)", data, err)
+			}
+
+			if err := store.PutBucketPolicy("bucket", policy); err != nil {
+				t.Fatalf("PutBucketPolicy failed: %v", err)
+			}
+			data, err := store.GetBucketPolicy("bucket")
+			if err != nil {
+				t.Fatalf("GetBucketPolicy failed: %v", err)
+			}
+			parsed, err := ParseBucketPolicy(data)
+			if err != nil || !parsed.IsPublicReadable("bucket", "key") {
+				t.Errorf("Expected the stored policy to round-trip, got %s (%v)", data, err)
+			}
+			if tags, _ := store.GetBucketTagging("bucket"); tags["team"] != "storage" {
+				t.Errorf("Bucket policy must keep the bucket tags, got %v", tags)
+			}
+
+			if err := store.PutBucketPolicy("bucket", []byte("not json")); !errors.Is(err, ErrPolicyParseFailed) {
+				t.Errorf("Expected ErrPolicyParseFailed, got %v", err)
+			}
+
+			if err := store.PutBucketPolicy("bucket", nil); err != nil {
+				t.Fatalf("Deleting the policy failed: %v", err)
+			}
+			if data, _ := store.GetBucketPolicy("bucket"); data != nil {
+				t.Errorf("Expected the policy to be removed, got %s", data)
+			}
+
+			if err := store.PutBucketPolicy("missing", policy); err != ErrBucketNotFound {
+				t.Errorf("Expected ErrBucketNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBucketPolicyEvaluate(t *testing.T) {
+	policy, err := ParseBucketPolicy([]byte(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Effect": "Allow",
+				"Principal": "*",
+				"Action": ["s3:GetObject", "s3:ListBucket"],
+				"Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"],
+				"Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}
+			},
+			{
+				"Effect": "Allow",
+				"Principal": {"AWS": ["arn:aws:iam::123456789012:user/writer"]},
+				"Action": "s3:Put*",
+				"Resource": "arn:aws:s3:::bucket/uploads/*"
+			},
+			{
+				"Effect": "Deny",
+				"Principal": "*",
+				"Action": "s3:ListBucket",
+				"Resource": "arn:aws:s3:::bucket",
+				"Condition": {"StringNotLike": {"s3:prefix": ["public/*", ""]}}
+			},
+			{
+				"Effect": "Deny",
+				"Principal": "*",
+				"Action": "s3:*",
+				"Resource": "arn:aws:s3:::bucket/secret/*"
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseBucketPolicy failed: %v", err)
+	}
+	if err := policy.Validate("bucket"); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	inside := map[string]string{"aws:SourceIp": "10.1.2.3"}
+	tests := []struct {
+		name string
+		req  PolicyRequest
+		want PolicyDecision
+	}{
+		{"GetObjectFromAllowedNetwork", PolicyRequest{Action: "s3:GetObject", Bucket: "bucket", Key: "a.txt", Context: inside}, PolicyAllow},
+		{"GetObjectFromOtherNetwork", PolicyRequest{Action: "s3:GetObject", Bucket: "bucket", Key: "a.txt", Context: map[string]string{"aws:SourceIp": "192.0.2.1"}}, PolicyNoMatch},
+		{"GetObjectWithoutSourceIp", PolicyRequest{Action: "s3:GetObject", Bucket: "bucket", Key: "a.txt"}, PolicyNoMatch},
+		{"OtherBucket", PolicyRequest{Action: "s3:GetObject", Bucket: "other", Key: "a.txt", Context: inside}, PolicyNoMatch},
+		{"ListPublicPrefix", PolicyRequest{Action: "s3:ListBucket", Bucket: "bucket", Context: map[string]string{"aws:SourceIp": "10.1.2.3", "s3:prefix": "public/docs"}}, PolicyAllow},
+		{"ListOtherPrefix", PolicyRequest{Action: "s3:ListBucket", Bucket: "bucket", Context: map[string]string{"aws:SourceIp": "10.1.2.3", "s3:prefix": "private/"}}, PolicyDeny},
+		{"DenyWinsOverAllow", PolicyRequest{Action: "s3:GetObject", Bucket: "bucket", Key: "secret/a.txt", Context: inside}, PolicyDeny},
+		{"PrincipalWildcardAction", PolicyRequest{Principal: "writer", Action: "s3:PutObject", Bucket: "bucket", Key: "uploads/a.txt"}, PolicyAllow},
+		{"PrincipalOutsideResource", PolicyRequest{Principal: "writer", Action: "s3:PutObject", Bucket: "bucket", Key: "a.txt"}, PolicyNoMatch},
+		{"AnonymousPut", PolicyRequest{Action: "s3:PutObject", Bucket: "bucket", Key: "uploads/a.txt"}, PolicyNoMatch},
+		{"OtherPrincipal", PolicyRequest{Principal: "reader", Action: "s3:PutObject", Bucket: "bucket", Key: "uploads/a.txt"}, PolicyNoMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Evaluate(tt.req); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if policy.IsPublic() {
+		t.Error("A policy whose public grants are conditional must not be public")
+	}
+	if !DefaultPublicReadPolicy("bucket").IsPublic() {
+		t.Error("The default public read policy must be public")
+	}
+}
+
+func TestBucketPolicyValidate(t *testing.T) {
+	tests := map[string]string{
+		"NoStatement":     `{"Version":"2012-10-17","Statement":[]}`,
+		"BadEffect":       `{"Statement":[{"Effect":"Maybe","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`,
+		"NoPrincipal":     `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`,
+		"BadAction":       `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"ec2:RunInstances","Resource":"arn:aws:s3:::bucket/*"}]}`,
+		"OtherBucket":     `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::other/*"}]}`,
+		"BadCondition":    `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*","Condition":{"DateGreaterThan":{"aws:CurrentTime":"2020-01-01T00:00:00Z"}}}]}`,
+		"BucketPrefixArn": `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket-other/*"}]}`,
+	}
+	for name, document := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy, err := ParseBucketPolicy([]byte(document))
+			if err != nil {
+				t.Fatalf("ParseBucketPolicy failed: %v", err)
+			}
+			if err := policy.Validate("bucket"); err == nil {
+				t.Error("Expected the policy to be rejected")
+			}
+		})
+	}
+}
+
+func TestACL(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	publicRead, _ := CannedACL("public-read", "owner")
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+
+			_, _ = store.PutObjectWithMetadata("bucket", "public", strings.NewReader("data"), 4, &ObjectMetadata{ACL: publicRead})
+			meta, _ := store.GetObjectMetadata("bucket", "public")
+			if !GrantsAllUsers(meta.ACL, PermissionRead) || GrantsAllUsers(meta.ACL, PermissionWrite) {
+				t.Errorf("Expected a public-read ACL, got %+v", meta.ACL)
+			}
+
+			copied, err := store.CopyObjectVersion("bucket", "public", "", "bucket", "copy", nil)
+			if err != nil {
+				t.Fatalf("CopyObjectVersion failed: %v", err)
+			}
+			if len(copied.ACL) != 0 {
+				t.Errorf("Expected the ACL not to be copied, got %+v", copied.ACL)
+			}
+
+			if err := store.PutObjectACL("bucket", "public", "", nil); err != nil {
+				t.Fatalf("PutObjectACL failed: %v", err)
+			}
+			if meta, _ := store.GetObjectMetadata("bucket", "public"); len(meta.ACL) != 0 {
+				t.Errorf("Expected the ACL to be reset, got %+v", meta.ACL)
+			}
+			if err := store.PutObjectACL("bucket", "missing", "", publicRead); err != ErrObjectNotFound {
+				t.Errorf("Expected ErrObjectNotFound, got %v", err)
+			}
+
+			if acl, err := store.GetBucketACL("bucket"); err != nil || len(acl) != 0 {
+				t.Errorf("Expected the default bucket ACL, got %+v (%v)", acl, err)
+			}
+			if err := store.PutBucketACL("bucket", publicRead); err != nil {
+				t.Fatalf("PutBucketACL failed: %v", err)
+			}
+			if acl, _ := store.GetBucketACL("bucket"); !GrantsAllUsers(acl, PermissionRead) {
+				t.Errorf("Expected a public-read bucket ACL, got %+v", acl)
+			}
+			if _, err := store.GetBucketACL("missing"); err != ErrBucketNotFound {
+				t.Errorf("Expected ErrBucketNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCannedACL(t *testing.T) {
+	tests := []struct {
+		name       string
+		permission string
+		public     bool
+	}{
+		{"private", PermissionRead, false},
+		{"public-read", PermissionRead, true},
+		{"public-read", PermissionWrite, false},
+		{"public-read-write", PermissionWrite, true},
+		{"authenticated-read", PermissionRead, false},
+	}
+	for _, tt := range tests {
+		acl, ok := CannedACL(tt.name, "owner")
+		if !ok {
+			t.Fatalf("CannedACL(%q) not recognised", tt.name)
+		}
+		if acl[0].Grantee != "owner" || acl[0].Permission != PermissionFullControl {
+			t.Errorf("CannedACL(%q) must give the owner full control, got %+v", tt.name, acl[0])
+		}
+		if got := GrantsAllUsers(acl, tt.permission); got != tt.public {
+			t.Errorf("GrantsAllUsers(%q, %s) = %v, want %v", tt.name, tt.permission, got, tt.public)
+		}
+	}
+
+	if _, ok := CannedACL("everyone", "owner"); ok {
+		t.Error("Expected an unknown canned ACL to be rejected")
+	}
+}
+
+func TestBucketCORS(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	rules := []CORSRule{{
+		ID:             "uploads",
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedMethods: []string{"PUT", "POST"},
+		AllowedHeaders: []string{"*"},
+		ExposeHeaders:  []string{"ETag"},
+		MaxAgeSeconds:  300,
+	}}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := newStorage(t)
+			_, _ = store.CreateBucket("bucket")
+
+			if stored, err := store.GetBucketCORS("bucket"); err != nil || stored != nil {
+				t.Errorf("Expected no CORS configuration, got %+v (%v)", stored, err)
+			}
+			if err := store.PutBucketCORS("bucket", rules); err != nil {
+				t.Fatalf("PutBucketCORS failed: %v", err)
+			}
+			if stored, _ := store.GetBucketCORS("bucket"); !reflect.DeepEqual(stored, rules) {
+				t.Errorf("Expected %+v, got %+v", rules, stored)
+			}
+			if err := store.PutBucketCORS("bucket", nil); err != nil {
+				t.Fatalf("PutBucketCORS failed: %v", err)
+			}
+			if stored, _ := store.GetBucketCORS("bucket"); stored != nil {
+				t.Errorf("Expected the CORS configuration to be removed, got %+v", stored)
+			}
+			if err := store.PutBucketCORS("missing", rules); err != ErrBucketNotFound {
+				t.Errorf("Expected ErrBucketNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMatchCORSRule(t *testing.T) {
+	rules := []CORSRule{
+		{
+			ID:             "uploads",
+			AllowedOrigins: []string{"https://*.example.com"},
+			AllowedMethods: []string{"PUT"},
+			AllowedHeaders: []string{"Content-*", "x-amz-*"},
+		},
+		{
+			ID:             "reads",
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "HEAD"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers []string
+		want    string
+	}{
+		{"Wildcard origin", "https://app.example.com", "PUT", nil, "uploads"},
+		{"Headers are case-insensitive", "https://app.example.com", "PUT", []string{"content-type", "X-Amz-Meta-Owner"}, "uploads"},
+		{"Header not allowed", "https://app.example.com", "PUT", []string{"Authorization"}, ""},
+		{"Origin not allowed", "https://example.org", "PUT", nil, ""},
+		{"Method not allowed", "https://app.example.com", "DELETE", nil, ""},
+		{"Later rule", "https://example.org", "GET", nil, "reads"},
+		{"No allowed headers", "https://example.org", "GET", []string{"Range"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if rule := MatchCORSRule(rules, tt.origin, tt.method, tt.headers); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.want {
+				t.Errorf("Expected rule %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Memory": func(t *testing.T) Storage { return NewMemoryStorage() },
+		"FileSystem": func(t *testing.T) Storage {
+			store, err := NewFileSystemStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create filesystem storage: %v", err)
+			}
+			return store
+		},
+	}
+
+	day := 24 * time.Hour
+	put := func(t *testing.T, store Storage, bucket, key, data string, tags map[string]string) {
+		t.Helper()
+		if _, err := store.PutObjectWithMetadata(bucket, key, strings.NewReader(data), int64(len(data)), &ObjectMetadata{Tags: tags}); err != nil {
+			t.Fatalf("PutObjectWithMetadata failed: %v", err)
+		}
+	}
+	exists := func(store Storage, bucket, key string) bool {
+		_, err := store.GetObjectMetadata(bucket, key)
+		return err == nil
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Configuration", func(t *testing.T) {
+				store := newStorage(t)
+				_, _ = store.CreateBucket("bucket")
+
+				rules := []LifecycleRule{{
+					ID:                                 "tmp",
+					Enabled:                            true,
+					Prefix:                             "tmp/",
+					Tags:                               map[string]string{"class": "scratch"},
+					ExpirationDate:                     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+					AbortIncompleteMultipartUploadDays: 7,
+				}}
+				if stored, err := store.GetBucketLifecycle("bucket"); err != nil || stored != nil {
+					t.Errorf("Expected no lifecycle configuration, got %+v (%v)", stored, err)
+				}
+				if err := store.PutBucketLifecycle("bucket", rules); err != nil {
+					t.Fatalf("PutBucketLifecycle failed: %v", err)
+				}
+				if stored, _ := store.GetBucketLifecycle("bucket"); !reflect.DeepEqual(stored, rules) {
+					t.Errorf("Expected %+v, got %+v", rules, stored)
+				}
+				_ = store.PutBucketLifecycle("bucket", nil)
+				if stored, _ := store.GetBucketLifecycle("bucket"); stored != nil {
+					t.Errorf("Expected the lifecycle configuration to be removed, got %+v", stored)
+				}
+				if err := store.PutBucketLifecycle("missing", rules); err != ErrBucketNotFound {
+					t.Errorf("Expected ErrBucketNotFound, got %v", err)
+				}
+			})
+
+			t.Run("Expiration with filters", func(t *testing.T) {
+				store := newStorage(t)
+				_, _ = store.CreateBucket("bucket")
+				_ = store.PutBucketLifecycle("bucket", []LifecycleRule{
+					{Enabled: true, Prefix: "tmp/", ExpirationDays: 1},
+					{Enabled: true, Tags: map[string]string{"retention": "short"}, ObjectSizeGreaterThan: 3, ExpirationDays: 1},
+					{Enabled: false, Prefix: "keep/", ExpirationDays: 1},
+				})
+
+				put(t, store, "bucket", "tmp/scratch", "data", nil)
+				put(t, store, "bucket", "keep/report", "data", nil)
+				put(t, store, "bucket", "tagged/large", "large", map[string]string{"retention": "short"})
+				put(t, store, "bucket", "tagged/small", "sm", map[string]string{"retention": "short"})
+				put(t, store, "bucket", "other/large", "large", map[string]string{"retention": "long"})
+
+				if removed, err := store.ApplyLifecycle(time.Now()); err != nil || removed != 0 {
+					t.Fatalf("Expected nothing to expire yet, removed %d (%v)", removed, err)
+				}
+				removed, err := store.ApplyLifecycle(time.Now().Add(2 * day))
+				if err != nil || removed != 2 {
+					t.Fatalf("Expected 2 objects to expire, removed %d (%v)", removed, err)
+				}
+				for key, want := range map[string]bool{
+					"tmp/scratch":  false,
+					"tagged/large": false,
+					"keep/report":  true,
+					"tagged/small": true,
+					"other/large":  true,
+				} {
+					if got := exists(store, "bucket", key); got != want {
+						t.Errorf("Expected %s to exist: %v, got %v", key, want, got)
+					}
+				}
+			})
+
+			t.Run("Versioned buckets", func(t *testing.T) {
+				store := newStorage(t)
+				_, _ = store.CreateBucket("bucket")
+				_ = store.PutBucketVersioning("bucket", VersioningEnabled)
+				_ = store.PutBucketLifecycle("bucket", []LifecycleRule{
+					{Enabled: true, Prefix: "logs/", NoncurrentVersionExpirationDays: 1, NewerNoncurrentVersions: 1},
+					{Enabled: true, Prefix: "tmp/", ExpirationDays: 1, ExpiredObjectDeleteMarker: true, NoncurrentVersionExpirationDays: 1},
+				})
+
+				for _, data := range []string{"v1", "v2", "v3"} {
+					put(t, store, "bucket", "logs/app", data, nil)
+				}
+				put(t, store, "bucket", "tmp/scratch", "data", nil)
+
+				versionCount := func(key string) int {
+					versions, _ := store.ListObjectVersions("bucket", key)
+					return len(versions)
+				}
+
+				// The oldest noncurrent log version expires, the newest one is
+				// kept; the current scratch version gets a delete marker
+				later := time.Now().Add(2 * day)
+				if removed, err := store.ApplyLifecycle(later); err != nil || removed != 2 {
+					t.Fatalf("Expected 2 removals, got %d (%v)", removed, err)
+				}
+				if n := versionCount("logs/app"); n != 2 {
+					t.Errorf("Expected 2 log versions to remain, got %d", n)
+				}
+				if exists(store, "bucket", "tmp/scratch") || versionCount("tmp/scratch") != 2 {
+					t.Errorf("Expected the scratch object to be hidden behind a delete marker")
+				}
+
+				// The version behind the marker expires, then the marker
+				// left without versions
+				if _, err := store.ApplyLifecycle(later.Add(2 * day)); err != nil {
+					t.Fatalf("ApplyLifecycle failed: %v", err)
+				}
+				if _, err := store.ApplyLifecycle(later.Add(2 * day)); err != nil {
+					t.Fatalf("ApplyLifecycle failed: %v", err)
+				}
+				if n := versionCount("tmp/scratch"); n != 0 {
+					t.Errorf("Expected the scratch object and its marker to be removed, %d version(s) left", n)
+				}
+			})
+
+			t.Run("Abort incomplete multipart uploads", func(t *testing.T) {
+				store := newStorage(t)
+				_, _ = store.CreateBucket("bucket")
+				_ = store.PutBucketLifecycle("bucket", []LifecycleRule{
+					{Enabled: true, Prefix: "uploads/", AbortIncompleteMultipartUploadDays: 7},
+				})
+
+				_, _ = store.InitiateMultipartUpload("bucket", "uploads/big")
+				other, _ := store.InitiateMultipartUpload("bucket", "other/big")
+
+				if removed, _ := store.ApplyLifecycle(time.Now().Add(6 * day)); removed != 0 {
+					t.Errorf("Expected no upload to be aborted yet, aborted %d", removed)
+				}
+				if removed, _ := store.ApplyLifecycle(time.Now().Add(8 * day)); removed != 1 {
+					t.Errorf("Expected 1 upload to be aborted, aborted %d", removed)
+				}
+				uploads, _ := store.ListMultipartUploads("bucket", "")
+				if len(uploads) != 1 || uploads[0].UploadId != other {
+					t.Errorf("Expected only the upload outside the prefix to remain, got %+v", uploads)
+				}
+			})
+		})
+	}
+}
+
+func TestLifecycleDue(t *testing.T) {
+	created := time.Date(2024, 3, 1, 15, 30, 0, 0, time.UTC)
+	if due := lifecycleDue(created, 1); !due.Equal(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the due time to be rounded up to midnight, got %v", due)
+	}
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if due := lifecycleDue(midnight, 2); !due.Equal(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a due time at midnight to be kept, got %v", due)
+	}
 }
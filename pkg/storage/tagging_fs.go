@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PutObjectTagging replaces the tag set of an object version in its sidecar
+func (fs *FileSystemStorage) PutObjectTagging(bucket, key, versionId string, tags map[string]string) error {
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta, path, err := resolveVersion(filepath.Join(fs.baseDir, bucket, key), versionId)
+	if err != nil {
+		return err
+	}
+
+	meta.Tags = cloneTags(tags)
+	return writeMetadataFile(metadataPath(path), meta)
+}
+
+// PutBucketTagging replaces the tag set of a bucket
+func (fs *FileSystemStorage) PutBucketTagging(bucket string, tags map[string]string) error {
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta := fs.loadBucketMeta(bucket)
+	if meta.Name == "" {
+		meta.Name = bucket
+		meta.Created = time.Now().UTC()
+	}
+	meta.Tags = cloneTags(tags)
+
+	return fs.saveBucketMeta(bucket, meta)
+}
+
+// GetBucketTagging returns the tag set of a bucket
+func (fs *FileSystemStorage) GetBucketTagging(bucket string) (map[string]string, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	return fs.loadBucketMeta(bucket).Tags, nil
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	storageerrors "github.com/wozozo/s3pit/pkg/errors"
 	"github.com/wozozo/s3pit/pkg/tenant"
@@ -123,8 +125,17 @@ func (t *TenantAwareStorage) PutObject(bucket, key string, reader io.Reader, siz
 	return storage.PutObject(bucket, key, reader, size, contentType)
 }
 
+// PutObjectWithMetadata stores an object with its metadata for the default tenant
+func (t *TenantAwareStorage) PutObjectWithMetadata(bucket, key string, reader io.Reader, size int64, metadata *ObjectMetadata) (string, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return "", err
+	}
+	return storage.PutObjectWithMetadata(bucket, key, reader, size, metadata)
+}
+
 // GetObject retrieves an object for the default tenant
-func (t *TenantAwareStorage) GetObject(bucket, key string) (io.ReadCloser, *ObjectMetadata, error) {
+func (t *TenantAwareStorage) GetObject(bucket, key string) (io.ReadSeekCloser, *ObjectMetadata, error) {
 	storage, err := t.GetStorageForTenant("default")
 	if err != nil {
 		return nil, nil, err
@@ -168,6 +179,15 @@ func (t *TenantAwareStorage) CopyObject(srcBucket, srcKey, dstBucket, dstKey str
 	return storage.CopyObject(srcBucket, srcKey, dstBucket, dstKey)
 }
 
+// CopyObjectVersion copies a version of an object within the default tenant's storage
+func (t *TenantAwareStorage) CopyObjectVersion(srcBucket, srcKey, srcVersionId, dstBucket, dstKey string, metadata *ObjectMetadata) (*ObjectMetadata, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.CopyObjectVersion(srcBucket, srcKey, srcVersionId, dstBucket, dstKey, metadata)
+}
+
 // InitiateMultipartUpload initiates a multipart upload for the default tenant
 func (t *TenantAwareStorage) InitiateMultipartUpload(bucket, key string) (string, error) {
 	storage, err := t.GetStorageForTenant("default")
@@ -177,6 +197,15 @@ func (t *TenantAwareStorage) InitiateMultipartUpload(bucket, key string) (string
 	return storage.InitiateMultipartUpload(bucket, key)
 }
 
+// InitiateMultipartUploadWithMetadata initiates a multipart upload with object metadata for the default tenant
+func (t *TenantAwareStorage) InitiateMultipartUploadWithMetadata(bucket, key string, metadata *ObjectMetadata) (string, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return "", err
+	}
+	return storage.InitiateMultipartUploadWithMetadata(bucket, key, metadata)
+}
+
 // UploadPart uploads a part of a multipart upload for the default tenant
 func (t *TenantAwareStorage) UploadPart(bucket, key, uploadId string, partNumber int, reader io.Reader, size int64) (string, error) {
 	storage, err := t.GetStorageForTenant("default")
@@ -186,6 +215,15 @@ func (t *TenantAwareStorage) UploadPart(bucket, key, uploadId string, partNumber
 	return storage.UploadPart(bucket, key, uploadId, partNumber, reader, size)
 }
 
+// UploadPartCopy copies object data into a part of a multipart upload for the default tenant
+func (t *TenantAwareStorage) UploadPartCopy(bucket, key, uploadId string, partNumber int, source CopySource) (string, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return "", err
+	}
+	return storage.UploadPartCopy(bucket, key, uploadId, partNumber, source)
+}
+
 // CompleteMultipartUpload completes a multipart upload for the default tenant
 func (t *TenantAwareStorage) CompleteMultipartUpload(bucket, key, uploadId string, parts []CompletedPart) (string, error) {
 	storage, err := t.GetStorageForTenant("default")
@@ -212,3 +250,246 @@ func (t *TenantAwareStorage) ListParts(bucket, key, uploadId string) ([]PartInfo
 	}
 	return storage.ListParts(bucket, key, uploadId)
 }
+
+// ListMultipartUploads lists in-progress multipart uploads for the default tenant
+func (t *TenantAwareStorage) ListMultipartUploads(bucket, prefix string) ([]MultipartUploadInfo, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.ListMultipartUploads(bucket, prefix)
+}
+
+// allStorages returns the storage of every configured tenant, opening the
+// ones no request has used since the server started, and of any other
+// tenant that has been used
+func (t *TenantAwareStorage) allStorages() ([]Storage, error) {
+	tenantIDs := make(map[string]bool)
+	if t.tenantManager != nil {
+		for _, configured := range t.tenantManager.ListTenants() {
+			tenantIDs[configured.AccessKeyID] = true
+		}
+	}
+	t.mu.RLock()
+	for tenantID := range t.storages {
+		tenantIDs[tenantID] = true
+	}
+	t.mu.RUnlock()
+
+	ids := make([]string, 0, len(tenantIDs))
+	for tenantID := range tenantIDs {
+		ids = append(ids, tenantID)
+	}
+	sort.Strings(ids)
+
+	storages := make([]Storage, 0, len(ids))
+	for _, tenantID := range ids {
+		storage, err := t.GetStorageForTenant(tenantID)
+		if err != nil {
+			return nil, err
+		}
+		storages = append(storages, storage)
+	}
+	return storages, nil
+}
+
+// AbortStaleMultipartUploads aborts stale uploads in the storage of every
+// tenant
+func (t *TenantAwareStorage) AbortStaleMultipartUploads(cutoff time.Time) (int, error) {
+	storages, err := t.allStorages()
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for _, storage := range storages {
+		n, err := storage.AbortStaleMultipartUploads(cutoff)
+		aborted += n
+		if err != nil {
+			return aborted, err
+		}
+	}
+	return aborted, nil
+}
+
+// PutBucketVersioning sets bucket versioning for the default tenant
+func (t *TenantAwareStorage) PutBucketVersioning(bucket, status string) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutBucketVersioning(bucket, status)
+}
+
+// PutObjectTagging replaces object tags for the default tenant
+func (t *TenantAwareStorage) PutObjectTagging(bucket, key, versionId string, tags map[string]string) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutObjectTagging(bucket, key, versionId, tags)
+}
+
+// PutBucketTagging replaces bucket tags for the default tenant
+func (t *TenantAwareStorage) PutBucketTagging(bucket string, tags map[string]string) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutBucketTagging(bucket, tags)
+}
+
+// GetBucketTagging returns bucket tags for the default tenant
+func (t *TenantAwareStorage) GetBucketTagging(bucket string) (map[string]string, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetBucketTagging(bucket)
+}
+
+// PutBucketPolicy replaces the bucket policy for the default tenant
+func (t *TenantAwareStorage) PutBucketPolicy(bucket string, policy []byte) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutBucketPolicy(bucket, policy)
+}
+
+// GetBucketPolicy returns the bucket policy for the default tenant
+func (t *TenantAwareStorage) GetBucketPolicy(bucket string) ([]byte, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetBucketPolicy(bucket)
+}
+
+// PutObjectACL replaces an object ACL for the default tenant
+func (t *TenantAwareStorage) PutObjectACL(bucket, key, versionId string, acl []Grant) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutObjectACL(bucket, key, versionId, acl)
+}
+
+// PutBucketACL replaces a bucket ACL for the default tenant
+func (t *TenantAwareStorage) PutBucketACL(bucket string, acl []Grant) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutBucketACL(bucket, acl)
+}
+
+// GetBucketACL returns a bucket ACL for the default tenant
+func (t *TenantAwareStorage) GetBucketACL(bucket string) ([]Grant, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetBucketACL(bucket)
+}
+
+// PutBucketCORS replaces a bucket CORS configuration for the default tenant
+func (t *TenantAwareStorage) PutBucketCORS(bucket string, rules []CORSRule) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutBucketCORS(bucket, rules)
+}
+
+// GetBucketCORS returns a bucket CORS configuration for the default tenant
+func (t *TenantAwareStorage) GetBucketCORS(bucket string) ([]CORSRule, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetBucketCORS(bucket)
+}
+
+// PutBucketLifecycle replaces a bucket lifecycle configuration for the
+// default tenant
+func (t *TenantAwareStorage) PutBucketLifecycle(bucket string, rules []LifecycleRule) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.PutBucketLifecycle(bucket, rules)
+}
+
+// GetBucketLifecycle returns a bucket lifecycle configuration for the
+// default tenant
+func (t *TenantAwareStorage) GetBucketLifecycle(bucket string) ([]LifecycleRule, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetBucketLifecycle(bucket)
+}
+
+// ApplyLifecycle applies the lifecycle rules in the storage of every tenant
+func (t *TenantAwareStorage) ApplyLifecycle(now time.Time) (int, error) {
+	storages, err := t.allStorages()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, storage := range storages {
+		n, err := storage.ApplyLifecycle(now)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// GetBucketVersioning returns bucket versioning for the default tenant
+func (t *TenantAwareStorage) GetBucketVersioning(bucket string) (string, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return "", err
+	}
+	return storage.GetBucketVersioning(bucket)
+}
+
+// ListObjectVersions lists object versions for the default tenant
+func (t *TenantAwareStorage) ListObjectVersions(bucket, prefix string) ([]ObjectVersion, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.ListObjectVersions(bucket, prefix)
+}
+
+// GetObjectVersion retrieves an object version for the default tenant
+func (t *TenantAwareStorage) GetObjectVersion(bucket, key, versionId string) (io.ReadSeekCloser, *ObjectMetadata, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage.GetObjectVersion(bucket, key, versionId)
+}
+
+// GetObjectVersionMetadata retrieves object version metadata for the default tenant
+func (t *TenantAwareStorage) GetObjectVersionMetadata(bucket, key, versionId string) (*ObjectMetadata, error) {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetObjectVersionMetadata(bucket, key, versionId)
+}
+
+// DeleteObjectVersion deletes an object version for the default tenant
+func (t *TenantAwareStorage) DeleteObjectVersion(bucket, key, versionId string) error {
+	storage, err := t.GetStorageForTenant("default")
+	if err != nil {
+		return err
+	}
+	return storage.DeleteObjectVersion(bucket, key, versionId)
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/wozozo/s3pit/pkg/tenant"
 )
@@ -173,6 +174,67 @@ func TestTenantAwareStorage_Isolation(t *testing.T) {
 	}
 }
 
+func TestTenantAwareStorage_AbortStaleUploadsOfUnusedTenants(t *testing.T) {
+	baseDir := t.TempDir()
+	tenantManager := tenant.NewManager("")
+	_ = tenantManager.AddTenant(&tenant.Tenant{
+		AccessKeyID:     "tenant1",
+		SecretAccessKey: "secret1",
+		CustomDir:       filepath.Join(baseDir, "tenant1"),
+	})
+
+	tas := NewTenantAwareStorage(baseDir, tenantManager, false)
+	storage, err := tas.GetStorageForTenant("tenant1")
+	if err != nil {
+		t.Fatalf("Failed to get storage for tenant1: %v", err)
+	}
+	_, _ = storage.CreateBucket("bucket")
+	if _, err := storage.InitiateMultipartUpload("bucket", "stale.bin"); err != nil {
+		t.Fatalf("InitiateMultipartUpload failed: %v", err)
+	}
+
+	// After a restart no request has opened the tenant's storage yet
+	restarted := NewTenantAwareStorage(baseDir, tenantManager, false)
+	aborted, err := restarted.AbortStaleMultipartUploads(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("AbortStaleMultipartUploads failed: %v", err)
+	}
+	if aborted != 1 {
+		t.Errorf("Expected the stale upload of the unused tenant to be aborted, got %d", aborted)
+	}
+}
+
+func TestTenantAwareStorage_ApplyLifecycleOfUnusedTenants(t *testing.T) {
+	baseDir := t.TempDir()
+	tenantManager := tenant.NewManager("")
+	_ = tenantManager.AddTenant(&tenant.Tenant{
+		AccessKeyID:     "tenant1",
+		SecretAccessKey: "secret1",
+		CustomDir:       filepath.Join(baseDir, "tenant1"),
+	})
+
+	tas := NewTenantAwareStorage(baseDir, tenantManager, false)
+	storage, err := tas.GetStorageForTenant("tenant1")
+	if err != nil {
+		t.Fatalf("Failed to get storage for tenant1: %v", err)
+	}
+	_, _ = storage.CreateBucket("bucket")
+	_, _ = storage.PutObject("bucket", "old.txt", bytes.NewReader([]byte("old")), 3, "text/plain")
+	if err := storage.PutBucketLifecycle("bucket", []LifecycleRule{{ID: "expire", Enabled: true, ExpirationDays: 1}}); err != nil {
+		t.Fatalf("PutBucketLifecycle failed: %v", err)
+	}
+
+	// After a restart no request has opened the tenant's storage yet
+	restarted := NewTenantAwareStorage(baseDir, tenantManager, false)
+	removed, err := restarted.ApplyLifecycle(time.Now().Add(72 * time.Hour))
+	if err != nil {
+		t.Fatalf("ApplyLifecycle failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected the object of the unused tenant to expire, got %d removed", removed)
+	}
+}
+
 func TestTenantAwareStorage_Concurrency(t *testing.T) {
 	// Create temporary directories
 	baseDir, err := os.MkdirTemp("", "s3pit-test-base")
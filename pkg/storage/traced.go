@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/wozozo/s3pit/pkg/tracing"
+)
+
+// WithTracing returns a storage recording a span per call as a child of the
+// span carried by ctx. Without a span in ctx the storage is returned as is.
+func WithTracing(ctx context.Context, s Storage) Storage {
+	if tracing.SpanFromContext(ctx) == nil {
+		return s
+	}
+	return &tracedStorage{store: s, ctx: ctx}
+}
+
+// tracedStorage wraps a storage for the duration of one request
+type tracedStorage struct {
+	store Storage
+	ctx   context.Context
+}
+
+func (t *tracedStorage) start(operation, bucket, key string) *tracing.Span {
+	_, span := tracing.Start(t.ctx, "storage."+operation)
+	span.SetAttribute("aws.s3.bucket", bucket)
+	span.SetAttribute("aws.s3.key", key)
+	return span
+}
+
+// finishSpan ends a span, marking it failed when the call returned an error
+func finishSpan(span *tracing.Span, err error) error {
+	if err != nil {
+		span.SetError(err.Error())
+	}
+	span.Finish()
+	return err
+}
+
+func (t *tracedStorage) CreateBucket(bucket string) (bool, error) {
+	span := t.start("CreateBucket", bucket, "")
+	created, err := t.store.CreateBucket(bucket)
+	return created, finishSpan(span, err)
+}
+
+func (t *tracedStorage) DeleteBucket(bucket string) error {
+	span := t.start("DeleteBucket", bucket, "")
+	return finishSpan(span, t.store.DeleteBucket(bucket))
+}
+
+func (t *tracedStorage) ListBuckets() ([]BucketInfo, error) {
+	span := t.start("ListBuckets", "", "")
+	buckets, err := t.store.ListBuckets()
+	return buckets, finishSpan(span, err)
+}
+
+func (t *tracedStorage) BucketExists(bucket string) (bool, error) {
+	span := t.start("BucketExists", bucket, "")
+	exists, err := t.store.BucketExists(bucket)
+	return exists, finishSpan(span, err)
+}
+
+func (t *tracedStorage) PutObject(bucket, key string, reader io.Reader, size int64, contentType string) (string, error) {
+	span := t.start("PutObject", bucket, key)
+	etag, err := t.store.PutObject(bucket, key, reader, size, contentType)
+	return etag, finishSpan(span, err)
+}
+
+func (t *tracedStorage) PutObjectWithMetadata(bucket, key string, reader io.Reader, size int64, metadata *ObjectMetadata) (string, error) {
+	span := t.start("PutObjectWithMetadata", bucket, key)
+	etag, err := t.store.PutObjectWithMetadata(bucket, key, reader, size, metadata)
+	return etag, finishSpan(span, err)
+}
+
+func (t *tracedStorage) GetObject(bucket, key string) (io.ReadSeekCloser, *ObjectMetadata, error) {
+	span := t.start("GetObject", bucket, key)
+	reader, metadata, err := t.store.GetObject(bucket, key)
+	return reader, metadata, finishSpan(span, err)
+}
+
+func (t *tracedStorage) GetObjectMetadata(bucket, key string) (*ObjectMetadata, error) {
+	span := t.start("GetObjectMetadata", bucket, key)
+	metadata, err := t.store.GetObjectMetadata(bucket, key)
+	return metadata, finishSpan(span, err)
+}
+
+func (t *tracedStorage) DeleteObject(bucket, key string) error {
+	span := t.start("DeleteObject", bucket, key)
+	return finishSpan(span, t.store.DeleteObject(bucket, key))
+}
+
+func (t *tracedStorage) ListObjects(bucket, prefix, delimiter string, maxKeys int, continuationToken string) ([]ObjectInfo, []string, string, error) {
+	span := t.start("ListObjects", bucket, "")
+	objects, prefixes, nextToken, err := t.store.ListObjects(bucket, prefix, delimiter, maxKeys, continuationToken)
+	return objects, prefixes, nextToken, finishSpan(span, err)
+}
+
+func (t *tracedStorage) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, error) {
+	span := t.start("CopyObject", dstBucket, dstKey)
+	etag, err := t.store.CopyObject(srcBucket, srcKey, dstBucket, dstKey)
+	return etag, finishSpan(span, err)
+}
+
+func (t *tracedStorage) CopyObjectVersion(srcBucket, srcKey, srcVersionId, dstBucket, dstKey string, metadata *ObjectMetadata) (*ObjectMetadata, error) {
+	span := t.start("CopyObjectVersion", dstBucket, dstKey)
+	copied, err := t.store.CopyObjectVersion(srcBucket, srcKey, srcVersionId, dstBucket, dstKey, metadata)
+	return copied, finishSpan(span, err)
+}
+
+func (t *tracedStorage) InitiateMultipartUpload(bucket, key string) (string, error) {
+	span := t.start("InitiateMultipartUpload", bucket, key)
+	uploadId, err := t.store.InitiateMultipartUpload(bucket, key)
+	return uploadId, finishSpan(span, err)
+}
+
+func (t *tracedStorage) InitiateMultipartUploadWithMetadata(bucket, key string, metadata *ObjectMetadata) (string, error) {
+	span := t.start("InitiateMultipartUploadWithMetadata", bucket, key)
+	uploadId, err := t.store.InitiateMultipartUploadWithMetadata(bucket, key, metadata)
+	return uploadId, finishSpan(span, err)
+}
+
+func (t *tracedStorage) UploadPart(bucket, key, uploadId string, partNumber int, reader io.Reader, size int64) (string, error) {
+	span := t.start("UploadPart", bucket, key)
+	etag, err := t.store.UploadPart(bucket, key, uploadId, partNumber, reader, size)
+	return etag, finishSpan(span, err)
+}
+
+func (t *tracedStorage) UploadPartCopy(bucket, key, uploadId string, partNumber int, source CopySource) (string, error) {
+	span := t.start("UploadPartCopy", bucket, key)
+	etag, err := t.store.UploadPartCopy(bucket, key, uploadId, partNumber, source)
+	return etag, finishSpan(span, err)
+}
+
+func (t *tracedStorage) CompleteMultipartUpload(bucket, key, uploadId string, parts []CompletedPart) (string, error) {
+	span := t.start("CompleteMultipartUpload", bucket, key)
+	etag, err := t.store.CompleteMultipartUpload(bucket, key, uploadId, parts)
+	return etag, finishSpan(span, err)
+}
+
+func (t *tracedStorage) AbortMultipartUpload(bucket, key, uploadId string) error {
+	span := t.start("AbortMultipartUpload", bucket, key)
+	return finishSpan(span, t.store.AbortMultipartUpload(bucket, key, uploadId))
+}
+
+func (t *tracedStorage) ListParts(bucket, key, uploadId string) ([]PartInfo, error) {
+	span := t.start("ListParts", bucket, key)
+	parts, err := t.store.ListParts(bucket, key, uploadId)
+	return parts, finishSpan(span, err)
+}
+
+func (t *tracedStorage) ListMultipartUploads(bucket, prefix string) ([]MultipartUploadInfo, error) {
+	span := t.start("ListMultipartUploads", bucket, "")
+	uploads, err := t.store.ListMultipartUploads(bucket, prefix)
+	return uploads, finishSpan(span, err)
+}
+
+func (t *tracedStorage) AbortStaleMultipartUploads(cutoff time.Time) (int, error) {
+	span := t.start("AbortStaleMultipartUploads", "", "")
+	aborted, err := t.store.AbortStaleMultipartUploads(cutoff)
+	return aborted, finishSpan(span, err)
+}
+
+func (t *tracedStorage) PutBucketVersioning(bucket, status string) error {
+	span := t.start("PutBucketVersioning", bucket, "")
+	return finishSpan(span, t.store.PutBucketVersioning(bucket, status))
+}
+
+func (t *tracedStorage) GetBucketVersioning(bucket string) (string, error) {
+	span := t.start("GetBucketVersioning", bucket, "")
+	status, err := t.store.GetBucketVersioning(bucket)
+	return status, finishSpan(span, err)
+}
+
+func (t *tracedStorage) ListObjectVersions(bucket, prefix string) ([]ObjectVersion, error) {
+	span := t.start("ListObjectVersions", bucket, "")
+	versions, err := t.store.ListObjectVersions(bucket, prefix)
+	return versions, finishSpan(span, err)
+}
+
+func (t *tracedStorage) GetObjectVersion(bucket, key, versionId string) (io.ReadSeekCloser, *ObjectMetadata, error) {
+	span := t.start("GetObjectVersion", bucket, key)
+	reader, metadata, err := t.store.GetObjectVersion(bucket, key, versionId)
+	return reader, metadata, finishSpan(span, err)
+}
+
+func (t *tracedStorage) GetObjectVersionMetadata(bucket, key, versionId string) (*ObjectMetadata, error) {
+	span := t.start("GetObjectVersionMetadata", bucket, key)
+	metadata, err := t.store.GetObjectVersionMetadata(bucket, key, versionId)
+	return metadata, finishSpan(span, err)
+}
+
+func (t *tracedStorage) DeleteObjectVersion(bucket, key, versionId string) error {
+	span := t.start("DeleteObjectVersion", bucket, key)
+	return finishSpan(span, t.store.DeleteObjectVersion(bucket, key, versionId))
+}
+
+func (t *tracedStorage) PutObjectTagging(bucket, key, versionId string, tags map[string]string) error {
+	span := t.start("PutObjectTagging", bucket, key)
+	return finishSpan(span, t.store.PutObjectTagging(bucket, key, versionId, tags))
+}
+
+func (t *tracedStorage) PutBucketTagging(bucket string, tags map[string]string) error {
+	span := t.start("PutBucketTagging", bucket, "")
+	return finishSpan(span, t.store.PutBucketTagging(bucket, tags))
+}
+
+func (t *tracedStorage) GetBucketTagging(bucket string) (map[string]string, error) {
+	span := t.start("GetBucketTagging", bucket, "")
+	tags, err := t.store.GetBucketTagging(bucket)
+	return tags, finishSpan(span, err)
+}
+
+func (t *tracedStorage) PutBucketPolicy(bucket string, policy []byte) error {
+	span := t.start("PutBucketPolicy", bucket, "")
+	return finishSpan(span, t.store.PutBucketPolicy(bucket, policy))
+}
+
+func (t *tracedStorage) GetBucketPolicy(bucket string) ([]byte, error) {
+	span := t.start("GetBucketPolicy", bucket, "")
+	policy, err := t.store.GetBucketPolicy(bucket)
+	return policy, finishSpan(span, err)
+}
+
+func (t *tracedStorage) PutObjectACL(bucket, key, versionId string, acl []Grant) error {
+	span := t.start("PutObjectACL", bucket, key)
+	return finishSpan(span, t.store.PutObjectACL(bucket, key, versionId, acl))
+}
+
+func (t *tracedStorage) PutBucketACL(bucket string, acl []Grant) error {
+	span := t.start("PutBucketACL", bucket, "")
+	return finishSpan(span, t.store.PutBucketACL(bucket, acl))
+}
+
+func (t *tracedStorage) GetBucketACL(bucket string) ([]Grant, error) {
+	span := t.start("GetBucketACL", bucket, "")
+	acl, err := t.store.GetBucketACL(bucket)
+	return acl, finishSpan(span, err)
+}
+
+func (t *tracedStorage) PutBucketCORS(bucket string, rules []CORSRule) error {
+	span := t.start("PutBucketCORS", bucket, "")
+	return finishSpan(span, t.store.PutBucketCORS(bucket, rules))
+}
+
+func (t *tracedStorage) GetBucketCORS(bucket string) ([]CORSRule, error) {
+	span := t.start("GetBucketCORS", bucket, "")
+	rules, err := t.store.GetBucketCORS(bucket)
+	return rules, finishSpan(span, err)
+}
+
+func (t *tracedStorage) PutBucketLifecycle(bucket string, rules []LifecycleRule) error {
+	span := t.start("PutBucketLifecycle", bucket, "")
+	return finishSpan(span, t.store.PutBucketLifecycle(bucket, rules))
+}
+
+func (t *tracedStorage) GetBucketLifecycle(bucket string) ([]LifecycleRule, error) {
+	span := t.start("GetBucketLifecycle", bucket, "")
+	rules, err := t.store.GetBucketLifecycle(bucket)
+	return rules, finishSpan(span, err)
+}
+
+func (t *tracedStorage) ApplyLifecycle(now time.Time) (int, error) {
+	span := t.start("ApplyLifecycle", "", "")
+	removed, err := t.store.ApplyLifecycle(now)
+	return removed, finishSpan(span, err)
+}
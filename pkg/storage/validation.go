@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"strings"
 
@@ -71,6 +72,11 @@ func CalculateETag(data []byte) string {
 	return fmt.Sprintf("\"%s\"", hex.EncodeToString(hash[:]))
 }
 
+// hashETag formats the ETag for an MD5 hash that has consumed the object data
+func hashETag(h hash.Hash) string {
+	return fmt.Sprintf("\"%s\"", hex.EncodeToString(h.Sum(nil)))
+}
+
 // CalculateETagFromReader calculates the ETag from an io.Reader
 func CalculateETagFromReader(reader io.Reader) (string, int64, []byte, error) {
 	hash := md5.New()
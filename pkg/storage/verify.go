@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"hash"
+	"io"
+)
+
+// VerifyingReader wraps an object body and checks it while it is streamed to
+// storage: it enforces a maximum size and compares the Content-MD5,
+// x-amz-content-sha256 and x-amz-checksum-* digests once the body has been read. Failures are
+// returned from Read in place of io.EOF, so a backend copying from the reader
+// aborts the write instead of committing a corrupt object.
+type VerifyingReader struct {
+	reader  io.Reader
+	maxSize int64
+	read    int64
+
+	md5            hash.Hash
+	expectedMD5    []byte
+	sha256         hash.Hash
+	expectedSHA256 []byte
+
+	checksumAlgorithm string
+	checksum          hash.Hash
+	expectedChecksum  func() string
+}
+
+// NewVerifyingReader wraps reader. A maxSize of zero or less disables the
+// size limit.
+func NewVerifyingReader(reader io.Reader, maxSize int64) *VerifyingReader {
+	return &VerifyingReader{
+		reader:  reader,
+		maxSize: maxSize,
+	}
+}
+
+// ExpectMD5 sets the raw MD5 digest the body must match
+func (v *VerifyingReader) ExpectMD5(sum []byte) {
+	v.md5 = md5.New()
+	v.expectedMD5 = sum
+}
+
+// ExpectSHA256 sets the raw SHA256 digest the body must match
+func (v *VerifyingReader) ExpectSHA256(sum []byte) {
+	v.sha256 = sha256.New()
+	v.expectedSHA256 = sum
+}
+
+// ExpectChecksum computes the additional checksum of the body with the given
+// algorithm. expected returns the base64 value the body must match once it
+// has been read, so values sent as trailers can be checked too. A nil function
+// or an empty value only computes the checksum.
+func (v *VerifyingReader) ExpectChecksum(algorithm string, expected func() string) {
+	v.checksumAlgorithm = algorithm
+	v.checksum = NewChecksumHash(algorithm)
+	v.expectedChecksum = expected
+}
+
+// Checksum returns the algorithm and value of the additional checksum
+// computed so far. It implements ChecksumReader.
+func (v *VerifyingReader) Checksum() (string, string) {
+	if v.checksum == nil {
+		return "", ""
+	}
+	return v.checksumAlgorithm, encodeChecksum(v.checksum)
+}
+
+func (v *VerifyingReader) Read(p []byte) (int, error) {
+	n, err := v.reader.Read(p)
+	v.read += int64(n)
+
+	if v.maxSize > 0 && v.read > v.maxSize {
+		return n, ErrEntityTooLarge
+	}
+
+	if n > 0 {
+		if v.md5 != nil {
+			v.md5.Write(p[:n])
+		}
+		if v.sha256 != nil {
+			v.sha256.Write(p[:n])
+		}
+		if v.checksum != nil {
+			v.checksum.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF {
+		if v.md5 != nil && !bytes.Equal(v.md5.Sum(nil), v.expectedMD5) {
+			return n, ErrBadDigest
+		}
+		if v.sha256 != nil && !bytes.Equal(v.sha256.Sum(nil), v.expectedSHA256) {
+			return n, ErrContentSHA256Mismatch
+		}
+		if v.checksum != nil && v.expectedChecksum != nil {
+			if expected := v.expectedChecksum(); expected != "" && expected != encodeChecksum(v.checksum) {
+				return n, ErrChecksumMismatch
+			}
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	storageerrors "github.com/wozozo/s3pit/pkg/errors"
+)
+
+// Bucket versioning states. A bucket on which versioning was never enabled
+// has an empty status.
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+// NullVersionId is the version ID of objects written while versioning was
+// unset or suspended
+const NullVersionId = "null"
+
+// ObjectVersion describes one entry of a key's version history
+type ObjectVersion struct {
+	Key            string
+	VersionId      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	Size           int64
+	LastModified   time.Time
+	ETag           string
+}
+
+// versionSequence disambiguates version IDs created within the same nanosecond
+var versionSequence atomic.Uint32
+
+// newVersionId returns a unique version ID that sorts in creation order
+func newVersionId() string {
+	return fmt.Sprintf("%016x%08x", time.Now().UnixNano(), versionSequence.Add(1))
+}
+
+// nextVersionId returns the version ID for a new write into a bucket with the
+// given versioning status
+func nextVersionId(status string) string {
+	switch status {
+	case VersioningEnabled:
+		return newVersionId()
+	case VersioningSuspended:
+		return NullVersionId
+	default:
+		return ""
+	}
+}
+
+// versionIdOrNull reports objects stored without a version ID as the null version
+func versionIdOrNull(versionId string) string {
+	if versionId == "" {
+		return NullVersionId
+	}
+	return versionId
+}
+
+// validateVersioningStatus checks a status passed to PutBucketVersioning
+func validateVersioningStatus(status string) error {
+	if status != VersioningEnabled && status != VersioningSuspended {
+		return storageerrors.ErrInvalidVersioningStatus
+	}
+	return nil
+}
+
+// sortVersionHistory orders noncurrent versions and delete markers newest first
+func sortVersionHistory(history []*ObjectMetadata) {
+	sort.SliceStable(history, func(i, j int) bool {
+		if !history[i].LastModified.Equal(history[j].LastModified) {
+			return history[i].LastModified.After(history[j].LastModified)
+		}
+		return history[i].VersionId > history[j].VersionId
+	})
+}
+
+// appendVersions adds the versions of a single key to a listing. The current
+// object, if any, is the latest version; otherwise the newest history entry is.
+func appendVersions(versions []ObjectVersion, key string, current *ObjectMetadata, history []*ObjectMetadata) []ObjectVersion {
+	entries := history
+	if current != nil {
+		entries = append([]*ObjectMetadata{current}, history...)
+	}
+
+	for i, meta := range entries {
+		versions = append(versions, ObjectVersion{
+			Key:            key,
+			VersionId:      versionIdOrNull(meta.VersionId),
+			IsLatest:       i == 0,
+			IsDeleteMarker: meta.DeleteMarker,
+			Size:           meta.Size,
+			LastModified:   meta.LastModified,
+			ETag:           meta.ETag,
+		})
+	}
+
+	return versions
+}
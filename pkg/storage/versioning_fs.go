@@ -0,0 +1,395 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	storageerrors "github.com/wozozo/s3pit/pkg/errors"
+)
+
+// versionsDirSuffix names the directory holding the noncurrent versions and
+// delete markers of an object. It sits next to the object and its sidecar:
+//
+//	photo.jpg
+//	photo.jpg.s3pit_meta.json
+//	photo.jpg.s3pit_versions/<versionId>
+//	photo.jpg.s3pit_versions/<versionId>.s3pit_meta.json
+//
+// Delete markers only have a sidecar.
+const versionsDirSuffix = ".s3pit_versions"
+
+// versionsDir returns the version history directory for an object path
+func versionsDir(objectPath string) string {
+	return objectPath + versionsDirSuffix
+}
+
+// versionPath returns the path of a stored noncurrent version
+func versionPath(objectPath, versionId string) string {
+	return filepath.Join(versionsDir(objectPath), versionId)
+}
+
+// isValidVersionId rejects version IDs that would escape the history directory
+func isValidVersionId(versionId string) bool {
+	return versionId != "" && versionId != "." && versionId != ".." &&
+		!strings.ContainsAny(versionId, `/\`) && !strings.Contains(versionId, ".s3pit_")
+}
+
+// archiveCurrentVersion moves the current object into its version history
+// before it is replaced or deleted. Nothing is archived when versioning was
+// never enabled, and the null version is dropped when versioning is suspended.
+// The caller must hold the bucket lock.
+func archiveCurrentVersion(objectPath, status string) error {
+	if status == "" {
+		return nil
+	}
+	if status == VersioningSuspended {
+		removeStoredVersion(objectPath, NullVersionId)
+	}
+
+	stat, err := os.Stat(objectPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	meta := loadMetadata(objectPath, stat)
+	versionId := versionIdOrNull(meta.VersionId)
+	if status == VersioningSuspended && versionId == NullVersionId {
+		os.Remove(objectPath)
+		os.Remove(metadataPath(objectPath))
+		return nil
+	}
+
+	dir := versionsDir(objectPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return storageerrors.WrapFileSystemError(dir, "create directory", err)
+	}
+
+	dst := versionPath(objectPath, versionId)
+	if err := os.Rename(objectPath, dst); err != nil {
+		return storageerrors.WrapFileSystemError(dst, "move file", err)
+	}
+
+	meta.VersionId = versionId
+	if err := writeMetadataFile(metadataPath(dst), meta); err != nil {
+		return err
+	}
+	os.Remove(metadataPath(objectPath))
+
+	return nil
+}
+
+// deleteVersioned archives the current object and records a delete marker
+// as the latest version. The caller must hold the bucket lock.
+func deleteVersioned(objectPath, status string) error {
+	if err := archiveCurrentVersion(objectPath, status); err != nil {
+		return err
+	}
+
+	dir := versionsDir(objectPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return storageerrors.WrapFileSystemError(dir, "create directory", err)
+	}
+
+	versionId := nextVersionId(status)
+	return writeMetadataFile(metadataPath(versionPath(objectPath, versionId)), &ObjectMetadata{
+		LastModified: time.Now().UTC(),
+		VersionId:    versionId,
+		DeleteMarker: true,
+	})
+}
+
+// removeStoredVersion deletes a noncurrent version and its sidecar
+func removeStoredVersion(objectPath, versionId string) bool {
+	path := versionPath(objectPath, versionId)
+	if _, err := os.Stat(metadataPath(path)); err != nil {
+		return false
+	}
+	os.Remove(path)
+	os.Remove(metadataPath(path))
+	return true
+}
+
+// loadStoredVersion reads the metadata of a noncurrent version or delete marker
+func loadStoredVersion(objectPath, versionId string) (*ObjectMetadata, error) {
+	path := versionPath(objectPath, versionId)
+
+	stored, err := readMetadataFile(metadataPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, err
+	}
+
+	if stored.DeleteMarker {
+		meta := &ObjectMetadata{}
+		stored.applyTo(meta)
+		meta.VersionId = versionId
+		return meta, nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, err
+	}
+
+	meta := loadMetadata(path, stat)
+	meta.VersionId = versionId
+	return meta, nil
+}
+
+// readVersionHistory returns the noncurrent versions and delete markers of an
+// object, newest first
+func readVersionHistory(objectPath string) []*ObjectMetadata {
+	entries, err := os.ReadDir(versionsDir(objectPath))
+	if err != nil {
+		return nil
+	}
+
+	var history []*ObjectMetadata
+	for _, entry := range entries {
+		versionId, ok := strings.CutSuffix(entry.Name(), ".s3pit_meta.json")
+		if !ok {
+			continue
+		}
+		if meta, err := loadStoredVersion(objectPath, versionId); err == nil {
+			history = append(history, meta)
+		}
+	}
+
+	sortVersionHistory(history)
+	return history
+}
+
+// loadCurrentVersion returns the metadata of the current object, if any
+func loadCurrentVersion(objectPath string) *ObjectMetadata {
+	stat, err := os.Stat(objectPath)
+	if err != nil || stat.IsDir() {
+		return nil
+	}
+	return loadMetadata(objectPath, stat)
+}
+
+// resolveVersion finds a version of an object and the file holding its data.
+// An empty versionId resolves to the current object or the delete marker
+// hiding it.
+func resolveVersion(objectPath, versionId string) (*ObjectMetadata, string, error) {
+	if current := loadCurrentVersion(objectPath); current != nil {
+		if versionId == "" || versionId == versionIdOrNull(current.VersionId) {
+			return current, objectPath, nil
+		}
+	}
+
+	if versionId == "" {
+		if history := readVersionHistory(objectPath); len(history) > 0 && history[0].DeleteMarker {
+			return history[0], "", ErrDeleteMarker
+		}
+		return nil, "", ErrObjectNotFound
+	}
+
+	if !isValidVersionId(versionId) {
+		return nil, "", ErrVersionNotFound
+	}
+
+	meta, err := loadStoredVersion(objectPath, versionId)
+	if err != nil {
+		return nil, "", err
+	}
+	if meta.DeleteMarker {
+		return meta, "", ErrDeleteMarker
+	}
+
+	return meta, versionPath(objectPath, versionId), nil
+}
+
+// promoteLatestVersion makes the newest noncurrent version current again
+// after the current version was removed, unless a delete marker is newest
+func promoteLatestVersion(objectPath string) error {
+	if loadCurrentVersion(objectPath) != nil {
+		return nil
+	}
+
+	history := readVersionHistory(objectPath)
+	if len(history) == 0 || history[0].DeleteMarker {
+		return nil
+	}
+
+	latest := history[0]
+	src := versionPath(objectPath, latest.VersionId)
+	if err := os.Rename(src, objectPath); err != nil {
+		return storageerrors.WrapFileSystemError(objectPath, "move file", err)
+	}
+	if err := writeMetadataFile(metadataPath(objectPath), latest); err != nil {
+		return err
+	}
+	os.Remove(metadataPath(src))
+
+	return nil
+}
+
+// PutBucketVersioning sets the versioning state of a bucket
+func (fs *FileSystemStorage) PutBucketVersioning(bucket, status string) error {
+	if err := validateVersioningStatus(status); err != nil {
+		return err
+	}
+
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return ErrBucketNotFound
+	}
+
+	meta := fs.loadBucketMeta(bucket)
+	if meta.Name == "" {
+		meta.Name = bucket
+		meta.Created = time.Now().UTC()
+	}
+	meta.Versioning = status
+
+	return fs.saveBucketMeta(bucket, meta)
+}
+
+// GetBucketVersioning returns the versioning state of a bucket
+func (fs *FileSystemStorage) GetBucketVersioning(bucket string) (string, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, err := os.Stat(filepath.Join(fs.baseDir, bucket)); os.IsNotExist(err) {
+		return "", ErrBucketNotFound
+	}
+
+	return fs.loadBucketMeta(bucket).Versioning, nil
+}
+
+// ListObjectVersions lists every version and delete marker of the keys
+// matching prefix, ordered by key and then newest first
+func (fs *FileSystemStorage) ListObjectVersions(bucket, prefix string) ([]ObjectVersion, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	bucketPath := filepath.Join(fs.baseDir, bucket)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		return nil, ErrBucketNotFound
+	}
+
+	keySet := make(map[string]bool)
+	err := filepath.Walk(bucketPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if objectPath, ok := strings.CutSuffix(path, versionsDirSuffix); ok {
+				if relPath, err := filepath.Rel(bucketPath, objectPath); err == nil {
+					keySet[filepath.ToSlash(relPath)] = true
+				}
+			}
+			if path != bucketPath && strings.Contains(name, ".s3pit_") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.Contains(name, ".s3pit_") {
+			return nil
+		}
+		if relPath, err := filepath.Rel(bucketPath, path); err == nil {
+			keySet[filepath.ToSlash(relPath)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key := range keySet {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var versions []ObjectVersion
+	for _, key := range keys {
+		objectPath := filepath.Join(bucketPath, key)
+		versions = appendVersions(versions, key, loadCurrentVersion(objectPath), readVersionHistory(objectPath))
+	}
+
+	return versions, nil
+}
+
+// GetObjectVersion retrieves a specific version of an object
+func (fs *FileSystemStorage) GetObjectVersion(bucket, key, versionId string) (io.ReadSeekCloser, *ObjectMetadata, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	meta, path, err := resolveVersion(filepath.Join(fs.baseDir, bucket, key), versionId)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrObjectNotFound
+		}
+		return nil, nil, err
+	}
+
+	return file, meta, nil
+}
+
+// GetObjectVersionMetadata retrieves the metadata of a specific version
+func (fs *FileSystemStorage) GetObjectVersionMetadata(bucket, key, versionId string) (*ObjectMetadata, error) {
+	lock := fs.getBucketLock(bucket)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	meta, _, err := resolveVersion(filepath.Join(fs.baseDir, bucket, key), versionId)
+	return meta, err
+}
+
+// DeleteObjectVersion permanently removes a version or delete marker. When
+// the latest version is removed the next newest one becomes current.
+func (fs *FileSystemStorage) DeleteObjectVersion(bucket, key, versionId string) error {
+	lock := fs.getBucketLock(bucket)
+	lock.Lock()
+	defer lock.Unlock()
+
+	objectPath := filepath.Join(fs.baseDir, bucket, key)
+
+	if current := loadCurrentVersion(objectPath); current != nil && versionIdOrNull(current.VersionId) == versionId {
+		if err := os.Remove(objectPath); err != nil {
+			return err
+		}
+		os.Remove(metadataPath(objectPath))
+	} else if !isValidVersionId(versionId) || !removeStoredVersion(objectPath, versionId) {
+		return ErrVersionNotFound
+	}
+
+	if err := promoteLatestVersion(objectPath); err != nil {
+		return err
+	}
+
+	// Only succeeds once the history is empty
+	os.Remove(versionsDir(objectPath))
+
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/pelletier/go-toml/v2"
+	"github.com/wozozo/s3pit/pkg/fault"
 )
 
 type Tenant struct {
@@ -15,18 +16,51 @@ type Tenant struct {
 	SecretAccessKey string   `toml:"secretAccessKey"`
 	CustomDir       string   `toml:"customDir"`
 	Description     string   `toml:"description,omitempty"`
-	PublicBuckets   []string `toml:"publicBuckets"` // List of public buckets for this tenant
+	PublicBuckets   []string `toml:"publicBuckets"`            // List of public buckets for this tenant
+	PermissiveCORS  bool     `toml:"permissiveCors,omitempty"` // Allow any origin for this tenant's buckets
+
+	// Bandwidth limits in bytes per second (0 = server default)
+	UploadBytesPerSecond   int64                `toml:"uploadBytesPerSecond,omitempty"`
+	DownloadBytesPerSecond int64                `toml:"downloadBytesPerSecond,omitempty"`
+	BucketBandwidth        map[string]Bandwidth `toml:"bucketBandwidth,omitempty"` // Limits of single buckets
+}
+
+// Bandwidth limits the throughput of request (upload) and response
+// (download) bodies in bytes per second. 0 leaves a direction unlimited.
+type Bandwidth struct {
+	UploadBytesPerSecond   int64 `toml:"uploadBytesPerSecond,omitempty"`
+	DownloadBytesPerSecond int64 `toml:"downloadBytesPerSecond,omitempty"`
+}
+
+// Bandwidth returns the limits of a bucket: the limits configured for the
+// bucket, falling back to the limits of the tenant
+func (t *Tenant) Bandwidth(bucket string) Bandwidth {
+	limits := Bandwidth{
+		UploadBytesPerSecond:   t.UploadBytesPerSecond,
+		DownloadBytesPerSecond: t.DownloadBytesPerSecond,
+	}
+	if b, ok := t.BucketBandwidth[bucket]; ok && bucket != "" {
+		if b.UploadBytesPerSecond > 0 {
+			limits.UploadBytesPerSecond = b.UploadBytesPerSecond
+		}
+		if b.DownloadBytesPerSecond > 0 {
+			limits.DownloadBytesPerSecond = b.DownloadBytesPerSecond
+		}
+	}
+	return limits
 }
 
 type Config struct {
-	GlobalDir string   `toml:"globalDir,omitempty"`
-	Tenants   []Tenant `toml:"tenants"`
+	GlobalDir string       `toml:"globalDir,omitempty"`
+	Tenants   []Tenant     `toml:"tenants"`
+	Faults    []fault.Rule `toml:"faults,omitempty"` // Fault injection rules, applied in order
 }
 
 type Manager struct {
 	configFile string
 	globalDir  string
 	tenants    map[string]*Tenant
+	faults     []fault.Rule
 	mu         sync.RWMutex
 }
 
@@ -68,6 +102,7 @@ func (m *Manager) LoadFromFile() error {
 		tenant := &config.Tenants[i]
 		m.tenants[tenant.AccessKeyID] = tenant
 	}
+	m.faults = config.Faults
 
 	return nil
 }
@@ -139,6 +174,13 @@ func (m *Manager) UpdateGlobalDir(globalDir string) {
 	m.globalDir = globalDir
 }
 
+// GetFaultRules returns the fault injection rules from config.toml
+func (m *Manager) GetFaultRules() []fault.Rule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]fault.Rule(nil), m.faults...)
+}
+
 // IsPublicBucket checks if a bucket is public for any tenant
 func (m *Manager) IsPublicBucket(bucket string) (bool, string) {
 	m.mu.RLock()
@@ -203,6 +245,7 @@ func (m *Manager) saveToFile() error {
 	config := Config{
 		GlobalDir: m.globalDir,
 		Tenants:   make([]Tenant, 0, len(m.tenants)),
+		Faults:    m.faults,
 	}
 
 	for _, tenant := range m.tenants {
@@ -65,6 +65,87 @@ func TestLoadTenants(t *testing.T) {
 	}
 }
 
+func TestLoadFaultRules(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	data := `globalDir = "/tmp/s3pit"
+
+[[tenants]]
+accessKeyId = "local-dev"
+secretAccessKey = "local-dev-secret"
+
+[[faults]]
+name = "slow-uploads"
+tenant = "local-dev"
+operation = "WRITE"
+probability = 0.25
+action = "error"
+code = "SlowDown"
+`
+	if err := os.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	manager := NewManager(configFile)
+	if err := manager.LoadFromFile(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	rules := manager.GetFaultRules()
+	if len(rules) != 1 {
+		t.Fatalf("Expected 1 fault rule, got %d", len(rules))
+	}
+	if rules[0].Name != "slow-uploads" || rules[0].Probability != 0.25 || rules[0].Code != "SlowDown" {
+		t.Errorf("Unexpected fault rule: %+v", rules[0])
+	}
+
+	// Saving the tenants keeps the fault rules
+	if err := manager.AddTenant(&Tenant{AccessKeyID: "other", SecretAccessKey: "other-secret"}); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+	reloaded := NewManager(configFile)
+	if err := reloaded.LoadFromFile(); err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if len(reloaded.GetFaultRules()) != 1 {
+		t.Errorf("Expected the fault rule to be saved, got %d", len(reloaded.GetFaultRules()))
+	}
+}
+
+func TestTenantBandwidth(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	data := `globalDir = "/tmp/s3pit"
+
+[[tenants]]
+accessKeyId = "mobile"
+secretAccessKey = "mobile-secret"
+uploadBytesPerSecond = 32000
+downloadBytesPerSecond = 125000
+
+[tenants.bucketBandwidth.videos]
+downloadBytesPerSecond = 64000
+`
+	if err := os.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	manager := NewManager(configFile)
+	if err := manager.LoadFromFile(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	tenant, ok := manager.GetTenant("mobile")
+	if !ok {
+		t.Fatal("Expected tenant mobile")
+	}
+
+	if got := tenant.Bandwidth("photos"); got != (Bandwidth{UploadBytesPerSecond: 32000, DownloadBytesPerSecond: 125000}) {
+		t.Errorf("Expected the tenant limits for photos, got %+v", got)
+	}
+	// The bucket limit overrides the download limit only
+	if got := tenant.Bandwidth("videos"); got != (Bandwidth{UploadBytesPerSecond: 32000, DownloadBytesPerSecond: 64000}) {
+		t.Errorf("Expected the bucket limits for videos, got %+v", got)
+	}
+}
+
 func TestGetTenant(t *testing.T) {
 	manager := NewManager("")
 
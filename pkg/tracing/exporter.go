@@ -0,0 +1,267 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceName is the service.name resource attribute of exported spans
+const ServiceName = "s3pit"
+
+// scopeName is the instrumentation scope of exported spans
+const scopeName = "github.com/wozozo/s3pit/pkg/tracing"
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	Export(spans []*Span) error
+	Close() error
+}
+
+// OTLPExporter posts spans to an OTLP/HTTP collector using the JSON encoding
+type OTLPExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewOTLPExporter creates an exporter for a collector such as
+// http://localhost:4318. The /v1/traces path is added unless the endpoint
+// already ends with it.
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Export posts the spans in a single request
+func (e *OTLPExporter) Export(spans []*Span) error {
+	body, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector at %s returned %s", e.url, resp.Status)
+	}
+	return nil
+}
+
+// Close does nothing; the exporter holds no resources
+func (e *OTLPExporter) Close() error {
+	return nil
+}
+
+// FileExporter appends spans to a file, one OTLP JSON export request per
+// line, the format read by the OpenTelemetry Collector's otlpjsonfile
+// receiver
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileExporter opens a trace file, appending to the file if it exists
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	return &FileExporter{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Export appends the spans as one line
+func (e *FileExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(encodeSpans(spans))
+}
+
+// Close closes the trace file
+func (e *FileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
+
+// The types below follow the JSON encoding of the OTLP
+// ExportTraceServiceRequest message. IDs are hex encoded and 64-bit
+// integers are strings, as the encoding requires.
+
+// TracesData is an OTLP export request
+type TracesData struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeSpans struct {
+	Scope Scope      `json:"scope"`
+	Spans []SpanData `json:"spans"`
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+// SpanData is an exported span
+type SpanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Status            Status     `json:"status"`
+}
+
+// Status codes of OTLP spans
+const (
+	StatusUnset = 0
+	StatusError = 2
+)
+
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// StringAttribute returns the string value of an attribute, or "" when the
+// span has no such attribute
+func (s SpanData) StringAttribute(key string) string {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			if kv.Value.StringValue != nil {
+				return *kv.Value.StringValue
+			}
+			if kv.Value.IntValue != nil {
+				return *kv.Value.IntValue
+			}
+			if kv.Value.BoolValue != nil {
+				return strconv.FormatBool(*kv.Value.BoolValue)
+			}
+		}
+	}
+	return ""
+}
+
+// ReadFile reads the spans of a trace file written by a FileExporter, in
+// the order they were exported
+func ReadFile(path string) ([]SpanData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	defer file.Close()
+
+	var spans []SpanData
+	dec := json.NewDecoder(file)
+	for {
+		var data TracesData
+		if err := dec.Decode(&data); err == io.EOF {
+			return spans, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read trace file: %w", err)
+		}
+		for _, rs := range data.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+}
+
+func encodeSpans(spans []*Span) TracesData {
+	data := make([]SpanData, 0, len(spans))
+	for _, span := range spans {
+		data = append(data, encodeSpan(span))
+	}
+	return TracesData{ResourceSpans: []ResourceSpans{{
+		Resource:   Resource{Attributes: []KeyValue{stringValue("service.name", ServiceName)}},
+		ScopeSpans: []ScopeSpans{{Scope: Scope{Name: scopeName}, Spans: data}},
+	}}}
+}
+
+func encodeSpan(span *Span) SpanData {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	data := SpanData{
+		TraceID:           span.TraceID.String(),
+		SpanID:            span.SpanID.String(),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+	}
+	if span.ParentSpanID.IsValid() {
+		data.ParentSpanID = span.ParentSpanID.String()
+	}
+	if span.Failed {
+		data.Status = Status{Code: StatusError, Message: span.Message}
+	}
+
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch v := span.Attributes[key].(type) {
+		case string:
+			data.Attributes = append(data.Attributes, stringValue(key, v))
+		case int:
+			data.Attributes = append(data.Attributes, intValue(key, int64(v)))
+		case int64:
+			data.Attributes = append(data.Attributes, intValue(key, v))
+		case bool:
+			data.Attributes = append(data.Attributes, KeyValue{Key: key, Value: AnyValue{BoolValue: &v}})
+		default:
+			data.Attributes = append(data.Attributes, stringValue(key, fmt.Sprint(v)))
+		}
+	}
+	return data
+}
+
+func stringValue(key, v string) KeyValue {
+	return KeyValue{Key: key, Value: AnyValue{StringValue: &v}}
+}
+
+func intValue(key string, v int64) KeyValue {
+	s := strconv.FormatInt(v, 10)
+	return KeyValue{Key: key, Value: AnyValue{IntValue: &s}}
+}
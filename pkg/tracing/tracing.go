@@ -0,0 +1,348 @@
+// Package tracing records a trace span per S3 request and exports the spans
+// in the OpenTelemetry protocol (OTLP) JSON encoding. Incoming W3C
+// traceparent headers are honoured, so the spans of s3pit join the traces
+// of the services calling it.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// flushInterval is how often finished spans are exported
+	flushInterval = time.Second
+	// maxBatch is how many finished spans are kept before they are exported
+	// without waiting for the next flush
+	maxBatch = 512
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span that is propagated to other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// ParseTraceparent parses a W3C traceparent header such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+	// Version 00 has exactly four fields, later versions may add more
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return sc, false
+	}
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) {
+		return sc, false
+	}
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return sc, false
+	}
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&0x01 != 0
+	return sc, true
+}
+
+// decodeHex decodes lowercase hex of exactly the length of dst
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Traceparent formats the span context as a W3C traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// SpanKind is the role of a span in a trace, numbered as in OTLP
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+)
+
+// Span is a timed operation. All methods of a nil span do nothing, so
+// callers need not check whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+
+	mu           sync.Mutex
+	Name         string
+	Kind         SpanKind
+	TraceID      TraceID
+	SpanID       SpanID
+	ParentSpanID SpanID // Zero for the root span of a trace
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]any // string, int, int64 or bool values
+	Failed       bool
+	Message      string // Description of the failure
+	ended        bool
+}
+
+// SetName renames the span, for when the operation is known only after it
+// started
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Name = name
+}
+
+// SetAttribute sets an attribute of the span. Empty strings are ignored.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	if str, ok := value.(string); ok && str == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed = true
+	s.Message = message
+}
+
+// Context returns the span context to propagate to other services
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.TraceID, SpanID: s.SpanID, Sampled: true}
+}
+
+// Finish ends the span and queues it for export. Only the first call has an
+// effect.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a context carrying the span, the parent of spans
+// started from the context
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by the context, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts a child of the span carried by the context. Without a span
+// in the context nothing is traced and the returned span is nil.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := parent.tracer.newSpan(name, KindInternal, parent.TraceID, parent.SpanID)
+	return ContextWithSpan(ctx, span), span
+}
+
+// Tracer starts request spans and exports them once they finish. A nil
+// tracer traces nothing.
+type Tracer struct {
+	exporters []Exporter
+
+	mu      sync.Mutex
+	pending []*Span
+	flush   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewTracer creates a tracer exporting finished spans to every exporter
+// in the background
+func NewTracer(exporters ...Exporter) *Tracer {
+	t := &Tracer{
+		exporters: exporters,
+		flush:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// StartRequest starts the span of a request. The span continues the trace
+// of the remote parent when there is one; a parent that was not sampled
+// is honoured by not tracing the request, in which case the span is nil.
+func (t *Tracer) StartRequest(ctx context.Context, name string, remote SpanContext, hasRemote bool) (context.Context, *Span) {
+	if t == nil || (hasRemote && !remote.Sampled) {
+		return ctx, nil
+	}
+	var traceID TraceID
+	var parentID SpanID
+	if hasRemote {
+		traceID, parentID = remote.TraceID, remote.SpanID
+	} else {
+		randomID(traceID[:])
+	}
+	span := t.newSpan(name, KindServer, traceID, parentID)
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) newSpan(name string, kind SpanKind, traceID TraceID, parentID SpanID) *Span {
+	span := &Span{
+		tracer:       t,
+		Name:         name,
+		Kind:         kind,
+		TraceID:      traceID,
+		ParentSpanID: parentID,
+		Start:        time.Now(),
+		Attributes:   make(map[string]any),
+	}
+	randomID(span.SpanID[:])
+	return span
+}
+
+func randomID(b []byte) {
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Sprintf("tracing: failed to generate ID: %v", err))
+		}
+		for _, v := range b {
+			if v != 0 {
+				return
+			}
+		}
+	}
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	t.pending = append(t.pending, span)
+	full := len(t.pending) >= maxBatch
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.export()
+		case <-t.flush:
+			t.export()
+		case <-t.done:
+			t.export()
+			return
+		}
+	}
+}
+
+// export sends the finished spans to the exporters. Failures are logged
+// and the spans dropped, as tracing must not affect the S3 API.
+func (t *Tracer) export() {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return
+	}
+	for _, exporter := range t.exporters {
+		if err := exporter.Export(spans); err != nil {
+			log.Printf("[TRACING] Failed to export %d span(s): %v", len(spans), err)
+		}
+	}
+}
+
+// Close exports the remaining spans and closes the exporters
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	var firstErr error
+	t.once.Do(func() {
+		close(t.done)
+		<-t.stopped
+		for _, exporter := range t.exporters {
+			if err := exporter.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header  string
+		valid   bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		// Later versions may append fields
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false, false},
+	}
+
+	for _, tt := range tests {
+		sc, ok := ParseTraceparent(tt.header)
+		if ok != tt.valid {
+			t.Errorf("ParseTraceparent(%q) valid = %v, want %v", tt.header, ok, tt.valid)
+			continue
+		}
+		if ok && sc.Sampled != tt.sampled {
+			t.Errorf("ParseTraceparent(%q) sampled = %v, want %v", tt.header, sc.Sampled, tt.sampled)
+		}
+	}
+
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, _ := ParseTraceparent(header)
+	if sc.Traceparent() != header {
+		t.Errorf("Expected %s, got %s", header, sc.Traceparent())
+	}
+}
+
+func TestTracerFileExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatalf("NewFileExporter failed: %v", err)
+	}
+	tracer := NewTracer(exporter)
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tracer.StartRequest(context.Background(), "S3", remote, true)
+	root.SetName("S3.GetObject")
+	root.SetAttribute("aws.s3.bucket", "photos")
+	root.SetAttribute("http.response.status_code", 404)
+	root.SetAttribute("s3pit.error_code", "")
+
+	_, child := Start(ctx, "storage.GetObject")
+	child.SetError("object not found")
+	child.Finish()
+	root.Finish()
+	root.Finish()
+
+	// A parent that was not sampled is honoured
+	unsampled, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := tracer.StartRequest(context.Background(), "S3", unsampled, true)
+	if span != nil {
+		t.Error("Expected no span for an unsampled parent")
+	}
+	span.SetAttribute("ignored", "by nil spans")
+	span.Finish()
+
+	// Without a span in the context nothing is traced
+	if _, span := Start(context.Background(), "orphan"); span != nil {
+		t.Error("Expected no span without a parent")
+	}
+
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	spans, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	childSpan, rootSpan := spans[0], spans[1]
+
+	if rootSpan.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || rootSpan.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the request span to continue the remote trace, got %+v", rootSpan)
+	}
+	if rootSpan.Name != "S3.GetObject" || rootSpan.Kind != KindServer {
+		t.Errorf("Unexpected request span %+v", rootSpan)
+	}
+	if rootSpan.StringAttribute("aws.s3.bucket") != "photos" || rootSpan.StringAttribute("http.response.status_code") != "404" {
+		t.Errorf("Unexpected attributes %+v", rootSpan.Attributes)
+	}
+	if rootSpan.StringAttribute("s3pit.error_code") != "" || len(rootSpan.Attributes) != 2 {
+		t.Errorf("Expected empty attributes to be dropped, got %+v", rootSpan.Attributes)
+	}
+
+	if childSpan.TraceID != rootSpan.TraceID || childSpan.ParentSpanID != rootSpan.SpanID || childSpan.Kind != KindInternal {
+		t.Errorf("Expected the storage span to be a child of the request span, got %+v", childSpan)
+	}
+	if childSpan.Status.Code != StatusError || childSpan.Status.Message != "object not found" {
+		t.Errorf("Expected an error status, got %+v", childSpan.Status)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got TracesData
+	var path, contentType string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("Invalid export request: %v", err)
+		}
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer(NewOTLPExporter(collector.URL))
+	_, span := tracer.StartRequest(context.Background(), "S3.ListBuckets", SpanContext{}, false)
+	span.Finish()
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if path != "/v1/traces" || contentType != "application/json" {
+		t.Errorf("Expected a JSON post to /v1/traces, got %s %s", contentType, path)
+	}
+	if len(got.ResourceSpans) != 1 || got.ResourceSpans[0].Resource.Attributes[0].Key != "service.name" {
+		t.Fatalf("Unexpected export request %+v", got)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 || spans[0].Name != "S3.ListBuckets" || spans[0].ParentSpanID != "" || len(spans[0].TraceID) != 32 {
+		t.Errorf("Unexpected spans %+v", spans)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := NewOTLPExporter(failing.URL + "/v1/traces").Export([]*Span{span}); err == nil {
+		t.Error("Expected an error from a failing collector")
+	}
+}